@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"app/internal/jitaccess"
+	"app/internal/jitauth"
+	"app/internal/worker/config"
+)
+
+// newAuthMiddleware builds the authentication layer of the JIT API.
+// Authentication is required unless JIT_AUTH_DISABLED is set, which is meant for local
+// development only: callers are then trusted to name themselves in the request body.
+func newAuthMiddleware(cfg *config.WorkerConfig, logger *slog.Logger) (func(http.Handler) http.Handler, error) {
+	if cfg.JITAuthDisabled {
+		logger.Warn("JIT API authentication is DISABLED; requesters are taken from the request body")
+		return func(next http.Handler) http.Handler { return next }, nil
+	}
+	if cfg.JITAuthJWKSFile == "" && cfg.JITAPITokensFile == "" {
+		return nil, fmt.Errorf("JIT API authentication is not configured: set JIT_AUTH_JWKS_FILE and/or JIT_API_TOKENS_FILE, " +
+			"or JIT_AUTH_DISABLED=true for local development")
+	}
+	auth, err := jitauth.NewAuthenticator(jitauth.Config{
+		JWT: jitauth.JWTConfig{
+			JWKSFile:      cfg.JITAuthJWKSFile,
+			Issuer:        cfg.JITAuthIssuer,
+			Audience:      cfg.JITAuthAudience,
+			UsernameClaim: cfg.JITAuthUsernameClaim,
+		},
+		TokensFile: cfg.JITAPITokensFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("JIT API authentication enabled", "jwks", cfg.JITAuthJWKSFile, "apiTokens", cfg.JITAPITokensFile)
+	return auth.Middleware, nil
+}
+
+// callerPrincipal returns the authenticated principal of the request.
+// Only when authentication is disabled does it fall back to the principal named in the body.
+func callerPrincipal(r *http.Request, fromBody string) string {
+	if id, ok := jitauth.FromContext(r.Context()); ok {
+		return id.Principal
+	}
+	return fromBody
+}
+
+// readAccess returns the caller of a read endpoint and whether it may see username's access to
+// role: everything for principals that may read all, see jitaccess.Policy.MayReadAll, and
+// otherwise what it may manage. The caller is taken from ?actor= only when authentication is
+// disabled. It answers 500 and returns ok false when the policy cannot be loaded.
+func readAccess(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (caller string, mayRead func(username, role string) bool, ok bool) {
+	policy, err := jitaccess.CurrentPolicy()
+	if err != nil {
+		logger.Error("failed to load policy", "error", err)
+		http.Error(w, fmt.Sprintf("failed to load policy: %v", err), http.StatusInternalServerError)
+		return "", nil, false
+	}
+	caller = callerPrincipal(r, r.URL.Query().Get("actor"))
+	if policy.MayReadAll(caller) {
+		return caller, func(string, string) bool { return true }, true
+	}
+	return caller, func(username, role string) bool { return policy.MayManage(caller, username, role) }, true
+}
+
+// authorize answers 403 unless actor may manage username's access to role, see
+// jitaccess.Policy.MayManage, and reports whether the request may go on.
+func authorize(w http.ResponseWriter, logger *slog.Logger, actor, username, role string) bool {
+	policy, err := jitaccess.CurrentPolicy()
+	if err != nil {
+		logger.Error("failed to load policy", "error", err)
+		http.Error(w, fmt.Sprintf("failed to load policy: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	if !policy.MayManage(actor, username, role) {
+		http.Error(w, fmt.Sprintf("%s may not manage the %s access of %s", actor, role, username), http.StatusForbidden)
+		return false
+	}
+	return true
+}
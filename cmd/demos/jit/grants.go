@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"app/internal/atlas"
+	"app/internal/jitaccess"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// jitTaskQueue is the task queue the JIT worker polls.
+const jitTaskQueue = "jit_access_task_queue"
+
+// grantRequestError is a request error that maps to a specific HTTP status.
+type grantRequestError struct {
+	status int
+	msg    string
+}
+
+func (e *grantRequestError) Error() string { return e.msg }
+
+// startOrMergeGrant merges the request into the user's running grant or starts a new one,
+// mapping rejections to HTTP statuses.
+func startOrMergeGrant(ctx context.Context, c client.Client, req jitaccess.JITAccessRequest) (*jitaccess.GrantStart, error) {
+	result, err := jitaccess.StartOrMergeGrant(ctx, c, jitTaskQueue, req, func(ctx context.Context) error {
+		// Check that new_role is different from current role.
+		currentRole, err := atlas.GetUserRole(ctx, req.Username)
+		if err != nil {
+			return fmt.Errorf("failed to get current role: %w", err)
+		}
+		if currentRole == req.NewRole {
+			return &grantRequestError{status: http.StatusBadRequest, msg: "new_role cannot be the same as current role"}
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		return result, nil
+	case jitaccess.IsApplicationError(err, jitaccess.GrantConflictError), jitaccess.IsApplicationError(err, "PolicyViolation"),
+		errors.Is(err, jitaccess.ErrGrantBusy):
+		return nil, &grantRequestError{status: http.StatusConflict, msg: err.Error()}
+	default:
+		return nil, err
+	}
+}
+
+// handleJITGrants lists the running grants the caller may see, see readAccess. ?mine=true keeps the
+// grants the caller holds or requested, and ?state= keeps grants in one state, such as pending_approval.
+func handleJITGrants(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	caller, mayRead, ok := readAccess(w, r, logger)
+	if !ok {
+		return
+	}
+	mine := r.URL.Query().Get("mine") == "true"
+	state := r.URL.Query().Get("state")
+	if mine && caller == "" {
+		http.Error(w, "actor parameter is required for mine=true when authentication is disabled", http.StatusBadRequest)
+		return
+	}
+	grants, err := jitaccess.ListRunningGrants(r.Context(), temporalClient)
+	if err != nil {
+		logger.Error("failed to list grants", "error", err)
+		http.Error(w, fmt.Sprintf("failed to list grants: %v", err), http.StatusInternalServerError)
+		return
+	}
+	filtered := []jitaccess.GrantStatus{}
+	for _, g := range grants {
+		if !mayReadGrant(g, caller, mayRead) {
+			continue
+		}
+		if mine && g.Username != caller && g.Requester != caller {
+			continue
+		}
+		if state != "" && g.State != state {
+			continue
+		}
+		filtered = append(filtered, g)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filtered)
+}
+
+// handleJITStatus returns the status of a user's grant, if the caller may see it, see readAccess.
+func handleJITStatus(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		http.Error(w, "username parameter is required", http.StatusBadRequest)
+		return
+	}
+	caller, mayRead, ok := readAccess(w, r, logger)
+	if !ok {
+		return
+	}
+	value, err := temporalClient.QueryWorkflow(r.Context(), jitaccess.JITWorkflowID(username), "", jitaccess.StatusQuery)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, fmt.Sprintf("no grant found for %s", username), http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to query grant", "username", username, "error", err)
+		http.Error(w, fmt.Sprintf("failed to query grant: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var status jitaccess.GrantStatus
+	if err := value.Get(&status); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode grant status: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !mayReadGrant(status, caller, mayRead) {
+		http.Error(w, fmt.Sprintf("%q may not read the grant of %s", caller, username), http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// JITRevoke represents the JSON payload for revoking a user's active grant.
+// Actor is the authenticated caller; the body field is only read when authentication is disabled.
+type JITRevoke struct {
+	Username string `json:"username"`
+	Actor    string `json:"actor"`
+	Reason   string `json:"reason"`
+}
+
+func handleJITRevoke(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	var req JITRevoke
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.Actor = callerPrincipal(r, req.Actor)
+	if req.Username == "" || req.Actor == "" {
+		http.Error(w, "username and actor are required", http.StatusBadRequest)
+		return
+	}
+	if !authorizeGrant(w, r, temporalClient, logger, req.Username, req.Actor) {
+		return
+	}
+	revoke := jitaccess.RevokeRequest{Actor: req.Actor, Reason: req.Reason}
+	signalGrant(w, r, temporalClient, logger, req.Username, jitaccess.RevokeSignal, revoke)
+}
+
+// JITExtend represents the JSON payload for extending a user's active grant.
+// Actor is the authenticated caller; the body field is only read when authentication is disabled.
+type JITExtend struct {
+	Username string `json:"username"`
+	Actor    string `json:"actor"`
+	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+func handleJITExtend(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	var req JITExtend
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.Actor = callerPrincipal(r, req.Actor)
+	if req.Username == "" || req.Actor == "" || req.Duration == "" {
+		http.Error(w, "username, actor, and duration are required", http.StatusBadRequest)
+		return
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d <= 0 {
+		http.Error(w, "invalid duration format", http.StatusBadRequest)
+		return
+	}
+	if !authorizeGrant(w, r, temporalClient, logger, req.Username, req.Actor) {
+		return
+	}
+	extend := jitaccess.ExtendRequest{Actor: req.Actor, Duration: d, Reason: req.Reason}
+	signalGrant(w, r, temporalClient, logger, req.Username, jitaccess.ExtendSignal, extend)
+}
+
+// mayReadGrant reports whether caller may see grant: a grant it may read, see readAccess, or one it requested.
+func mayReadGrant(grant jitaccess.GrantStatus, caller string, mayRead func(username, role string) bool) bool {
+	return mayRead(grant.Username, grant.NewRole) || (caller != "" && grant.Requester == caller)
+}
+
+// authorizeGrant answers 403 unless actor may manage the user's grant: the grantee, a principal
+// allowed to act on their behalf, or an approver of the granted role.
+func authorizeGrant(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger, username, actor string) bool {
+	value, err := temporalClient.QueryWorkflow(r.Context(), jitaccess.JITWorkflowID(username), "", jitaccess.StatusQuery)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, fmt.Sprintf("no active grant for %s", username), http.StatusNotFound)
+			return false
+		}
+		logger.Error("failed to query grant", "username", username, "error", err)
+		http.Error(w, fmt.Sprintf("failed to query grant: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	var status jitaccess.GrantStatus
+	if err := value.Get(&status); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode grant status: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	return authorize(w, logger, actor, status.Username, status.NewRole)
+}
+
+func signalGrant(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger, username, signal string, payload interface{}) {
+	workflowID := jitaccess.JITWorkflowID(username)
+	if err := temporalClient.SignalWorkflow(r.Context(), workflowID, "", signal, payload); err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, fmt.Sprintf("no active grant for %s", username), http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to signal grant", "workflowID", workflowID, "signal", signal, "error", err)
+		http.Error(w, fmt.Sprintf("failed to signal workflow: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "signalled",
+		"workflowID": workflowID,
+	})
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -61,6 +62,13 @@ func main() {
 		"temporalHost", cfg.TemporalHost,
 		"temporalNamespace", cfg.TemporalNamespace)
 
+	// Authenticate API callers; fail fast before starting the worker if this is misconfigured
+	authMiddleware, err := newAuthMiddleware(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to set up JIT API authentication", "error", err)
+		os.Exit(1)
+	}
+
 	// Create centralized worker
 	centralizedWorker, err := worker.NewCentralizedWorker(cfg, temporalLogger)
 	if err != nil {
@@ -81,6 +89,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Schedule access reconciliation if it is configured
+	if err := ensureReconcileSchedule(context.Background(), centralizedWorker.GetClient(), cfg, logger); err != nil {
+		logger.Error("Failed to schedule JIT reconciliation", "error", err)
+	}
+
 	// Setup HTTP server for demo UI
 	mux := http.NewServeMux()
 
@@ -102,26 +115,81 @@ func main() {
 					<li><a href="/api/user-role?username=demo-user">Get User Role</a></li>
 					<li><a href="/api/built-in-roles">Get Built-in Roles</a></li>
 					<li><a href="/api/database-users">Get Database Users</a></li>
+					<li><a href="/api/policy">Get JIT Policy</a></li>
+					<li><a href="/api/audit?username=demo-user">Get Audit Trail</a></li>
+					<li><a href="/api/jit-status?username=demo-user">Get Grant Status</a></li>
+					<li><a href="/api/jit-grants?mine=true">List My Running Grants</a> (?state= to filter)</li>
 					<li>POST /api/jit-request - Submit JIT request</li>
+					<li>POST /api/jit-approval - Approve or deny a pending JIT request</li>
+					<li>POST /api/jit-extend - Extend an active grant</li>
+					<li>POST /api/jit-revoke - Revoke an active grant</li>
+					<li><a href="/api/jit-reviews">List open post-incident reviews</a></li>
+					<li>POST /api/jit-reviews/ack - Acknowledge a post-incident review</li>
+					<li><a href="/api/reconcile">Latest Access Reconciliation Report</a> (POST to run now)</li>
+					<li><a href="/api/jit-schedules">List JIT Schedules</a> (POST to create, DELETE ?id= to delete)</li>
+					<li>POST /api/jit-schedules/pause - Pause or resume a schedule</li>
+					<li>POST /api/jit-schedules/trigger - Start a scheduled window now</li>
 				</ul>
 			</body>
 			</html>
 		`)
 	})
 
-	// API endpoints from original JIT demo
-	mux.HandleFunc("/api/user-role", func(w http.ResponseWriter, r *http.Request) {
+	// API endpoints from original JIT demo. Every /api/ endpoint requires an authenticated caller.
+	api := http.NewServeMux()
+	api.HandleFunc("/api/user-role", func(w http.ResponseWriter, r *http.Request) {
 		handleGetUserRole(w, r, logger)
 	})
-	mux.HandleFunc("/api/built-in-roles", func(w http.ResponseWriter, r *http.Request) {
+	api.HandleFunc("/api/built-in-roles", func(w http.ResponseWriter, r *http.Request) {
 		handleGetBuiltInRoles(w, r)
 	})
-	mux.HandleFunc("/api/jit-request", func(w http.ResponseWriter, r *http.Request) {
+	api.HandleFunc("/api/jit-request", func(w http.ResponseWriter, r *http.Request) {
 		handleJITRequest(w, r, centralizedWorker.GetClient(), logger)
 	})
-	mux.HandleFunc("/api/database-users", func(w http.ResponseWriter, r *http.Request) {
+	api.HandleFunc("/api/database-users", func(w http.ResponseWriter, r *http.Request) {
 		handleGetDatabaseUsers(w, r, logger)
 	})
+	api.HandleFunc("/api/policy", func(w http.ResponseWriter, r *http.Request) {
+		handleGetPolicy(w, r, logger)
+	})
+	api.HandleFunc("/api/jit-approval", func(w http.ResponseWriter, r *http.Request) {
+		handleJITApproval(w, r, centralizedWorker.GetClient(), logger)
+	})
+	api.HandleFunc("/api/audit", func(w http.ResponseWriter, r *http.Request) {
+		handleGetAudit(w, r, logger)
+	})
+	api.HandleFunc("/api/jit-status", func(w http.ResponseWriter, r *http.Request) {
+		handleJITStatus(w, r, centralizedWorker.GetClient(), logger)
+	})
+	api.HandleFunc("/api/jit-grants", func(w http.ResponseWriter, r *http.Request) {
+		handleJITGrants(w, r, centralizedWorker.GetClient(), logger)
+	})
+	api.HandleFunc("/api/jit-revoke", func(w http.ResponseWriter, r *http.Request) {
+		handleJITRevoke(w, r, centralizedWorker.GetClient(), logger)
+	})
+	api.HandleFunc("/api/jit-extend", func(w http.ResponseWriter, r *http.Request) {
+		handleJITExtend(w, r, centralizedWorker.GetClient(), logger)
+	})
+	api.HandleFunc("/api/jit-reviews", func(w http.ResponseWriter, r *http.Request) {
+		handleJITReviews(w, r, centralizedWorker.GetClient(), logger)
+	})
+	api.HandleFunc("/api/jit-reviews/ack", func(w http.ResponseWriter, r *http.Request) {
+		handleJITReviewAck(w, r, centralizedWorker.GetClient(), logger)
+	})
+	api.HandleFunc("/api/reconcile", func(w http.ResponseWriter, r *http.Request) {
+		handleJITReconcile(w, r, centralizedWorker.GetClient(), cfg, logger)
+	})
+	api.HandleFunc("/api/jit-schedules", func(w http.ResponseWriter, r *http.Request) {
+		handleJITSchedules(w, r, centralizedWorker.GetClient(), logger)
+	})
+	api.HandleFunc("/api/jit-schedules/pause", func(w http.ResponseWriter, r *http.Request) {
+		handleJITSchedulePause(w, r, centralizedWorker.GetClient(), logger)
+	})
+	api.HandleFunc("/api/jit-schedules/trigger", func(w http.ResponseWriter, r *http.Request) {
+		handleJITScheduleTrigger(w, r, centralizedWorker.GetClient(), logger)
+	})
+
+	mux.Handle("/api/", authMiddleware(api))
 
 	// Create HTTP server
 	server := &http.Server{
@@ -208,11 +276,15 @@ func handleGetDatabaseUsers(w http.ResponseWriter, r *http.Request, logger *slog
 }
 
 // JITRequest represents the JSON payload for a JIT access request.
+// Username is the user to elevate and defaults to the authenticated caller.
+// BreakGlass requests emergency access, which requires an IncidentRef.
 type JITRequest struct {
-	Username string `json:"username"`
-	Reason   string `json:"reason"`
-	NewRole  string `json:"new_role"`
-	Duration string `json:"duration"`
+	Username    string `json:"username"`
+	Reason      string `json:"reason"`
+	NewRole     string `json:"new_role"`
+	Duration    string `json:"duration"`
+	BreakGlass  bool   `json:"break_glass"`
+	IncidentRef string `json:"incident_ref"`
 }
 
 func handleJITRequest(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
@@ -221,6 +293,11 @@ func handleJITRequest(w http.ResponseWriter, r *http.Request, temporalClient cli
 		http.Error(w, "invalid request payload", http.StatusBadRequest)
 		return
 	}
+	// The requester is the authenticated caller, never a field of the body.
+	requester := callerPrincipal(r, req.Username)
+	if req.Username == "" {
+		req.Username = requester
+	}
 	// Validate required fields.
 	if req.Username == "" || req.NewRole == "" || req.Duration == "" {
 		http.Error(w, "username, new_role, and duration are required", http.StatusBadRequest)
@@ -232,41 +309,144 @@ func handleJITRequest(w http.ResponseWriter, r *http.Request, temporalClient cli
 		http.Error(w, "invalid duration format", http.StatusBadRequest)
 		return
 	}
-	// Check that new_role is different from current role.
-	ctx := r.Context()
-	currentRole, err := atlas.GetUserRole(ctx, req.Username)
+	// Pre-check the request against the policy; the workflow evaluates it again.
+	workflowRequest := jitaccess.JITAccessRequest{
+		Username:    req.Username,
+		Reason:      req.Reason,
+		NewRole:     req.NewRole,
+		Duration:    d,
+		Requester:   requester,
+		BreakGlass:  req.BreakGlass,
+		IncidentRef: req.IncidentRef,
+	}
+	policy, err := jitaccess.CurrentPolicy()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to get current role: %v", err), http.StatusInternalServerError)
+		logger.Error("failed to load policy", "error", err)
+		http.Error(w, fmt.Sprintf("failed to load policy: %v", err), http.StatusInternalServerError)
 		return
 	}
-	if currentRole == req.NewRole {
-		http.Error(w, "new_role cannot be the same as current role", http.StatusBadRequest)
+	decision := policy.Evaluate(workflowRequest, time.Now())
+	if !decision.Allowed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":         "denied",
+			"policy_version": decision.PolicyVersion,
+			"violations":     decision.Violations,
+		})
 		return
 	}
-	// Build the workflow request.
-	workflowRequest := jitaccess.JITAccessRequest{
-		Username: req.Username,
-		Reason:   req.Reason,
-		NewRole:  req.NewRole,
-		Duration: d,
-	}
-	workflowID := "jit_access_" + req.Username + "_" + fmt.Sprintf("%d", time.Now().Unix())
-	options := client.StartWorkflowOptions{
-		ID:                                       workflowID,
-		TaskQueue:                                "jit_access_task_queue",
-		WorkflowExecutionErrorWhenAlreadyStarted: true,
-	}
-	we, err := temporalClient.ExecuteWorkflow(context.Background(), options, jitaccess.JITAccessWorkflow, workflowRequest)
+	// Merge into the user's running grant, or start a new one.
+	result, err := startOrMergeGrant(r.Context(), temporalClient, workflowRequest)
 	if err != nil {
+		var reqErr *grantRequestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.Error(), reqErr.status)
+			return
+		}
 		logger.Error("failed to start workflow", "error", err)
 		http.Error(w, fmt.Sprintf("failed to start workflow: %v", err), http.StatusInternalServerError)
 		return
 	}
-	resp := map[string]string{
-		"status":     "accepted",
-		"workflowID": we.GetID(),
-		"runID":      we.GetRunID(),
+	status := "accepted"
+	switch {
+	case result.Merged:
+		status = "merged"
+	case decision.BreakGlass:
+		status = "break_glass"
+	case decision.RequireApproval:
+		status = "pending_approval"
+	}
+	resp := map[string]interface{}{
+		"status":        status,
+		"requester":     requester,
+		"workflowID":    result.WorkflowID,
+		"runID":         result.RunID,
+		"policyVersion": decision.PolicyVersion,
+	}
+	if result.Merged {
+		resp["grant"] = result.Grant
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+func handleGetPolicy(w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	policy, err := jitaccess.CurrentPolicy()
+	if err != nil {
+		logger.Error("failed to load policy", "error", err)
+		http.Error(w, fmt.Sprintf("failed to load policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// handleGetAudit returns the audit trail of ?username=, or of every user when it is empty, keeping
+// only the records the caller may see, see readAccess.
+func handleGetAudit(w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	auditLog := jitaccess.CurrentAuditLog()
+	if auditLog == nil {
+		http.Error(w, "audit log is not initialized", http.StatusServiceUnavailable)
+		return
+	}
+	_, mayRead, ok := readAccess(w, r, logger)
+	if !ok {
+		return
+	}
+	records, err := auditLog.Records(r.URL.Query().Get("username"))
+	if err != nil {
+		logger.Error("failed to read audit log", "error", err)
+		http.Error(w, fmt.Sprintf("failed to read audit log: %v", err), http.StatusInternalServerError)
+		return
+	}
+	visible := []jitaccess.AuditRecord{}
+	for _, record := range records {
+		if mayRead(record.Event.Username, record.Event.Role) {
+			visible = append(visible, record)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visible)
+}
+
+// JITApproval represents the JSON payload for approving or denying a pending JIT request.
+// The approver is the authenticated caller; Approver is only read when authentication is disabled.
+type JITApproval struct {
+	WorkflowID string `json:"workflow_id"`
+	Username   string `json:"username"`
+	Approved   bool   `json:"approved"`
+	Approver   string `json:"approver"`
+	Comment    string `json:"comment"`
+}
+
+func handleJITApproval(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	var req JITApproval
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.Approver = callerPrincipal(r, req.Approver)
+	if req.WorkflowID == "" && req.Username != "" {
+		req.WorkflowID = jitaccess.JITWorkflowID(req.Username)
+	}
+	if req.WorkflowID == "" || req.Approver == "" {
+		http.Error(w, "workflow_id (or username) and approver are required", http.StatusBadRequest)
+		return
+	}
+	approval := jitaccess.ApprovalDecision{
+		Approved: req.Approved,
+		Approver: req.Approver,
+		Comment:  req.Comment,
+	}
+	if err := temporalClient.SignalWorkflow(r.Context(), req.WorkflowID, "", jitaccess.ApprovalSignal, approval); err != nil {
+		logger.Error("failed to signal approval", "workflowID", req.WorkflowID, "error", err)
+		http.Error(w, fmt.Sprintf("failed to signal workflow: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "signalled",
+		"workflowID": req.WorkflowID,
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"app/internal/jitaccess"
+	"app/internal/worker/config"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// reconcileRunsQuery selects reconciliation runs; visibility lists the most recent first.
+const reconcileRunsQuery = "WorkflowType = 'ReconcileAccessWorkflow'"
+
+// ensureReconcileSchedule creates the reconciliation schedule, or updates an existing one to
+// the configured interval. Nothing is scheduled unless both a baseline and an interval are set.
+func ensureReconcileSchedule(ctx context.Context, c client.Client, cfg *config.WorkerConfig, logger *slog.Logger) error {
+	if cfg.JITReconcileInterval <= 0 {
+		return nil
+	}
+	if cfg.JITBaselineFile == "" {
+		logger.Warn("JIT_RECONCILE_INTERVAL is set without JIT_BASELINE_FILE; reconciliation is not scheduled")
+		return nil
+	}
+	options := jitaccess.ReconcileScheduleOptions(cfg.JITTaskQueue, cfg.JITReconcileInterval, cfg.JITReconcileRemediate)
+	_, err := c.ScheduleClient().Create(ctx, options)
+	if errors.Is(err, temporal.ErrScheduleAlreadyRunning) {
+		handle := c.ScheduleClient().GetHandle(ctx, options.ID)
+		err = handle.Update(ctx, client.ScheduleUpdateOptions{
+			DoUpdate: func(in client.ScheduleUpdateInput) (*client.ScheduleUpdate, error) {
+				schedule := in.Description.Schedule
+				schedule.Spec = &options.Spec
+				schedule.Action = options.Action
+				return &client.ScheduleUpdate{Schedule: &schedule}, nil
+			},
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to schedule JIT reconciliation: %w", err)
+	}
+	logger.Info("JIT reconciliation scheduled", "every", cfg.JITReconcileInterval, "remediate", cfg.JITReconcileRemediate)
+	return nil
+}
+
+// handleJITReconcile returns the report of a reconciliation run (GET, ?workflow_id= or the latest run),
+// or starts a run now (POST). Whether a run remediates is a server setting, not a caller choice.
+func handleJITReconcile(w http.ResponseWriter, r *http.Request, temporalClient client.Client, cfg *config.WorkerConfig, logger *slog.Logger) {
+	switch r.Method {
+	case http.MethodGet:
+		getReconcileReport(w, r, temporalClient, logger)
+	case http.MethodPost:
+		if cfg.JITBaselineFile == "" {
+			http.Error(w, "no reconciliation baseline is configured (JIT_BASELINE_FILE)", http.StatusServiceUnavailable)
+			return
+		}
+		options := client.StartWorkflowOptions{
+			ID:        fmt.Sprintf("jit_reconcile_manual_%d", time.Now().UnixNano()),
+			TaskQueue: cfg.JITTaskQueue,
+		}
+		req := jitaccess.ReconcileRequest{Remediate: cfg.JITReconcileRemediate}
+		we, err := temporalClient.ExecuteWorkflow(r.Context(), options, jitaccess.ReconcileAccessWorkflow, req)
+		if err != nil {
+			logger.Error("failed to start reconciliation", "error", err)
+			http.Error(w, fmt.Sprintf("failed to start workflow: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "started",
+			"workflowID": we.GetID(),
+			"runID":      we.GetRunID(),
+			"remediate":  req.Remediate,
+		})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func getReconcileReport(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	workflowID := r.URL.Query().Get("workflow_id")
+	runID := ""
+	if workflowID == "" {
+		resp, err := temporalClient.ListWorkflow(r.Context(), &workflowservice.ListWorkflowExecutionsRequest{
+			Query:    reconcileRunsQuery,
+			PageSize: 1,
+		})
+		if err != nil {
+			logger.Error("failed to list reconciliation runs", "error", err)
+			http.Error(w, fmt.Sprintf("failed to list reconciliation runs: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if len(resp.GetExecutions()) == 0 {
+			http.Error(w, "no reconciliation has run yet", http.StatusNotFound)
+			return
+		}
+		execution := resp.GetExecutions()[0].GetExecution()
+		workflowID, runID = execution.GetWorkflowId(), execution.GetRunId()
+	}
+	value, err := temporalClient.QueryWorkflow(r.Context(), workflowID, runID, jitaccess.ReconcileReportQuery)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, fmt.Sprintf("no reconciliation run %s", workflowID), http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to query reconciliation report", "workflowID", workflowID, "error", err)
+		http.Error(w, fmt.Sprintf("failed to query report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var report jitaccess.ReconcileReport
+	if err := value.Get(&report); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode report: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workflowID": workflowID,
+		"report":     report,
+	})
+}
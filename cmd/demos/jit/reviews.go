@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"app/internal/jitaccess"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+)
+
+// openReviewsQuery selects the post-incident reviews that are still waiting for acknowledgement.
+const openReviewsQuery = "WorkflowType = 'BreakGlassReviewWorkflow' AND ExecutionStatus = 'Running'"
+
+// JITReviewAck represents the JSON payload for acknowledging a post-incident review.
+// Reviewer is the authenticated caller; the body field is only read when authentication is disabled.
+type JITReviewAck struct {
+	WorkflowID string `json:"workflow_id"`
+	Reviewer   string `json:"reviewer"`
+	Notes      string `json:"notes"`
+}
+
+// handleJITReviews lists the open post-incident reviews of break-glass grants.
+func handleJITReviews(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	resp, err := temporalClient.ListWorkflow(r.Context(), &workflowservice.ListWorkflowExecutionsRequest{
+		Query: openReviewsQuery,
+	})
+	if err != nil {
+		logger.Error("failed to list reviews", "error", err)
+		http.Error(w, fmt.Sprintf("failed to list reviews: %v", err), http.StatusInternalServerError)
+		return
+	}
+	reviews := []map[string]interface{}{}
+	for _, execution := range resp.GetExecutions() {
+		workflowID := execution.GetExecution().GetWorkflowId()
+		review := map[string]interface{}{"workflowID": workflowID}
+		value, err := temporalClient.QueryWorkflow(r.Context(), workflowID, "", jitaccess.StatusQuery)
+		if err != nil {
+			logger.Warn("failed to query review", "workflowID", workflowID, "error", err)
+		} else {
+			var status jitaccess.ReviewStatus
+			if err := value.Get(&status); err == nil {
+				review["review"] = status
+			}
+		}
+		reviews = append(reviews, review)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reviews)
+}
+
+// handleJITReviewAck acknowledges a post-incident review. Only the role's approvers and the
+// principals allowed to request on behalf of others may, and never the user who had the grant
+// or whoever requested it; the review ignores anyone else.
+func handleJITReviewAck(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req JITReviewAck
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	req.Reviewer = callerPrincipal(r, req.Reviewer)
+	if req.WorkflowID == "" || req.Reviewer == "" || req.Notes == "" {
+		http.Error(w, "workflow_id, reviewer, and notes are required", http.StatusBadRequest)
+		return
+	}
+	value, err := temporalClient.QueryWorkflow(r.Context(), req.WorkflowID, "", jitaccess.StatusQuery)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, fmt.Sprintf("no open review %s", req.WorkflowID), http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to query review", "workflowID", req.WorkflowID, "error", err)
+		http.Error(w, fmt.Sprintf("failed to query review: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var review jitaccess.ReviewStatus
+	if err := value.Get(&review); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode review: %v", err), http.StatusInternalServerError)
+		return
+	}
+	policy, err := jitaccess.CurrentPolicy()
+	if err != nil {
+		logger.Error("failed to load policy", "error", err)
+		http.Error(w, fmt.Sprintf("failed to load policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !policy.MayReview(req.Reviewer, review.Username, review.Requester, review.Role) {
+		http.Error(w, fmt.Sprintf("%s may not review %s for %s", req.Reviewer, review.Role, review.Username), http.StatusForbidden)
+		return
+	}
+
+	ack := jitaccess.ReviewAcknowledgement{Reviewer: req.Reviewer, Notes: req.Notes}
+	if err := temporalClient.SignalWorkflow(r.Context(), req.WorkflowID, "", jitaccess.ReviewAckSignal, ack); err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			http.Error(w, fmt.Sprintf("no open review %s", req.WorkflowID), http.StatusNotFound)
+			return
+		}
+		logger.Error("failed to signal review", "workflowID", req.WorkflowID, "error", err)
+		http.Error(w, fmt.Sprintf("failed to signal workflow: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "signalled",
+		"workflowID": req.WorkflowID,
+	})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"app/internal/jitaccess"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+)
+
+// JITScheduleRequest represents the JSON payload for creating a recurring JIT window.
+type JITScheduleRequest struct {
+	ID              string                        `json:"id"`
+	Username        string                        `json:"username"`
+	Reason          string                        `json:"reason"`
+	NewRole         string                        `json:"new_role"`
+	Duration        string                        `json:"duration"`
+	CronExpressions []string                      `json:"cron"`
+	Calendars       []client.ScheduleCalendarSpec `json:"calendars"`
+	TimeZone        string                        `json:"timezone"`
+	Note            string                        `json:"note"`
+	Paused          bool                          `json:"paused"`
+}
+
+// JITScheduleSummary describes a JIT schedule and its upcoming windows.
+type JITScheduleSummary struct {
+	ID       string      `json:"id"`
+	Username string      `json:"username,omitempty"`
+	NewRole  string      `json:"new_role,omitempty"`
+	Duration string      `json:"duration,omitempty"`
+	Paused   bool        `json:"paused"`
+	Note     string      `json:"note,omitempty"`
+	Upcoming []time.Time `json:"upcoming"`
+}
+
+// JITScheduleAction represents the JSON payload for pausing, resuming or triggering a schedule.
+// Actor is the authenticated caller; the body field is only read when authentication is disabled.
+type JITScheduleAction struct {
+	ID     string `json:"id"`
+	Actor  string `json:"actor"`
+	Paused bool   `json:"paused"`
+	Note   string `json:"note"`
+}
+
+// handleJITSchedules lists (GET), creates (POST) and deletes (DELETE ?id=&actor=) JIT schedules.
+// The actor parameter is only read when authentication is disabled.
+func handleJITSchedules(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	switch r.Method {
+	case http.MethodGet:
+		listJITSchedules(w, r, temporalClient, logger)
+	case http.MethodPost:
+		createJITSchedule(w, r, temporalClient, logger)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id parameter is required", http.StatusBadRequest)
+			return
+		}
+		handle := temporalClient.ScheduleClient().GetHandle(r.Context(), jitaccess.ScheduleID(id))
+		if !authorizeSchedule(w, r, handle, logger, id, callerPrincipal(r, r.URL.Query().Get("actor"))) {
+			return
+		}
+		if err := handle.Delete(r.Context()); err != nil {
+			writeScheduleError(w, logger, id, "delete", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "deleted", "id": handle.GetID()})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func createJITSchedule(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	var req JITScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil {
+		http.Error(w, "invalid duration format", http.StatusBadRequest)
+		return
+	}
+	schedule := jitaccess.JITSchedule{
+		ID: req.ID,
+		Request: jitaccess.JITAccessRequest{
+			Username: req.Username,
+			Reason:   req.Reason,
+			NewRole:  req.NewRole,
+			Duration: d,
+			// Every window is requested by whoever created the schedule.
+			Requester: callerPrincipal(r, req.Username),
+		},
+		CronExpressions: req.CronExpressions,
+		Calendars:       req.Calendars,
+		TimeZone:        req.TimeZone,
+		Note:            req.Note,
+		Paused:          req.Paused,
+	}
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Refuse windows the current policy would deny whenever they start. Rules that depend on
+	// the time, such as business hours, are checked by each window's workflow.
+	policy, err := jitaccess.CurrentPolicy()
+	if err != nil {
+		logger.Error("failed to load policy", "error", err)
+		http.Error(w, fmt.Sprintf("failed to load policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if decision := policy.EvaluateAnyTime(schedule.Request); !decision.Allowed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":         "denied",
+			"policy_version": decision.PolicyVersion,
+			"violations":     decision.Violations,
+		})
+		return
+	}
+
+	options := schedule.ScheduleOptions(jitTaskQueue)
+	options.Memo = map[string]interface{}{
+		"username": req.Username,
+		"new_role": req.NewRole,
+		"duration": d.String(),
+	}
+	handle, err := temporalClient.ScheduleClient().Create(r.Context(), options)
+	if err != nil {
+		writeScheduleError(w, logger, req.ID, "create", err)
+		return
+	}
+	logger.Info("Created JIT schedule", "scheduleID", handle.GetID(), "username", req.Username, "new_role", req.NewRole)
+
+	summary := JITScheduleSummary{ID: handle.GetID(), Username: req.Username, NewRole: req.NewRole, Duration: d.String(), Paused: req.Paused, Note: req.Note}
+	if desc, err := handle.Describe(r.Context()); err == nil {
+		summary.Upcoming = desc.Info.NextActionTimes
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(summary)
+}
+
+// listJITSchedules lists the JIT schedules the caller may see, see readAccess.
+func listJITSchedules(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	_, mayRead, ok := readAccess(w, r, logger)
+	if !ok {
+		return
+	}
+	iter, err := temporalClient.ScheduleClient().List(r.Context(), client.ScheduleListOptions{})
+	if err != nil {
+		logger.Error("failed to list schedules", "error", err)
+		http.Error(w, fmt.Sprintf("failed to list schedules: %v", err), http.StatusInternalServerError)
+		return
+	}
+	summaries := []JITScheduleSummary{}
+	for iter.HasNext() {
+		entry, err := iter.Next()
+		if err != nil {
+			logger.Error("failed to list schedules", "error", err)
+			http.Error(w, fmt.Sprintf("failed to list schedules: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !strings.HasPrefix(entry.ID, jitaccess.ScheduleIDPrefix) {
+			continue
+		}
+		summary := JITScheduleSummary{ID: entry.ID, Paused: entry.Paused, Note: entry.Note, Upcoming: entry.NextActionTimes}
+		if entry.Memo != nil {
+			fields := entry.Memo.GetFields()
+			dc := converter.GetDefaultDataConverter()
+			_ = dc.FromPayload(fields["username"], &summary.Username)
+			_ = dc.FromPayload(fields["new_role"], &summary.NewRole)
+			_ = dc.FromPayload(fields["duration"], &summary.Duration)
+		}
+		if !mayRead(summary.Username, summary.NewRole) {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// handleJITSchedulePause pauses a schedule, or resumes it when paused is false.
+func handleJITSchedulePause(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	var req JITScheduleAction
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, "invalid request payload, id is required", http.StatusBadRequest)
+		return
+	}
+	handle := temporalClient.ScheduleClient().GetHandle(r.Context(), jitaccess.ScheduleID(req.ID))
+	if !authorizeSchedule(w, r, handle, logger, req.ID, callerPrincipal(r, req.Actor)) {
+		return
+	}
+	var err error
+	action, status := "pause", "paused"
+	if req.Paused {
+		err = handle.Pause(r.Context(), client.SchedulePauseOptions{Note: req.Note})
+	} else {
+		action, status = "resume", "resumed"
+		err = handle.Unpause(r.Context(), client.ScheduleUnpauseOptions{Note: req.Note})
+	}
+	if err != nil {
+		writeScheduleError(w, logger, req.ID, action, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": status, "id": handle.GetID()})
+}
+
+// handleJITScheduleTrigger starts a window now. The schedule's SKIP overlap policy still
+// applies, so triggering while a window is running does nothing.
+func handleJITScheduleTrigger(w http.ResponseWriter, r *http.Request, temporalClient client.Client, logger *slog.Logger) {
+	var req JITScheduleAction
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, "invalid request payload, id is required", http.StatusBadRequest)
+		return
+	}
+	handle := temporalClient.ScheduleClient().GetHandle(r.Context(), jitaccess.ScheduleID(req.ID))
+	if !authorizeSchedule(w, r, handle, logger, req.ID, callerPrincipal(r, req.Actor)) {
+		return
+	}
+	if err := handle.Trigger(r.Context(), client.ScheduleTriggerOptions{}); err != nil {
+		writeScheduleError(w, logger, req.ID, "trigger", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "triggered", "id": handle.GetID()})
+}
+
+// authorizeSchedule answers 403 unless actor may manage the access the schedule grants: the
+// user, a principal allowed to act on their behalf, or an approver of the role.
+func authorizeSchedule(w http.ResponseWriter, r *http.Request, handle client.ScheduleHandle, logger *slog.Logger, id, actor string) bool {
+	if actor == "" {
+		http.Error(w, "actor is required", http.StatusBadRequest)
+		return false
+	}
+	desc, err := handle.Describe(r.Context())
+	if err != nil {
+		writeScheduleError(w, logger, id, "describe", err)
+		return false
+	}
+	req, err := jitaccess.ScheduledRequest(desc)
+	if err != nil {
+		logger.Error("failed to read schedule", "scheduleID", id, "error", err)
+		http.Error(w, fmt.Sprintf("failed to read schedule %s: %v", id, err), http.StatusInternalServerError)
+		return false
+	}
+	return authorize(w, logger, actor, req.Username, req.NewRole)
+}
+
+func writeScheduleError(w http.ResponseWriter, logger *slog.Logger, id, action string, err error) {
+	var notFound *serviceerror.NotFound
+	switch {
+	case errors.As(err, &notFound):
+		http.Error(w, fmt.Sprintf("schedule %s not found", id), http.StatusNotFound)
+	case errors.Is(err, temporal.ErrScheduleAlreadyRunning):
+		http.Error(w, fmt.Sprintf("schedule %s already exists", id), http.StatusConflict)
+	default:
+		logger.Error("schedule operation failed", "scheduleID", id, "action", action, "error", err)
+		http.Error(w, fmt.Sprintf("failed to %s schedule: %v", action, err), http.StatusInternalServerError)
+	}
+}
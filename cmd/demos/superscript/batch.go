@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"app/internal/superscript"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// batchControlRequest names a running orchestrator. Its latest run is addressed, so requests
+// follow the batch across continue-as-new.
+type batchControlRequest struct {
+	WorkflowID    string `json:"workflow_id"`
+	MaxConcurrent int    `json:"max_concurrent,omitempty"`
+}
+
+// writeBatchError answers with the status that fits a Temporal error.
+func writeBatchError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		status = http.StatusNotFound
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// decodeBatchControl reads a batchControlRequest from a POST body, and checks that it names
+// an orchestrator so no other workflow can be signalled, updated or cancelled through it.
+func decodeBatchControl(w http.ResponseWriter, r *http.Request, c client.Client) (batchControlRequest, bool) {
+	var request batchControlRequest
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "POST required"})
+		return request, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.WorkflowID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "workflow_id is required"})
+		return request, false
+	}
+	description, err := c.DescribeWorkflowExecution(r.Context(), request.WorkflowID, "")
+	if err != nil {
+		writeBatchError(w, err)
+		return request, false
+	}
+	if workflowType := description.GetWorkflowExecutionInfo().GetType().GetName(); workflowType != superscript.OrchestratorWorkflowType {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("%s is a %s, not an %s", request.WorkflowID, workflowType, superscript.OrchestratorWorkflowType)})
+		return request, false
+	}
+	return request, true
+}
+
+// handleBatchProgress returns the progress of an orchestrator run.
+func handleBatchProgress(w http.ResponseWriter, r *http.Request, c client.Client) {
+	w.Header().Set("Content-Type", "application/json")
+	workflowID := r.URL.Query().Get("workflow_id")
+	if workflowID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "workflow_id is required"})
+		return
+	}
+	value, err := c.QueryWorkflow(r.Context(), workflowID, "", superscript.ProgressQuery)
+	if err != nil {
+		writeBatchError(w, err)
+		return
+	}
+	var progress superscript.OrchestratorProgress
+	if err := value.Get(&progress); err != nil {
+		writeBatchError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(progress)
+}
+
+// handleBatchSignal sends the pause or resume signal to an orchestrator.
+func handleBatchSignal(w http.ResponseWriter, r *http.Request, c client.Client, signal string) {
+	request, ok := decodeBatchControl(w, r, c)
+	if !ok {
+		return
+	}
+	if err := c.SignalWorkflow(r.Context(), request.WorkflowID, "", signal, nil); err != nil {
+		writeBatchError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"workflow_id": request.WorkflowID, "status": signal + " requested"})
+}
+
+// handleBatchConcurrency changes MaxConcurrent of a running orchestrator.
+func handleBatchConcurrency(w http.ResponseWriter, r *http.Request, c client.Client) {
+	request, ok := decodeBatchControl(w, r, c)
+	if !ok {
+		return
+	}
+	handle, err := c.UpdateWorkflow(r.Context(), client.UpdateWorkflowOptions{
+		WorkflowID:   request.WorkflowID,
+		UpdateName:   superscript.SetConcurrencyUpdate,
+		Args:         []interface{}{request.MaxConcurrent},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	if err != nil {
+		writeBatchError(w, err)
+		return
+	}
+	var previous int
+	if err := handle.Get(r.Context(), &previous); err != nil {
+		// The validator rejects values out of range.
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workflow_id":    request.WorkflowID,
+		"max_concurrent": request.MaxConcurrent,
+		"previous":       previous,
+	})
+}
+
+// handleBatchCancel cancels an orchestrator. It stops starting children and finishes once the
+// running ones are done.
+func handleBatchCancel(w http.ResponseWriter, r *http.Request, c client.Client) {
+	request, ok := decodeBatchControl(w, r, c)
+	if !ok {
+		return
+	}
+	if err := c.CancelWorkflow(r.Context(), request.WorkflowID, ""); err != nil {
+		writeBatchError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"workflow_id": request.WorkflowID, "status": "cancelling"})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"app/internal/superscript"
+
+	"go.temporal.io/sdk/client"
+)
+
+// redriveRequest selects the dead-lettered orders to re-drive; no OrderIDs re-drives all failed ones.
+type redriveRequest struct {
+	OrderIDs      []string `json:"order_ids"`
+	MaxConcurrent int      `json:"max_concurrent"`
+}
+
+// handleDeadLetters lists the dead-lettered orders (?status=failed, resolved or all), or returns
+// the dead letter of one order (?order_id=).
+func handleDeadLetters(w http.ResponseWriter, r *http.Request, store *superscript.DeadLetterStore) {
+	w.Header().Set("Content-Type", "application/json")
+	if orderID := r.URL.Query().Get("order_id"); orderID != "" {
+		letter, err := store.Get(orderID)
+		if errors.Is(err, superscript.ErrDeadLetterNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(letter)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = superscript.DeadLetterFailed
+	case "all":
+		status = ""
+	}
+	letters, err := store.List(status)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(letters)
+}
+
+// handleRedrive starts a RedriveWorkflow for the selected dead-lettered orders.
+func handleRedrive(w http.ResponseWriter, r *http.Request, c client.Client) {
+	w.Header().Set("Content-Type", "application/json")
+	var request redriveRequest
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "POST required"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+		return
+	}
+	workflowRun, err := c.ExecuteWorkflow(r.Context(), client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("%s-%d", superscript.RedriveWorkflowType, time.Now().UnixNano()),
+		TaskQueue: superscript.SuperscriptTaskQueue,
+	}, superscript.RedriveWorkflowType, superscript.RedriveParams{
+		OrderIDs:      request.OrderIDs,
+		MaxConcurrent: request.MaxConcurrent,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"workflow_id": workflowRun.GetID(),
+		"run_id":      workflowRun.GetRunID(),
+		"status":      "running",
+	})
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"app/internal/superscript"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+)
+
+const (
+	defaultEventsInterval = time.Second
+	minEventsInterval     = 200 * time.Millisecond
+)
+
+// sseWriter writes Server-Sent Events and flushes each one to the client.
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s *sseWriter) send(id, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		fmt.Fprintf(s.w, "id: %s\n", id)
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// handleBatchEvents streams the progress of an orchestrator (?workflow_id=) as Server-Sent
+// Events, polling its progress and events queries every interval (&interval=, default 1s):
+//   - "child" events are the ProgressEvents of children starting, completing or failing, with
+//     their Seq as event ID, so a reconnecting EventSource resumes after the last one it got,
+//   - "progress" events carry the OrchestratorProgress whenever it changes,
+//   - a final "done" event carries the status the workflow closed with,
+//   - "error" events report failed queries; the stream ends after one.
+func handleBatchEvents(w http.ResponseWriter, r *http.Request, c client.Client) {
+	workflowID := r.URL.Query().Get("workflow_id")
+	interval := defaultEventsInterval
+	if value := r.URL.Query().Get("interval"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < minEventsInterval {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("interval must be a duration of at least %s", minEventsInterval)})
+			return
+		}
+		interval = parsed
+	}
+	flusher, ok := w.(http.Flusher)
+	if workflowID == "" || !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "workflow_id is required"})
+		return
+	}
+	var seq int64
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		seq, _ = strconv.ParseInt(last, 10, 64)
+	} else if since := r.URL.Query().Get("since"); since != "" {
+		seq, _ = strconv.ParseInt(since, 10, 64)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	stream := &sseWriter{w: w, flusher: flusher}
+
+	var lastProgress []byte
+	// poll sends what happened since the last poll.
+	poll := func() error {
+		value, err := c.QueryWorkflow(r.Context(), workflowID, "", superscript.EventsQuery, seq)
+		if err != nil {
+			return err
+		}
+		var events []superscript.ProgressEvent
+		if err := value.Get(&events); err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := stream.send(strconv.FormatInt(event.Seq, 10), "child", event); err != nil {
+				return err
+			}
+			seq = event.Seq
+		}
+		value, err = c.QueryWorkflow(r.Context(), workflowID, "", superscript.ProgressQuery)
+		if err != nil {
+			return err
+		}
+		var progress superscript.OrchestratorProgress
+		if err := value.Get(&progress); err != nil {
+			return err
+		}
+		if encoded, _ := json.Marshal(progress); !bytes.Equal(encoded, lastProgress) {
+			lastProgress = encoded
+			return stream.send("", "progress", progress)
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		description, err := c.DescribeWorkflowExecution(r.Context(), workflowID, "")
+		if err == nil {
+			// A closed workflow still answers queries, so its last events are sent too.
+			err = poll()
+		}
+		if err != nil {
+			if r.Context().Err() == nil {
+				stream.send("", "error", map[string]string{"error": err.Error()})
+			}
+			return
+		}
+		if status := description.GetWorkflowExecutionInfo().GetStatus(); status != enums.WORKFLOW_EXECUTION_STATUS_RUNNING {
+			stream.send("", "done", map[string]string{"workflow_id": workflowID, "status": status.String()})
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchPage follows the events of an orchestrator with EventSource. It needs no other assets.
+var watchPage = template.Must(template.New("watch").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Batch {{.}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+progress { width: 30em; }
+#log { font-family: monospace; white-space: pre-wrap; }
+.failed { color: #cf222e; }
+.completed { color: #1a7f37; }
+</style>
+</head>
+<body>
+<h1>Batch {{.}}</h1>
+<p><progress id="bar" value="0" max="1"></progress> <span id="counts"></span></p>
+<p id="state"></p>
+<div id="log"></div>
+<script>
+const workflowID = {{.}};
+const log = document.getElementById("log");
+function line(text, cls) {
+  const div = document.createElement("div");
+  div.textContent = text;
+  if (cls) div.className = cls;
+  log.prepend(div);
+}
+const source = new EventSource("/batch/events?workflow_id=" + encodeURIComponent(workflowID));
+source.addEventListener("progress", e => {
+  const p = JSON.parse(e.data);
+  document.getElementById("bar").max = Math.max(p.total_count, 1);
+  document.getElementById("bar").value = p.completed;
+  document.getElementById("counts").textContent = p.completed + "/" + p.total_count + " done, " +
+    p.success_count + " succeeded, " + p.fail_count + " failed, " + p.in_flight.length + " running";
+  document.getElementById("state").textContent = (p.paused ? "Paused. " : "") + (p.cancelling ? "Cancelling. " : "") +
+    (p.backoff ? "Backing off " + p.backoff + ". " : "");
+});
+source.addEventListener("child", e => {
+  const ev = JSON.parse(e.data);
+  let text = ev.time + " " + ev.order_id + " " + ev.type;
+  if (ev.status === "deduplicated") text += " (deduplicated)";
+  if (ev.type !== "started") text += " in " + ev.execution_time + ", exit code " + ev.exit_code;
+  if (ev.error) text += ": " + ev.error;
+  if (ev.output) text += "\n    " + ev.output.split("\n").join("\n    ");
+  line(text, ev.type);
+});
+source.addEventListener("done", e => {
+  line("Batch closed: " + JSON.parse(e.data).status);
+  source.close();
+});
+source.addEventListener("error", e => {
+  if (e.data) {
+    line("Error: " + JSON.parse(e.data).error, "failed");
+    source.close();
+  }
+});
+</script>
+</body>
+</html>
+`))
+
+// handleBatchWatch serves a page that shows the progress of an orchestrator (?workflow_id=) live.
+func handleBatchWatch(w http.ResponseWriter, r *http.Request) {
+	workflowID := r.URL.Query().Get("workflow_id")
+	if workflowID == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "workflow_id is required"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	watchPage.Execute(w, workflowID)
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -59,6 +61,22 @@ func main() {
 	cfg.EnabledFeatures = []string{"superscript"} // Only enable superscript for this demo
 	cfg.HTTPPort = 8080                           // Use port 8080 for HTTP server
 
+	catalogue, err := superscript.LoadCatalogue(cfg.SuperscriptCatalogue)
+	if err != nil {
+		logger.Error("Failed to load script catalogue", "error", err)
+		os.Exit(1)
+	}
+	sources, err := superscript.LoadSourceCatalogue(cfg.SuperscriptSources)
+	if err != nil {
+		logger.Error("Failed to load order sources", "error", err)
+		os.Exit(1)
+	}
+	reports, err := superscript.NewReportStore(superscriptFeature.ReportStoreConfig(cfg))
+	if err != nil {
+		logger.Error("Failed to set up the report store", "error", err)
+		os.Exit(1)
+	}
+
 	logger.Info("Starting SuperScript demo",
 		"temporalHost", cfg.TemporalHost,
 		"temporalNamespace", cfg.TemporalNamespace)
@@ -103,6 +121,12 @@ func main() {
 				<ul>
 					<li><a href="/health">Health Check</a></li>
 					<li><a href="/status">Worker Status</a></li>
+					<li><a href="/scripts">Script Catalogue</a></li>
+					<li>Batch Progress: /batch/progress?workflow_id=...</li>
+					<li>Watch a Batch Live: /batch/watch?workflow_id=... (events stream: /batch/events?workflow_id=...)</li>
+					<li><a href="/schedules">Payment-Collection Schedules</a></li>
+					<li><a href="/dlq">Failed Orders (dead-letter queue)</a></li>
+					<li>Batch Reports: /batch/report?batch_id=...&amp;format=html</li>
 				</ul>
 			</body>
 			</html>
@@ -135,14 +159,55 @@ func main() {
 
 	// API endpoints from original superscript
 	mux.HandleFunc("/run/single", func(w http.ResponseWriter, r *http.Request) {
-		handleRunSingle(w, r, centralizedWorker.GetClient(), temporalLogger)
+		handleRunSingle(w, r, centralizedWorker.GetClient(), cfg, temporalLogger)
 	})
 	mux.HandleFunc("/run/batch", func(w http.ResponseWriter, r *http.Request) {
-		handleRunBatch(w, r, centralizedWorker.GetClient(), temporalLogger)
+		handleRunBatch(w, r, centralizedWorker.GetClient(), cfg, sources, temporalLogger)
 	})
 	mux.HandleFunc("/run/traditional", func(w http.ResponseWriter, r *http.Request) {
 		handleRunTraditional(w, r, temporalLogger)
 	})
+	mux.HandleFunc("/scripts", func(w http.ResponseWriter, r *http.Request) {
+		handleListScripts(w, r, catalogue)
+	})
+	mux.HandleFunc("/run/script", func(w http.ResponseWriter, r *http.Request) {
+		handleRunScript(w, r, centralizedWorker.GetClient(), catalogue, temporalLogger)
+	})
+	mux.HandleFunc("/batch/progress", func(w http.ResponseWriter, r *http.Request) {
+		handleBatchProgress(w, r, centralizedWorker.GetClient())
+	})
+	mux.HandleFunc("/batch/events", func(w http.ResponseWriter, r *http.Request) {
+		handleBatchEvents(w, r, centralizedWorker.GetClient())
+	})
+	mux.HandleFunc("/batch/watch", handleBatchWatch)
+	mux.HandleFunc("/batch/pause", func(w http.ResponseWriter, r *http.Request) {
+		handleBatchSignal(w, r, centralizedWorker.GetClient(), superscript.PauseSignal)
+	})
+	mux.HandleFunc("/batch/resume", func(w http.ResponseWriter, r *http.Request) {
+		handleBatchSignal(w, r, centralizedWorker.GetClient(), superscript.ResumeSignal)
+	})
+	mux.HandleFunc("/batch/concurrency", func(w http.ResponseWriter, r *http.Request) {
+		handleBatchConcurrency(w, r, centralizedWorker.GetClient())
+	})
+	mux.HandleFunc("/batch/cancel", func(w http.ResponseWriter, r *http.Request) {
+		handleBatchCancel(w, r, centralizedWorker.GetClient())
+	})
+	mux.HandleFunc("/batch/report", func(w http.ResponseWriter, r *http.Request) {
+		handleBatchReport(w, r, reports)
+	})
+	mux.HandleFunc("/schedules", func(w http.ResponseWriter, r *http.Request) {
+		handleSchedules(w, r, centralizedWorker.GetClient(), sources, temporalLogger)
+	})
+	mux.HandleFunc("/schedules/backfill", func(w http.ResponseWriter, r *http.Request) {
+		handleScheduleBackfill(w, r, centralizedWorker.GetClient(), temporalLogger)
+	})
+	deadLetters := superscript.NewDeadLetterStore(cfg.SuperscriptDeadLetterDir)
+	mux.HandleFunc("/dlq", func(w http.ResponseWriter, r *http.Request) {
+		handleDeadLetters(w, r, deadLetters)
+	})
+	mux.HandleFunc("/dlq/redrive", func(w http.ResponseWriter, r *http.Request) {
+		handleRedrive(w, r, centralizedWorker.GetClient())
+	})
 
 	// Create HTTP server
 	server := &http.Server{
@@ -182,10 +247,27 @@ func main() {
 	logger.Info("SuperScript demo shut down")
 }
 
+// checkFaults refuses faults the demo's worker does not accept, and reports whether the
+// request may go on.
+func checkFaults(w http.ResponseWriter, faults *superscript.FaultSpec, cfg *config.WorkerConfig) bool {
+	err := superscript.CheckFaults(faults, cfg.SuperscriptFaultsEnabled, cfg.SuperscriptFaultsKillWorker)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, superscript.ErrRequestFaultsDisabled), errors.Is(err, superscript.ErrKillWorkerDisabled):
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	return false
+}
+
 // handleRunSingle starts a single payment collection workflow
-func handleRunSingle(w http.ResponseWriter, r *http.Request, c client.Client, logger *logAdapter) {
+func handleRunSingle(w http.ResponseWriter, r *http.Request, c client.Client, cfg *config.WorkerConfig, logger *logAdapter) {
 	var request struct {
-		OrderID string `json:"order_id"`
+		OrderID string                 `json:"order_id"`
+		Faults  *superscript.FaultSpec `json:"faults"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -193,12 +275,15 @@ func handleRunSingle(w http.ResponseWriter, r *http.Request, c client.Client, lo
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
 		return
 	}
+	if !checkFaults(w, request.Faults, cfg) {
+		return
+	}
 
 	if request.OrderID == "" {
 		request.OrderID = superscript.SampleOrderID
 	}
 
-	workflowID := fmt.Sprintf("%s-%s", superscript.SinglePaymentWorkflowType, request.OrderID)
+	workflowID := superscript.PaymentWorkflowID(request.OrderID)
 	workflowOptions := client.StartWorkflowOptions{
 		ID:                    workflowID,
 		TaskQueue:             superscript.SuperscriptTaskQueue,
@@ -207,6 +292,7 @@ func handleRunSingle(w http.ResponseWriter, r *http.Request, c client.Client, lo
 
 	workflowRun, err := c.ExecuteWorkflow(r.Context(), workflowOptions, superscript.SinglePaymentCollectionWorkflow, superscript.SinglePaymentWorkflowParams{
 		OrderID: request.OrderID,
+		Faults:  request.Faults,
 	})
 
 	if err != nil {
@@ -232,10 +318,18 @@ func handleRunSingle(w http.ResponseWriter, r *http.Request, c client.Client, lo
 	})
 }
 
-// handleRunBatch starts the orchestrator workflow
-func handleRunBatch(w http.ResponseWriter, r *http.Request, c client.Client, logger *logAdapter) {
+// handleRunBatch starts the orchestrator workflow. A source must name one of the configured sources.
+func handleRunBatch(w http.ResponseWriter, r *http.Request, c client.Client, cfg *config.WorkerConfig, sources *superscript.SourceCatalogue, logger *logAdapter) {
 	var request struct {
-		OrderIDs []string `json:"order_ids"`
+		OrderIDs       []string                        `json:"order_ids"`
+		Source         *superscript.OrderSource        `json:"source"`
+		MaxConcurrent  int                             `json:"max_concurrent"`
+		PageSize       int                             `json:"page_size"`
+		ChildrenPerRun int                             `json:"children_per_run"`
+		RateLimit      float64                         `json:"rate_limit"`
+		RateBurst      int                             `json:"rate_burst"`
+		Backpressure   *superscript.BackpressurePolicy `json:"backpressure"`
+		Faults         *superscript.FaultSpec          `json:"faults"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -244,9 +338,19 @@ func handleRunBatch(w http.ResponseWriter, r *http.Request, c client.Client, log
 		return
 	}
 
-	if len(request.OrderIDs) == 0 {
+	if request.Source != nil {
+		// The worker resolves the source again against its own catalogue.
+		if _, err := sources.Resolve(*request.Source); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	} else if len(request.OrderIDs) == 0 {
 		request.OrderIDs = []string{"7307", "5493", "7387", "2614", "5999"}
 	}
+	if !checkFaults(w, request.Faults, cfg) {
+		return
+	}
 
 	workflowID := fmt.Sprintf("%s-%s", superscript.OrchestratorWorkflowType, time.Now().Format("2006-01-02"))
 	workflowOptions := client.StartWorkflowOptions{
@@ -256,8 +360,16 @@ func handleRunBatch(w http.ResponseWriter, r *http.Request, c client.Client, log
 	}
 
 	workflowRun, err := c.ExecuteWorkflow(r.Context(), workflowOptions, superscript.OrchestratorWorkflow, superscript.OrchestratorWorkflowParams{
-		OrderIDs: request.OrderIDs,
-		RunDate:  time.Now(),
+		OrderIDs:       request.OrderIDs,
+		Source:         request.Source,
+		RunDate:        time.Now(),
+		MaxConcurrent:  request.MaxConcurrent,
+		PageSize:       request.PageSize,
+		ChildrenPerRun: request.ChildrenPerRun,
+		RateLimit:      request.RateLimit,
+		RateBurst:      request.RateBurst,
+		Backpressure:   request.Backpressure,
+		Faults:         request.Faults,
 	})
 
 	if err != nil {
@@ -271,7 +383,10 @@ func handleRunBatch(w http.ResponseWriter, r *http.Request, c client.Client, log
 		"message":     "Orchestrator workflow started successfully",
 		"workflow_id": workflowRun.GetID(),
 		"run_id":      workflowRun.GetRunID(),
-		"status":      "running",
+		// The workflow defaults its batch ID to the workflow ID and first run ID.
+		"batch_id":  workflowRun.GetID() + "_" + workflowRun.GetRunID(),
+		"status":    "running",
+		"watch_url": "/batch/watch?workflow_id=" + url.QueryEscape(workflowRun.GetID()),
 	})
 }
 
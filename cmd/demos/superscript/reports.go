@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"app/internal/superscript"
+)
+
+// handleBatchReport downloads the report of a completed batch (?batch_id=) in a format
+// (&format=html, csv or json; default html). HTML is shown in the browser, CSV and JSON are
+// saved as files.
+func handleBatchReport(w http.ResponseWriter, r *http.Request, store superscript.ReportStore) {
+	batchID := r.URL.Query().Get("batch_id")
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = superscript.ReportHTML
+	}
+	if batchID == "" || (format != superscript.ReportHTML && format != superscript.ReportCSV && format != superscript.ReportJSON) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "batch_id, and a format of html, csv or json, are required"})
+		return
+	}
+	data, contentType, err := store.Get(r.Context(), superscript.ReportName(batchID, format))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, superscript.ErrReportNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if format != superscript.ReportHTML {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", batchID+"-report."+format))
+	}
+	w.Write(data)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"app/internal/superscript"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// scheduleRequest is the JSON payload for creating a daily payment-collection schedule.
+type scheduleRequest struct {
+	ID             string                        `json:"id"`
+	Source         superscript.OrderSource       `json:"source"`
+	Calendars      []client.ScheduleCalendarSpec `json:"calendars"`
+	TimeZone       string                        `json:"timezone"`
+	Overlap        string                        `json:"overlap"`
+	CatchupWindow  superscript.Duration          `json:"catchup_window"`
+	MaxConcurrent  int                           `json:"max_concurrent"`
+	PageSize       int                           `json:"page_size"`
+	ChildrenPerRun int                           `json:"children_per_run"`
+	Note           string                        `json:"note"`
+	Paused         bool                          `json:"paused"`
+}
+
+// backfillRequest runs a schedule for the times it had between Start and End, e.g. the days
+// missed during an outage longer than its catch-up window.
+type backfillRequest struct {
+	ID      string    `json:"id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Overlap string    `json:"overlap"`
+}
+
+// scheduleSummary describes a payment-collection schedule and its upcoming runs.
+type scheduleSummary struct {
+	ID       string      `json:"id"`
+	Paused   bool        `json:"paused"`
+	Note     string      `json:"note,omitempty"`
+	Upcoming []time.Time `json:"upcoming"`
+}
+
+// writeScheduleError answers with the status that fits a Temporal schedule error.
+func writeScheduleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, temporal.ErrScheduleAlreadyRunning) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "schedule already exists"})
+		return
+	}
+	writeBatchError(w, err)
+}
+
+// handleSchedules lists (GET), creates (POST) and deletes (DELETE ?id=) payment-collection schedules.
+func handleSchedules(w http.ResponseWriter, r *http.Request, c client.Client, sources *superscript.SourceCatalogue, logger *logAdapter) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		listSchedules(w, r, c)
+	case http.MethodPost:
+		createSchedule(w, r, c, sources, logger)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "id is required"})
+			return
+		}
+		handle := c.ScheduleClient().GetHandle(r.Context(), superscript.ScheduleID(id))
+		if err := handle.Delete(r.Context()); err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": handle.GetID(), "status": "deleted"})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "GET, POST or DELETE required"})
+	}
+}
+
+// createSchedule creates a schedule whose source names one of the configured sources.
+func createSchedule(w http.ResponseWriter, r *http.Request, c client.Client, sources *superscript.SourceCatalogue, logger *logAdapter) {
+	var request scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+		return
+	}
+	schedule := superscript.PaymentSchedule{
+		ID:             request.ID,
+		Source:         request.Source,
+		Calendars:      request.Calendars,
+		TimeZone:       request.TimeZone,
+		Overlap:        request.Overlap,
+		CatchupWindow:  time.Duration(request.CatchupWindow),
+		MaxConcurrent:  request.MaxConcurrent,
+		PageSize:       request.PageSize,
+		ChildrenPerRun: request.ChildrenPerRun,
+		Note:           request.Note,
+		Paused:         request.Paused,
+	}
+	if err := schedule.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if _, err := sources.Resolve(schedule.Source); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	handle, err := c.ScheduleClient().Create(r.Context(), schedule.ScheduleOptions(superscript.SuperscriptTaskQueue))
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	logger.Info("Created payment-collection schedule", "scheduleID", handle.GetID(), "timezone", request.TimeZone)
+
+	summary := scheduleSummary{ID: handle.GetID(), Paused: request.Paused, Note: request.Note}
+	if desc, err := handle.Describe(r.Context()); err == nil {
+		summary.Upcoming = desc.Info.NextActionTimes
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(summary)
+}
+
+func listSchedules(w http.ResponseWriter, r *http.Request, c client.Client) {
+	iter, err := c.ScheduleClient().List(r.Context(), client.ScheduleListOptions{})
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	summaries := []scheduleSummary{}
+	for iter.HasNext() {
+		entry, err := iter.Next()
+		if err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		if strings.HasPrefix(entry.ID, superscript.ScheduleIDPrefix) {
+			summaries = append(summaries, scheduleSummary{ID: entry.ID, Paused: entry.Paused, Note: entry.Note, Upcoming: entry.NextActionTimes})
+		}
+	}
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// handleScheduleBackfill runs the missed times of a schedule. Each backfilled run collects the
+// orders of the day it was scheduled for. By default the runs are buffered and run one after
+// another; days whose batch already completed are skipped.
+func handleScheduleBackfill(w http.ResponseWriter, r *http.Request, c client.Client, logger *logAdapter) {
+	w.Header().Set("Content-Type", "application/json")
+	var request backfillRequest
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "POST required"})
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ID == "" || !request.End.After(request.Start) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "id, and a start before end, are required"})
+		return
+	}
+	overlap := enumspb.SCHEDULE_OVERLAP_POLICY_BUFFER_ALL
+	if request.Overlap != "" {
+		var err error
+		if overlap, err = superscript.OverlapPolicy(request.Overlap); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	handle := c.ScheduleClient().GetHandle(r.Context(), superscript.ScheduleID(request.ID))
+	err := handle.Backfill(r.Context(), client.ScheduleBackfillOptions{
+		Backfill: []client.ScheduleBackfill{{Start: request.Start, End: request.End, Overlap: overlap}},
+	})
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	logger.Info("Backfilling payment-collection schedule", "scheduleID", handle.GetID(), "start", request.Start, "end", request.End)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     handle.GetID(),
+		"start":  request.Start,
+		"end":    request.End,
+		"status": "backfilling",
+	})
+}
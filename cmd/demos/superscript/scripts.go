@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"app/internal/superscript"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// handleListScripts returns the script catalogue
+func handleListScripts(w http.ResponseWriter, r *http.Request, catalogue *superscript.Catalogue) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(catalogue)
+}
+
+// handleRunScript starts a ScriptWorkflow for a catalogue script.
+// Requests with the same script and parameters (or the same idempotency_key) run only once.
+func handleRunScript(w http.ResponseWriter, r *http.Request, c client.Client, catalogue *superscript.Catalogue, logger *logAdapter) {
+	var request struct {
+		Script         string            `json:"script"`
+		Params         map[string]string `json:"params"`
+		IdempotencyKey string            `json:"idempotency_key"`
+		MaxAttempts    int32             `json:"max_attempts"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request format"})
+		return
+	}
+
+	// Validate before starting; the activity validates again against the worker's catalogue.
+	spec, ok := catalogue.Script(request.Script)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("script %q is not in the catalogue", request.Script)})
+		return
+	}
+	if _, err := spec.Args(request.Params); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	key := request.IdempotencyKey
+	if key == "" {
+		key = paramsKey(request.Params)
+	}
+	workflowID := fmt.Sprintf("%s-%s-%s", superscript.ScriptWorkflowType, spec.Name, key)
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                    workflowID,
+		TaskQueue:             superscript.SuperscriptTaskQueue,
+		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+	}
+	workflowRun, err := c.ExecuteWorkflow(r.Context(), workflowOptions, superscript.ScriptWorkflow, superscript.ScriptWorkflowParams{
+		Request:     superscript.ScriptRequest{Script: spec.Name, Params: request.Params},
+		MaxAttempts: request.MaxAttempts,
+	})
+	if err != nil {
+		if temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"message":      "Workflow already started and handled idempotently",
+				"workflow_id":  workflowID,
+				"is_duplicate": true,
+			})
+			return
+		}
+		logger.Error("Failed to start script workflow", "script", spec.Name, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workflow_id": workflowRun.GetID(),
+		"run_id":      workflowRun.GetRunID(),
+		"status":      "started",
+	})
+}
+
+// paramsKey derives a stable idempotency key from the parameter values.
+func paramsKey(params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s\x00", name, params[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
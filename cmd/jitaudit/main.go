@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"app/internal/jitaccess"
+)
+
+const usage = `Usage: jitaudit [-file path] <command>
+
+Commands:
+  verify            check the hash chain of the audit log
+  show [username]   print audit records as JSON lines, optionally for one user
+`
+
+func main() {
+	defaultFile := os.Getenv("JIT_AUDIT_LOG")
+	if defaultFile == "" {
+		defaultFile = "./jit-audit.log"
+	}
+	file := flag.String("file", defaultFile, "path to the JIT audit log")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if err := run(*file, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+func run(file string, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return fmt.Errorf("missing command")
+	}
+
+	switch args[0] {
+	case "verify":
+		count, err := jitaccess.VerifyAuditLog(file)
+		if errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("audit log %s is NOT intact after %d valid records: %w", file, count, err)
+		}
+		fmt.Printf("audit log %s is intact: %d records verified\n", file, count)
+		return nil
+
+	case "show":
+		username := ""
+		if len(args) > 1 {
+			username = args[1]
+		}
+		records, err := jitaccess.ReadAuditRecords(file, username)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, rec := range records {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"app/internal/jitaccess"
+	"app/internal/worker/config"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/log"
+)
+
+// backend is how jitctl reaches JIT grants: the JIT HTTP API or Temporal itself.
+type backend interface {
+	request(ctx context.Context, req jitaccess.JITAccessRequest) (requestResult, error)
+	// grants lists running grants, only those the caller holds or requested when mine is set.
+	grants(ctx context.Context, mine bool, state string) ([]jitaccess.GrantStatus, error)
+	status(ctx context.Context, username string) (jitaccess.GrantStatus, error)
+	approve(ctx context.Context, username string, decision jitaccess.ApprovalDecision) error
+	revoke(ctx context.Context, username string, req jitaccess.RevokeRequest) error
+	extend(ctx context.Context, username string, req jitaccess.ExtendRequest) error
+	// audit returns the audit trail in log order, for one user or everyone.
+	audit(ctx context.Context, username string) ([]jitaccess.AuditRecord, error)
+	close()
+}
+
+// requestResult mirrors the response of /api/jit-request.
+type requestResult struct {
+	Status        string                 `json:"status"`
+	Requester     string                 `json:"requester"`
+	WorkflowID    string                 `json:"workflowID"`
+	RunID         string                 `json:"runID"`
+	PolicyVersion string                 `json:"policyVersion"`
+	Grant         *jitaccess.GrantStatus `json:"grant,omitempty"`
+}
+
+func newBackend(opts options) (backend, error) {
+	switch opts.backend {
+	case "http":
+		return &httpBackend{baseURL: strings.TrimRight(opts.url, "/"), token: opts.token, principal: opts.principal, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	case "temporal":
+		return newTemporalBackend(opts)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", opts.backend)
+	}
+}
+
+// httpBackend talks to the JIT HTTP API. The server records the principal of the token;
+// principal is only sent for servers running with authentication disabled.
+type httpBackend struct {
+	baseURL   string
+	token     string
+	principal string
+	client    *http.Client
+}
+
+func (b *httpBackend) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		// Policy denials carry the violations as JSON.
+		var denied struct {
+			PolicyVersion string   `json:"policy_version"`
+			Violations    []string `json:"violations"`
+		}
+		if json.Unmarshal(data, &denied) == nil && len(denied.Violations) > 0 {
+			return fmt.Errorf("denied by policy %s: %s", denied.PolicyVersion, strings.Join(denied.Violations, "; "))
+		}
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (b *httpBackend) request(ctx context.Context, req jitaccess.JITAccessRequest) (requestResult, error) {
+	var result requestResult
+	err := b.do(ctx, http.MethodPost, "/api/jit-request", map[string]any{
+		"username":     req.Username,
+		"reason":       req.Reason,
+		"new_role":     req.NewRole,
+		"duration":     req.Duration.String(),
+		"break_glass":  req.BreakGlass,
+		"incident_ref": req.IncidentRef,
+	}, &result)
+	return result, err
+}
+
+func (b *httpBackend) grants(ctx context.Context, mine bool, state string) ([]jitaccess.GrantStatus, error) {
+	query := url.Values{}
+	if mine {
+		query.Set("mine", "true")
+		query.Set("username", b.principal)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	var grants []jitaccess.GrantStatus
+	err := b.do(ctx, http.MethodGet, "/api/jit-grants?"+query.Encode(), nil, &grants)
+	return grants, err
+}
+
+func (b *httpBackend) status(ctx context.Context, username string) (jitaccess.GrantStatus, error) {
+	var status jitaccess.GrantStatus
+	err := b.do(ctx, http.MethodGet, "/api/jit-status?username="+url.QueryEscape(username), nil, &status)
+	return status, err
+}
+
+func (b *httpBackend) approve(ctx context.Context, username string, decision jitaccess.ApprovalDecision) error {
+	return b.do(ctx, http.MethodPost, "/api/jit-approval", map[string]any{
+		"username": username,
+		"approved": decision.Approved,
+		"approver": b.principal,
+		"comment":  decision.Comment,
+	}, nil)
+}
+
+func (b *httpBackend) revoke(ctx context.Context, username string, req jitaccess.RevokeRequest) error {
+	return b.do(ctx, http.MethodPost, "/api/jit-revoke", map[string]any{
+		"username": username,
+		"actor":    b.principal,
+		"reason":   req.Reason,
+	}, nil)
+}
+
+func (b *httpBackend) extend(ctx context.Context, username string, req jitaccess.ExtendRequest) error {
+	return b.do(ctx, http.MethodPost, "/api/jit-extend", map[string]any{
+		"username": username,
+		"actor":    b.principal,
+		"duration": req.Duration.String(),
+		"reason":   req.Reason,
+	}, nil)
+}
+
+func (b *httpBackend) audit(ctx context.Context, username string) ([]jitaccess.AuditRecord, error) {
+	var records []jitaccess.AuditRecord
+	err := b.do(ctx, http.MethodGet, "/api/audit?username="+url.QueryEscape(username), nil, &records)
+	return records, err
+}
+
+func (b *httpBackend) close() {}
+
+// temporalBackend talks to Temporal directly, as the principal given with -as. It bypasses
+// the API's authentication and policy pre-check, so it is meant for operators; the workflow
+// still evaluates the policy and enforces approvals.
+type temporalBackend struct {
+	client    client.Client
+	taskQueue string
+	principal string
+	auditFile string
+}
+
+func newTemporalBackend(opts options) (*temporalBackend, error) {
+	if opts.principal == "" {
+		return nil, errors.New("the temporal backend needs a principal, set -as")
+	}
+	cfg := config.LoadConfig()
+	c, err := client.Dial(client.Options{
+		HostPort:  cfg.TemporalHost,
+		Namespace: cfg.TemporalNamespace,
+		Logger:    log.NewStructuredLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Temporal at %s: %w", cfg.TemporalHost, err)
+	}
+	return &temporalBackend{client: c, taskQueue: cfg.JITTaskQueue, principal: opts.principal, auditFile: opts.auditFile}, nil
+}
+
+func (b *temporalBackend) request(ctx context.Context, req jitaccess.JITAccessRequest) (requestResult, error) {
+	req.Requester = b.principal
+	if req.Username == "" {
+		req.Username = b.principal
+	}
+	start, err := jitaccess.StartOrMergeGrant(ctx, b.client, b.taskQueue, req, nil)
+	if err != nil {
+		return requestResult{}, err
+	}
+	result := requestResult{Status: "accepted", Requester: b.principal, WorkflowID: start.WorkflowID, RunID: start.RunID}
+	if start.Merged {
+		result.Status = "merged"
+		result.Grant = &start.Grant
+		result.PolicyVersion = start.Grant.PolicyVersion
+	}
+	return result, nil
+}
+
+func (b *temporalBackend) grants(ctx context.Context, mine bool, state string) ([]jitaccess.GrantStatus, error) {
+	grants, err := jitaccess.ListRunningGrants(ctx, b.client)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []jitaccess.GrantStatus
+	for _, g := range grants {
+		if mine && g.Username != b.principal && g.Requester != b.principal {
+			continue
+		}
+		if state != "" && g.State != state {
+			continue
+		}
+		filtered = append(filtered, g)
+	}
+	return filtered, nil
+}
+
+func (b *temporalBackend) status(ctx context.Context, username string) (jitaccess.GrantStatus, error) {
+	var status jitaccess.GrantStatus
+	value, err := b.client.QueryWorkflow(ctx, jitaccess.JITWorkflowID(username), "", jitaccess.StatusQuery)
+	if err != nil {
+		return status, grantError(username, err)
+	}
+	err = value.Get(&status)
+	return status, err
+}
+
+func (b *temporalBackend) approve(ctx context.Context, username string, decision jitaccess.ApprovalDecision) error {
+	decision.Approver = b.principal
+	return b.signal(ctx, username, jitaccess.ApprovalSignal, decision)
+}
+
+func (b *temporalBackend) revoke(ctx context.Context, username string, req jitaccess.RevokeRequest) error {
+	req.Actor = b.principal
+	return b.signal(ctx, username, jitaccess.RevokeSignal, req)
+}
+
+func (b *temporalBackend) extend(ctx context.Context, username string, req jitaccess.ExtendRequest) error {
+	req.Actor = b.principal
+	return b.signal(ctx, username, jitaccess.ExtendSignal, req)
+}
+
+func (b *temporalBackend) signal(ctx context.Context, username, signal string, payload any) error {
+	err := b.client.SignalWorkflow(ctx, jitaccess.JITWorkflowID(username), "", signal, payload)
+	return grantError(username, err)
+}
+
+// audit reads the audit log file the worker writes, so it must run next to the worker.
+func (b *temporalBackend) audit(ctx context.Context, username string) ([]jitaccess.AuditRecord, error) {
+	return jitaccess.ReadAuditRecords(b.auditFile, username)
+}
+
+func (b *temporalBackend) close() {
+	b.client.Close()
+}
+
+func grantError(username string, err error) error {
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("no active grant for %s", username)
+	}
+	return err
+}
@@ -0,0 +1,326 @@
+// Command jitctl requests, approves and inspects JIT access grants, either through the
+// JIT HTTP API or directly against Temporal.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"app/internal/jitaccess"
+)
+
+const usage = `Usage: jitctl [global flags] <command> [flags] [args]
+
+Commands:
+  request -role R -duration D -reason TEXT [-user U] [-break-glass -incident REF]
+                               request access (for yourself unless -user is given)
+  grants [-all] [-state S]     list my running grants (-all: everyone's)
+  status USER                  show the grant of a user
+  approve [-comment C] USER    approve the pending request of a user
+  deny [-comment C] USER       deny the pending request of a user
+  revoke [-reason R] USER      end a user's grant now
+  extend -duration D [-reason R] USER
+                               extend a user's grant
+  audit [-n N] [-f] [USER]     print the last N audit records; -f keeps following
+
+Global flags:
+`
+
+// options are the global flags.
+type options struct {
+	backend   string
+	url       string
+	token     string
+	principal string
+	auditFile string
+	output    string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.backend, "backend", envOr("JITCTL_BACKEND", "http"), `"http" for the JIT API, or "temporal" to talk to Temporal directly`)
+	flag.StringVar(&opts.url, "url", envOr("JITCTL_URL", "http://localhost:8080"), "JIT API base URL (http backend)")
+	flag.StringVar(&opts.token, "token", os.Getenv("JIT_API_TOKEN"), "bearer token for the JIT API (http backend)")
+	flag.StringVar(&opts.principal, "as", envOr("JITCTL_USER", os.Getenv("USER")), "principal to act as (temporal backend, or http with authentication disabled)")
+	flag.StringVar(&opts.auditFile, "audit-file", envOr("JIT_AUDIT_LOG", "./jit-audit.log"), "JIT audit log to read (temporal backend)")
+	flag.StringVar(&opts.output, "o", "table", `output format, "table" or "json"`)
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, opts, flag.Args(), os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, opts options, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return fmt.Errorf("missing command")
+	}
+	if opts.output != "table" && opts.output != "json" {
+		return fmt.Errorf("unknown output format %q", opts.output)
+	}
+	b, err := newBackend(opts)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+	p := printer{out: out, json: opts.output == "json"}
+
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	switch cmd {
+	case "request":
+		role := fs.String("role", "", "role to request")
+		duration := fs.Duration("duration", 0, "how long the grant lasts")
+		reason := fs.String("reason", "", "why access is needed")
+		user := fs.String("user", "", "user to grant access to; defaults to yourself")
+		breakGlass := fs.Bool("break-glass", false, "emergency access without approval")
+		incident := fs.String("incident", "", "incident reference, required with -break-glass")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *role == "" || *duration <= 0 {
+			return fmt.Errorf("request needs -role and a positive -duration")
+		}
+		result, err := b.request(ctx, jitaccess.JITAccessRequest{
+			Username:    *user,
+			Reason:      *reason,
+			NewRole:     *role,
+			Duration:    *duration,
+			BreakGlass:  *breakGlass,
+			IncidentRef: *incident,
+		})
+		if err != nil {
+			return err
+		}
+		return p.fields(result, [][2]string{
+			{"STATUS", result.Status},
+			{"WORKFLOW", result.WorkflowID},
+			{"POLICY", result.PolicyVersion},
+		})
+
+	case "grants":
+		all := fs.Bool("all", false, "list everyone's grants")
+		state := fs.String("state", "", "only grants in this state, e.g. pending_approval")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		grants, err := b.grants(ctx, !*all, *state)
+		if err != nil {
+			return err
+		}
+		return p.grants(grants)
+
+	case "status":
+		username, err := oneArg(fs, args, "status USER")
+		if err != nil {
+			return err
+		}
+		status, err := b.status(ctx, username)
+		if err != nil {
+			return err
+		}
+		return p.grants([]jitaccess.GrantStatus{status})
+
+	case "approve", "deny":
+		comment := fs.String("comment", "", "comment recorded with the decision")
+		username, err := oneArg(fs, args, cmd+" [-comment C] USER")
+		if err != nil {
+			return err
+		}
+		if err := b.approve(ctx, username, jitaccess.ApprovalDecision{Approved: cmd == "approve", Comment: *comment}); err != nil {
+			return err
+		}
+		return p.fields(map[string]string{"status": "signalled", "username": username}, [][2]string{{"SIGNALLED", cmd + " " + username}})
+
+	case "revoke":
+		reason := fs.String("reason", "", "why the grant is revoked")
+		username, err := oneArg(fs, args, "revoke [-reason R] USER")
+		if err != nil {
+			return err
+		}
+		if err := b.revoke(ctx, username, jitaccess.RevokeRequest{Reason: *reason}); err != nil {
+			return err
+		}
+		return p.fields(map[string]string{"status": "signalled", "username": username}, [][2]string{{"SIGNALLED", "revoke " + username}})
+
+	case "extend":
+		duration := fs.Duration("duration", 0, "how much longer the grant lasts")
+		reason := fs.String("reason", "", "why the grant is extended")
+		username, err := oneArg(fs, args, "extend -duration D [-reason R] USER")
+		if err != nil {
+			return err
+		}
+		if *duration <= 0 {
+			return fmt.Errorf("extend needs a positive -duration")
+		}
+		if err := b.extend(ctx, username, jitaccess.ExtendRequest{Duration: *duration, Reason: *reason}); err != nil {
+			return err
+		}
+		return p.fields(map[string]string{"status": "signalled", "username": username}, [][2]string{{"SIGNALLED", "extend " + username}})
+
+	case "audit":
+		last := fs.Int("n", 20, "number of records to print")
+		follow := fs.Bool("f", false, "keep printing new records")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return tailAudit(ctx, b, p, fs.Arg(0), *last, *follow)
+
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// auditPollInterval is how often "audit -f" checks for new records.
+const auditPollInterval = 2 * time.Second
+
+func tailAudit(ctx context.Context, b backend, p printer, username string, last int, follow bool) error {
+	records, err := b.audit(ctx, username)
+	if err != nil {
+		return err
+	}
+	if last >= 0 && len(records) > last {
+		records = records[len(records)-last:]
+	}
+	if err := p.audit(records, true); err != nil {
+		return err
+	}
+	var seen int64
+	if len(records) > 0 {
+		seen = records[len(records)-1].Seq
+	}
+	for follow {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(auditPollInterval):
+		}
+		records, err := b.audit(ctx, username)
+		if err != nil {
+			return err
+		}
+		var fresh []jitaccess.AuditRecord
+		for _, rec := range records {
+			if rec.Seq > seen {
+				fresh = append(fresh, rec)
+				seen = rec.Seq
+			}
+		}
+		if err := p.audit(fresh, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func oneArg(fs *flag.FlagSet, args []string, usage string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("usage: jitctl %s", usage)
+	}
+	return fs.Arg(0), nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// printer writes results as aligned tables or as JSON.
+type printer struct {
+	out  io.Writer
+	json bool
+}
+
+func (p printer) encode(v any) error {
+	enc := json.NewEncoder(p.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// fields prints v as JSON, or the given label/value pairs as a table.
+func (p printer) fields(v any, rows [][2]string) error {
+	if p.json {
+		return p.encode(v)
+	}
+	tw := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
+
+func (p printer) grants(grants []jitaccess.GrantStatus) error {
+	if p.json {
+		return p.encode(grants)
+	}
+	tw := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tROLE\tSTATE\tEXPIRES\tREQUESTER\tAPPROVER\tNOTE")
+	for _, g := range grants {
+		expires := "-"
+		if !g.ExpiresAt.IsZero() {
+			expires = g.ExpiresAt.Local().Format(time.DateTime)
+		}
+		var note []string
+		if g.BreakGlass {
+			note = append(note, "break-glass "+g.IncidentRef)
+		}
+		if g.RevokedBy != "" {
+			note = append(note, "revoked by "+g.RevokedBy)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			g.Username, g.NewRole, g.State, expires, dash(g.Requester), dash(g.Approver), strings.Join(note, ", "))
+	}
+	return tw.Flush()
+}
+
+// audit prints records; JSON output is one record per line so it can be followed.
+func (p printer) audit(records []jitaccess.AuditRecord, header bool) error {
+	if p.json {
+		enc := json.NewEncoder(p.out)
+		for _, rec := range records {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	if header {
+		fmt.Fprintln(tw, "SEQ\tTIME\tEVENT\tUSER\tROLE\tACTOR\tREASON")
+	}
+	for _, rec := range records {
+		e := rec.Event
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			rec.Seq, e.Time.Local().Format(time.DateTime), e.Type, e.Username, dash(e.Role), dash(e.Actor), e.Reason)
+	}
+	return tw.Flush()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"app/internal/jitaccess"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_HTTPBackend(t *testing.T) {
+	var requests []map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/jit-request", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer demo-user-token", r.Header.Get("Authorization"))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body)
+		if body["new_role"] == "atlasAdmin" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"status": "denied", "policy_version": "2025-06-01", "violations": []string{"role atlasAdmin is not allowed"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "pending_approval", "workflowID": "jit_access_demo-user", "policyVersion": "2025-06-01"})
+	})
+	mux.HandleFunc("/api/jit-grants", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "true", r.URL.Query().Get("mine"))
+		json.NewEncoder(w).Encode([]jitaccess.GrantStatus{{
+			Username:  "demo-user",
+			NewRole:   "readWriteAnyDatabase",
+			State:     jitaccess.StateActive,
+			ExpiresAt: time.Date(2025, 6, 4, 10, 0, 0, 0, time.UTC),
+			Approver:  "sre-lead",
+		}})
+	})
+	mux.HandleFunc("/api/jit-revoke", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no active grant for bob", http.StatusNotFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	opts := options{backend: "http", url: srv.URL, token: "demo-user-token", principal: "demo-user", output: "table"}
+	call := func(opts options, args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(context.Background(), opts, args, &out)
+		return out.String(), err
+	}
+
+	out, err := call(opts, "request", "-role", "readWriteAnyDatabase", "-duration", "15m", "-reason", "fix stuck payments")
+	require.NoError(t, err)
+	require.Contains(t, out, "pending_approval")
+	require.Equal(t, "15m0s", requests[0]["duration"])
+
+	_, err = call(opts, "request", "-role", "atlasAdmin", "-duration", "15m", "-reason", "fix stuck payments")
+	require.ErrorContains(t, err, "denied by policy 2025-06-01: role atlasAdmin is not allowed")
+
+	out, err = call(opts, "grants")
+	require.NoError(t, err)
+	require.Contains(t, out, "USER")
+	require.Contains(t, out, "readWriteAnyDatabase")
+	require.Contains(t, out, "sre-lead")
+
+	jsonOpts := opts
+	jsonOpts.output = "json"
+	out, err = call(jsonOpts, "grants")
+	require.NoError(t, err)
+	var grants []jitaccess.GrantStatus
+	require.NoError(t, json.Unmarshal([]byte(out), &grants))
+	require.Len(t, grants, 1)
+
+	_, err = call(opts, "revoke", "bob")
+	require.ErrorContains(t, err, "no active grant for bob")
+
+	_, err = call(opts, "approve")
+	require.ErrorContains(t, err, "usage: jitctl approve")
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// scriptRun is one run of single_payment_collection.sh, from the execution log the script
+// writes to SUPERSCRIPT_EXECUTION_LOG.
+type scriptRun struct {
+	pid       int
+	orderID   string
+	collected bool
+	exited    bool
+	// beforeKill is set for runs that started before the simulated kill.
+	beforeKill bool
+}
+
+// executionStats is what the runs of the scripts did to the orders of a benchmark.
+type executionStats struct {
+	// Runs is how many times the payment script ran.
+	Runs int `json:"runs"`
+	// Completed is how many orders had their payment collected at least once.
+	Completed int `json:"completed"`
+	// Duplicates is how many payments were collected again for an order that already had one.
+	Duplicates int `json:"duplicate_executions"`
+	// Interrupted is how many runs were killed before they finished.
+	Interrupted int `json:"interrupted"`
+	// Redone is how many runs after the kill were for orders already collected before it.
+	Redone int `json:"redone_after_kill"`
+}
+
+// workLost is the work a kill cost: runs it interrupted, and runs repeated for orders that
+// were already done.
+func (s executionStats) workLost() int {
+	return s.Interrupted + s.Redone
+}
+
+// readExecutionLog reads the runs in the execution log at path. Lines are
+// "<epoch seconds> <pid> <OrderID> <event> [exit code]"; a missing log has no runs.
+func readExecutionLog(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseRuns returns the runs in the lines of an execution log, in the order they started.
+// Runs that started in the first killLine lines are marked beforeKill.
+func parseRuns(lines []string, killLine int) []*scriptRun {
+	var runs []*scriptRun
+	running := make(map[string]*scriptRun)
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		key := fields[1] + " " + fields[2]
+		switch fields[3] {
+		case "attempt":
+			run := &scriptRun{pid: pid, orderID: fields[2], beforeKill: i < killLine}
+			runs = append(runs, run)
+			running[key] = run
+		case "collected":
+			if run := running[key]; run != nil {
+				run.collected = true
+			}
+		case "exit":
+			if run := running[key]; run != nil {
+				run.exited = true
+				delete(running, key)
+			}
+		}
+	}
+	return runs
+}
+
+// unfinishedRuns returns the runs in the lines that have not exited yet.
+func unfinishedRuns(lines []string) []*scriptRun {
+	var unfinished []*scriptRun
+	for _, run := range parseRuns(lines, len(lines)) {
+		if !run.exited {
+			unfinished = append(unfinished, run)
+		}
+	}
+	return unfinished
+}
+
+// analyzeRuns computes the execution stats of runs. Without a kill, killed is false.
+func analyzeRuns(runs []*scriptRun, killed bool) executionStats {
+	var stats executionStats
+	collections := make(map[string]int)
+	collectedBeforeKill := make(map[string]bool)
+	for _, run := range runs {
+		stats.Runs++
+		if !run.exited {
+			stats.Interrupted++
+		}
+		if run.collected {
+			collections[run.orderID]++
+			if run.beforeKill {
+				collectedBeforeKill[run.orderID] = true
+			}
+		}
+	}
+	for _, n := range collections {
+		stats.Completed++
+		stats.Duplicates += n - 1
+	}
+	if killed {
+		for _, run := range runs {
+			if !run.beforeKill && collectedBeforeKill[run.orderID] {
+				stats.Redone++
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeRuns(t *testing.T) {
+	lines := []string{
+		"1700000000 101 A attempt",
+		"1700000000 101 A collected",
+		"1700000001 101 A exit 0",
+		"1700000001 102 B attempt",
+		"1700000002 102 B exit 1",
+		"1700000002 103 C attempt",
+		// Killed here: 103 never exits, and the orders run again.
+		"1700000010 201 A attempt",
+		"1700000010 201 A collected",
+		"1700000011 201 A exit 0",
+		"1700000011 202 B attempt",
+		"1700000011 202 B collected",
+		"1700000012 202 B exit 0",
+		"malformed",
+	}
+
+	runs := parseRuns(lines, 6)
+	require.Len(t, runs, 5)
+	require.Equal(t, executionStats{Runs: 5, Completed: 2, Duplicates: 1, Interrupted: 1, Redone: 1}, analyzeRuns(runs, true))
+	require.Equal(t, 2, analyzeRuns(runs, true).workLost())
+
+	unfinished := unfinishedRuns(lines[:6])
+	require.Len(t, unfinished, 1)
+	require.Equal(t, 103, unfinished[0].pid)
+
+	// Without a kill, nothing is redone, but duplicates still count.
+	require.Equal(t, executionStats{Runs: 5, Completed: 2, Duplicates: 1, Interrupted: 1}, analyzeRuns(parseRuns(lines, len(lines)), false))
+}
+
+func TestNewModeReport(t *testing.T) {
+	lines := []string{
+		"1700000000 101 A attempt",
+		"1700000000 101 A collected",
+		"1700000001 101 A exit 0",
+		"1700000001 102 B attempt",
+		"1700000002 102 B exit 1",
+	}
+	report := newModeReport("temporal", 4, 0, lines, -1)
+	require.False(t, report.Killed)
+	require.Equal(t, 25.0, report.CompletionRate)
+	require.Equal(t, 0, report.WorkLost)
+}
+
+func TestOptionsValidate(t *testing.T) {
+	opts := options{Mode: "both", Orders: 10, Concurrency: 5, Step1Fail: 50, Step2Timeout: 20, Step2Gibberish: 20, SleepPercent: 100}
+	require.NoError(t, opts.validate())
+
+	opts.Step2Gibberish = 90
+	require.Error(t, opts.validate())
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"app/internal/superscript"
+)
+
+const usage = `Usage: superscriptbench [flags]
+
+Runs the same generated orders through traditional_payment_collection.sh and through
+OrchestratorWorkflow, with failures injected into the payment scripts, and compares completion
+rate, wall time, duplicate executions and the work lost when the process running the payments
+is killed.
+
+The Temporal run starts its own worker on the superscript task queue of TEMPORAL_HOST; run it
+against a server without other superscript workers, e.g. temporal server start-dev.
+
+Flags:
+`
+
+// options configure a benchmark.
+type options struct {
+	Mode        string
+	Orders      int
+	Concurrency int
+	// Failure injection, in percent; see func_collect_payment.sh.
+	Step1Fail       int
+	Step2Timeout    int
+	Step2Gibberish  int
+	SleepPercent    int
+	KillAfter       time.Duration
+	ScriptsPath     string
+	OutDir          string
+	JSON            bool
+	WorkflowTimeout time.Duration
+}
+
+func (o options) validate() error {
+	switch {
+	case o.Mode != "both" && o.Mode != "traditional" && o.Mode != "temporal":
+		return fmt.Errorf("mode must be both, traditional or temporal")
+	case o.Orders < 1 || o.Orders > 10000:
+		return fmt.Errorf("orders must be between 1 and 10000")
+	case o.Concurrency < 1 || o.Concurrency > superscript.MaxConcurrencyLimit:
+		return fmt.Errorf("concurrency must be between 1 and %d", superscript.MaxConcurrencyLimit)
+	case !percent(o.Step1Fail) || !percent(o.Step2Timeout) || !percent(o.Step2Gibberish) || !percent(o.Step2Timeout+o.Step2Gibberish):
+		return fmt.Errorf("failure rates must be percentages, and step 2's must add up to at most 100")
+	case o.SleepPercent < 0:
+		return fmt.Errorf("sleep must not be negative")
+	case o.KillAfter < 0:
+		return fmt.Errorf("kill-after must not be negative")
+	}
+	return nil
+}
+
+func percent(p int) bool {
+	return p >= 0 && p <= 100
+}
+
+// scriptEnv is the failure injection of the payment scripts, with the execution log they write.
+func (o options) scriptEnv(executionLog string) map[string]string {
+	return map[string]string{
+		"SUPERSCRIPT_STEP1_FAIL_PCT":      fmt.Sprint(o.Step1Fail),
+		"SUPERSCRIPT_STEP2_TIMEOUT_PCT":   fmt.Sprint(o.Step2Timeout),
+		"SUPERSCRIPT_STEP2_GIBBERISH_PCT": fmt.Sprint(o.Step2Gibberish),
+		"SUPERSCRIPT_SLEEP_PCT":           fmt.Sprint(o.SleepPercent),
+		"SUPERSCRIPT_EXECUTION_LOG":       executionLog,
+	}
+}
+
+// modeReport is the outcome of running the orders one way.
+type modeReport struct {
+	Mode   string `json:"mode"`
+	Orders int    `json:"orders"`
+	// CompletionRate is the percentage of orders whose payment was collected.
+	CompletionRate float64              `json:"completion_rate"`
+	WallTime       superscript.Duration `json:"wall_time"`
+	// Killed is set when the run was killed; runs that end before KillAfter are not.
+	Killed bool `json:"killed"`
+	// WorkLost is how many script runs the kill interrupted or made redo.
+	WorkLost int `json:"work_lost"`
+	executionStats
+	// Reported is what the run itself reported as collected: the summary of the traditional
+	// script, or the BatchResult of the orchestrator.
+	Reported int    `json:"reported_successes"`
+	Error    string `json:"error,omitempty"`
+}
+
+func newModeReport(mode string, orders int, wallTime time.Duration, lines []string, killLine int) *modeReport {
+	killed := killLine >= 0
+	if !killed {
+		killLine = len(lines)
+	}
+	stats := analyzeRuns(parseRuns(lines, killLine), killed)
+	return &modeReport{
+		Mode:           mode,
+		Orders:         orders,
+		CompletionRate: float64(stats.Completed) * 100 / float64(orders),
+		WallTime:       superscript.Duration(wallTime.Round(time.Millisecond)),
+		Killed:         killed,
+		WorkLost:       stats.workLost(),
+		executionStats: stats,
+	}
+}
+
+// generateOrders returns count numeric OrderIDs that no earlier benchmark used, so the
+// orchestrator does not find payment workflows they already have.
+func generateOrders(count int, now time.Time) []string {
+	orders := make([]string, count)
+	for i := range orders {
+		orders[i] = fmt.Sprintf("%d%05d", now.Unix(), i)
+	}
+	return orders
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == workerCommand {
+		if err := runWorker(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var opts options
+	flag.StringVar(&opts.Mode, "mode", "both", "what to run: both, traditional or temporal")
+	flag.IntVar(&opts.Orders, "orders", 20, "number of generated orders")
+	flag.IntVar(&opts.Concurrency, "concurrency", 5, "MaxConcurrent of the orchestrator")
+	flag.IntVar(&opts.Step1Fail, "step1-fail", 50, "percentage of runs whose step 1 fails")
+	flag.IntVar(&opts.Step2Timeout, "step2-timeout", 20, "percentage of runs whose step 2 times out")
+	flag.IntVar(&opts.Step2Gibberish, "step2-gibberish", 20, "percentage of runs whose step 2 prints gibberish and fails")
+	flag.IntVar(&opts.SleepPercent, "sleep", 100, "percentage of the scripts' simulated work time to sleep")
+	flag.DurationVar(&opts.KillAfter, "kill-after", 0, "kill the traditional script and the worker after this long, then start them again (0: no kill)")
+	flag.StringVar(&opts.ScriptsPath, "scripts", "./internal/superscript/", "base path of the superscript scripts")
+	flag.StringVar(&opts.OutDir, "out", "", "directory for logs and stores (default: a new temporary directory)")
+	flag.BoolVar(&opts.JSON, "json", false, "print the comparison as JSON")
+	flag.DurationVar(&opts.WorkflowTimeout, "timeout", 30*time.Minute, "longest time to wait for either run")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(opts, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, out io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if opts.OutDir == "" {
+		dir, err := os.MkdirTemp("", "superscriptbench-")
+		if err != nil {
+			return err
+		}
+		opts.OutDir = dir
+	}
+	outDir, err := filepath.Abs(opts.OutDir)
+	if err != nil {
+		return err
+	}
+	opts.OutDir = outDir
+	if opts.ScriptsPath, err = filepath.Abs(opts.ScriptsPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(opts.OutDir, 0o750); err != nil {
+		return err
+	}
+	orders := generateOrders(opts.Orders, time.Now())
+	fmt.Fprintf(os.Stderr, "Benchmarking %d orders (%s to %s); logs in %s\n", len(orders), orders[0], orders[len(orders)-1], opts.OutDir)
+
+	var reports []*modeReport
+	if opts.Mode != "temporal" {
+		fmt.Fprintln(os.Stderr, "Running traditional_payment_collection.sh...")
+		report, err := runTraditional(opts, orders)
+		if err != nil {
+			return fmt.Errorf("traditional run: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	if opts.Mode != "traditional" {
+		fmt.Fprintln(os.Stderr, "Running OrchestratorWorkflow...")
+		report, err := runTemporal(opts, orders)
+		if err != nil {
+			return fmt.Errorf("temporal run: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	if opts.JSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+	}
+	return printComparison(out, reports)
+}
+
+func printComparison(out io.Writer, reports []*modeReport) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	row := func(name string, value func(r *modeReport) string) {
+		fmt.Fprint(w, name)
+		for _, r := range reports {
+			fmt.Fprint(w, "\t", value(r))
+		}
+		fmt.Fprintln(w)
+	}
+	row("", func(r *modeReport) string { return r.Mode })
+	row("orders", func(r *modeReport) string { return fmt.Sprint(r.Orders) })
+	row("collected", func(r *modeReport) string { return fmt.Sprintf("%d (%.0f%%)", r.Completed, r.CompletionRate) })
+	row("reported successes", func(r *modeReport) string { return fmt.Sprint(r.Reported) })
+	row("wall time", func(r *modeReport) string { return r.WallTime.String() })
+	row("script runs", func(r *modeReport) string { return fmt.Sprint(r.Runs) })
+	row("duplicate executions", func(r *modeReport) string { return fmt.Sprint(r.Duplicates) })
+	row("killed", func(r *modeReport) string { return fmt.Sprint(r.Killed) })
+	row("  runs interrupted", func(r *modeReport) string { return fmt.Sprint(r.Interrupted) })
+	row("  runs redone", func(r *modeReport) string { return fmt.Sprint(r.Redone) })
+	row("  work lost", func(r *modeReport) string { return fmt.Sprint(r.WorkLost) })
+	for _, r := range reports {
+		if r.Error != "" {
+			fmt.Fprintf(w, "%s error: %s\n", r.Mode, r.Error)
+		}
+	}
+	return w.Flush()
+}
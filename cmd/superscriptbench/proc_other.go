@@ -0,0 +1,25 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op without process groups.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills only the process pid itself; its children keep running.
+func killProcessGroup(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+// stopProcess kills the process, as there are no signals to ask it to stop.
+func stopProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in its own process group, so it can be killed with its children.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the process group led by pid with SIGKILL, like a crashing host would.
+func killProcessGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
+
+// stopProcess asks a process to shut down gracefully.
+func stopProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	superscriptFeature "app/internal/features/superscript"
+	"app/internal/superscript"
+	"app/internal/worker"
+	"app/internal/worker/config"
+
+	"go.temporal.io/sdk/client"
+)
+
+// workerCommand runs the benchmark's own worker, in a process that can be killed.
+const workerCommand = "worker"
+
+// stopTimeout is how long a worker gets to shut down gracefully before it is killed.
+const stopTimeout = 10 * time.Second
+
+// logAdapter adapts slog.Logger to Temporal's log.Logger interface
+type logAdapter struct {
+	logger *slog.Logger
+}
+
+func (l *logAdapter) Debug(msg string, keyvals ...interface{}) {
+	l.logger.Debug(msg, keyvals...)
+}
+
+func (l *logAdapter) Info(msg string, keyvals ...interface{}) {
+	l.logger.Info(msg, keyvals...)
+}
+
+func (l *logAdapter) Warn(msg string, keyvals ...interface{}) {
+	l.logger.Warn(msg, keyvals...)
+}
+
+func (l *logAdapter) Error(msg string, keyvals ...interface{}) {
+	l.logger.Error(msg, keyvals...)
+}
+
+// process is a started command and the result of waiting for it.
+type process struct {
+	cmd  *exec.Cmd
+	done chan error
+}
+
+func startProcess(cmd *exec.Cmd) (*process, error) {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &process{cmd: cmd, done: make(chan error, 1)}
+	go func() { p.done <- cmd.Wait() }()
+	return p, nil
+}
+
+// kill kills the process with its process group and waits for it to exit.
+func (p *process) kill() {
+	killProcessGroup(p.cmd.Process.Pid)
+	<-p.done
+}
+
+// stop asks the process to shut down, and kills it if it does not within stopTimeout.
+func (p *process) stop() {
+	stopProcess(p.cmd)
+	select {
+	case <-p.done:
+	case <-time.After(stopTimeout):
+		p.kill()
+	}
+}
+
+// killRunningScripts kills the payment scripts that have not exited according to the
+// execution log. The runner starts each script in a process group of its own, so killing a
+// worker leaves them running, unlike a crashing host.
+func killRunningScripts(executionLog string) error {
+	lines, err := readExecutionLog(executionLog)
+	if err != nil {
+		return err
+	}
+	for _, run := range unfinishedRuns(lines) {
+		killProcessGroup(run.pid)
+	}
+	return nil
+}
+
+// killLineAfter returns the number of lines in the execution log, once the kill is done.
+func killLineAfter(executionLog string) (int, error) {
+	lines, err := readExecutionLog(executionLog)
+	return len(lines), err
+}
+
+var traditionalSummary = regexp.MustCompile(`Successful: ([0-9]+)`)
+
+// runTraditional runs the orders through traditional_payment_collection.sh. When it is killed,
+// the script is run again for all orders, as it keeps no record of what it did.
+func runTraditional(opts options, orders []string) (*modeReport, error) {
+	executionLog := filepath.Join(opts.OutDir, "traditional-executions.log")
+	outputPath := filepath.Join(opts.OutDir, "traditional.out")
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+	env := os.Environ()
+	for name, value := range opts.scriptEnv(executionLog) {
+		env = append(env, name+"="+value)
+	}
+	start := func() (*process, error) {
+		cmd := exec.Command(filepath.Join(opts.ScriptsPath, "scripts", "traditional_payment_collection.sh"), orders...)
+		cmd.Env = env
+		cmd.Stdout = output
+		cmd.Stderr = output
+		return startProcess(cmd)
+	}
+
+	started := time.Now()
+	deadline := time.After(opts.WorkflowTimeout)
+	var kill <-chan time.Time
+	if opts.KillAfter > 0 {
+		kill = time.After(opts.KillAfter)
+	}
+	script, err := start()
+	if err != nil {
+		return nil, err
+	}
+	killLine := -1
+	var runErr error
+	select {
+	case runErr = <-script.done:
+	case <-kill:
+		script.kill()
+		if killLine, err = killLineAfter(executionLog); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(output, "=== killed; running the whole batch again ===")
+		if script, err = start(); err != nil {
+			return nil, err
+		}
+		select {
+		case runErr = <-script.done:
+		case <-deadline:
+			script.kill()
+			runErr = errors.New("timed out")
+		}
+	case <-deadline:
+		script.kill()
+		runErr = errors.New("timed out")
+	}
+	wallTime := time.Since(started)
+
+	lines, err := readExecutionLog(executionLog)
+	if err != nil {
+		return nil, err
+	}
+	report := newModeReport("traditional", len(orders), wallTime, lines, killLine)
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	// The last summary is that of the run that finished.
+	if summaries := traditionalSummary.FindAllSubmatch(data, -1); len(summaries) > 0 {
+		report.Reported, _ = strconv.Atoi(string(summaries[len(summaries)-1][1]))
+	}
+	return report, nil
+}
+
+// runTemporal runs the orders through OrchestratorWorkflow, with a worker of its own. When it
+// is killed, a new worker is started and the batch carries on where it was.
+func runTemporal(opts options, orders []string) (*modeReport, error) {
+	executionLog := filepath.Join(opts.OutDir, "temporal-executions.log")
+	catalogue := superscript.DefaultCatalogue()
+	for i := range catalogue.Scripts {
+		if catalogue.Scripts[i].Name == superscript.PaymentScriptName {
+			catalogue.Scripts[i].SetEnv = opts.scriptEnv(executionLog)
+		}
+	}
+	data, err := json.MarshalIndent(catalogue, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	cataloguePath := filepath.Join(opts.OutDir, "catalogue.json")
+	if err := os.WriteFile(cataloguePath, data, 0o640); err != nil {
+		return nil, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	workerLog, err := os.Create(filepath.Join(opts.OutDir, "worker.log"))
+	if err != nil {
+		return nil, err
+	}
+	defer workerLog.Close()
+	startWorker := func() (*process, error) {
+		cmd := exec.Command(self, workerCommand,
+			"-catalogue", cataloguePath,
+			"-scripts", opts.ScriptsPath,
+			"-dir", opts.OutDir,
+			"-concurrency", strconv.Itoa(opts.Concurrency))
+		cmd.Stdout = workerLog
+		cmd.Stderr = workerLog
+		return startProcess(cmd)
+	}
+
+	cfg := config.LoadConfig()
+	c, err := client.Dial(client.Options{
+		HostPort:  cfg.TemporalHost,
+		Namespace: cfg.TemporalNamespace,
+		Logger:    &logAdapter{logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Temporal at %s: %w", cfg.TemporalHost, err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.WorkflowTimeout)
+	defer cancel()
+	w, err := startWorker()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { w.stop() }()
+
+	batchID := "superscriptbench-" + orders[0]
+	started := time.Now()
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        batchID,
+		TaskQueue: superscript.SuperscriptTaskQueue,
+	}, superscript.OrchestratorWorkflowType, superscript.OrchestratorWorkflowParams{
+		BatchID:       batchID,
+		OrderIDs:      orders,
+		RunDate:       started,
+		MaxConcurrent: opts.Concurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var batch superscript.BatchResult
+	result := make(chan error, 1)
+	go func() { result <- run.Get(ctx, &batch) }()
+
+	var kill <-chan time.Time
+	if opts.KillAfter > 0 {
+		kill = time.After(opts.KillAfter)
+	}
+	killLine := -1
+	var runErr error
+	select {
+	case runErr = <-result:
+	case <-kill:
+		w.kill()
+		if err := killRunningScripts(executionLog); err != nil {
+			return nil, err
+		}
+		if killLine, err = killLineAfter(executionLog); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(workerLog, "=== killed; starting a new worker ===")
+		if w, err = startWorker(); err != nil {
+			return nil, err
+		}
+		runErr = <-result
+	}
+	wallTime := time.Since(started)
+
+	lines, err := readExecutionLog(executionLog)
+	if err != nil {
+		return nil, err
+	}
+	report := newModeReport("temporal", len(orders), wallTime, lines, killLine)
+	report.Reported = batch.SuccessCount
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+	return report, nil
+}
+
+// runWorker runs a superscript worker with the benchmark's catalogue and stores until it is
+// told to stop.
+func runWorker(args []string) error {
+	fs := flag.NewFlagSet(workerCommand, flag.ExitOnError)
+	cataloguePath := fs.String("catalogue", "", "script catalogue with the failure injection")
+	scriptsPath := fs.String("scripts", "./internal/superscript/", "base path of the superscript scripts")
+	dir := fs.String("dir", "", "directory of the benchmark's stores")
+	concurrency := fs.Int("concurrency", 5, "MaxConcurrent of the orchestrator")
+	fs.Parse(args)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	temporalLogger := &logAdapter{logger: logger}
+	cfg := config.LoadConfig()
+	cfg.EnabledFeatures = []string{"superscript"}
+	cfg.SuperscriptBasePath = *scriptsPath
+	cfg.SuperscriptCatalogue = *cataloguePath
+	cfg.SuperscriptBatchDir = filepath.Join(*dir, "batches")
+	cfg.SuperscriptDeadLetterDir = filepath.Join(*dir, "dlq")
+	cfg.SuperscriptReportDir = filepath.Join(*dir, "reports")
+	cfg.SuperscriptReportS3URL = ""
+	cfg.MaxConcurrentActivities = max(cfg.MaxConcurrentActivities, 2*(*concurrency))
+
+	centralizedWorker, err := worker.NewCentralizedWorker(cfg, temporalLogger)
+	if err != nil {
+		return fmt.Errorf("failed to create worker: %w", err)
+	}
+	if err := centralizedWorker.RegisterFeature(superscriptFeature.NewFeature(temporalLogger)); err != nil {
+		return fmt.Errorf("failed to register superscript feature: %w", err)
+	}
+	if err := centralizedWorker.Start(); err != nil {
+		return fmt.Errorf("failed to start worker: %w", err)
+	}
+	centralizedWorker.WaitForShutdown()
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"app/internal/superscript"
+)
+
+const usage = `Usage: superscriptdlq [-dir path] <command>
+
+Commands:
+  list [status]     list dead-lettered orders: failed (default), resolved or all
+  show <order_id>   print the dead letter of an order, with its last output, as JSON
+`
+
+func main() {
+	defaultDir := os.Getenv("SUPERSCRIPT_DLQ_DIR")
+	if defaultDir == "" {
+		defaultDir = superscript.DefaultDeadLetterDir
+	}
+	dir := flag.String("dir", defaultDir, "path to the superscript dead-letter store")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if err := run(superscript.NewDeadLetterStore(*dir), flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
+
+func run(store *superscript.DeadLetterStore, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return fmt.Errorf("missing command")
+	}
+
+	switch args[0] {
+	case "list":
+		status := superscript.DeadLetterFailed
+		if len(args) > 1 {
+			status = args[1]
+		}
+		switch status {
+		case "all":
+			status = ""
+		case superscript.DeadLetterFailed, superscript.DeadLetterResolved:
+		default:
+			return fmt.Errorf("unknown status %q", status)
+		}
+		letters, err := store.List(status)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ORDER\tSTATUS\tBATCH\tFAILED AT\tEXIT\tREDRIVES\tERROR")
+		for _, l := range letters {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", l.OrderID, l.Status, l.BatchID, l.FailedAt.Format(time.RFC3339), l.ExitCode, l.Redrives, l.Error)
+		}
+		return w.Flush()
+
+	case "show":
+		if len(args) < 2 {
+			return fmt.Errorf("show needs an order_id")
+		}
+		letter, err := store.Get(args[1])
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(letter)
+
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
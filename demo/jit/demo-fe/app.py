@@ -1,17 +1,22 @@
 #!/usr/bin/env python3
 
+import os
+
 import streamlit as st
 import requests
 import json
 
 # Configure the backend API base URL.
 BACKEND_URL = "http://localhost:8080"
+# Bearer token (JWT or static API token) used to authenticate to the JIT API.
+API_TOKEN = os.environ.get("JIT_API_TOKEN", "")
+AUTH_HEADERS = {"Authorization": f"Bearer {API_TOKEN}"} if API_TOKEN else {}
 
 st.title("MongoDB Atlas JIT Access Request")
 
 # Step 1: Get the list of existing database users
 try:
-    users_response = requests.get(f"{BACKEND_URL}/api/database-users")
+    users_response = requests.get(f"{BACKEND_URL}/api/database-users", headers=AUTH_HEADERS)
     users_response.raise_for_status()
     database_users = users_response.json()
 except Exception as e:
@@ -24,13 +29,13 @@ username = st.selectbox("Select your database username:", database_users)
 if st.button("Load User Info") and username:
     try:
         # Call the backend to get the current role.
-        role_response = requests.get(f"{BACKEND_URL}/api/user-role", params={"username": username})
+        role_response = requests.get(f"{BACKEND_URL}/api/user-role", params={"username": username}, headers=AUTH_HEADERS)
         role_response.raise_for_status()
         role_data = role_response.json()
         current_role = role_data.get("current_role", "")
         
         # Call the backend to get the list of built-in roles.
-        roles_response = requests.get(f"{BACKEND_URL}/api/built-in-roles")
+        roles_response = requests.get(f"{BACKEND_URL}/api/built-in-roles", headers=AUTH_HEADERS)
         roles_response.raise_for_status()
         built_in_roles = roles_response.json()
         
@@ -50,9 +55,11 @@ if "current_role" in st.session_state and "built_in_roles" in st.session_state:
     new_role = st.selectbox("Select the new role you want to request:", available_roles)
     reason = st.text_area("Reason for access (required):", placeholder="Please provide a reason for requesting this access")
     duration = st.selectbox("Select duration of access:", ["3m", "5m", "15m", "1h"])
+    break_glass = st.checkbox("Break-glass (emergency access, no approval, reviewed afterwards)")
+    incident_ref = st.text_input("Incident reference (required for break-glass):", placeholder="INC-1234") if break_glass else ""
     
-    # Only disable submit button if reason is empty
-    submit_disabled = not reason.strip()
+    # Disable submit until a reason (and, for break-glass, an incident reference) is given
+    submit_disabled = not reason.strip() or (break_glass and not incident_ref.strip())
     
     if st.button("Submit JIT Request", disabled=submit_disabled):
         if new_role == "atlasAdmin":
@@ -65,15 +72,26 @@ if "current_role" in st.session_state and "built_in_roles" in st.session_state:
                 "reason": reason,
                 "new_role": new_role,
                 "duration": duration,
+                "break_glass": break_glass,
+                "incident_ref": incident_ref,
             }
             try:
                 response = requests.post(
                     f"{BACKEND_URL}/api/jit-request",
                     json=payload,
-                    headers={"Content-Type": "application/json"},
+                    headers={"Content-Type": "application/json", **AUTH_HEADERS},
                 )
-                response.raise_for_status()
-                result = response.json()
-                st.success(f"Request submitted successfully! Workflow ID: {result.get('workflowID')}, Run ID: {result.get('runID')}")
+                if response.status_code == 403:
+                    denied = response.json()
+                    st.error(f"Request denied by policy {denied.get('policy_version')}: " + "; ".join(denied.get("violations", [])))
+                else:
+                    response.raise_for_status()
+                    result = response.json()
+                    if result.get("status") == "break_glass":
+                        st.warning(f"Break-glass access granted; a post-incident review will be opened. Workflow ID: {result.get('workflowID')}")
+                    elif result.get("status") == "pending_approval":
+                        st.info(f"Request is waiting for approval. Workflow ID: {result.get('workflowID')}")
+                    else:
+                        st.success(f"Request submitted successfully! Workflow ID: {result.get('workflowID')}, Run ID: {result.get('runID')}")
             except Exception as e:
                 st.error(f"Failed to submit JIT request: {e}")
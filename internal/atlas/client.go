@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/mongodb-forks/digest"
 	"github.com/mongodb/atlas-sdk-go/admin"
@@ -67,15 +68,25 @@ func GetUserRole(ctx context.Context, username string) (string, error) {
 
 // SetUserRole updates the role for a given database user in the "admin" database.
 func SetUserRole(ctx context.Context, username, role string) error {
+	return SetUserRoles(ctx, username, []string{role})
+}
+
+// SetUserRoles replaces all roles of a database user.
+// Roles use the format returned by ListDatabaseUsers: "role" for the admin database, "role@db" otherwise.
+func SetUserRoles(ctx context.Context, username string, roles []string) error {
+	if len(roles) == 0 {
+		return fmt.Errorf("a database user needs at least one role")
+	}
 	// Build the payload.
-	payload := map[string]any{
-		"roles": []map[string]string{
-			{
-				"databaseName": "admin",
-				"roleName":     role,
-			},
-		},
+	payloadRoles := make([]map[string]string, 0, len(roles))
+	for _, role := range roles {
+		name, db := splitRole(role)
+		payloadRoles = append(payloadRoles, map[string]string{
+			"databaseName": db,
+			"roleName":     name,
+		})
 	}
+	payload := map[string]any{"roles": payloadRoles}
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal payload: %w", err)
@@ -109,18 +120,71 @@ func SetUserRole(ctx context.Context, username, role string) error {
 	return nil
 }
 
+// DatabaseUser is a database user and the roles it holds.
+type DatabaseUser struct {
+	Username string
+	// Roles are "role" for roles on the admin database and "role@db" for any other database.
+	Roles []string
+}
+
+// usersPageSize is the page size used when listing database users.
+const usersPageSize = 500
+
+// ListDatabaseUsers returns every database user in the project with its roles.
+func ListDatabaseUsers(ctx context.Context) ([]DatabaseUser, error) {
+	var users []DatabaseUser
+	for page := 1; ; page++ {
+		resp, _, err := atlasClient.DatabaseUsersApi.
+			ListDatabaseUsers(ctx, projectID).
+			ItemsPerPage(usersPageSize).
+			PageNum(page).
+			Execute()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get database users from Atlas: %w", err)
+		}
+		if resp.Results == nil {
+			break
+		}
+		for _, user := range *resp.Results {
+			u := DatabaseUser{Username: user.Username}
+			if user.Roles != nil {
+				for _, role := range *user.Roles {
+					u.Roles = append(u.Roles, joinRole(role.RoleName, role.DatabaseName))
+				}
+			}
+			users = append(users, u)
+		}
+		if len(*resp.Results) < usersPageSize {
+			break
+		}
+	}
+	return users, nil
+}
+
 // GetDatabaseUsers returns a list of all database users in the project.
 func GetDatabaseUsers(ctx context.Context) ([]string, error) {
-	resp, _, err := atlasClient.DatabaseUsersApi.
-		ListDatabaseUsers(ctx, projectID).
-		Execute()
+	users, err := ListDatabaseUsers(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get database users from Atlas: %w", err)
+		return nil, err
 	}
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return names, nil
+}
 
-	users := make([]string, 0, len(*resp.Results))
-	for _, user := range *resp.Results {
-		users = append(users, user.Username)
+func joinRole(role, db string) string {
+	if db == "" || db == "admin" {
+		return role
 	}
-	return users, nil
+	return role + "@" + db
+}
+
+func splitRole(role string) (name, db string) {
+	name, db, ok := strings.Cut(role, "@")
+	if !ok {
+		return role, "admin"
+	}
+	return name, db
 }
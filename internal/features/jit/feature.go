@@ -23,8 +23,36 @@ func NewFeature() *Feature {
 // RegisterComponents registers JIT workflows and activities
 func (f *Feature) RegisterComponents(registry *worker.Registry, cfg interface{}) error {
 	// Cast config to get the task queue configuration
+	policyFile := ""
+	auditLog := "./jit-audit.log"
+	notifyConfig := ""
+	baselineFile := ""
 	if workerConfig, ok := cfg.(*config.WorkerConfig); ok {
 		f.taskQueue = workerConfig.JITTaskQueue
+		policyFile = workerConfig.JITPolicyFile
+		auditLog = workerConfig.JITAuditLog
+		notifyConfig = workerConfig.JITNotifyConfig
+		baselineFile = workerConfig.JITBaselineFile
+	}
+
+	// Load the access policy; an empty path falls back to the built-in policy
+	if err := jitaccess.InitPolicy(policyFile); err != nil {
+		return fmt.Errorf("failed to load JIT policy: %w", err)
+	}
+
+	// Open the tamper-evident audit log
+	if err := jitaccess.InitAuditLog(auditLog); err != nil {
+		return fmt.Errorf("failed to open JIT audit log: %w", err)
+	}
+
+	// Load notification sinks; an empty path disables notifications
+	if err := jitaccess.InitNotifier(notifyConfig); err != nil {
+		return fmt.Errorf("failed to load JIT notifier config: %w", err)
+	}
+
+	// Load the desired-state baseline for access reconciliation; an empty path disables it
+	if err := jitaccess.InitBaseline(baselineFile); err != nil {
+		return fmt.Errorf("failed to load JIT reconciliation baseline: %w", err)
 	}
 
 	// Initialize the Atlas client (required for JIT activities)
@@ -34,10 +62,21 @@ func (f *Feature) RegisterComponents(registry *worker.Registry, cfg interface{})
 
 	// Register workflows
 	registry.RegisterWorkflow("JITAccessWorkflow", jitaccess.JITAccessWorkflow)
+	registry.RegisterWorkflow("ScheduledJITWorkflow", jitaccess.ScheduledJITWorkflow)
+	registry.RegisterWorkflow("BreakGlassReviewWorkflow", jitaccess.BreakGlassReviewWorkflow)
+	registry.RegisterWorkflow("ReconcileAccessWorkflow", jitaccess.ReconcileAccessWorkflow)
 
 	// Register activities
 	registry.RegisterActivity("GetUserRoleActivity", jitaccess.GetUserRoleActivity)
 	registry.RegisterActivity("SetUserRoleActivity", jitaccess.SetUserRoleActivity)
+	registry.RegisterActivity("LoadPolicyActivity", jitaccess.LoadPolicyActivity)
+	registry.RegisterActivity("RecordAuditEventActivity", jitaccess.RecordAuditEventActivity)
+	registry.RegisterActivity("LoadNotifierSettingsActivity", jitaccess.LoadNotifierSettingsActivity)
+	registry.RegisterActivity("NotifyActivity", jitaccess.NotifyActivity)
+	registry.RegisterActivity("LoadBaselineActivity", jitaccess.LoadBaselineActivity)
+	registry.RegisterActivity("ListDatabaseUsersActivity", jitaccess.ListDatabaseUsersActivity)
+	registry.RegisterActivity("ListActiveGrantsActivity", jitaccess.ListActiveGrantsActivity)
+	registry.RegisterActivity("SetUserRolesActivity", jitaccess.SetUserRolesActivity)
 
 	return nil
 }
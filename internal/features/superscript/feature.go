@@ -1,6 +1,8 @@
 package superscript
 
 import (
+	"fmt"
+
 	"app/internal/superscript"
 	"app/internal/worker"
 	"app/internal/worker/config"
@@ -27,27 +29,98 @@ func NewFeature(logger log.Logger) *Feature {
 // RegisterComponents registers superscript workflows and activities
 func (f *Feature) RegisterComponents(registry *worker.Registry, cfg interface{}) error {
 	// Cast config to get the script base path
-	scriptBasePath := "./internal/superscript/scripts/"
+	scriptBasePath := "./internal/superscript/"
+	catalogueFile := ""
+	sourcesFile := ""
+	batchDir := ""
+	deadLetterDir := ""
+	faultSpec := ""
+	requestFaults, killWorker := false, false
+	var reportConfig superscript.ReportStoreConfig
 	if workerConfig, ok := cfg.(*config.WorkerConfig); ok {
 		f.taskQueue = superscript.SuperscriptTaskQueue
 		scriptBasePath = workerConfig.SuperscriptBasePath
+		catalogueFile = workerConfig.SuperscriptCatalogue
+		sourcesFile = workerConfig.SuperscriptSources
+		batchDir = workerConfig.SuperscriptBatchDir
+		deadLetterDir = workerConfig.SuperscriptDeadLetterDir
+		reportConfig = ReportStoreConfig(workerConfig)
+		faultSpec = workerConfig.SuperscriptFaults
+		requestFaults = workerConfig.SuperscriptFaultsEnabled
+		killWorker = workerConfig.SuperscriptFaultsKillWorker
+	}
+	catalogue, err := superscript.LoadCatalogue(catalogueFile)
+	if err != nil {
+		return fmt.Errorf("failed to load script catalogue: %w", err)
+	}
+	sources, err := superscript.LoadSourceCatalogue(sourcesFile)
+	if err != nil {
+		return fmt.Errorf("failed to load order sources: %w", err)
+	}
+	reports, err := superscript.NewReportStore(reportConfig)
+	if err != nil {
+		return fmt.Errorf("failed to set up the report store: %w", err)
+	}
+	faults, err := superscript.ParseFaultSpec(faultSpec)
+	if err != nil {
+		return fmt.Errorf("invalid SUPERSCRIPT_FAULTS: %w", err)
+	}
+	if faults != nil && faults.KillWorkerPct > 0 && !killWorker {
+		return fmt.Errorf("invalid SUPERSCRIPT_FAULTS: %w", superscript.ErrKillWorkerDisabled)
+	}
+	if faults != nil {
+		f.logger.Warn("Injecting faults into superscript scripts", "faults", faultSpec)
+	}
+	if requestFaults {
+		f.logger.Warn("Accepting faults on superscript requests", "killWorker", killWorker)
 	}
 
 	// Create activities using the proper constructor
 	// Convert temporal logger to slog.Logger (simplified approach)
 	slogLogger := slog.Default()
 	f.activities = superscript.NewActivities(scriptBasePath, *slogLogger)
+	f.activities.Catalogue = catalogue
+	f.activities.Sources = sources
+	f.activities.Batches = superscript.NewBatchStore(batchDir)
+	f.activities.DeadLetters = superscript.NewDeadLetterStore(deadLetterDir)
+	f.activities.Reports = reports
+	f.activities.Faults = faults
+	f.activities.AllowRequestFaults = requestFaults
+	f.activities.AllowKillWorker = killWorker
 
 	// Register workflows
 	registry.RegisterWorkflow("SinglePaymentCollectionWorkflow", superscript.SinglePaymentCollectionWorkflow)
 	registry.RegisterWorkflow("OrchestratorWorkflow", superscript.OrchestratorWorkflow)
+	registry.RegisterWorkflow(superscript.ScriptWorkflowType, superscript.ScriptWorkflow)
+	registry.RegisterWorkflow(superscript.ScheduledOrchestratorWorkflowType, superscript.ScheduledOrchestratorWorkflow)
+	registry.RegisterWorkflow(superscript.RedriveWorkflowType, superscript.RedriveWorkflow)
 
 	// Register activities
 	registry.RegisterActivity("RunPaymentCollectionScript", f.activities.RunPaymentCollectionScript)
+	registry.RegisterActivity("RunScript", f.activities.RunScript)
+	registry.RegisterActivity("StageBatchOrders", f.activities.StageBatchOrders)
+	registry.RegisterActivity("LoadOrderPage", f.activities.LoadOrderPage)
+	registry.RegisterActivity("RecordBatchResults", f.activities.RecordBatchResults)
+	registry.RegisterActivity("DiscoverOrders", f.activities.DiscoverOrders)
+	registry.RegisterActivity("AwaitPaymentResult", f.activities.AwaitPaymentResult)
+	registry.RegisterActivity("SelectRedrives", f.activities.SelectRedrives)
+	registry.RegisterActivity("RecordRedrive", f.activities.RecordRedrive)
+	registry.RegisterActivity("GenerateBatchReport", f.activities.GenerateBatchReport)
 
 	return nil
 }
 
+// ReportStoreConfig returns the batch report store settings of the worker configuration.
+func ReportStoreConfig(cfg *config.WorkerConfig) superscript.ReportStoreConfig {
+	return superscript.ReportStoreConfig{
+		Dir:             cfg.SuperscriptReportDir,
+		S3URL:           cfg.SuperscriptReportS3URL,
+		Region:          cfg.SuperscriptReportS3Region,
+		AccessKeyID:     cfg.AWSAccessKeyID,
+		SecretAccessKey: cfg.AWSSecretAccessKey,
+	}
+}
+
 // GetTaskQueues returns the task queues used by this feature
 func (f *Feature) GetTaskQueues() []string {
 	return []string{f.taskQueue}
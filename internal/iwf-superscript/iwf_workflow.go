@@ -112,7 +112,7 @@ func (s *CollectPaymentState) Execute(ctx iwf.WorkflowContext, input iwf.Object,
 	startTime := time.Now()
 
 	// Execute the payment collection script
-	result, err := s.activities.RunPaymentCollectionScript(context.Background(), params.OrderID)
+	result, err := s.activities.RunPaymentCollectionScript(context.Background(), params.OrderID, nil)
 
 	// Prepare workflow result
 	paymentResult := &superscript.PaymentResult{
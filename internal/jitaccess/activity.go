@@ -6,6 +6,8 @@ import (
 	"log/slog"
 
 	"app/internal/atlas"
+
+	"go.temporal.io/sdk/activity"
 )
 
 // GetUserRoleActivity is an activity that fetches the current role for a user from Atlas.
@@ -30,3 +32,90 @@ func SetUserRoleActivity(ctx context.Context, username string, role string) erro
 	slog.Info("SetUserRoleActivity completed", "username", username, "role", role)
 	return nil
 }
+
+// LoadPolicyActivity is an activity that loads the current JIT policy.
+// Loading through an activity records the policy (and its version) in workflow history.
+func LoadPolicyActivity(ctx context.Context) (*Policy, error) {
+	policy, err := CurrentPolicy()
+	if err != nil {
+		slog.Error("LoadPolicyActivity failed", "error", err)
+		return nil, err
+	}
+	slog.Info("LoadPolicyActivity completed", "version", policy.Version)
+	return policy, nil
+}
+
+// RecordAuditEventActivity is an activity that appends an event to the JIT audit log.
+func RecordAuditEventActivity(ctx context.Context, evt AuditEvent) error {
+	log := CurrentAuditLog()
+	if log == nil {
+		return fmt.Errorf("audit log is not initialized")
+	}
+	if _, err := log.Append(evt); err != nil {
+		slog.Error("RecordAuditEventActivity failed", "event", evt.Type, "username", evt.Username, "error", err)
+		return err
+	}
+	slog.Info("RecordAuditEventActivity completed", "event", evt.Type, "username", evt.Username, "id", evt.ID)
+	return nil
+}
+
+// LoadNotifierSettingsActivity is an activity that returns the configured notification sinks.
+func LoadNotifierSettingsActivity(ctx context.Context) (NotifierSettings, error) {
+	settings := CurrentNotifier().Settings()
+	slog.Info("LoadNotifierSettingsActivity completed", "sinks", len(settings.Sinks))
+	return settings, nil
+}
+
+// NotifyActivity is an activity that delivers a notification to a single sink.
+// Each sink runs in its own activity so it retries on its own schedule.
+func NotifyActivity(ctx context.Context, sinkName string, n Notification) error {
+	if err := CurrentNotifier().Send(ctx, sinkName, n); err != nil {
+		slog.Error("NotifyActivity failed", "sink", sinkName, "event", n.Event, "username", n.Username, "error", err)
+		return err
+	}
+	slog.Info("NotifyActivity completed", "sink", sinkName, "event", n.Event, "username", n.Username)
+	return nil
+}
+
+// LoadBaselineActivity is an activity that loads the reconciliation baseline.
+func LoadBaselineActivity(ctx context.Context) (*Baseline, error) {
+	baseline, err := CurrentBaseline()
+	if err != nil {
+		slog.Error("LoadBaselineActivity failed", "error", err)
+		return nil, err
+	}
+	slog.Info("LoadBaselineActivity completed", "version", baseline.Version, "users", len(baseline.Users))
+	return baseline, nil
+}
+
+// ListDatabaseUsersActivity is an activity that lists every Atlas database user with its roles.
+func ListDatabaseUsersActivity(ctx context.Context) ([]atlas.DatabaseUser, error) {
+	users, err := atlas.ListDatabaseUsers(ctx)
+	if err != nil {
+		slog.Error("ListDatabaseUsersActivity failed", "error", err)
+		return nil, err
+	}
+	slog.Info("ListDatabaseUsersActivity completed", "users", len(users))
+	return users, nil
+}
+
+// SetUserRolesActivity is an activity that replaces all roles of a user in Atlas.
+func SetUserRolesActivity(ctx context.Context, username string, roles []string) error {
+	if err := atlas.SetUserRoles(ctx, username, roles); err != nil {
+		slog.Error("SetUserRolesActivity failed", "username", username, "roles", roles, "error", err)
+		return fmt.Errorf("failed to set roles: %w", err)
+	}
+	slog.Info("SetUserRolesActivity completed", "username", username, "roles", roles)
+	return nil
+}
+
+// ListActiveGrantsActivity is an activity that returns the status of every running JIT grant.
+func ListActiveGrantsActivity(ctx context.Context) ([]GrantStatus, error) {
+	grants, err := ListRunningGrants(ctx, activity.GetClient(ctx))
+	if err != nil {
+		slog.Error("ListActiveGrantsActivity failed", "error", err)
+		return nil, err
+	}
+	slog.Info("ListActiveGrantsActivity completed", "grants", len(grants))
+	return grants, nil
+}
@@ -0,0 +1,351 @@
+package jitaccess
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audit event types emitted by JITAccessWorkflow.
+const (
+	AuditRequested     = "requested"
+	AuditApproved      = "approved"
+	AuditDenied        = "denied"
+	AuditGranted       = "granted"
+	AuditExtended      = "extended"
+	AuditRevoked       = "revoked"
+	AuditReverted      = "reverted"
+	AuditRevertFailed  = "revert_failed"
+	AuditDriftDetected = "drift_detected"
+)
+
+// AuditEvent is a single structured JIT access event.
+// ID is derived from the workflow run so activity retries do not duplicate events.
+type AuditEvent struct {
+	ID            string            `json:"id"`
+	Type          string            `json:"type"`
+	Username      string            `json:"username"`
+	Role          string            `json:"role,omitempty"`
+	Actor         string            `json:"actor,omitempty"`
+	Reason        string            `json:"reason,omitempty"`
+	PolicyVersion string            `json:"policy_version,omitempty"`
+	WorkflowID    string            `json:"workflow_id"`
+	RunID         string            `json:"run_id"`
+	Time          time.Time         `json:"time"`
+	Details       map[string]string `json:"details,omitempty"`
+}
+
+// AuditRecord is an AuditEvent as stored in the log, chained to the previous record by hash.
+type AuditRecord struct {
+	Seq        int64      `json:"seq"`
+	RecordedAt time.Time  `json:"recorded_at"`
+	Event      AuditEvent `json:"event"`
+	PrevHash   string     `json:"prev_hash"`
+	Hash       string     `json:"hash"`
+}
+
+// computeHash hashes everything in the record except the Hash field itself.
+func (r AuditRecord) computeHash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditLog is an append-only, hash-chained JSON-lines audit log stored in a local file.
+// Each record carries the hash of its predecessor, so editing, removing or reordering
+// any record breaks the chain and is reported by VerifyAuditLog. The seq and hash of the last
+// record are also kept in a head file next to the log (AuditHeadPath), so cutting records off
+// the end of the log is reported too.
+// A log file should be owned by a single process.
+type AuditLog struct {
+	mu       sync.Mutex
+	path     string
+	seq      int64
+	lastHash string
+	seen     map[string]bool
+}
+
+// auditHead anchors the last record of a log outside of it.
+type auditHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// AuditHeadPath returns the head file of the audit log at path.
+func AuditHeadPath(path string) string {
+	return path + ".head"
+}
+
+// OpenAuditLog opens (or creates) the audit log at path for writing and resumes its hash
+// chain. A last record whose write was cut short, e.g. by a crash, is removed first; a log
+// that VerifyAuditLog would reject is not opened.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	l := &AuditLog{path: path, seen: make(map[string]bool)}
+	if err := trimTornRecord(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	records, err := readAuditRecords(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	head, err := readAuditHead(path)
+	if err != nil {
+		return nil, err
+	}
+	if count, err := verifyAuditRecords(records, head); err != nil {
+		return nil, fmt.Errorf("audit log is not intact after %d valid records: %w", count, err)
+	}
+	for _, rec := range records {
+		l.seq = rec.Seq
+		l.lastHash = rec.Hash
+		l.seen[rec.Event.ID] = true
+	}
+	// Adopt logs written before the head file, and records appended after its last update.
+	if len(records) > 0 && (head == nil || head.Seq != l.seq) {
+		if err := writeAuditHead(path, auditHead{Seq: l.seq, Hash: l.lastHash}); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Append adds an event to the log. Events whose ID was already recorded are ignored.
+func (l *AuditLog) Append(evt AuditEvent) (*AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if evt.ID != "" && l.seen[evt.ID] {
+		return nil, nil
+	}
+	rec := AuditRecord{
+		Seq:        l.seq + 1,
+		RecordedAt: time.Now().UTC(),
+		Event:      evt,
+		PrevHash:   l.lastHash,
+	}
+	hash, err := rec.computeHash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash audit record: %w", err)
+	}
+	rec.Hash = hash
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		// Do not leave part of a record for the next one to be appended to.
+		_ = f.Truncate(info.Size())
+		return nil, fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	l.seq = rec.Seq
+	l.lastHash = rec.Hash
+	if evt.ID != "" {
+		l.seen[evt.ID] = true
+	}
+	if err := writeAuditHead(l.path, auditHead{Seq: rec.Seq, Hash: rec.Hash}); err != nil {
+		return &rec, err
+	}
+	return &rec, nil
+}
+
+// Records returns the records for username, or all records if username is empty.
+func (l *AuditLog) Records(username string) ([]AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return ReadAuditRecords(l.path, username)
+}
+
+// Path returns the file backing the log.
+func (l *AuditLog) Path() string {
+	return l.path
+}
+
+// ReadAuditRecords returns the records of the log at path for username, or all records if
+// username is empty, without opening the log for writing. A missing log has no records.
+func ReadAuditRecords(path, username string) ([]AuditRecord, error) {
+	records, err := readAuditRecords(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if username == "" {
+		return records, nil
+	}
+	filtered := make([]AuditRecord, 0, len(records))
+	for _, rec := range records {
+		if rec.Event.Username == username {
+			filtered = append(filtered, rec)
+		}
+	}
+	return filtered, nil
+}
+
+// VerifyAuditLog checks the sequence numbers and hash chain of the log at path, and that
+// it still holds the record of its head file.
+// It returns the number of valid records, and an error describing the first break.
+func VerifyAuditLog(path string) (int, error) {
+	records, err := readAuditRecords(path)
+	if err != nil {
+		return 0, err
+	}
+	head, err := readAuditHead(path)
+	if err != nil {
+		return 0, err
+	}
+	return verifyAuditRecords(records, head)
+}
+
+func verifyAuditRecords(records []AuditRecord, head *auditHead) (int, error) {
+	prevHash := ""
+	for i, rec := range records {
+		if rec.Seq != int64(i+1) {
+			return i, fmt.Errorf("record %d: expected seq %d, found %d", i+1, i+1, rec.Seq)
+		}
+		if rec.PrevHash != prevHash {
+			return i, fmt.Errorf("record %d: prev_hash does not match the previous record", rec.Seq)
+		}
+		hash, err := rec.computeHash()
+		if err != nil {
+			return i, err
+		}
+		if hash != rec.Hash {
+			return i, fmt.Errorf("record %d: hash mismatch, record was modified", rec.Seq)
+		}
+		prevHash = rec.Hash
+	}
+	// Records appended after the head was last written are fine; missing ones are not.
+	if head != nil && head.Seq > 0 {
+		if int64(len(records)) < head.Seq {
+			return len(records), fmt.Errorf("log ends at record %d, but its head is record %d", len(records), head.Seq)
+		}
+		if records[head.Seq-1].Hash != head.Hash {
+			return int(head.Seq - 1), fmt.Errorf("record %d: hash does not match the head", head.Seq)
+		}
+	}
+	return len(records), nil
+}
+
+// readAuditRecords reads the records of the log at path. A last line without a newline is
+// a record whose write did not complete; it is not part of the log.
+func readAuditRecords(path string) ([]AuditRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = data[:completeLength(data)]
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var records []AuditRecord
+	for i, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+		var rec AuditRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return records, fmt.Errorf("line %d: malformed audit record: %w", i+1, err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// completeLength returns the length of the newline-terminated lines at the start of data.
+func completeLength(data []byte) int {
+	return bytes.LastIndexByte(data, '\n') + 1
+}
+
+// trimTornRecord removes a last line without a newline from the log at path.
+func trimTornRecord(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if n := completeLength(data); n < len(data) {
+		if err := os.Truncate(path, int64(n)); err != nil {
+			return fmt.Errorf("failed to trim torn audit record: %w", err)
+		}
+	}
+	return nil
+}
+
+func readAuditHead(path string) (*auditHead, error) {
+	data, err := os.ReadFile(AuditHeadPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var head auditHead
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, fmt.Errorf("malformed audit log head: %w", err)
+	}
+	return &head, nil
+}
+
+// writeAuditHead replaces the head file of the log at path, so it is never seen half written.
+func writeAuditHead(path string, head auditHead) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	tmp := AuditHeadPath(path) + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write audit log head: %w", err)
+	}
+	if err := os.Rename(tmp, AuditHeadPath(path)); err != nil {
+		return fmt.Errorf("failed to write audit log head: %w", err)
+	}
+	return nil
+}
+
+var (
+	auditMu  sync.RWMutex
+	auditLog *AuditLog
+)
+
+// InitAuditLog opens the audit log used by RecordAuditEventActivity.
+func InitAuditLog(path string) error {
+	if path == "" {
+		return fmt.Errorf("audit log path is required")
+	}
+	l, err := OpenAuditLog(path)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditLog = l
+	return nil
+}
+
+// CurrentAuditLog returns the log opened by InitAuditLog, or nil if none is open.
+func CurrentAuditLog() *AuditLog {
+	auditMu.RLock()
+	defer auditMu.RUnlock()
+	return auditLog
+}
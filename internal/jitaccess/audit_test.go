@@ -0,0 +1,143 @@
+package jitaccess_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"app/internal/jitaccess"
+
+	"github.com/stretchr/testify/require"
+)
+
+func appendTestEvents(t *testing.T, auditLog *jitaccess.AuditLog) {
+	t.Helper()
+	events := []jitaccess.AuditEvent{
+		{ID: "wf/run/1", Type: jitaccess.AuditRequested, Username: "alice", Role: "readAnyDatabase", Reason: "debugging prod issue"},
+		{ID: "wf/run/2", Type: jitaccess.AuditGranted, Username: "alice", Role: "readAnyDatabase"},
+		{ID: "wf2/run/1", Type: jitaccess.AuditRequested, Username: "bob", Role: "readAnyDatabase"},
+		{ID: "wf/run/3", Type: jitaccess.AuditReverted, Username: "alice", Role: "readAnyDatabase"},
+	}
+	for _, evt := range events {
+		evt.Time = time.Date(2025, 6, 4, 10, 0, 0, 0, time.UTC)
+		_, err := auditLog.Append(evt)
+		require.NoError(t, err)
+	}
+}
+
+func TestAuditLog_AppendVerifyAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := jitaccess.OpenAuditLog(path)
+	require.NoError(t, err)
+	appendTestEvents(t, auditLog)
+
+	// A retried activity must not duplicate an event.
+	rec, err := auditLog.Append(jitaccess.AuditEvent{ID: "wf/run/2", Type: jitaccess.AuditGranted, Username: "alice"})
+	require.NoError(t, err)
+	require.Nil(t, rec)
+
+	count, err := jitaccess.VerifyAuditLog(path)
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+
+	alice, err := auditLog.Records("alice")
+	require.NoError(t, err)
+	require.Len(t, alice, 3)
+	require.Equal(t, jitaccess.AuditReverted, alice[2].Event.Type)
+
+	// Reopening resumes the chain rather than starting a new one.
+	reopened, err := jitaccess.OpenAuditLog(path)
+	require.NoError(t, err)
+	rec, err = reopened.Append(jitaccess.AuditEvent{ID: "wf3/run/1", Type: jitaccess.AuditRequested, Username: "carol"})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), rec.Seq)
+	require.Equal(t, alice[2].Hash, rec.PrevHash)
+
+	count, err = jitaccess.VerifyAuditLog(path)
+	require.NoError(t, err)
+	require.Equal(t, 5, count)
+}
+
+func TestAuditLog_DetectsTampering(t *testing.T) {
+	tests := map[string]func(lines []string) []string{
+		"edited record": func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `"username":"alice"`, `"username":"mallory"`, 1)
+			return lines
+		},
+		"removed record": func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		},
+		"reordered records": func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		},
+	}
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			auditLog, err := jitaccess.OpenAuditLog(path)
+			require.NoError(t, err)
+			appendTestEvents(t, auditLog)
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			lines = tamper(lines)
+			require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+			count, err := jitaccess.VerifyAuditLog(path)
+			require.Error(t, err)
+			require.Equal(t, 1, count)
+			_, err = jitaccess.OpenAuditLog(path)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAuditLog_DetectsTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := jitaccess.OpenAuditLog(path)
+	require.NoError(t, err)
+	appendTestEvents(t, auditLog)
+
+	// Cutting the last record off leaves a valid chain, but not the record of the head.
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines[:3], "\n")+"\n"), 0o600))
+
+	count, err := jitaccess.VerifyAuditLog(path)
+	require.ErrorContains(t, err, "head is record 4")
+	require.Equal(t, 3, count)
+	_, err = jitaccess.OpenAuditLog(path)
+	require.ErrorContains(t, err, "not intact")
+}
+
+func TestAuditLog_TornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := jitaccess.OpenAuditLog(path)
+	require.NoError(t, err)
+	appendTestEvents(t, auditLog)
+
+	// A crash in the middle of a write leaves part of a record behind.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":5,"recorded_at":"2025-06`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	records, err := jitaccess.ReadAuditRecords(path, "")
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	reopened, err := jitaccess.OpenAuditLog(path)
+	require.NoError(t, err)
+	rec, err := reopened.Append(jitaccess.AuditEvent{ID: "wf3/run/1", Type: jitaccess.AuditRequested, Username: "carol"})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), rec.Seq)
+	count, err := jitaccess.VerifyAuditLog(path)
+	require.NoError(t, err)
+	require.Equal(t, 5, count)
+}
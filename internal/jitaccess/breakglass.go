@@ -0,0 +1,187 @@
+package jitaccess
+
+import (
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ReviewAckSignal acknowledges a post-incident review.
+const ReviewAckSignal = "review_ack"
+
+// Audit event types of the post-incident review.
+const (
+	AuditReviewOpened       = "review_opened"
+	AuditReviewAcknowledged = "review_acknowledged"
+)
+
+// Review states reported through StatusQuery on a BreakGlassReviewWorkflow.
+const (
+	ReviewStateOpen         = "open"
+	ReviewStateAcknowledged = "acknowledged"
+)
+
+// BreakGlassReviewID returns the workflow ID of the review opened by a break-glass grant run.
+func BreakGlassReviewID(username, grantRunID string) string {
+	return "jit_review_" + username + "_" + grantRunID
+}
+
+// BreakGlassReview is the input of BreakGlassReviewWorkflow.
+type BreakGlassReview struct {
+	Request          JITAccessRequest
+	GrantWorkflowID  string
+	PolicyVersion    string
+	OriginalRole     string
+	GrantedAt        time.Time
+	RevertedAt       time.Time
+	RevokedBy        string
+	ReminderInterval time.Duration
+	// RevertError is set when the role could not be reverted; RevertedAt is then zero.
+	RevertError string
+	// Policy decides who may acknowledge the review. Reviews opened without one accept any
+	// independent reviewer.
+	Policy *Policy
+}
+
+// ReviewAcknowledgement is the payload of ReviewAckSignal.
+type ReviewAcknowledgement struct {
+	Reviewer string
+	Notes    string
+}
+
+// ReviewStatus describes a post-incident review.
+type ReviewStatus struct {
+	Username        string
+	Requester       string
+	Role            string
+	IncidentRef     string
+	GrantWorkflowID string
+	GrantedAt       time.Time
+	RevertedAt      time.Time
+	RevertError     string
+	State           string
+	Reminders       int
+	Reviewer        string
+	Notes           string
+}
+
+// openReview starts the post-incident review of a break-glass grant as an abandoned child,
+// so the user's JIT workflow ID is freed for new requests while the review stays open.
+func openReview(ctx workflow.Context, review BreakGlassReview) (string, error) {
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:        BreakGlassReviewID(review.Request.Username, workflow.GetInfo(ctx).WorkflowExecution.RunID),
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_ABANDON,
+	})
+	var execution workflow.Execution
+	child := workflow.ExecuteChildWorkflow(childCtx, BreakGlassReviewWorkflow, review)
+	if err := child.GetChildWorkflowExecution().Get(ctx, &execution); err != nil {
+		return "", err
+	}
+	return execution.ID, nil
+}
+
+// BreakGlassReviewWorkflow is the post-incident review task of a break-glass grant.
+// It stays open, re-announcing itself every ReminderInterval, until an approver of the role or
+// a principal allowed to request on behalf of others, other than the user or the requester,
+// acknowledges it with ReviewAckSignal.
+func BreakGlassReviewWorkflow(ctx workflow.Context, review BreakGlassReview) (ReviewStatus, error) {
+	logger := workflow.GetLogger(ctx)
+	req := review.Request
+	logger.Info("Opening post-incident review", "username", req.Username, "incident_ref", req.IncidentRef)
+
+	status := ReviewStatus{
+		Username:        req.Username,
+		Requester:       req.RequestedBy(),
+		Role:            req.NewRole,
+		IncidentRef:     req.IncidentRef,
+		GrantWorkflowID: review.GrantWorkflowID,
+		GrantedAt:       review.GrantedAt,
+		RevertedAt:      review.RevertedAt,
+		RevertError:     review.RevertError,
+		State:           ReviewStateOpen,
+	}
+	if err := workflow.SetQueryHandler(ctx, StatusQuery, func() (ReviewStatus, error) {
+		return status, nil
+	}); err != nil {
+		return status, err
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    5 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    1 * time.Minute,
+			MaximumAttempts:    5,
+		},
+	})
+	audit := &auditor{req: req, policyVersion: review.PolicyVersion}
+	notify := &notifier{req: req}
+	if err := workflow.ExecuteActivity(ctx, LoadNotifierSettingsActivity).Get(ctx, &notify.settings); err != nil {
+		logger.Warn("failed to load notifier settings, notifications disabled", "error", err)
+	}
+	grant := GrantStatus{
+		Username:     req.Username,
+		Requester:    req.RequestedBy(),
+		NewRole:      req.NewRole,
+		OriginalRole: review.OriginalRole,
+		BreakGlass:   true,
+		IncidentRef:  req.IncidentRef,
+		RevokedBy:    review.RevokedBy,
+	}
+
+	_ = audit.record(ctx, AuditReviewOpened, "system", "", map[string]string{
+		"incident_ref":      req.IncidentRef,
+		"grant_workflow_id": review.GrantWorkflowID,
+	})
+	notify.send(ctx, NotifyReviewPending, "system", grant)
+
+	interval := review.ReminderInterval
+	if interval <= 0 {
+		interval = defaultReviewReminder
+	}
+	acks := workflow.GetSignalChannel(ctx, ReviewAckSignal)
+	for status.State == ReviewStateOpen {
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, interval)
+
+		var ack *ReviewAcknowledgement
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(timer, func(f workflow.Future) {})
+		selector.AddReceive(acks, func(c workflow.ReceiveChannel, more bool) {
+			ack = &ReviewAcknowledgement{}
+			c.Receive(ctx, ack)
+		})
+		selector.Select(ctx)
+		cancelTimer()
+
+		switch {
+		case ack == nil:
+			status.Reminders++
+			logger.Info("Post-incident review still open", "reminders", status.Reminders)
+			notify.send(ctx, NotifyReviewPending, "system", grant)
+		case ack.Reviewer == "" || ack.Reviewer == req.Username || ack.Reviewer == req.RequestedBy():
+			logger.Warn("Ignoring review acknowledgement without an independent reviewer", "reviewer", ack.Reviewer)
+		case review.Policy != nil && !review.Policy.MayReview(ack.Reviewer, req.Username, req.RequestedBy(), req.NewRole):
+			logger.Warn("Ignoring review acknowledgement from a principal who may not review the role", "reviewer", ack.Reviewer, "role", req.NewRole)
+		case ack.Notes == "":
+			logger.Warn("Ignoring review acknowledgement without notes", "reviewer", ack.Reviewer)
+		default:
+			status.State = ReviewStateAcknowledged
+			status.Reviewer = ack.Reviewer
+			status.Notes = ack.Notes
+		}
+	}
+
+	logger.Info("Post-incident review acknowledged", "reviewer", status.Reviewer)
+	_ = audit.record(ctx, AuditReviewAcknowledged, status.Reviewer, status.Notes, map[string]string{
+		"incident_ref": req.IncidentRef,
+	})
+	notify.send(ctx, NotifyReviewAcknowledged, status.Reviewer, grant)
+	if err := workflow.Await(ctx, func() bool { return notify.pending == 0 }); err != nil {
+		return status, err
+	}
+	return status, nil
+}
@@ -0,0 +1,232 @@
+package jitaccess_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"app/internal/jitaccess"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// breakGlassPolicy is testPolicy with break-glass enabled for approvalRole.
+func breakGlassPolicy() *jitaccess.Policy {
+	policy := testPolicy()
+	policy.BreakGlass = &jitaccess.BreakGlassPolicy{
+		Principals:     []string{"*"},
+		Roles:          []string{"approvalRole"},
+		MaxDuration:    jitaccess.Duration(30 * time.Minute),
+		ReviewReminder: jitaccess.Duration(12 * time.Hour),
+	}
+	return policy
+}
+
+func TestJITAccessWorkflow_BreakGlass(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(breakGlassPolicy(), nil)
+	events := recordAuditEvents(env)
+	// The sink only subscribes to reverts, but critical notifications reach it anyway.
+	notifications := recordNotifications(env, jitaccess.NotifierSettings{
+		Sinks: []jitaccess.SinkSettings{{Name: "pager", Events: []string{jitaccess.NotifyReverted}}},
+	})
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("approvalRole", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", mock.AnythingOfType("string")).Return(nil)
+
+	var review jitaccess.BreakGlassReview
+	env.RegisterWorkflow(jitaccess.BreakGlassReviewWorkflow)
+	env.OnWorkflow(jitaccess.BreakGlassReviewWorkflow, mock.Anything, mock.Anything).Return(
+		func(ctx workflow.Context, r jitaccess.BreakGlassReview) (jitaccess.ReviewStatus, error) {
+			review = r
+			return jitaccess.ReviewStatus{State: jitaccess.ReviewStateAcknowledged}, nil
+		})
+
+	// No approval is needed, and an extension cannot push the grant past the 30 minute cap.
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(jitaccess.ExtendSignal, jitaccess.ExtendRequest{Actor: "testuser", Duration: time.Hour})
+	}, 5*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(jitaccess.StatusQuery)
+		require.NoError(t, err)
+		var status jitaccess.GrantStatus
+		require.NoError(t, value.Get(&status))
+		require.Equal(t, jitaccess.StateActive, status.State)
+		require.True(t, status.BreakGlass)
+		require.Equal(t, "INC-4711", status.IncidentRef)
+	}, 6*time.Minute)
+
+	start := env.Now()
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username:    "testuser",
+		Reason:      "primary is down",
+		NewRole:     "approvalRole",
+		Duration:    10 * time.Minute,
+		BreakGlass:  true,
+		IncidentRef: "INC-4711",
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, []string{
+		jitaccess.AuditRequested, jitaccess.AuditGranted, jitaccess.AuditExtended, jitaccess.AuditReverted,
+	}, auditTypes(*events))
+	require.Equal(t, "INC-4711", (*events)[0].Details["incident_ref"])
+
+	require.Len(t, *notifications, 2)
+	require.Equal(t, jitaccess.NotifyBreakGlass, (*notifications)[0].Event)
+	require.Equal(t, jitaccess.NotifyReverted, (*notifications)[1].Event)
+	for _, n := range *notifications {
+		require.Equal(t, jitaccess.SeverityCritical, n.Severity)
+		require.Equal(t, "INC-4711", n.IncidentRef)
+	}
+
+	require.Equal(t, "INC-4711", review.Request.IncidentRef)
+	require.Equal(t, "originalRole", review.OriginalRole)
+	require.Equal(t, 12*time.Hour, review.ReminderInterval)
+	require.WithinDuration(t, start.Add(30*time.Minute), review.RevertedAt, time.Second)
+}
+
+func TestJITAccessWorkflow_BreakGlassRevertFailed(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(breakGlassPolicy(), nil)
+	events := recordAuditEvents(env)
+	notifications := recordNotifications(env, jitaccess.NotifierSettings{
+		Sinks: []jitaccess.SinkSettings{{Name: "pager", Events: []string{jitaccess.NotifyReverted}}},
+	})
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("approvalRole", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", "approvalRole").Return(nil)
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", "originalRole").Return(errors.New("atlas unavailable"))
+
+	var review jitaccess.BreakGlassReview
+	env.RegisterWorkflow(jitaccess.BreakGlassReviewWorkflow)
+	env.OnWorkflow(jitaccess.BreakGlassReviewWorkflow, mock.Anything, mock.Anything).Return(
+		func(ctx workflow.Context, r jitaccess.BreakGlassReview) (jitaccess.ReviewStatus, error) {
+			review = r
+			return jitaccess.ReviewStatus{State: jitaccess.ReviewStateAcknowledged}, nil
+		})
+
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username:    "testuser",
+		Reason:      "primary is down",
+		NewRole:     "approvalRole",
+		Duration:    10 * time.Minute,
+		BreakGlass:  true,
+		IncidentRef: "INC-4711",
+	})
+
+	// The user still has the role: the failure is announced and reviewed, then the grant fails.
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "atlas unavailable")
+	require.Equal(t, []string{
+		jitaccess.AuditRequested, jitaccess.AuditGranted, jitaccess.AuditRevertFailed,
+	}, auditTypes(*events))
+	require.Len(t, *notifications, 2)
+	require.Equal(t, jitaccess.NotifyRevertFailed, (*notifications)[1].Event)
+	require.Equal(t, jitaccess.SeverityCritical, (*notifications)[1].Severity)
+	require.Equal(t, "INC-4711", review.Request.IncidentRef)
+	require.Contains(t, review.RevertError, "atlas unavailable")
+	require.True(t, review.RevertedAt.IsZero())
+}
+
+func TestJITAccessWorkflow_BreakGlassNotMerged(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(breakGlassPolicy(), nil)
+	recordAuditEvents(env)
+	recordNotifications(env, jitaccess.NotifierSettings{})
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("approvalRole", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", mock.AnythingOfType("string")).Return(nil)
+
+	var conflict error
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(jitaccess.MergeRequestUpdate, "break-glass", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { require.Fail(t, "break-glass request merged") },
+			OnReject:   func(err error) { conflict = err },
+			OnComplete: func(interface{}, error) {},
+		}, jitaccess.JITAccessRequest{Username: "testuser", Reason: "primary is down", NewRole: "approvalRole", Duration: time.Minute, BreakGlass: true, IncidentRef: "INC-1"})
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(jitaccess.ApprovalSignal, jitaccess.ApprovalDecision{Approved: true, Approver: "approver"})
+	}, 2*time.Minute)
+
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username: "testuser",
+		Reason:   "testing",
+		NewRole:  "approvalRole",
+		Duration: time.Minute,
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.ErrorContains(t, conflict, "break-glass requests cannot be merged")
+}
+
+func TestBreakGlassReviewWorkflow(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	events := recordAuditEvents(env)
+	notifications := recordNotifications(env, jitaccess.NotifierSettings{
+		Sinks: []jitaccess.SinkSettings{{Name: "pager"}},
+	})
+
+	// The user and the requester cannot close their own review, only the role's approvers and
+	// delegates may, and notes are required.
+	policy := breakGlassPolicy()
+	policy.OnBehalfOf = []string{"delegate", "sre-lead"}
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(jitaccess.ReviewAckSignal, jitaccess.ReviewAcknowledgement{Reviewer: "testuser", Notes: "all good"})
+		env.SignalWorkflow(jitaccess.ReviewAckSignal, jitaccess.ReviewAcknowledgement{Reviewer: "delegate", Notes: "all good"})
+		env.SignalWorkflow(jitaccess.ReviewAckSignal, jitaccess.ReviewAcknowledgement{Reviewer: "bystander", Notes: "looks fine to me"})
+		env.SignalWorkflow(jitaccess.ReviewAckSignal, jitaccess.ReviewAcknowledgement{Reviewer: "sre-lead"})
+	}, time.Hour)
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(jitaccess.StatusQuery)
+		require.NoError(t, err)
+		var status jitaccess.ReviewStatus
+		require.NoError(t, value.Get(&status))
+		require.Equal(t, jitaccess.ReviewStateOpen, status.State)
+		require.Equal(t, 2, status.Reminders)
+
+		env.SignalWorkflow(jitaccess.ReviewAckSignal, jitaccess.ReviewAcknowledgement{Reviewer: "sre-lead", Notes: "failover runbook updated"})
+	}, 50*time.Hour)
+
+	env.ExecuteWorkflow(jitaccess.BreakGlassReviewWorkflow, jitaccess.BreakGlassReview{
+		Request: jitaccess.JITAccessRequest{
+			Username: "testuser", Requester: "delegate", Reason: "primary is down", NewRole: "approvalRole",
+			Duration: 10 * time.Minute, BreakGlass: true, IncidentRef: "INC-4711",
+		},
+		GrantWorkflowID:  jitaccess.JITWorkflowID("testuser"),
+		ReminderInterval: 24 * time.Hour,
+		Policy:           policy,
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var status jitaccess.ReviewStatus
+	require.NoError(t, env.GetWorkflowResult(&status))
+	require.Equal(t, jitaccess.ReviewStateAcknowledged, status.State)
+	require.Equal(t, "sre-lead", status.Reviewer)
+
+	require.Equal(t, []string{jitaccess.AuditReviewOpened, jitaccess.AuditReviewAcknowledged}, auditTypes(*events))
+	require.Equal(t, "failover runbook updated", (*events)[1].Reason)
+	var sent []string
+	for _, n := range *notifications {
+		sent = append(sent, n.Event)
+	}
+	require.Equal(t, []string{
+		jitaccess.NotifyReviewPending, jitaccess.NotifyReviewPending, jitaccess.NotifyReviewPending,
+		jitaccess.NotifyReviewAcknowledged,
+	}, sent)
+}
@@ -0,0 +1,165 @@
+package jitaccess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// startOrMergeAttempts bounds how often StartOrMergeGrant races a grant that is starting up or closing.
+const startOrMergeAttempts = 3
+
+// runningGrantsQuery selects the JIT workflows that may hold, or be about to hold, a grant.
+const runningGrantsQuery = "WorkflowType = 'JITAccessWorkflow' AND ExecutionStatus = 'Running'"
+
+// ErrGrantBusy means the user's grant kept starting up or closing while a request was submitted.
+var ErrGrantBusy = errors.New("grant is busy, retry later")
+
+// errNoRunningGrant means the user has no JIT workflow running.
+var errNoRunningGrant = errors.New("no running grant")
+
+// GrantStart describes the workflow that ended up handling a request.
+type GrantStart struct {
+	WorkflowID string
+	RunID      string
+	Merged     bool
+	// Grant is the status of the running grant a request was merged into.
+	Grant GrantStatus
+}
+
+// StartOrMergeGrant serializes grants per user. Every grant for a user uses the same
+// workflow ID, so an overlapping request is merged into the running workflow through
+// an update, or rejected explicitly, instead of starting a second interleaved grant.
+// beforeStart, if set, runs only when a new workflow is about to be started and may reject it.
+// Rejected merges return the update's application error (GrantConflictError or "PolicyViolation").
+func StartOrMergeGrant(ctx context.Context, c client.Client, taskQueue string, req JITAccessRequest, beforeStart func(context.Context) error) (*GrantStart, error) {
+	workflowID := JITWorkflowID(req.Username)
+
+	for attempt := 1; attempt <= startOrMergeAttempts; attempt++ {
+		grant, err := mergeIntoRunningGrant(ctx, c, workflowID, req)
+		switch {
+		case err == nil:
+			return &GrantStart{WorkflowID: workflowID, Merged: true, Grant: grant}, nil
+		case errors.Is(err, errNoRunningGrant):
+			// Nothing to merge into; start a new grant below.
+		case IsApplicationError(err, GrantBusyError):
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+			continue
+		default:
+			return nil, err
+		}
+
+		if beforeStart != nil {
+			if err := beforeStart(ctx); err != nil {
+				return nil, err
+			}
+		}
+		options := client.StartWorkflowOptions{
+			ID:                                       workflowID,
+			TaskQueue:                                taskQueue,
+			WorkflowIDReusePolicy:                    enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+			WorkflowIDConflictPolicy:                 enumspb.WORKFLOW_ID_CONFLICT_POLICY_FAIL,
+			WorkflowExecutionErrorWhenAlreadyStarted: true,
+		}
+		// The grant outlives the caller's request, so it is not started with the caller's context.
+		we, err := c.ExecuteWorkflow(context.Background(), options, JITAccessWorkflow, req)
+		if err == nil {
+			return &GrantStart{WorkflowID: we.GetID(), RunID: we.GetRunID()}, nil
+		}
+		if !temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			return nil, err
+		}
+		// Another request won the race; merge into it on the next attempt.
+	}
+	return nil, fmt.Errorf("grant for %s: %w", req.Username, ErrGrantBusy)
+}
+
+// mergeIntoRunningGrant sends the request to the user's running workflow as an update.
+func mergeIntoRunningGrant(ctx context.Context, c client.Client, workflowID string, req JITAccessRequest) (GrantStatus, error) {
+	var grant GrantStatus
+	handle, err := c.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   workflowID,
+		UpdateName:   MergeRequestUpdate,
+		Args:         []interface{}{req},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			return grant, errNoRunningGrant
+		}
+		return grant, err
+	}
+	err = handle.Get(ctx, &grant)
+	return grant, err
+}
+
+// IsApplicationError reports whether err wraps a Temporal application error of the given type.
+func IsApplicationError(err error, errType string) bool {
+	var appErr *temporal.ApplicationError
+	return errors.As(err, &appErr) && appErr.Type() == errType
+}
+
+// legacyWorkflowID matches the workflow IDs of grants started before grants were serialized per
+// user: jit_access_<username>_<unix time>.
+var legacyWorkflowID = regexp.MustCompile(`^jit_access_(.+)_[0-9]+$`)
+
+// ListRunningGrants returns the status of every running JIT grant. Grants started before the
+// policy engine cannot be queried; they are returned in StateLegacy, with the username taken
+// from their workflow ID.
+func ListRunningGrants(ctx context.Context, c client.Client) ([]GrantStatus, error) {
+	var grants []GrantStatus
+	var pageToken []byte
+	for {
+		resp, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         runningGrantsQuery,
+			NextPageToken: pageToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list JIT workflows: %w", err)
+		}
+		for _, execution := range resp.GetExecutions() {
+			workflowID := execution.GetExecution().GetWorkflowId()
+			value, err := c.QueryWorkflow(ctx, workflowID, execution.GetExecution().GetRunId(), StatusQuery)
+			var queryFailed *serviceerror.QueryFailed
+			if errors.As(err, &queryFailed) {
+				grants = append(grants, legacyGrant(workflowID))
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to query grant %s: %w", workflowID, err)
+			}
+			var status GrantStatus
+			if err := value.Get(&status); err != nil {
+				return nil, fmt.Errorf("failed to decode grant %s: %w", workflowID, err)
+			}
+			status.WorkflowID = workflowID
+			grants = append(grants, status)
+		}
+		pageToken = resp.GetNextPageToken()
+		if len(pageToken) == 0 {
+			return grants, nil
+		}
+	}
+}
+
+// legacyGrant describes a running grant that does not answer StatusQuery.
+func legacyGrant(workflowID string) GrantStatus {
+	grant := GrantStatus{State: StateLegacy, WorkflowID: workflowID}
+	if m := legacyWorkflowID.FindStringSubmatch(workflowID); m != nil {
+		grant.Username = m[1]
+	}
+	return grant
+}
@@ -0,0 +1,41 @@
+package jitaccess_test
+
+import (
+	"context"
+	"testing"
+
+	"app/internal/jitaccess"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/mocks"
+)
+
+func TestListRunningGrants_LegacyGrant(t *testing.T) {
+	c := &mocks.Client{}
+	execution := func(workflowID string) *workflowpb.WorkflowExecutionInfo {
+		return &workflowpb.WorkflowExecutionInfo{Execution: &commonpb.WorkflowExecution{WorkflowId: workflowID, RunId: "run"}}
+	}
+	c.On("ListWorkflow", mock.Anything, mock.Anything).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{execution("jit_access_demo-user"), execution("jit_access_old_user_1700000000")},
+	}, nil)
+	value := &mocks.Value{}
+	value.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*jitaccess.GrantStatus) = jitaccess.GrantStatus{Username: "demo-user", State: jitaccess.StateActive}
+	}).Return(nil)
+	c.On("QueryWorkflow", mock.Anything, "jit_access_demo-user", "run", jitaccess.StatusQuery).Return(value, nil)
+	// Grants started before the policy engine never registered StatusQuery.
+	c.On("QueryWorkflow", mock.Anything, "jit_access_old_user_1700000000", "run", jitaccess.StatusQuery).
+		Return(nil, serviceerror.NewQueryFailed("unknown queryType status"))
+
+	grants, err := jitaccess.ListRunningGrants(context.Background(), c)
+	require.NoError(t, err)
+	require.Equal(t, []jitaccess.GrantStatus{
+		{Username: "demo-user", State: jitaccess.StateActive, WorkflowID: "jit_access_demo-user"},
+		{Username: "old_user", State: jitaccess.StateLegacy, WorkflowID: "jit_access_old_user_1700000000"},
+	}, grants)
+}
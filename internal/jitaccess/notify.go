@@ -0,0 +1,374 @@
+package jitaccess
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Notification events sent by JITAccessWorkflow.
+const (
+	NotifyGranted  = "granted"
+	NotifyExpiring = "expiring"
+	NotifyReverted = "reverted"
+	// NotifyRevertFailed reports a role that could not be reverted. It is always critical.
+	NotifyRevertFailed = "revert_failed"
+	// NotifyBreakGlass replaces NotifyGranted for break-glass grants.
+	NotifyBreakGlass = "break_glass"
+	// NotifyReviewPending announces, and periodically re-announces, an open post-incident review.
+	NotifyReviewPending = "review_pending"
+	// NotifyReviewAcknowledged closes a post-incident review.
+	NotifyReviewAcknowledged = "review_acknowledged"
+)
+
+// Notification severities. Everything about a break-glass grant is critical.
+const (
+	SeverityInfo     = "info"
+	SeverityCritical = "critical"
+)
+
+// Notification is the data available to sink templates.
+type Notification struct {
+	Event        string
+	Severity     string
+	IncidentRef  string
+	Username     string
+	Role         string
+	OriginalRole string
+	Reason       string
+	Actor        string
+	ExpiresAt    time.Time
+	WorkflowID   string
+	Time         time.Time
+}
+
+// NotifierConfig is the notification configuration file.
+type NotifierConfig struct {
+	// ExpiryWarning is how long before expiry the "expiring" notification is sent.
+	ExpiryWarning Duration     `json:"expiry_warning"`
+	Sinks         []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig configures a single notification sink.
+type SinkConfig struct {
+	Name string `json:"name"`
+	// Type is one of "webhook", "slack" or "smtp".
+	Type    string            `json:"type"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	SMTP    *SMTPConfig       `json:"smtp,omitempty"`
+	// Events limits the sink to some events; empty means all events.
+	// Critical notifications are sent regardless.
+	Events []string `json:"events,omitempty"`
+	// Templates maps an event (or "default", or "subject" for email) to a text/template.
+	Templates map[string]string `json:"templates,omitempty"`
+	Retry     *SinkRetry        `json:"retry,omitempty"`
+}
+
+// SMTPConfig configures an SMTP email sink.
+type SMTPConfig struct {
+	Addr     string   `json:"addr"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+}
+
+// SinkRetry is the retry and backoff policy used when delivering to a sink.
+type SinkRetry struct {
+	MaxAttempts        int      `json:"max_attempts"`
+	InitialInterval    Duration `json:"initial_interval"`
+	BackoffCoefficient float64  `json:"backoff_coefficient"`
+	MaxInterval        Duration `json:"max_interval"`
+}
+
+// NotifierSettings is the part of the configuration the workflow needs.
+// It deliberately leaves out URLs and credentials so they never reach workflow history.
+type NotifierSettings struct {
+	ExpiryWarning time.Duration
+	Sinks         []SinkSettings
+}
+
+// SinkSettings describes a sink to the workflow.
+type SinkSettings struct {
+	Name   string
+	Events []string
+	Retry  SinkRetry
+}
+
+// Subscribed reports whether the sink wants the event.
+func (s SinkSettings) Subscribed(event string) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, event)
+}
+
+const (
+	defaultTemplate = `[JIT{{if eq .Severity "critical"}} CRITICAL{{end}}] {{.Event}}: ` +
+		`{{if eq .Event "reverted"}}{{.Username}} returned to {{.OriginalRole}} from {{.Role}}` +
+		`{{else if eq .Event "review_pending"}}post-incident review of {{.Username}}'s {{.Role}} grant is awaiting acknowledgement` +
+		`{{else if eq .Event "review_acknowledged"}}post-incident review of {{.Username}}'s {{.Role}} grant acknowledged by {{.Actor}}` +
+		`{{else}}{{.Username}} has {{.Role}} until {{.ExpiresAt.Format "2006-01-02 15:04 MST"}}{{end}}` +
+		`{{if .Reason}} ({{.Reason}}){{end}}{{if .IncidentRef}} [incident {{.IncidentRef}}]{{end}}`
+	defaultSubjectTemplate = `[JIT{{if eq .Severity "critical"}} CRITICAL{{end}}] {{.Event}}: {{.Role}} for {{.Username}}`
+)
+
+// defaultSinkRetry is used for sinks that do not configure retries.
+var defaultSinkRetry = SinkRetry{
+	MaxAttempts:        5,
+	InitialInterval:    Duration(2 * time.Second),
+	BackoffCoefficient: 2.0,
+	MaxInterval:        Duration(time.Minute),
+}
+
+// sink delivers a rendered notification.
+type sink interface {
+	send(ctx context.Context, n Notification) error
+}
+
+// Notifier holds the configured sinks.
+type Notifier struct {
+	config NotifierConfig
+	sinks  map[string]sink
+}
+
+// NewNotifier validates the configuration and builds its sinks.
+func NewNotifier(cfg NotifierConfig) (*Notifier, error) {
+	n := &Notifier{config: cfg, sinks: make(map[string]sink)}
+	for _, sc := range cfg.Sinks {
+		if sc.Name == "" {
+			return nil, fmt.Errorf("sink name is required")
+		}
+		if _, exists := n.sinks[sc.Name]; exists {
+			return nil, fmt.Errorf("sink %s is defined more than once", sc.Name)
+		}
+		templates, err := parseSinkTemplates(sc)
+		if err != nil {
+			return nil, err
+		}
+		switch sc.Type {
+		case "webhook", "slack":
+			if sc.URL == "" {
+				return nil, fmt.Errorf("sink %s: url is required", sc.Name)
+			}
+			n.sinks[sc.Name] = &webhookSink{config: sc, templates: templates, slack: sc.Type == "slack", client: &http.Client{Timeout: 10 * time.Second}}
+		case "smtp":
+			if sc.SMTP == nil || sc.SMTP.Addr == "" || sc.SMTP.From == "" || len(sc.SMTP.To) == 0 {
+				return nil, fmt.Errorf("sink %s: smtp addr, from and to are required", sc.Name)
+			}
+			n.sinks[sc.Name] = &smtpSink{config: *sc.SMTP, templates: templates}
+		default:
+			return nil, fmt.Errorf("sink %s: unknown type %q", sc.Name, sc.Type)
+		}
+	}
+	return n, nil
+}
+
+// LoadNotifier reads a JSON notifier configuration. An empty path returns a notifier without sinks.
+func LoadNotifier(path string) (*Notifier, error) {
+	var cfg NotifierConfig
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read notifier config: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse notifier config %s: %w", path, err)
+		}
+	}
+	return NewNotifier(cfg)
+}
+
+// Settings returns the workflow-facing view of the configuration.
+func (n *Notifier) Settings() NotifierSettings {
+	settings := NotifierSettings{ExpiryWarning: time.Duration(n.config.ExpiryWarning)}
+	for _, sc := range n.config.Sinks {
+		retry := defaultSinkRetry
+		if sc.Retry != nil {
+			// Unset fields keep their defaults; in particular MaxAttempts 0 would retry forever.
+			if sc.Retry.MaxAttempts > 0 {
+				retry.MaxAttempts = sc.Retry.MaxAttempts
+			}
+			if sc.Retry.InitialInterval > 0 {
+				retry.InitialInterval = sc.Retry.InitialInterval
+			}
+			if sc.Retry.BackoffCoefficient >= 1 {
+				retry.BackoffCoefficient = sc.Retry.BackoffCoefficient
+			}
+			if sc.Retry.MaxInterval > 0 {
+				retry.MaxInterval = sc.Retry.MaxInterval
+			}
+		}
+		settings.Sinks = append(settings.Sinks, SinkSettings{Name: sc.Name, Events: sc.Events, Retry: retry})
+	}
+	return settings
+}
+
+// Send delivers a notification to the named sink.
+func (n *Notifier) Send(ctx context.Context, sinkName string, notification Notification) error {
+	s, ok := n.sinks[sinkName]
+	if !ok {
+		return fmt.Errorf("unknown notification sink %s", sinkName)
+	}
+	return s.send(ctx, notification)
+}
+
+func parseSinkTemplates(sc SinkConfig) (map[string]*template.Template, error) {
+	sources := map[string]string{"default": defaultTemplate, "subject": defaultSubjectTemplate}
+	for key, text := range sc.Templates {
+		sources[key] = text
+	}
+	templates := make(map[string]*template.Template, len(sources))
+	for key, text := range sources {
+		tmpl, err := template.New(sc.Name + "/" + key).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: invalid %s template: %w", sc.Name, key, err)
+		}
+		templates[key] = tmpl
+	}
+	return templates, nil
+}
+
+// render executes the template for key, falling back to the sink's "default" template.
+func render(templates map[string]*template.Template, key string, n Notification) (string, error) {
+	tmpl, ok := templates[key]
+	if !ok {
+		tmpl = templates["default"]
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, n); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", key, err)
+	}
+	return buf.String(), nil
+}
+
+// webhookSink posts JSON to a URL: the full notification for generic webhooks,
+// or a Slack-compatible incoming webhook payload.
+type webhookSink struct {
+	config    SinkConfig
+	templates map[string]*template.Template
+	slack     bool
+	client    *http.Client
+}
+
+func (s *webhookSink) send(ctx context.Context, n Notification) error {
+	message, err := render(s.templates, n.Event, n)
+	if err != nil {
+		return err
+	}
+	var payload any
+	if s.slack {
+		payload = map[string]string{"text": message}
+	} else {
+		payload = map[string]any{"event": n.Event, "message": message, "notification": n}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook %s returned status %d: %s", s.config.Name, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// headerLineBreaks flattens a header value onto one line, so a rendered subject cannot end
+// the header early or add headers of its own.
+var headerLineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// smtpSink sends a plain-text email.
+type smtpSink struct {
+	config    SMTPConfig
+	templates map[string]*template.Template
+}
+
+func (s *smtpSink) send(ctx context.Context, n Notification) error {
+	subject, err := render(s.templates, "subject", n)
+	if err != nil {
+		return err
+	}
+	body, err := render(s.templates, n.Event, n)
+	if err != nil {
+		return err
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", headerLineBreaks.Replace(subject))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(body)
+	msg.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		host := s.config.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, host)
+	}
+	// net/smtp has no context support, so honour cancellation around the call.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.config.Addr, auth, s.config.From, s.config.To, []byte(msg.String()))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("smtp send failed: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var (
+	notifierMu      sync.RWMutex
+	currentNotifier *Notifier
+)
+
+// InitNotifier loads the notifier configuration used by the notification activities.
+func InitNotifier(path string) error {
+	n, err := LoadNotifier(path)
+	if err != nil {
+		return err
+	}
+	notifierMu.Lock()
+	defer notifierMu.Unlock()
+	currentNotifier = n
+	return nil
+}
+
+// CurrentNotifier returns the notifier set by InitNotifier, or one without sinks.
+func CurrentNotifier() *Notifier {
+	notifierMu.RLock()
+	defer notifierMu.RUnlock()
+	if currentNotifier == nil {
+		return &Notifier{sinks: map[string]sink{}}
+	}
+	return currentNotifier
+}
@@ -0,0 +1,241 @@
+package jitaccess_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"app/internal/jitaccess"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testNotification() jitaccess.Notification {
+	return jitaccess.Notification{
+		Event:        jitaccess.NotifyGranted,
+		Username:     "demo-user",
+		Role:         "readWriteAnyDatabase",
+		OriginalRole: "readAnyDatabase",
+		Reason:       "INC-42 fix stuck payments",
+		Actor:        "sre-lead",
+		ExpiresAt:    time.Date(2025, 6, 2, 10, 30, 0, 0, time.UTC),
+		WorkflowID:   "jit_access_demo-user",
+		Time:         time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestNotifier_Webhooks(t *testing.T) {
+	type received struct {
+		path   string
+		header string
+		body   map[string]any
+	}
+	requests := make(chan received, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests <- received{path: r.URL.Path, header: r.Header.Get("X-Token"), body: body}
+	}))
+	defer server.Close()
+
+	n, err := jitaccess.NewNotifier(jitaccess.NotifierConfig{Sinks: []jitaccess.SinkConfig{
+		{Name: "hook", Type: "webhook", URL: server.URL + "/hook", Headers: map[string]string{"X-Token": "secret"}},
+		{Name: "slack", Type: "slack", URL: server.URL + "/slack", Templates: map[string]string{
+			"granted": ":unlock: {{.Username}} got {{.Role}} (approved by {{.Actor}})",
+		}},
+	}})
+	require.NoError(t, err)
+
+	require.NoError(t, n.Send(context.Background(), "hook", testNotification()))
+	got := <-requests
+	require.Equal(t, "/hook", got.path)
+	require.Equal(t, "secret", got.header)
+	require.Equal(t, "granted", got.body["event"])
+	require.Equal(t, "[JIT] granted: demo-user has readWriteAnyDatabase until 2025-06-02 10:30 UTC (INC-42 fix stuck payments)", got.body["message"])
+	require.Equal(t, "demo-user", got.body["notification"].(map[string]any)["Username"])
+
+	require.NoError(t, n.Send(context.Background(), "slack", testNotification()))
+	got = <-requests
+	require.Equal(t, "/slack", got.path)
+	require.Equal(t, map[string]any{"text": ":unlock: demo-user got readWriteAnyDatabase (approved by sre-lead)"}, got.body)
+
+	breakGlass := testNotification()
+	breakGlass.Event = jitaccess.NotifyBreakGlass
+	breakGlass.Severity = jitaccess.SeverityCritical
+	breakGlass.IncidentRef = "INC-42"
+	require.NoError(t, n.Send(context.Background(), "hook", breakGlass))
+	got = <-requests
+	require.Equal(t, "[JIT CRITICAL] break_glass: demo-user has readWriteAnyDatabase until 2025-06-02 10:30 UTC (INC-42 fix stuck payments) [incident INC-42]", got.body["message"])
+
+	require.ErrorContains(t, n.Send(context.Background(), "missing", testNotification()), "unknown notification sink")
+}
+
+func TestNotifier_WebhookErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	n, err := jitaccess.NewNotifier(jitaccess.NotifierConfig{Sinks: []jitaccess.SinkConfig{
+		{Name: "hook", Type: "webhook", URL: server.URL},
+	}})
+	require.NoError(t, err)
+	require.ErrorContains(t, n.Send(context.Background(), "hook", testNotification()), "returned status 429")
+}
+
+// fakeSMTPServer accepts a single SMTP session and returns the DATA it received.
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+		reply("220 localhost fake smtp")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					messages <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				reply("354 end with .")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func TestNotifier_SMTP(t *testing.T) {
+	addr, messages := fakeSMTPServer(t)
+
+	n, err := jitaccess.NewNotifier(jitaccess.NotifierConfig{Sinks: []jitaccess.SinkConfig{{
+		Name: "email",
+		Type: "smtp",
+		SMTP: &jitaccess.SMTPConfig{Addr: addr, From: "jit@example.com", To: []string{"sre@example.com"}},
+	}}})
+	require.NoError(t, err)
+
+	notification := testNotification()
+	notification.Event = jitaccess.NotifyReverted
+	require.NoError(t, n.Send(context.Background(), "email", notification))
+
+	select {
+	case msg := <-messages:
+		require.Contains(t, msg, "To: sre@example.com\r\n")
+		require.Contains(t, msg, "Subject: [JIT] reverted: readWriteAnyDatabase for demo-user\r\n")
+		require.Contains(t, msg, "demo-user returned to readAnyDatabase from readWriteAnyDatabase")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received by the fake SMTP server")
+	}
+}
+
+func TestNotifier_SMTPSubjectLineBreaks(t *testing.T) {
+	addr, messages := fakeSMTPServer(t)
+
+	n, err := jitaccess.NewNotifier(jitaccess.NotifierConfig{Sinks: []jitaccess.SinkConfig{{
+		Name:      "email",
+		Type:      "smtp",
+		SMTP:      &jitaccess.SMTPConfig{Addr: addr, From: "jit@example.com", To: []string{"sre@example.com"}},
+		Templates: map[string]string{"default": "body"},
+	}}})
+	require.NoError(t, err)
+
+	// Line breaks in the subject cannot inject headers.
+	notification := testNotification()
+	notification.Event = jitaccess.NotifyReverted
+	notification.Username = "demo-user\rBcc: attacker@example.com\r\nX-Injected: 1"
+	require.NoError(t, n.Send(context.Background(), "email", notification))
+	select {
+	case msg := <-messages:
+		require.Contains(t, msg, "Subject: [JIT] reverted: readWriteAnyDatabase for demo-user Bcc: attacker@example.com X-Injected: 1\r\n")
+		require.NotContains(t, msg, "\rBcc:")
+		require.NotContains(t, msg, "\nX-Injected:")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received by the fake SMTP server")
+	}
+}
+
+func TestLoadNotifier(t *testing.T) {
+	n, err := jitaccess.LoadNotifier("")
+	require.NoError(t, err)
+	require.Empty(t, n.Settings().Sinks)
+
+	n, err = jitaccess.LoadNotifier("../../demo/jit/notify.example.json")
+	require.NoError(t, err)
+	require.Len(t, n.Settings().Sinks, 3)
+
+	path := filepath.Join(t.TempDir(), "notify.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"expiry_warning": "10m",
+		"sinks": [
+			{"name": "hook", "type": "webhook", "url": "http://localhost/hook", "events": ["granted"]},
+			{"name": "slack", "type": "slack", "url": "http://localhost/slack", "retry": {"max_attempts": 10}}
+		]
+	}`), 0o600))
+	n, err = jitaccess.LoadNotifier(path)
+	require.NoError(t, err)
+
+	settings := n.Settings()
+	require.Equal(t, 10*time.Minute, settings.ExpiryWarning)
+	require.Len(t, settings.Sinks, 2)
+	require.True(t, settings.Sinks[0].Subscribed(jitaccess.NotifyGranted))
+	require.False(t, settings.Sinks[0].Subscribed(jitaccess.NotifyExpiring))
+	require.Equal(t, 5, settings.Sinks[0].Retry.MaxAttempts)
+	// Unset retry fields fall back to the defaults.
+	require.Equal(t, 10, settings.Sinks[1].Retry.MaxAttempts)
+	require.Equal(t, jitaccess.Duration(2*time.Second), settings.Sinks[1].Retry.InitialInterval)
+
+	for name, cfg := range map[string]jitaccess.SinkConfig{
+		"unknown type":   {Name: "x", Type: "pager"},
+		"missing url":    {Name: "x", Type: "webhook"},
+		"missing smtp":   {Name: "x", Type: "smtp"},
+		"bad template":   {Name: "x", Type: "slack", URL: "http://localhost", Templates: map[string]string{"granted": "{{.Nope"}},
+		"unnamed sink":   {Type: "webhook", URL: "http://localhost"},
+		"duplicate name": {Name: "hook", Type: "webhook", URL: "http://localhost"},
+	} {
+		sinks := []jitaccess.SinkConfig{cfg}
+		if name == "duplicate name" {
+			sinks = append(sinks, cfg)
+		}
+		_, err := jitaccess.NewNotifier(jitaccess.NotifierConfig{Sinks: sinks})
+		require.Error(t, err, name)
+	}
+}
@@ -0,0 +1,419 @@
+package jitaccess
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	// Embed the timezone database so business-hours checks inside the workflow
+	// resolve the same way on every worker, regardless of the host's tzdata.
+	_ "time/tzdata"
+)
+
+// Duration is a time.Duration that reads and writes as a Go duration string ("1h30m") in JSON.
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a duration string such as "15m".
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Policy defines which principals may request which roles, and under which conditions.
+// Policies are versioned; the version is recorded with every decision.
+type Policy struct {
+	Version         string         `json:"version"`
+	MinReasonLength int            `json:"min_reason_length"`
+	ApprovalTimeout Duration       `json:"approval_timeout,omitempty"`
+	BusinessHours   *BusinessHours `json:"business_hours,omitempty"`
+	// OnBehalfOf lists the principals that may request access for other users.
+	OnBehalfOf []string          `json:"on_behalf_of,omitempty"`
+	BreakGlass *BreakGlassPolicy `json:"break_glass,omitempty"`
+	Roles      []RolePolicy      `json:"roles"`
+}
+
+// MaxBreakGlassDuration is the hard cap on any break-glass grant, whatever the policy says.
+const MaxBreakGlassDuration = time.Hour
+
+const defaultReviewReminder = 24 * time.Hour
+
+// BreakGlassPolicy enables emergency access: an immediate grant without approval or
+// business-hours checks, capped to a short duration and tied to an incident.
+// Break-glass is disabled when the policy has no break_glass section.
+type BreakGlassPolicy struct {
+	// Principals that may be granted break-glass access; "*" allows everyone.
+	Principals []string `json:"principals"`
+	// Roles that may be granted through break-glass. They need not be requestable normally.
+	Roles       []string `json:"roles"`
+	MaxDuration Duration `json:"max_duration"`
+	// IncidentRefPattern is a regular expression incident references must match, e.g. "^INC-[0-9]+$".
+	IncidentRefPattern string `json:"incident_ref_pattern,omitempty"`
+	// ReviewReminder is how often an unacknowledged post-incident review is re-announced.
+	ReviewReminder Duration `json:"review_reminder,omitempty"`
+}
+
+// ReviewReminderInterval returns how often an open post-incident review is re-announced.
+func (bg *BreakGlassPolicy) ReviewReminderInterval() time.Duration {
+	if bg == nil || bg.ReviewReminder <= 0 {
+		return defaultReviewReminder
+	}
+	return time.Duration(bg.ReviewReminder)
+}
+
+// BusinessHours describes the window in which business-hours-only roles may be requested.
+type BusinessHours struct {
+	Timezone  string   `json:"timezone"`
+	StartHour int      `json:"start_hour"`
+	EndHour   int      `json:"end_hour"`
+	Weekdays  []string `json:"weekdays,omitempty"` // Defaults to Monday-Friday
+}
+
+// RolePolicy describes the rules for a single requestable role.
+// Roles not listed in the policy cannot be requested at all.
+type RolePolicy struct {
+	Role string `json:"role"`
+	// Principals that may be granted this role; "*" allows everyone.
+	Principals        []string `json:"principals"`
+	MaxDuration       Duration `json:"max_duration"`
+	RequireApproval   bool     `json:"require_approval"`
+	Approvers         []string `json:"approvers,omitempty"`
+	BusinessHoursOnly bool     `json:"business_hours_only"`
+}
+
+// PolicyDecision is the outcome of evaluating a request against a Policy.
+type PolicyDecision struct {
+	PolicyVersion   string
+	Allowed         bool
+	RequireApproval bool
+	Approvers       []string
+	// BreakGlass is set for emergency requests, which never require approval.
+	BreakGlass bool
+	// MaxDuration is the longest the grant may last, and the cap applied to extensions.
+	MaxDuration time.Duration
+	Violations  []string
+}
+
+const defaultApprovalTimeout = time.Hour
+
+// DefaultPolicy returns the built-in policy used when no policy file is configured.
+// It allows short read-only access for everyone, read-write with approval, and never atlasAdmin.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Version:         "builtin-1",
+		MinReasonLength: 10,
+		ApprovalTimeout: Duration(defaultApprovalTimeout),
+		Roles: []RolePolicy{
+			{
+				Role:        "readAnyDatabase",
+				Principals:  []string{"*"},
+				MaxDuration: Duration(time.Hour),
+			},
+			{
+				Role:            "readWriteAnyDatabase",
+				Principals:      []string{"*"},
+				MaxDuration:     Duration(time.Hour),
+				RequireApproval: true,
+			},
+		},
+	}
+}
+
+// LoadPolicy reads and validates a JSON policy file.
+// An empty path returns DefaultPolicy.
+func LoadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		return DefaultPolicy(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Validate checks that the policy is internally consistent.
+func (p *Policy) Validate() error {
+	if p.Version == "" {
+		return fmt.Errorf("policy version is required")
+	}
+	if p.MinReasonLength < 0 {
+		return fmt.Errorf("min_reason_length cannot be negative")
+	}
+	if p.BusinessHours != nil {
+		bh := p.BusinessHours
+		if _, err := time.LoadLocation(bh.Timezone); err != nil {
+			return fmt.Errorf("invalid business_hours timezone %q: %w", bh.Timezone, err)
+		}
+		if bh.StartHour < 0 || bh.EndHour > 24 || bh.StartHour >= bh.EndHour {
+			return fmt.Errorf("business_hours must satisfy 0 <= start_hour < end_hour <= 24")
+		}
+		for _, day := range bh.Weekdays {
+			if _, ok := parseWeekday(day); !ok {
+				return fmt.Errorf("invalid business_hours weekday %q", day)
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for _, rp := range p.Roles {
+		if rp.Role == "" {
+			return fmt.Errorf("role name is required")
+		}
+		if seen[rp.Role] {
+			return fmt.Errorf("role %s is defined more than once", rp.Role)
+		}
+		seen[rp.Role] = true
+		if rp.MaxDuration <= 0 {
+			return fmt.Errorf("role %s must have a positive max_duration", rp.Role)
+		}
+		if rp.BusinessHoursOnly && p.BusinessHours == nil {
+			return fmt.Errorf("role %s is business_hours_only but no business_hours are defined", rp.Role)
+		}
+	}
+	if bg := p.BreakGlass; bg != nil {
+		if len(bg.Roles) == 0 {
+			return fmt.Errorf("break_glass must list at least one role")
+		}
+		if bg.MaxDuration <= 0 || time.Duration(bg.MaxDuration) > MaxBreakGlassDuration {
+			return fmt.Errorf("break_glass max_duration must be positive and at most %s", MaxBreakGlassDuration)
+		}
+		if _, err := regexp.Compile(bg.IncidentRefPattern); err != nil {
+			return fmt.Errorf("invalid break_glass incident_ref_pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// Evaluate checks a request against the policy at the given time.
+// It is deterministic and safe to call from workflow code.
+func (p *Policy) Evaluate(req JITAccessRequest, now time.Time) PolicyDecision {
+	return p.evaluate(req, &now)
+}
+
+// EvaluateAnyTime checks a request without the rules that depend on when it is made, such as
+// business hours. A request it denies is denied whenever it is made, e.g. in every window of a
+// schedule.
+func (p *Policy) EvaluateAnyTime(req JITAccessRequest) PolicyDecision {
+	return p.evaluate(req, nil)
+}
+
+// evaluate checks a request at now, or at any time when now is nil.
+func (p *Policy) evaluate(req JITAccessRequest, now *time.Time) PolicyDecision {
+	decision := PolicyDecision{PolicyVersion: p.Version}
+	violate := func(format string, args ...any) {
+		decision.Violations = append(decision.Violations, fmt.Sprintf(format, args...))
+	}
+
+	if len(strings.TrimSpace(req.Reason)) < p.MinReasonLength {
+		violate("reason must be at least %d characters", p.MinReasonLength)
+	}
+	if req.Duration <= 0 {
+		violate("duration must be positive")
+	}
+	if requester := req.RequestedBy(); requester != req.Username && !matchesPrincipal(p.OnBehalfOf, requester) {
+		violate("%s is not allowed to request access for %s", requester, req.Username)
+	}
+	if req.BreakGlass {
+		p.evaluateBreakGlass(req, &decision, violate)
+		decision.Allowed = len(decision.Violations) == 0
+		return decision
+	}
+
+	rp := p.role(req.NewRole)
+	if rp == nil {
+		violate("role %s is not requestable", req.NewRole)
+		return decision
+	}
+	if !matchesPrincipal(rp.Principals, req.Username) {
+		violate("%s is not allowed to request role %s", req.Username, req.NewRole)
+	}
+	if req.Duration > time.Duration(rp.MaxDuration) {
+		violate("duration %s exceeds maximum %s for role %s", req.Duration, time.Duration(rp.MaxDuration), req.NewRole)
+	}
+	if rp.BusinessHoursOnly && now != nil && !p.BusinessHours.Contains(*now) {
+		violate("role %s can only be requested during business hours", req.NewRole)
+	}
+
+	decision.Allowed = len(decision.Violations) == 0
+	decision.RequireApproval = rp.RequireApproval
+	decision.Approvers = rp.Approvers
+	decision.MaxDuration = time.Duration(rp.MaxDuration)
+	return decision
+}
+
+// evaluateBreakGlass applies the break-glass rules in place of the role's approval and
+// business-hours rules. The grant is always capped by MaxBreakGlassDuration.
+func (p *Policy) evaluateBreakGlass(req JITAccessRequest, decision *PolicyDecision, violate func(string, ...any)) {
+	decision.BreakGlass = true
+	bg := p.BreakGlass
+	if bg == nil {
+		violate("break-glass access is not enabled by policy %s", p.Version)
+		return
+	}
+	decision.MaxDuration = min(time.Duration(bg.MaxDuration), MaxBreakGlassDuration)
+
+	if strings.TrimSpace(req.IncidentRef) == "" {
+		violate("break-glass requests require an incident reference")
+	} else if bg.IncidentRefPattern != "" {
+		if re, err := regexp.Compile(bg.IncidentRefPattern); err != nil || !re.MatchString(req.IncidentRef) {
+			violate("incident reference %q does not match %s", req.IncidentRef, bg.IncidentRefPattern)
+		}
+	}
+	if !slices.Contains(bg.Roles, req.NewRole) {
+		violate("role %s is not available through break-glass", req.NewRole)
+	}
+	if !matchesPrincipal(bg.Principals, req.Username) {
+		violate("%s is not allowed break-glass access", req.Username)
+	}
+	if req.Duration > decision.MaxDuration {
+		violate("duration %s exceeds break-glass maximum %s", req.Duration, decision.MaxDuration)
+	}
+}
+
+// MayManage reports whether principal may revoke or extend username's grant of role, or manage
+// their schedules for it: the user, principals allowed to request on behalf of others, and the
+// role's approvers may.
+func (p *Policy) MayManage(principal, username, role string) bool {
+	if principal == "" {
+		return false
+	}
+	if principal == username || matchesPrincipal(p.OnBehalfOf, principal) {
+		return true
+	}
+	rp := p.role(role)
+	return rp != nil && slices.Contains(rp.Approvers, principal)
+}
+
+// MayReadAll reports whether principal may read every user's grants, schedules and audit trail:
+// principals allowed to request on behalf of others and the approvers of any role may.
+func (p *Policy) MayReadAll(principal string) bool {
+	if principal == "" {
+		return false
+	}
+	if matchesPrincipal(p.OnBehalfOf, principal) {
+		return true
+	}
+	return slices.ContainsFunc(p.Roles, func(rp RolePolicy) bool { return slices.Contains(rp.Approvers, principal) })
+}
+
+// MayReview reports whether principal may acknowledge the post-incident review of a
+// break-glass grant of role to username requested by requester: the role's approvers and the
+// principals allowed to request on behalf of others may, unless they had or asked for the grant.
+func (p *Policy) MayReview(principal, username, requester, role string) bool {
+	if principal == "" || principal == username || principal == requester {
+		return false
+	}
+	if matchesPrincipal(p.OnBehalfOf, principal) {
+		return true
+	}
+	rp := p.role(role)
+	return rp != nil && slices.Contains(rp.Approvers, principal)
+}
+
+// ApprovalWindow returns how long a request may wait for approval.
+func (p *Policy) ApprovalWindow() time.Duration {
+	if p.ApprovalTimeout <= 0 {
+		return defaultApprovalTimeout
+	}
+	return time.Duration(p.ApprovalTimeout)
+}
+
+func (p *Policy) role(name string) *RolePolicy {
+	for i := range p.Roles {
+		if p.Roles[i].Role == name {
+			return &p.Roles[i]
+		}
+	}
+	return nil
+}
+
+// Contains reports whether t falls inside the business hours window.
+func (bh *BusinessHours) Contains(t time.Time) bool {
+	if bh == nil {
+		return true
+	}
+	loc, err := time.LoadLocation(bh.Timezone)
+	if err != nil {
+		return false
+	}
+	local := t.In(loc)
+	weekdays := bh.Weekdays
+	if len(weekdays) == 0 {
+		weekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday"}
+	}
+	onDay := false
+	for _, day := range weekdays {
+		if wd, ok := parseWeekday(day); ok && wd == local.Weekday() {
+			onDay = true
+			break
+		}
+	}
+	return onDay && local.Hour() >= bh.StartHour && local.Hour() < bh.EndHour
+}
+
+func matchesPrincipal(principals []string, principal string) bool {
+	for _, p := range principals {
+		if p == "*" || p == principal {
+			return true
+		}
+	}
+	return false
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), s) || strings.EqualFold(d.String()[:3], s) {
+			return d, true
+		}
+	}
+	return time.Sunday, false
+}
+
+var (
+	policyMu   sync.RWMutex
+	policyPath string
+)
+
+// InitPolicy configures the policy file used by LoadPolicyActivity and CurrentPolicy.
+// The file is validated immediately so a broken policy fails worker startup.
+func InitPolicy(path string) error {
+	if _, err := LoadPolicy(path); err != nil {
+		return err
+	}
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	policyPath = path
+	return nil
+}
+
+// CurrentPolicy re-reads the configured policy file so edits apply to new requests.
+func CurrentPolicy() (*Policy, error) {
+	policyMu.RLock()
+	path := policyPath
+	policyMu.RUnlock()
+	return LoadPolicy(path)
+}
@@ -0,0 +1,141 @@
+package jitaccess_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"app/internal/jitaccess"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Evaluate(t *testing.T) {
+	policy, err := jitaccess.LoadPolicy("../../demo/jit/policy.example.json")
+	require.NoError(t, err)
+
+	// Wednesday 10:00 and 22:00 in Kuala Lumpur.
+	kl, err := time.LoadLocation("Asia/Kuala_Lumpur")
+	require.NoError(t, err)
+	workHours := time.Date(2025, 6, 4, 10, 0, 0, 0, kl)
+	afterHours := time.Date(2025, 6, 4, 22, 0, 0, 0, kl)
+
+	tests := []struct {
+		name           string
+		req            jitaccess.JITAccessRequest
+		now            time.Time
+		wantAllowed    bool
+		wantApproval   bool
+		wantViolations int
+	}{
+		{"read access for anyone", jitaccess.JITAccessRequest{Username: "alice", Reason: "debugging prod issue", NewRole: "readAnyDatabase", Duration: time.Hour}, afterHours, true, false, 0},
+		{"duration over maximum", jitaccess.JITAccessRequest{Username: "alice", Reason: "debugging prod issue", NewRole: "readAnyDatabase", Duration: 10000 * time.Hour}, workHours, false, false, 1},
+		{"reason too short", jitaccess.JITAccessRequest{Username: "alice", Reason: "pls", NewRole: "readAnyDatabase", Duration: time.Hour}, workHours, false, false, 1},
+		{"principal not eligible", jitaccess.JITAccessRequest{Username: "alice", Reason: "debugging prod issue", NewRole: "readWriteAnyDatabase", Duration: time.Minute}, workHours, false, true, 1},
+		{"eligible with approval", jitaccess.JITAccessRequest{Username: "demo-user", Reason: "debugging prod issue", NewRole: "readWriteAnyDatabase", Duration: time.Minute}, workHours, true, true, 0},
+		{"unknown role", jitaccess.JITAccessRequest{Username: "oncall-sre", Reason: "debugging prod issue", NewRole: "dbOwner", Duration: time.Minute}, workHours, false, false, 1},
+		{"admin in business hours", jitaccess.JITAccessRequest{Username: "oncall-sre", Reason: "debugging prod issue", NewRole: "atlasAdmin", Duration: 10 * time.Minute}, workHours, true, true, 0},
+		{"admin after hours", jitaccess.JITAccessRequest{Username: "oncall-sre", Reason: "debugging prod issue", NewRole: "atlasAdmin", Duration: 10 * time.Minute}, afterHours, false, true, 1},
+		{"self request with requester", jitaccess.JITAccessRequest{Username: "alice", Requester: "alice", Reason: "debugging prod issue", NewRole: "readAnyDatabase", Duration: time.Hour}, workHours, true, false, 0},
+		{"on behalf of by delegate", jitaccess.JITAccessRequest{Username: "demo-user", Requester: "ci-bot", Reason: "nightly data repair", NewRole: "readWriteAnyDatabase", Duration: time.Minute}, workHours, true, true, 0},
+		{"on behalf of by non-delegate", jitaccess.JITAccessRequest{Username: "demo-user", Requester: "alice", Reason: "debugging prod issue", NewRole: "readAnyDatabase", Duration: time.Minute}, workHours, false, false, 1},
+		{"break-glass admin after hours", jitaccess.JITAccessRequest{Username: "oncall-sre", Reason: "primary is down", NewRole: "atlasAdmin", Duration: 15 * time.Minute, BreakGlass: true, IncidentRef: "INC-4711"}, afterHours, true, false, 0},
+		{"break-glass without incident", jitaccess.JITAccessRequest{Username: "oncall-sre", Reason: "primary is down", NewRole: "atlasAdmin", Duration: 15 * time.Minute, BreakGlass: true}, afterHours, false, false, 1},
+		{"break-glass bad incident ref", jitaccess.JITAccessRequest{Username: "oncall-sre", Reason: "primary is down", NewRole: "atlasAdmin", Duration: 15 * time.Minute, BreakGlass: true, IncidentRef: "JIRA-1"}, afterHours, false, false, 1},
+		{"break-glass over cap", jitaccess.JITAccessRequest{Username: "oncall-sre", Reason: "primary is down", NewRole: "atlasAdmin", Duration: time.Hour, BreakGlass: true, IncidentRef: "INC-4711"}, afterHours, false, false, 1},
+		{"break-glass role not allowed", jitaccess.JITAccessRequest{Username: "oncall-sre", Reason: "primary is down", NewRole: "readAnyDatabase", Duration: time.Minute, BreakGlass: true, IncidentRef: "INC-4711"}, afterHours, false, false, 1},
+		{"break-glass principal not eligible", jitaccess.JITAccessRequest{Username: "alice", Reason: "primary is down", NewRole: "atlasAdmin", Duration: time.Minute, BreakGlass: true, IncidentRef: "INC-4711"}, afterHours, false, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := policy.Evaluate(tt.req, tt.now)
+			require.Equal(t, tt.wantAllowed, decision.Allowed, decision.Violations)
+			require.Equal(t, tt.wantApproval, decision.RequireApproval)
+			require.Len(t, decision.Violations, tt.wantViolations)
+			require.Equal(t, "2025-06-01", decision.PolicyVersion)
+		})
+	}
+}
+
+func TestPolicy_EvaluateAnyTime(t *testing.T) {
+	policy, err := jitaccess.LoadPolicy("../../demo/jit/policy.example.json")
+	require.NoError(t, err)
+
+	// Business hours depend on when a window starts, so they never deny a request outright.
+	decision := policy.EvaluateAnyTime(jitaccess.JITAccessRequest{Username: "oncall-sre", Reason: "debugging prod issue", NewRole: "atlasAdmin", Duration: 10 * time.Minute})
+	require.True(t, decision.Allowed, decision.Violations)
+	require.True(t, decision.RequireApproval)
+
+	decision = policy.EvaluateAnyTime(jitaccess.JITAccessRequest{Username: "alice", Reason: "debugging prod issue", NewRole: "atlasAdmin", Duration: 10000 * time.Hour})
+	require.False(t, decision.Allowed)
+	require.Len(t, decision.Violations, 2)
+}
+
+func TestPolicy_MayManage(t *testing.T) {
+	policy, err := jitaccess.LoadPolicy("../../demo/jit/policy.example.json")
+	require.NoError(t, err)
+
+	require.True(t, policy.MayManage("demo-user", "demo-user", "readWriteAnyDatabase"))
+	require.True(t, policy.MayManage("ci-bot", "demo-user", "readAnyDatabase"))
+	require.True(t, policy.MayManage("sre-lead", "oncall-sre", "atlasAdmin"))
+	require.False(t, policy.MayManage("oncall-sre", "demo-user", "readWriteAnyDatabase"))
+	require.False(t, policy.MayManage("alice", "demo-user", "dbOwner"))
+	require.False(t, policy.MayManage("", "", "readAnyDatabase"))
+}
+
+func TestPolicy_MayReadAll(t *testing.T) {
+	policy, err := jitaccess.LoadPolicy("../../demo/jit/policy.example.json")
+	require.NoError(t, err)
+
+	require.True(t, policy.MayReadAll("sre-lead"))
+	require.True(t, policy.MayReadAll("ci-bot"))
+	require.False(t, policy.MayReadAll("demo-user"))
+	require.False(t, policy.MayReadAll(""))
+}
+
+func TestLoadPolicy_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"missing version":       `{"roles": []}`,
+		"non-positive duration": `{"version": "1", "roles": [{"role": "r", "principals": ["*"], "max_duration": "0s"}]}`,
+		"bad duration":          `{"version": "1", "roles": [{"role": "r", "principals": ["*"], "max_duration": "forever"}]}`,
+		"duplicate role":        `{"version": "1", "roles": [{"role": "r", "max_duration": "1m"}, {"role": "r", "max_duration": "1m"}]}`,
+		"hours without window":  `{"version": "1", "roles": [{"role": "r", "max_duration": "1m", "business_hours_only": true}]}`,
+		"bad timezone":          `{"version": "1", "business_hours": {"timezone": "Mars/Olympus", "start_hour": 9, "end_hour": 17}, "roles": []}`,
+		"break-glass over cap":  `{"version": "1", "break_glass": {"roles": ["r"], "max_duration": "2h"}, "roles": []}`,
+		"break-glass no roles":  `{"version": "1", "break_glass": {"max_duration": "15m"}, "roles": []}`,
+		"break-glass bad regex": `{"version": "1", "break_glass": {"roles": ["r"], "max_duration": "15m", "incident_ref_pattern": "INC-("}, "roles": []}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			_, err := jitaccess.LoadPolicy(path)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLoadPolicy_Default(t *testing.T) {
+	policy, err := jitaccess.LoadPolicy("")
+	require.NoError(t, err)
+	decision := policy.Evaluate(jitaccess.JITAccessRequest{Username: "alice", Reason: "debugging prod issue", NewRole: "atlasAdmin", Duration: 10000 * time.Hour}, time.Now())
+	require.False(t, decision.Allowed)
+
+	// Break-glass is disabled unless the policy enables it.
+	decision = policy.Evaluate(jitaccess.JITAccessRequest{Username: "alice", Reason: "primary is down", NewRole: "readWriteAnyDatabase", Duration: time.Minute, BreakGlass: true, IncidentRef: "INC-1"}, time.Now())
+	require.False(t, decision.Allowed)
+	require.Contains(t, decision.Violations, "break-glass access is not enabled by policy builtin-1")
+}
+
+func TestPolicy_MayReview(t *testing.T) {
+	policy, err := jitaccess.LoadPolicy("../../demo/jit/policy.example.json")
+	require.NoError(t, err)
+
+	require.True(t, policy.MayReview("sre-lead", "demo-user", "demo-user", "atlasAdmin"))
+	require.True(t, policy.MayReview("ci-bot", "demo-user", "demo-user", "atlasAdmin"))
+	require.False(t, policy.MayReview("sre-lead", "sre-lead", "sre-lead", "atlasAdmin"))
+	require.False(t, policy.MayReview("sre-lead", "demo-user", "sre-lead", "atlasAdmin"))
+	require.False(t, policy.MayReview("other-user", "demo-user", "demo-user", "atlasAdmin"))
+	require.False(t, policy.MayReview("", "demo-user", "demo-user", "atlasAdmin"))
+}
@@ -0,0 +1,277 @@
+package jitaccess
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"app/internal/atlas"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ReconcileReportQuery returns the ReconcileReport of a reconciliation run.
+const ReconcileReportQuery = "report"
+
+// ReconcileScheduleID is the ID of the Temporal schedule that runs ReconcileAccessWorkflow.
+const ReconcileScheduleID = "jit-reconcile"
+
+// AuditRemediated records an unexpected role removed by reconciliation.
+const AuditRemediated = "remediated"
+
+// Baseline is the desired state of database user roles outside of JIT grants.
+type Baseline struct {
+	Version string `json:"version"`
+	// Users maps each database user to the roles it is expected to hold.
+	Users map[string][]string `json:"users"`
+	// DefaultRoles are expected for users not listed in Users.
+	DefaultRoles []string `json:"default_roles,omitempty"`
+	// Ignore lists users that are never reported, such as service accounts managed elsewhere.
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+// ExpectedRoles returns the roles a user should hold outside of JIT grants.
+func (b *Baseline) ExpectedRoles(username string) []string {
+	if roles, ok := b.Users[username]; ok {
+		return roles
+	}
+	return b.DefaultRoles
+}
+
+// LoadBaseline reads a JSON baseline file.
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline file: %w", err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline file %s: %w", path, err)
+	}
+	if b.Version == "" {
+		return nil, fmt.Errorf("invalid baseline file %s: version is required", path)
+	}
+	return &b, nil
+}
+
+// ReconcileRequest is the input of ReconcileAccessWorkflow.
+type ReconcileRequest struct {
+	// Remediate resets users with unexpected roles to their baseline roles.
+	Remediate bool
+}
+
+// RoleDrift is a database user whose roles differ from the baseline and its active JIT grants.
+type RoleDrift struct {
+	Username string
+	Actual   []string
+	Expected []string
+	// Unexpected roles are held without a baseline entry or an active grant.
+	Unexpected []string
+	// Missing roles are in the baseline but not held. They are reported, never remediated.
+	Missing []string
+	// Remediable is false when the baseline has no roles for the user to fall back to.
+	Remediable bool
+	Remediated bool
+	// Error is set when remediation was attempted and failed, or was skipped.
+	Error string `json:",omitempty"`
+}
+
+// ReconcileReport is the diff produced by a reconciliation run.
+type ReconcileReport struct {
+	BaselineVersion string
+	StartedAt       time.Time
+	CompletedAt     time.Time
+	UsersChecked    int
+	ActiveGrants    int
+	Drift           []RoleDrift
+	Remediate       bool
+	Remediated      int
+}
+
+// Reconcile compares database users with the baseline plus the roles of active JIT grants.
+// Grants that are still evaluating or pending approval do not cover any role.
+// It is deterministic and safe to call from workflow code.
+func Reconcile(baseline *Baseline, users []atlas.DatabaseUser, grants []GrantStatus) []RoleDrift {
+	granted := make(map[string][]string)
+	for _, g := range grants {
+		if g.State == StateActive || g.State == StateReverting {
+			granted[g.Username] = append(granted[g.Username], g.NewRole)
+		}
+	}
+
+	var drift []RoleDrift
+	for _, user := range users {
+		if slices.Contains(baseline.Ignore, user.Username) {
+			continue
+		}
+		expected := baseline.ExpectedRoles(user.Username)
+		d := RoleDrift{
+			Username:   user.Username,
+			Actual:     user.Roles,
+			Expected:   expected,
+			Remediable: len(expected) > 0,
+		}
+		for _, role := range user.Roles {
+			if !slices.Contains(expected, role) && !slices.Contains(granted[user.Username], role) {
+				d.Unexpected = append(d.Unexpected, role)
+			}
+		}
+		// While a grant is active the baseline role is expected to have been replaced.
+		if len(granted[user.Username]) == 0 {
+			for _, role := range expected {
+				if !slices.Contains(user.Roles, role) {
+					d.Missing = append(d.Missing, role)
+				}
+			}
+		}
+		if len(d.Unexpected) > 0 || len(d.Missing) > 0 {
+			drift = append(drift, d)
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Username < drift[j].Username })
+	return drift
+}
+
+// ReconcileAccessWorkflow compares every Atlas database user with the baseline and the active
+// JIT grants, and reports unexpected roles. With Remediate set, users holding unexpected roles
+// are reset to their baseline roles, unless they have a JIT grant in progress by then.
+// The report is returned and is also available through ReconcileReportQuery while running.
+func ReconcileAccessWorkflow(ctx workflow.Context, req ReconcileRequest) (ReconcileReport, error) {
+	logger := workflow.GetLogger(ctx)
+	report := ReconcileReport{StartedAt: workflow.Now(ctx), Remediate: req.Remediate}
+	if err := workflow.SetQueryHandler(ctx, ReconcileReportQuery, func() (ReconcileReport, error) {
+		return report, nil
+	}); err != nil {
+		return report, err
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    5 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    1 * time.Minute,
+			MaximumAttempts:    5,
+		},
+	})
+
+	var baseline Baseline
+	if err := workflow.ExecuteActivity(ctx, LoadBaselineActivity).Get(ctx, &baseline); err != nil {
+		return report, err
+	}
+	report.BaselineVersion = baseline.Version
+
+	var users []atlas.DatabaseUser
+	if err := workflow.ExecuteActivity(ctx, ListDatabaseUsersActivity).Get(ctx, &users); err != nil {
+		return report, err
+	}
+	var grants []GrantStatus
+	if err := workflow.ExecuteActivity(ctx, ListActiveGrantsActivity).Get(ctx, &grants); err != nil {
+		return report, err
+	}
+	report.UsersChecked = len(users)
+	report.ActiveGrants = len(grants)
+	report.Drift = Reconcile(&baseline, users, grants)
+	logger.Info("Reconciliation diff computed", "users", len(users), "grants", len(grants), "drift", len(report.Drift))
+
+	if req.Remediate {
+		remediate(ctx, &report)
+	}
+	report.CompletedAt = workflow.Now(ctx)
+	return report, nil
+}
+
+// remediate resets users with unexpected roles to their baseline roles.
+// Active grants are listed again first, so a grant that started after the diff is never undone.
+func remediate(ctx workflow.Context, report *ReconcileReport) {
+	logger := workflow.GetLogger(ctx)
+	var grants []GrantStatus
+	if err := workflow.ExecuteActivity(ctx, ListActiveGrantsActivity).Get(ctx, &grants); err != nil {
+		logger.Error("failed to re-check active grants, skipping remediation", "error", err)
+		for i := range report.Drift {
+			report.Drift[i].Error = "remediation skipped: " + err.Error()
+		}
+		return
+	}
+	audit := &auditor{policyVersion: report.BaselineVersion}
+
+	for i := range report.Drift {
+		d := &report.Drift[i]
+		if len(d.Unexpected) == 0 || !d.Remediable {
+			continue
+		}
+		if slices.ContainsFunc(grants, func(g GrantStatus) bool { return g.Username == d.Username }) {
+			d.Error = "remediation skipped: a JIT grant is in progress"
+			continue
+		}
+		if err := workflow.ExecuteActivity(ctx, SetUserRolesActivity, d.Username, d.Expected).Get(ctx, nil); err != nil {
+			logger.Error("failed to remediate user", "username", d.Username, "error", err)
+			d.Error = err.Error()
+			continue
+		}
+		d.Remediated = true
+		report.Remediated++
+		logger.Info("Remediated unexpected roles", "username", d.Username, "removed", d.Unexpected)
+		audit.req = JITAccessRequest{Username: d.Username}
+		_ = audit.record(ctx, AuditRemediated, "reconciliation", "unexpected roles outside of JIT", map[string]string{
+			"removed_roles":  fmt.Sprint(d.Unexpected),
+			"restored_roles": fmt.Sprint(d.Expected),
+		})
+	}
+}
+
+// ReconcileScheduleOptions returns the schedule that runs ReconcileAccessWorkflow every interval.
+// Runs never overlap: a run still going when the next one is due causes that one to be skipped.
+func ReconcileScheduleOptions(taskQueue string, every time.Duration, remediate bool) client.ScheduleOptions {
+	return client.ScheduleOptions{
+		ID: ReconcileScheduleID,
+		Spec: client.ScheduleSpec{
+			Intervals: []client.ScheduleIntervalSpec{{Every: every}},
+		},
+		Action: &client.ScheduleWorkflowAction{
+			ID:        "jit_reconcile",
+			Workflow:  ReconcileAccessWorkflow,
+			Args:      []interface{}{ReconcileRequest{Remediate: remediate}},
+			TaskQueue: taskQueue,
+		},
+		Overlap: enumspb.SCHEDULE_OVERLAP_POLICY_SKIP,
+		Note:    "JIT access reconciliation",
+	}
+}
+
+var (
+	baselineMu   sync.RWMutex
+	baselinePath string
+)
+
+// InitBaseline configures the baseline file used by LoadBaselineActivity.
+// An empty path disables reconciliation; otherwise the file is validated immediately.
+func InitBaseline(path string) error {
+	if path != "" {
+		if _, err := LoadBaseline(path); err != nil {
+			return err
+		}
+	}
+	baselineMu.Lock()
+	defer baselineMu.Unlock()
+	baselinePath = path
+	return nil
+}
+
+// CurrentBaseline re-reads the configured baseline file so edits apply to the next run.
+func CurrentBaseline() (*Baseline, error) {
+	baselineMu.RLock()
+	path := baselinePath
+	baselineMu.RUnlock()
+	if path == "" {
+		return nil, fmt.Errorf("no reconciliation baseline is configured")
+	}
+	return LoadBaseline(path)
+}
@@ -0,0 +1,129 @@
+package jitaccess_test
+
+import (
+	"testing"
+	"time"
+
+	"app/internal/atlas"
+	"app/internal/jitaccess"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func testBaseline(t *testing.T) *jitaccess.Baseline {
+	baseline, err := jitaccess.LoadBaseline("../../demo/jit/baseline.example.json")
+	require.NoError(t, err)
+	return baseline
+}
+
+func TestReconcile(t *testing.T) {
+	users := []atlas.DatabaseUser{
+		{Username: "demo-user", Roles: []string{"readAnyDatabase"}},
+		{Username: "oncall-sre", Roles: []string{"atlasAdmin"}},
+		{Username: "alice", Roles: []string{"readWriteAnyDatabase"}},
+		{Username: "etl-service", Roles: []string{"readWrite@analytics"}},
+		{Username: "atlas-backup-agent", Roles: []string{"backup"}},
+		{Username: "bob", Roles: []string{"readWriteAnyDatabase"}},
+	}
+	grants := []jitaccess.GrantStatus{
+		{Username: "oncall-sre", NewRole: "atlasAdmin", State: jitaccess.StateActive},
+		// A pending grant does not cover the role yet.
+		{Username: "bob", NewRole: "readWriteAnyDatabase", State: jitaccess.StatePendingApproval},
+	}
+
+	drift := jitaccess.Reconcile(testBaseline(t), users, grants)
+	require.Len(t, drift, 2)
+
+	require.Equal(t, "alice", drift[0].Username)
+	require.Equal(t, []string{"readWriteAnyDatabase"}, drift[0].Unexpected)
+	require.Equal(t, []string{"readAnyDatabase"}, drift[0].Missing)
+	require.Equal(t, []string{"readAnyDatabase"}, drift[0].Expected)
+	require.True(t, drift[0].Remediable)
+
+	require.Equal(t, "bob", drift[1].Username)
+	require.Equal(t, []string{"readWriteAnyDatabase"}, drift[1].Unexpected)
+}
+
+func TestReconcile_NoBaselineRoles(t *testing.T) {
+	baseline := &jitaccess.Baseline{Version: "1", Users: map[string][]string{"svc": {}}}
+	drift := jitaccess.Reconcile(baseline, []atlas.DatabaseUser{{Username: "svc", Roles: []string{"dbAdmin@app"}}}, nil)
+	require.Len(t, drift, 1)
+	require.Equal(t, []string{"dbAdmin@app"}, drift[0].Unexpected)
+	require.False(t, drift[0].Remediable)
+}
+
+func TestReconcileAccessWorkflow(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	events := recordAuditEvents(env)
+	env.OnActivity(jitaccess.LoadBaselineActivity, mock.Anything).Return(testBaseline(t), nil)
+	env.OnActivity(jitaccess.ListDatabaseUsersActivity, mock.Anything).Return([]atlas.DatabaseUser{
+		{Username: "alice", Roles: []string{"readWriteAnyDatabase"}},
+		{Username: "bob", Roles: []string{"atlasAdmin"}},
+		{Username: "demo-user", Roles: []string{"readAnyDatabase"}},
+	}, nil)
+	// Bob requests access between the diff and the remediation, so he must be left alone.
+	env.OnActivity(jitaccess.ListActiveGrantsActivity, mock.Anything).Return([]jitaccess.GrantStatus{}, nil).Once()
+	env.OnActivity(jitaccess.ListActiveGrantsActivity, mock.Anything).Return([]jitaccess.GrantStatus{
+		{Username: "bob", NewRole: "atlasAdmin", State: jitaccess.StateEvaluating},
+	}, nil).Once()
+	env.OnActivity(jitaccess.SetUserRolesActivity, mock.Anything, "alice", []string{"readAnyDatabase"}).Return(nil).Once()
+
+	env.ExecuteWorkflow(jitaccess.ReconcileAccessWorkflow, jitaccess.ReconcileRequest{Remediate: true})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	var report jitaccess.ReconcileReport
+	require.NoError(t, env.GetWorkflowResult(&report))
+	require.Equal(t, "2025-06-01", report.BaselineVersion)
+	require.Equal(t, 3, report.UsersChecked)
+	require.Equal(t, 1, report.Remediated)
+	require.Len(t, report.Drift, 2)
+	require.True(t, report.Drift[0].Remediated)
+	require.False(t, report.Drift[1].Remediated)
+	require.Contains(t, report.Drift[1].Error, "JIT grant is in progress")
+
+	value, err := env.QueryWorkflow(jitaccess.ReconcileReportQuery)
+	require.NoError(t, err)
+	var queried jitaccess.ReconcileReport
+	require.NoError(t, value.Get(&queried))
+	require.Equal(t, report.Drift, queried.Drift)
+
+	require.Equal(t, []string{jitaccess.AuditRemediated}, auditTypes(*events))
+	require.Equal(t, "alice", (*events)[0].Username)
+}
+
+func TestReconcileAccessWorkflow_ReportOnly(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadBaselineActivity, mock.Anything).Return(testBaseline(t), nil)
+	env.OnActivity(jitaccess.ListDatabaseUsersActivity, mock.Anything).Return([]atlas.DatabaseUser{
+		{Username: "alice", Roles: []string{"readWriteAnyDatabase"}},
+	}, nil)
+	env.OnActivity(jitaccess.ListActiveGrantsActivity, mock.Anything).Return([]jitaccess.GrantStatus{}, nil)
+
+	env.ExecuteWorkflow(jitaccess.ReconcileAccessWorkflow, jitaccess.ReconcileRequest{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var report jitaccess.ReconcileReport
+	require.NoError(t, env.GetWorkflowResult(&report))
+	require.Len(t, report.Drift, 1)
+	require.Zero(t, report.Remediated)
+	env.AssertActivityNotCalled(t, "SetUserRolesActivity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReconcileScheduleOptions(t *testing.T) {
+	options := jitaccess.ReconcileScheduleOptions("jit_access_task_queue", time.Hour, true)
+	require.Equal(t, jitaccess.ReconcileScheduleID, options.ID)
+	require.Equal(t, []client.ScheduleIntervalSpec{{Every: time.Hour}}, options.Spec.Intervals)
+	action := options.Action.(*client.ScheduleWorkflowAction)
+	require.Equal(t, []interface{}{jitaccess.ReconcileRequest{Remediate: true}}, action.Args)
+}
@@ -0,0 +1,154 @@
+package jitaccess
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// ScheduleIDPrefix prefixes the IDs of all JIT schedules.
+const ScheduleIDPrefix = "jit-schedule-"
+
+// AuditSkipped records a scheduled window that was not granted because the user already had a grant.
+const AuditSkipped = "skipped"
+
+// JITSchedule describes a recurring JIT window.
+type JITSchedule struct {
+	// ID is the schedule ID without ScheduleIDPrefix.
+	ID      string
+	Request JITAccessRequest
+	// CronExpressions and Calendars say when each window starts; at least one is required.
+	CronExpressions []string
+	Calendars       []client.ScheduleCalendarSpec
+	// TimeZone is the IANA time zone the spec is interpreted in; empty means UTC.
+	TimeZone string
+	Note     string
+	Paused   bool
+}
+
+// ScheduleID returns the full Temporal schedule ID for a JIT schedule ID.
+func ScheduleID(id string) string {
+	if strings.HasPrefix(id, ScheduleIDPrefix) {
+		return id
+	}
+	return ScheduleIDPrefix + id
+}
+
+// Validate checks that the schedule can be created.
+func (s JITSchedule) Validate() error {
+	var problems []string
+	if s.ID == "" {
+		problems = append(problems, "id is required")
+	}
+	if s.Request.Username == "" || s.Request.NewRole == "" || s.Request.Reason == "" {
+		problems = append(problems, "username, new_role, and reason are required")
+	}
+	if s.Request.Duration <= 0 {
+		problems = append(problems, "duration must be positive")
+	}
+	if s.Request.BreakGlass {
+		problems = append(problems, "break-glass access cannot be scheduled")
+	}
+	if len(s.CronExpressions) == 0 && len(s.Calendars) == 0 {
+		problems = append(problems, "at least one cron expression or calendar is required")
+	}
+	if s.TimeZone != "" {
+		if _, err := time.LoadLocation(s.TimeZone); err != nil {
+			problems = append(problems, fmt.Sprintf("unknown time zone %q", s.TimeZone))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid schedule: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ScheduleOptions returns the Temporal schedule that runs ScheduledJITWorkflow for s.
+// The overlap policy is SKIP: an occurrence that fires while the previous window is still
+// running is dropped, so a schedule never stacks grants.
+func (s JITSchedule) ScheduleOptions(taskQueue string) client.ScheduleOptions {
+	id := ScheduleID(s.ID)
+	return client.ScheduleOptions{
+		ID: id,
+		Spec: client.ScheduleSpec{
+			CronExpressions: s.CronExpressions,
+			Calendars:       s.Calendars,
+			TimeZoneName:    s.TimeZone,
+		},
+		Action: &client.ScheduleWorkflowAction{
+			ID:        "jit_scheduled_" + strings.TrimPrefix(id, ScheduleIDPrefix),
+			Workflow:  ScheduledJITWorkflow,
+			Args:      []interface{}{s.Request},
+			TaskQueue: taskQueue,
+		},
+		Overlap:       enumspb.SCHEDULE_OVERLAP_POLICY_SKIP,
+		CatchupWindow: 10 * time.Minute,
+		Note:          s.Note,
+		Paused:        s.Paused,
+	}
+}
+
+// ScheduledRequest returns the request every window of a described JIT schedule makes.
+func ScheduledRequest(desc *client.ScheduleDescription) (JITAccessRequest, error) {
+	var req JITAccessRequest
+	action, ok := desc.Schedule.Action.(*client.ScheduleWorkflowAction)
+	if !ok || len(action.Args) != 1 {
+		return req, fmt.Errorf("schedule does not start a JIT window")
+	}
+	payload, ok := action.Args[0].(*commonpb.Payload)
+	if !ok {
+		return req, fmt.Errorf("schedule does not start a JIT window")
+	}
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &req); err != nil {
+		return req, fmt.Errorf("failed to decode the schedule's request: %w", err)
+	}
+	return req, nil
+}
+
+// ScheduledJITWorkflow runs one occurrence of a recurring JIT window.
+// It starts JITAccessWorkflow as a child under the user's JIT workflow ID, so scheduled windows
+// are serialized with on-demand grants. If the user already has a grant in progress, the
+// occurrence is skipped and recorded in the audit log instead of stacking a second grant.
+func ScheduledJITWorkflow(ctx workflow.Context, req JITAccessRequest) error {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting scheduled JIT window", "username", req.Username, "new_role", req.NewRole, "duration", req.Duration)
+
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:            JITWorkflowID(req.Username),
+		WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+		// The grant reverts itself, so it must outlive this wrapper if the wrapper is terminated.
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_ABANDON,
+	})
+	child := workflow.ExecuteChildWorkflow(childCtx, JITAccessWorkflow, req)
+
+	var execution workflow.Execution
+	if err := child.GetChildWorkflowExecution().Get(ctx, &execution); err != nil {
+		if !temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			return err
+		}
+		logger.Info("Skipping scheduled window, user already has a grant in progress", "username", req.Username)
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: 1 * time.Minute,
+			RetryPolicy: &temporal.RetryPolicy{
+				InitialInterval:    5 * time.Second,
+				BackoffCoefficient: 2.0,
+				MaximumInterval:    1 * time.Minute,
+				MaximumAttempts:    5,
+			},
+		})
+		audit := &auditor{req: req}
+		return audit.record(ctx, AuditSkipped, "schedule", "user already has a grant in progress", map[string]string{
+			"schedule_workflow_id": workflow.GetInfo(ctx).WorkflowExecution.ID,
+		})
+	}
+
+	logger.Info("Scheduled grant started", "workflowID", execution.ID, "runID", execution.RunID)
+	return child.Get(ctx, nil)
+}
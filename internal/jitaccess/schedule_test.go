@@ -0,0 +1,106 @@
+package jitaccess_test
+
+import (
+	"testing"
+	"time"
+
+	"app/internal/jitaccess"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func weekendWindow() jitaccess.JITSchedule {
+	return jitaccess.JITSchedule{
+		ID: "oncall-weekend",
+		Request: jitaccess.JITAccessRequest{
+			Username: "testuser",
+			Reason:   "weekend on-call",
+			NewRole:  "elevatedRole",
+			Duration: 48 * time.Hour,
+		},
+		CronExpressions: []string{"0 9 * * SAT"},
+		TimeZone:        "Asia/Kuala_Lumpur",
+	}
+}
+
+func TestJITSchedule_ScheduleOptions(t *testing.T) {
+	s := weekendWindow()
+	require.NoError(t, s.Validate())
+
+	opts := s.ScheduleOptions("jit_access_task_queue")
+	require.Equal(t, "jit-schedule-oncall-weekend", opts.ID)
+	require.Equal(t, enumspb.SCHEDULE_OVERLAP_POLICY_SKIP, opts.Overlap)
+	require.Equal(t, "Asia/Kuala_Lumpur", opts.Spec.TimeZoneName)
+	action := opts.Action.(*client.ScheduleWorkflowAction)
+	require.Equal(t, "jit_access_task_queue", action.TaskQueue)
+	require.Equal(t, []interface{}{s.Request}, action.Args)
+
+	require.Equal(t, opts.ID, jitaccess.ScheduleID(opts.ID))
+
+	invalid := weekendWindow()
+	invalid.CronExpressions = nil
+	invalid.TimeZone = "Mars/Olympus_Mons"
+	invalid.Request.Duration = 0
+	err := invalid.Validate()
+	require.ErrorContains(t, err, "duration must be positive")
+	require.ErrorContains(t, err, "at least one cron expression or calendar")
+	require.ErrorContains(t, err, "unknown time zone")
+}
+
+func TestScheduledRequest(t *testing.T) {
+	s := weekendWindow()
+	payload, err := converter.GetDefaultDataConverter().ToPayload(s.Request)
+	require.NoError(t, err)
+	// Describing a schedule returns its arguments as payloads.
+	desc := &client.ScheduleDescription{Schedule: client.Schedule{
+		Action: &client.ScheduleWorkflowAction{Args: []interface{}{payload}},
+	}}
+	req, err := jitaccess.ScheduledRequest(desc)
+	require.NoError(t, err)
+	require.Equal(t, s.Request, req)
+
+	_, err = jitaccess.ScheduledRequest(&client.ScheduleDescription{Schedule: client.Schedule{Action: &client.ScheduleWorkflowAction{}}})
+	require.Error(t, err)
+}
+
+func TestScheduledJITWorkflow_StartsGrant(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	var childID string
+	env.RegisterWorkflow(jitaccess.JITAccessWorkflow)
+	env.OnWorkflow(jitaccess.JITAccessWorkflow, mock.Anything, mock.Anything).Return(
+		func(ctx workflow.Context, req jitaccess.JITAccessRequest) error {
+			childID = workflow.GetInfo(ctx).WorkflowExecution.ID
+			return nil
+		})
+
+	env.ExecuteWorkflow(jitaccess.ScheduledJITWorkflow, weekendWindow().Request)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, jitaccess.JITWorkflowID("testuser"), childID)
+}
+
+func TestScheduledJITWorkflow_SkipsWhenGrantInProgress(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.RegisterWorkflow(jitaccess.JITAccessWorkflow)
+	events := recordAuditEvents(env)
+	// A workflow already running under the user's JIT workflow ID stands in for an on-demand grant.
+	env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: jitaccess.JITWorkflowID("testuser")})
+
+	env.ExecuteWorkflow(jitaccess.ScheduledJITWorkflow, weekendWindow().Request)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, []string{jitaccess.AuditSkipped}, auditTypes(*events))
+	require.Equal(t, "schedule", (*events)[0].Actor)
+}
@@ -1,24 +1,152 @@
 package jitaccess
 
 import (
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
+const (
+	// ApprovalSignal is the signal used to approve or deny a request that needs approval.
+	ApprovalSignal = "approval"
+	// ExtendSignal extends an active grant.
+	ExtendSignal = "extend"
+	// RevokeSignal ends an active grant early.
+	RevokeSignal = "revoke"
+	// StatusQuery returns the current GrantStatus of a JIT access workflow.
+	StatusQuery = "status"
+	// MergeRequestUpdate merges an overlapping request for the same user into the running workflow.
+	MergeRequestUpdate = "merge_request"
+)
+
+// Error types returned when MergeRequestUpdate rejects a request.
+const (
+	// GrantConflictError means the user already has a grant in progress that cannot absorb the request.
+	GrantConflictError = "GrantConflict"
+	// GrantBusyError means the running workflow is starting up or closing; the caller should retry.
+	GrantBusyError = "GrantBusy"
+)
+
+// Grant states reported through StatusQuery.
+const (
+	StateEvaluating      = "evaluating"
+	StatePendingApproval = "pending_approval"
+	StateDenied          = "denied"
+	StateActive          = "active"
+	StateReverting       = "reverting"
+	StateReverted        = "reverted"
+	// StateRevertFailed is a grant whose role could not be reverted; the user still has it.
+	StateRevertFailed = "revert_failed"
+	// StateLegacy is a grant started before the policy engine, which cannot report its status.
+	StateLegacy = "legacy"
+)
+
+// policyEngineChange is the version marker of grants that run through the policy engine, the
+// audit log and approval. Grants started before it replay the grant, sleep and revert they
+// started with.
+const policyEngineChange = "policy-engine"
+
+// revertFailureChange is the version marker of grants that report a failed revert, with a
+// critical notification and the post-incident review of break-glass grants, before failing.
+const revertFailureChange = "revert-failure"
+
+// JITWorkflowID returns the workflow ID used for all JIT grants of a user.
+// Using one ID per user serializes grants: a second request cannot start a parallel
+// workflow and is merged into (or rejected by) the running one instead.
+func JITWorkflowID(username string) string {
+	return "jit_access_" + username
+}
+
 // JITAccessRequest defines the input for the JIT access workflow.
 type JITAccessRequest struct {
 	Username string
 	Reason   string
 	NewRole  string
 	Duration time.Duration
+	// Requester is the authenticated principal that submitted the request.
+	// It differs from Username when requesting on behalf of another user.
+	Requester string
+	// BreakGlass requests emergency access: granted immediately without approval,
+	// capped by the break-glass policy, and followed by a post-incident review.
+	BreakGlass  bool
+	IncidentRef string
+}
+
+// RequestedBy returns the principal responsible for the request.
+// Requests without a Requester are treated as made by the user themselves.
+func (r JITAccessRequest) RequestedBy() string {
+	if r.Requester != "" {
+		return r.Requester
+	}
+	return r.Username
+}
+
+// ApprovalDecision is the payload of ApprovalSignal.
+type ApprovalDecision struct {
+	Approved bool
+	Approver string
+	Comment  string
+}
+
+// ExtendRequest is the payload of ExtendSignal.
+type ExtendRequest struct {
+	Actor    string
+	Duration time.Duration
+	Reason   string
+}
+
+// RevokeRequest is the payload of RevokeSignal.
+type RevokeRequest struct {
+	Actor  string
+	Reason string
+}
+
+// GrantStatus describes where a JIT access workflow is in its lifecycle.
+type GrantStatus struct {
+	Username      string
+	Requester     string
+	NewRole       string
+	OriginalRole  string
+	State         string
+	PolicyVersion string
+	Approver      string
+	ExpiresAt     time.Time
+	Extensions    int
+	Merged        int
+	RevokedBy     string
+	BreakGlass    bool
+	IncidentRef   string
+	// ReviewWorkflowID is the post-incident review opened after a break-glass grant ends.
+	ReviewWorkflowID string
+	// WorkflowID is the grant's workflow, as listed by ListRunningGrants.
+	WorkflowID string
 }
 
 // JITAccessWorkflow is the Temporal workflow that performs the JIT access process.
 func JITAccessWorkflow(ctx workflow.Context, req JITAccessRequest) error {
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Starting JITAccessWorkflow", "username", req.Username, "new_role", req.NewRole, "duration", req.Duration)
+	if workflow.GetVersion(ctx, policyEngineChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return legacyJITAccess(ctx, req)
+	}
+
+	status := GrantStatus{
+		Username:    req.Username,
+		Requester:   req.RequestedBy(),
+		NewRole:     req.NewRole,
+		State:       StateEvaluating,
+		BreakGlass:  req.BreakGlass,
+		IncidentRef: req.IncidentRef,
+	}
+	if err := workflow.SetQueryHandler(ctx, StatusQuery, func() (GrantStatus, error) {
+		return status, nil
+	}); err != nil {
+		return err
+	}
 
 	activityOpts := workflow.ActivityOptions{
 		StartToCloseTimeout: 1 * time.Minute,
@@ -30,6 +158,85 @@ func JITAccessWorkflow(ctx workflow.Context, req JITAccessRequest) error {
 		},
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOpts)
+	audit := &auditor{req: req}
+
+	// Overlapping requests for the same user arrive as updates on this workflow.
+	var policy Policy
+	policyLoaded := false
+	wake := workflow.NewBufferedChannel(ctx, 1)
+	if err := workflow.SetUpdateHandlerWithOptions(ctx, MergeRequestUpdate,
+		func(ctx workflow.Context, other JITAccessRequest) (GrantStatus, error) {
+			ctx = workflow.WithActivityOptions(ctx, activityOpts)
+			return mergeRequest(ctx, &req, other, &status, audit, wake), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, other JITAccessRequest) error {
+				if !policyLoaded {
+					return temporal.NewApplicationError("grant is still being evaluated", GrantBusyError)
+				}
+				return validateMerge(ctx, &policy, req, other, status)
+			},
+		},
+	); err != nil {
+		return err
+	}
+
+	// Evaluate the request against the current policy.
+	if err := workflow.ExecuteActivity(ctx, LoadPolicyActivity).Get(ctx, &policy); err != nil {
+		logger.Error("failed to load policy", "error", err)
+		return err
+	}
+	policyLoaded = true
+	// Notifications are best effort, so a missing configuration only disables them.
+	notify := &notifier{req: req}
+	if err := workflow.ExecuteActivity(ctx, LoadNotifierSettingsActivity).Get(ctx, &notify.settings); err != nil {
+		logger.Warn("failed to load notifier settings, notifications disabled", "error", err)
+	}
+	decision := policy.Evaluate(req, workflow.Now(ctx))
+	status.PolicyVersion = decision.PolicyVersion
+	audit.policyVersion = decision.PolicyVersion
+
+	// No grant happens without an audit trail, so a failure to record the request is fatal.
+	requestDetails := map[string]string{"duration": req.Duration.String()}
+	if req.BreakGlass {
+		requestDetails["break_glass"] = "true"
+		requestDetails["incident_ref"] = req.IncidentRef
+	}
+	if err := audit.record(ctx, AuditRequested, req.RequestedBy(), req.Reason, requestDetails); err != nil {
+		return err
+	}
+
+	if !decision.Allowed {
+		status.State = StateDenied
+		logger.Warn("Request denied by policy", "policy_version", decision.PolicyVersion, "violations", decision.Violations)
+		violations := strings.Join(decision.Violations, "; ")
+		_ = audit.record(ctx, AuditDenied, "policy", violations, nil)
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("request denied by policy %s: %s", decision.PolicyVersion, violations),
+			"PolicyViolation", nil)
+	}
+
+	// Wait for an approver if the policy requires one.
+	if decision.RequireApproval {
+		status.State = StatePendingApproval
+		approval, err := awaitApproval(ctx, req, decision, policy.ApprovalWindow())
+		if err != nil {
+			status.State = StateDenied
+			_ = audit.record(ctx, AuditDenied, "policy", err.Error(), nil)
+			return err
+		}
+		status.Approver = approval.Approver
+		if !approval.Approved {
+			status.State = StateDenied
+			logger.Info("Request denied by approver", "approver", approval.Approver, "comment", approval.Comment)
+			_ = audit.record(ctx, AuditDenied, approval.Approver, approval.Comment, nil)
+			return temporal.NewNonRetryableApplicationError("request denied by "+approval.Approver, "RequestDenied", nil)
+		}
+		logger.Info("Request approved", "approver", approval.Approver)
+		if err := audit.record(ctx, AuditApproved, approval.Approver, approval.Comment, nil); err != nil {
+			return err
+		}
+	}
 
 	var originalRole string
 	// Fetch the user's current role.
@@ -38,6 +245,7 @@ func JITAccessWorkflow(ctx workflow.Context, req JITAccessRequest) error {
 		return err
 	}
 	logger.Info("Fetched current role", "username", req.Username, "current_role", originalRole)
+	status.OriginalRole = originalRole
 
 	// Ensure the new role is different
 	if originalRole == req.NewRole {
@@ -50,12 +258,127 @@ func JITAccessWorkflow(ctx workflow.Context, req JITAccessRequest) error {
 		return err
 	}
 	logger.Info("User role updated to new role", "username", req.Username, "new_role", req.NewRole)
+	status.State = StateActive
+	grantedAt := workflow.Now(ctx)
+	status.ExpiresAt = grantedAt.Add(req.Duration)
+	// From here on audit failures are only logged: nothing may block the revert.
+	_ = audit.record(ctx, AuditGranted, status.Approver, "", map[string]string{
+		"original_role": originalRole,
+		"expires_at":    status.ExpiresAt.Format(time.RFC3339),
+	})
+	if req.BreakGlass {
+		notify.send(ctx, NotifyBreakGlass, req.RequestedBy(), status)
+	} else {
+		notify.send(ctx, NotifyGranted, status.Approver, status)
+	}
 
-	// Wait for the duration.
-	logger.Info("Sleeping for duration", "duration", req.Duration)
-	workflow.Sleep(ctx, req.Duration)
+	// Extensions may never push expiry beyond the maximum duration from now; a break-glass
+	// grant can never outlive its cap measured from the moment it was granted.
+	maxExpiry := func() time.Time { return workflow.Now(ctx).Add(decision.MaxDuration) }
+	if req.BreakGlass {
+		hardLimit := grantedAt.Add(decision.MaxDuration)
+		maxExpiry = func() time.Time { return hardLimit }
+	}
+
+	// Hold the grant until it expires or is revoked.
+	logger.Info("Holding grant until expiry", "expires_at", status.ExpiresAt)
+	holdGrant(ctx, &policy, req, maxExpiry, &status, audit, notify, wake)
+	status.State = StateReverting
+
+	// Detect anything that changed the role behind our back before reverting.
+	var currentRole string
+	if err := workflow.ExecuteActivity(ctx, GetUserRoleActivity, req.Username).Get(ctx, &currentRole); err != nil {
+		logger.Warn("failed to check role before revert", "error", err)
+	} else if currentRole != req.NewRole {
+		logger.Warn("Role drift detected", "username", req.Username, "expected_role", req.NewRole, "current_role", currentRole)
+		_ = audit.record(ctx, AuditDriftDetected, "system", "role changed outside of JIT", map[string]string{
+			"expected_role": req.NewRole,
+			"current_role":  currentRole,
+		})
+	}
+
+	// Revert the user's role to the original role. When that fails the user keeps the role, so
+	// the failure is announced and a break-glass grant still gets its review before failing.
+	review := BreakGlassReview{
+		Request:          req,
+		GrantWorkflowID:  workflow.GetInfo(ctx).WorkflowExecution.ID,
+		PolicyVersion:    decision.PolicyVersion,
+		OriginalRole:     originalRole,
+		GrantedAt:        grantedAt,
+		RevokedBy:        status.RevokedBy,
+		ReminderInterval: policy.BreakGlass.ReviewReminderInterval(),
+		Policy:           &policy,
+	}
+	revertErr := workflow.ExecuteActivity(ctx, SetUserRoleActivity, req.Username, originalRole).Get(ctx, nil)
+	if revertErr != nil {
+		logger.Error("failed to revert user role", "error", revertErr)
+		if workflow.GetVersion(ctx, revertFailureChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+			return revertErr
+		}
+		status.State = StateRevertFailed
+		_ = audit.record(ctx, AuditRevertFailed, "system", revertErr.Error(), map[string]string{"original_role": originalRole})
+		notify.send(ctx, NotifyRevertFailed, "system", status)
+		review.RevertError = revertErr.Error()
+	} else {
+		logger.Info("User role reverted to original", "username", req.Username, "original_role", originalRole)
+		status.State = StateReverted
+		_ = audit.record(ctx, AuditReverted, "system", "", map[string]string{"original_role": originalRole})
+		revertedBy := "system"
+		if status.RevokedBy != "" {
+			revertedBy = status.RevokedBy
+		}
+		notify.send(ctx, NotifyReverted, revertedBy, status)
+		review.RevertedAt = workflow.Now(ctx)
+	}
+
+	if req.BreakGlass {
+		reviewID, err := openReview(ctx, review)
+		if err != nil {
+			logger.Error("failed to open post-incident review", "error", err)
+			if revertErr != nil {
+				return revertErr
+			}
+			return err
+		}
+		status.ReviewWorkflowID = reviewID
+	}
+
+	// Let any in-flight merge and notification finish before completing.
+	err := workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) && notify.pending == 0 })
+	if revertErr != nil {
+		return revertErr
+	}
+	return err
+}
+
+// legacyJITAccess is the grant of workflows started before policyEngineChange: it grants the
+// role without policy, audit or approval, sleeps for the duration and reverts it.
+func legacyJITAccess(ctx workflow.Context, req JITAccessRequest) error {
+	logger := workflow.GetLogger(ctx)
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    5 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    1 * time.Minute,
+			MaximumAttempts:    5,
+		},
+	})
 
-	// Revert the user's role to the original role.
+	var originalRole string
+	if err := workflow.ExecuteActivity(ctx, GetUserRoleActivity, req.Username).Get(ctx, &originalRole); err != nil {
+		logger.Error("failed to get user role", "error", err)
+		return err
+	}
+	if originalRole == req.NewRole {
+		return temporal.NewNonRetryableApplicationError("new_role cannot be same as current role", "InvalidRole", nil)
+	}
+	if err := workflow.ExecuteActivity(ctx, SetUserRoleActivity, req.Username, req.NewRole).Get(ctx, nil); err != nil {
+		logger.Error("failed to set new role", "error", err)
+		return err
+	}
+	logger.Info("User role updated to new role", "username", req.Username, "new_role", req.NewRole)
+	workflow.Sleep(ctx, req.Duration)
 	if err := workflow.ExecuteActivity(ctx, SetUserRoleActivity, req.Username, originalRole).Get(ctx, nil); err != nil {
 		logger.Error("failed to revert user role", "error", err)
 		return err
@@ -63,3 +386,311 @@ func JITAccessWorkflow(ctx workflow.Context, req JITAccessRequest) error {
 	logger.Info("User role reverted to original", "username", req.Username, "original_role", originalRole)
 	return nil
 }
+
+// holdGrant waits until the grant expires or is revoked, applying extensions as they arrive.
+// Extensions must pass validateExtension, and can never push expiry beyond maxExpiry().
+// Receiving on wake re-arms the expiry timer after a merged request moved ExpiresAt.
+// If the notifier has an expiry warning, the "expiring" notification is sent once per expiry time.
+func holdGrant(ctx workflow.Context, policy *Policy, req JITAccessRequest, maxExpiry func() time.Time, status *GrantStatus, audit *auditor, notify *notifier, wake workflow.ReceiveChannel) {
+	logger := workflow.GetLogger(ctx)
+	extensions := workflow.GetSignalChannel(ctx, ExtendSignal)
+	revocations := workflow.GetSignalChannel(ctx, RevokeSignal)
+	var warnedFor time.Time
+
+	for {
+		now := workflow.Now(ctx)
+		remaining := status.ExpiresAt.Sub(now)
+		if remaining <= 0 {
+			return
+		}
+		wait := remaining
+		warnAt := status.ExpiresAt.Add(-notify.settings.ExpiryWarning)
+		warning := notify.settings.ExpiryWarning > 0 && !warnedFor.Equal(status.ExpiresAt) && warnAt.After(now)
+		if warning {
+			wait = warnAt.Sub(now)
+		}
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, wait)
+
+		expired := false
+		var extend *ExtendRequest
+		var revoke *RevokeRequest
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(timer, func(f workflow.Future) {
+			expired = true
+		})
+		selector.AddReceive(extensions, func(c workflow.ReceiveChannel, more bool) {
+			extend = &ExtendRequest{}
+			c.Receive(ctx, extend)
+		})
+		selector.AddReceive(revocations, func(c workflow.ReceiveChannel, more bool) {
+			revoke = &RevokeRequest{}
+			c.Receive(ctx, revoke)
+		})
+		selector.AddReceive(wake, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+		})
+		selector.Select(ctx)
+		cancelTimer()
+
+		switch {
+		case expired && warning:
+			warnedFor = status.ExpiresAt
+			notify.send(ctx, NotifyExpiring, "system", *status)
+		case expired:
+			return
+		case revoke != nil:
+			logger.Info("Grant revoked", "actor", revoke.Actor, "reason", revoke.Reason)
+			status.RevokedBy = revoke.Actor
+			_ = audit.record(ctx, AuditRevoked, revoke.Actor, revoke.Reason, nil)
+			return
+		case extend != nil:
+			if extend.Duration <= 0 {
+				logger.Warn("Ignoring extension with non-positive duration", "actor", extend.Actor)
+				continue
+			}
+			newExpiry := status.ExpiresAt.Add(extend.Duration)
+			if limit := maxExpiry(); newExpiry.After(limit) {
+				newExpiry = limit
+			}
+			if !newExpiry.After(status.ExpiresAt) {
+				logger.Warn("Ignoring extension beyond the grant's maximum duration", "actor", extend.Actor)
+				continue
+			}
+			if err := validateExtension(ctx, policy, req, *extend, newExpiry); err != nil {
+				logger.Warn("Ignoring extension rejected by policy", "actor", extend.Actor, "error", err)
+				continue
+			}
+			logger.Info("Grant extended", "actor", extend.Actor, "expires_at", newExpiry)
+			status.ExpiresAt = newExpiry
+			status.Extensions++
+			_ = audit.record(ctx, AuditExtended, extend.Actor, extend.Reason, map[string]string{
+				"expires_at": newExpiry.Format(time.RFC3339),
+			})
+		}
+	}
+}
+
+// validateMerge decides whether an overlapping request can be merged into the running grant.
+// Only requests for the same role that the policy allows are merged; anything else is rejected
+// explicitly so the caller never ends up with two interleaved grants.
+func validateMerge(ctx workflow.Context, policy *Policy, req, other JITAccessRequest, status GrantStatus) error {
+	if other.Username != req.Username {
+		return temporal.NewApplicationError(
+			fmt.Sprintf("request for %s cannot be merged into the grant of %s", other.Username, req.Username),
+			GrantConflictError)
+	}
+	switch status.State {
+	case StateDenied, StateReverting, StateReverted:
+		return temporal.NewApplicationError("grant is closing, retry the request", GrantBusyError)
+	}
+	// Break-glass grants have a hard cap and their own review, so they never absorb or join other requests.
+	if req.BreakGlass || other.BreakGlass {
+		return temporal.NewApplicationError(
+			fmt.Sprintf("%s already has a %s grant in progress (%s); break-glass requests cannot be merged with it",
+				req.Username, req.NewRole, status.State),
+			GrantConflictError)
+	}
+	if other.NewRole != req.NewRole {
+		return temporal.NewApplicationError(
+			fmt.Sprintf("%s already has a %s grant in progress (%s); revoke it or wait for it to expire",
+				req.Username, req.NewRole, status.State),
+			GrantConflictError)
+	}
+	decision := policy.Evaluate(other, workflow.Now(ctx))
+	if !decision.Allowed {
+		return temporal.NewApplicationError(
+			fmt.Sprintf("request denied by policy %s: %s", decision.PolicyVersion, strings.Join(decision.Violations, "; ")),
+			"PolicyViolation")
+	}
+	if decision.RequireApproval && status.State == StateActive {
+		return temporal.NewApplicationError(
+			fmt.Sprintf("role %s requires approval, so an active grant cannot be extended by a new request", req.NewRole),
+			GrantConflictError)
+	}
+	// Approvers sign off on the duration of the request they were shown.
+	if decision.RequireApproval && other.Duration > req.Duration {
+		return temporal.NewApplicationError(
+			fmt.Sprintf("role %s requires approval, so a pending grant of %s cannot be lengthened to %s by a new request",
+				req.NewRole, req.Duration, other.Duration),
+			GrantConflictError)
+	}
+	return nil
+}
+
+// validateExtension decides whether an extension of the active grant to newExpiry is allowed.
+// It applies the same policy as validateMerge: the extension is evaluated as a request by its
+// actor for the time left until newExpiry, and roles that require approval are never extended
+// without a new, approved request.
+func validateExtension(ctx workflow.Context, policy *Policy, req JITAccessRequest, extend ExtendRequest, newExpiry time.Time) error {
+	if extend.Actor == "" {
+		return fmt.Errorf("extension has no actor")
+	}
+	now := workflow.Now(ctx)
+	other := req
+	other.Requester = extend.Actor
+	other.Reason = extend.Reason
+	other.Duration = newExpiry.Sub(now)
+	decision := policy.Evaluate(other, now)
+	if !decision.Allowed {
+		return fmt.Errorf("extension denied by policy %s: %s", decision.PolicyVersion, strings.Join(decision.Violations, "; "))
+	}
+	if decision.RequireApproval {
+		return fmt.Errorf("role %s requires approval, so an active grant cannot be extended", req.NewRole)
+	}
+	return nil
+}
+
+// mergeRequest folds a validated overlapping request into the running grant.
+// A pending request takes the longer duration, which validateMerge only allows for roles without
+// approval; an active grant is extended to cover the new request.
+func mergeRequest(ctx workflow.Context, req *JITAccessRequest, other JITAccessRequest, status *GrantStatus, audit *auditor, wake workflow.Channel) GrantStatus {
+	logger := workflow.GetLogger(ctx)
+	status.Merged++
+
+	if status.State != StateActive {
+		if other.Duration > req.Duration {
+			req.Duration = other.Duration
+		}
+		logger.Info("Merged request into pending grant", "duration", req.Duration)
+		return *status
+	}
+
+	newExpiry := workflow.Now(ctx).Add(other.Duration)
+	if newExpiry.After(status.ExpiresAt) {
+		status.ExpiresAt = newExpiry
+		status.Extensions++
+		logger.Info("Merged request extended active grant", "expires_at", newExpiry)
+		_ = audit.record(ctx, AuditExtended, other.RequestedBy(), other.Reason, map[string]string{
+			"expires_at": newExpiry.Format(time.RFC3339),
+			"merged":     "true",
+		})
+		wake.SendAsync(struct{}{})
+	}
+	return *status
+}
+
+// auditor records the audit events of a single workflow run.
+// Event IDs are derived from the run and a sequence number, so retried activities are deduplicated.
+type auditor struct {
+	req           JITAccessRequest
+	policyVersion string
+	seq           int
+}
+
+func (a *auditor) record(ctx workflow.Context, eventType, actor, reason string, details map[string]string) error {
+	info := workflow.GetInfo(ctx)
+	a.seq++
+	evt := AuditEvent{
+		ID:            fmt.Sprintf("%s/%s/%d", info.WorkflowExecution.ID, info.WorkflowExecution.RunID, a.seq),
+		Type:          eventType,
+		Username:      a.req.Username,
+		Role:          a.req.NewRole,
+		Actor:         actor,
+		Reason:        reason,
+		PolicyVersion: a.policyVersion,
+		WorkflowID:    info.WorkflowExecution.ID,
+		RunID:         info.WorkflowExecution.RunID,
+		Time:          workflow.Now(ctx),
+		Details:       details,
+	}
+	if err := workflow.ExecuteActivity(ctx, RecordAuditEventActivity, evt).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Error("failed to record audit event", "event", eventType, "error", err)
+		return err
+	}
+	return nil
+}
+
+// notifier sends lifecycle notifications to every subscribed sink.
+// Notifications about break-glass grants are critical and go to every sink.
+// Each sink is a separate activity with the sink's own retry policy. Delivery runs in the
+// background and failures are only logged, so a broken sink never delays or blocks a grant.
+type notifier struct {
+	req      JITAccessRequest
+	settings NotifierSettings
+	pending  int
+}
+
+func (n *notifier) send(ctx workflow.Context, event, actor string, status GrantStatus) {
+	info := workflow.GetInfo(ctx)
+	severity := SeverityInfo
+	if n.req.BreakGlass || event == NotifyRevertFailed {
+		severity = SeverityCritical
+	}
+	notification := Notification{
+		Event:        event,
+		Severity:     severity,
+		IncidentRef:  n.req.IncidentRef,
+		Username:     status.Username,
+		Role:         status.NewRole,
+		OriginalRole: status.OriginalRole,
+		Reason:       n.req.Reason,
+		Actor:        actor,
+		ExpiresAt:    status.ExpiresAt,
+		WorkflowID:   info.WorkflowExecution.ID,
+		Time:         workflow.Now(ctx),
+	}
+	for _, sink := range n.settings.Sinks {
+		// Critical notifications go to every sink, whatever events it subscribes to.
+		if severity != SeverityCritical && !sink.Subscribed(event) {
+			continue
+		}
+		sinkCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: 30 * time.Second,
+			RetryPolicy: &temporal.RetryPolicy{
+				InitialInterval:    time.Duration(sink.Retry.InitialInterval),
+				BackoffCoefficient: sink.Retry.BackoffCoefficient,
+				MaximumInterval:    time.Duration(sink.Retry.MaxInterval),
+				MaximumAttempts:    int32(sink.Retry.MaxAttempts),
+			},
+		})
+		future := workflow.ExecuteActivity(sinkCtx, NotifyActivity, sink.Name, notification)
+		sinkName := sink.Name
+		n.pending++
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			defer func() { n.pending-- }()
+			if err := future.Get(ctx, nil); err != nil {
+				workflow.GetLogger(ctx).Warn("failed to deliver notification", "sink", sinkName, "event", event, "error", err)
+			}
+		})
+	}
+}
+
+// awaitApproval blocks until a valid ApprovalSignal arrives or the approval window closes.
+// Approvals from the user, the requester, or from non-approvers are ignored.
+func awaitApproval(ctx workflow.Context, req JITAccessRequest, decision PolicyDecision, timeout time.Duration) (ApprovalDecision, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Waiting for approval", "timeout", timeout, "approvers", decision.Approvers)
+
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	defer cancelTimer()
+	timer := workflow.NewTimer(timerCtx, timeout)
+	approvals := workflow.GetSignalChannel(ctx, ApprovalSignal)
+
+	for {
+		var approval ApprovalDecision
+		timedOut := false
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(approvals, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, &approval)
+		})
+		selector.AddFuture(timer, func(f workflow.Future) {
+			timedOut = true
+		})
+		selector.Select(ctx)
+
+		if timedOut {
+			return ApprovalDecision{}, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("no approval received within %s", timeout), "ApprovalTimeout", nil)
+		}
+		if approval.Approver == "" || approval.Approver == req.Username || approval.Approver == req.RequestedBy() {
+			logger.Warn("Ignoring approval without an independent approver", "approver", approval.Approver)
+			continue
+		}
+		if len(decision.Approvers) > 0 && !slices.Contains(decision.Approvers, approval.Approver) {
+			logger.Warn("Ignoring approval from principal who is not an approver", "approver", approval.Approver)
+			continue
+		}
+		return approval, nil
+	}
+}
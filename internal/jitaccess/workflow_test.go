@@ -1,6 +1,8 @@
 package jitaccess_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -9,12 +11,60 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
 )
 
+// testPolicy allows elevatedRole without approval and approvalRole with approval from "approver".
+// Only "delegate" may request on behalf of other users.
+func testPolicy() *jitaccess.Policy {
+	return &jitaccess.Policy{
+		Version:    "test-1",
+		OnBehalfOf: []string{"delegate"},
+		Roles: []jitaccess.RolePolicy{
+			{Role: "elevatedRole", Principals: []string{"*"}, MaxDuration: jitaccess.Duration(time.Hour)},
+			{Role: "approvalRole", Principals: []string{"*"}, MaxDuration: jitaccess.Duration(time.Hour), RequireApproval: true, Approvers: []string{"approver"}},
+		},
+	}
+}
+
+// recordAuditEvents captures every audit event the workflow emits.
+func recordAuditEvents(env *testsuite.TestWorkflowEnvironment) *[]jitaccess.AuditEvent {
+	var events []jitaccess.AuditEvent
+	env.OnActivity(jitaccess.RecordAuditEventActivity, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, evt jitaccess.AuditEvent) error {
+			events = append(events, evt)
+			return nil
+		})
+	return &events
+}
+
+// recordNotifications configures the notifier settings and captures every notification sent.
+func recordNotifications(env *testsuite.TestWorkflowEnvironment, settings jitaccess.NotifierSettings) *[]jitaccess.Notification {
+	var notifications []jitaccess.Notification
+	env.OnActivity(jitaccess.LoadNotifierSettingsActivity, mock.Anything).Return(settings, nil)
+	env.OnActivity(jitaccess.NotifyActivity, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, sinkName string, n jitaccess.Notification) error {
+			notifications = append(notifications, n)
+			return nil
+		}).Maybe()
+	return &notifications
+}
+
+func auditTypes(events []jitaccess.AuditEvent) []string {
+	types := make([]string, 0, len(events))
+	for _, evt := range events {
+		types = append(types, evt.Type)
+	}
+	return types
+}
+
 func TestJITAccessWorkflow_Success(t *testing.T) {
 	var ts testsuite.WorkflowTestSuite
 	env := ts.NewTestWorkflowEnvironment()
 
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+	recordAuditEvents(env)
+	recordNotifications(env, jitaccess.NotifierSettings{})
 	// Stub GetUserRoleActivity: For any context and any string parameter, return "originalRole".
 	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, mock.AnythingOfType("string")).Return("originalRole", nil)
 	// Stub SetUserRoleActivity: For any context and any two string parameters, return nil.
@@ -33,3 +83,399 @@ func TestJITAccessWorkflow_Success(t *testing.T) {
 	require.True(t, env.IsWorkflowCompleted())
 	require.NoError(t, env.GetWorkflowError())
 }
+
+func TestJITAccessWorkflow_ReplaysGrantsStartedBeforePolicyEngine(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnGetVersion("policy-engine", workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", "atlasAdmin").Return(nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", "originalRole").Return(nil).Once()
+
+	// The grant predates the policy, so a role the policy denies is still granted and reverted.
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username: "testuser",
+		NewRole:  "atlasAdmin",
+		Duration: time.Hour,
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	env.AssertActivityNotCalled(t, "LoadPolicyActivity", mock.Anything)
+}
+
+func TestJITAccessWorkflow_PolicyViolation(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+	events := recordAuditEvents(env)
+	recordNotifications(env, jitaccess.NotifierSettings{})
+
+	req := jitaccess.JITAccessRequest{
+		Username: "testuser",
+		Reason:   "testing",
+		NewRole:  "atlasAdmin",
+		Duration: 10000 * time.Hour,
+	}
+
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, req)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "role atlasAdmin is not requestable")
+	env.AssertActivityNotCalled(t, "SetUserRoleActivity", mock.Anything, mock.Anything, mock.Anything)
+	require.Equal(t, []string{jitaccess.AuditRequested, jitaccess.AuditDenied}, auditTypes(*events))
+}
+
+func TestJITAccessWorkflow_Approval(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+	events := recordAuditEvents(env)
+	recordNotifications(env, jitaccess.NotifierSettings{})
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("approvalRole", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", "approvalRole").Return(nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", "originalRole").Return(nil).Once()
+
+	// Self-approval is ignored; the real approver's signal grants access.
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(jitaccess.ApprovalSignal, jitaccess.ApprovalDecision{Approved: true, Approver: "testuser"})
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(jitaccess.StatusQuery)
+		require.NoError(t, err)
+		var status jitaccess.GrantStatus
+		require.NoError(t, value.Get(&status))
+		require.Equal(t, jitaccess.StatePendingApproval, status.State)
+
+		env.SignalWorkflow(jitaccess.ApprovalSignal, jitaccess.ApprovalDecision{Approved: true, Approver: "approver"})
+	}, 2*time.Minute)
+	// A request cannot lengthen the grant the approver is asked about.
+	var lengthened error
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(jitaccess.MergeRequestUpdate, "longer", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { require.Fail(t, "longer request accepted") },
+			OnReject:   func(err error) { lengthened = err },
+			OnComplete: func(interface{}, error) {},
+		}, jitaccess.JITAccessRequest{Username: "testuser", Reason: "more", NewRole: "approvalRole", Duration: time.Hour})
+	}, 90*time.Second)
+
+	start := env.Now()
+	var expiresAt time.Time
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(jitaccess.StatusQuery)
+		require.NoError(t, err)
+		var status jitaccess.GrantStatus
+		require.NoError(t, value.Get(&status))
+		expiresAt = status.ExpiresAt
+	}, 150*time.Second)
+
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username: "testuser",
+		Reason:   "testing",
+		NewRole:  "approvalRole",
+		Duration: time.Minute,
+	})
+	require.True(t, jitaccess.IsApplicationError(lengthened, jitaccess.GrantConflictError), "lengthened: %v", lengthened)
+	require.WithinDuration(t, start.Add(3*time.Minute), expiresAt, time.Second)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	require.Equal(t, []string{
+		jitaccess.AuditRequested, jitaccess.AuditApproved, jitaccess.AuditGranted, jitaccess.AuditReverted,
+	}, auditTypes(*events))
+	require.Equal(t, "approver", (*events)[1].Actor)
+}
+
+func TestJITAccessWorkflow_OnBehalfOfRequesterCannotApprove(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+	events := recordAuditEvents(env)
+	recordNotifications(env, jitaccess.NotifierSettings{})
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("approvalRole", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", mock.AnythingOfType("string")).Return(nil)
+
+	// The delegate who submitted the request cannot approve it.
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(jitaccess.ApprovalSignal, jitaccess.ApprovalDecision{Approved: true, Approver: "delegate"})
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(jitaccess.StatusQuery)
+		require.NoError(t, err)
+		var status jitaccess.GrantStatus
+		require.NoError(t, value.Get(&status))
+		require.Equal(t, jitaccess.StatePendingApproval, status.State)
+		require.Equal(t, "delegate", status.Requester)
+
+		env.SignalWorkflow(jitaccess.ApprovalSignal, jitaccess.ApprovalDecision{Approved: true, Approver: "approver"})
+	}, 2*time.Minute)
+
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username:  "testuser",
+		Requester: "delegate",
+		Reason:    "testing",
+		NewRole:   "approvalRole",
+		Duration:  time.Minute,
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, jitaccess.AuditRequested, (*events)[0].Type)
+	require.Equal(t, "delegate", (*events)[0].Actor)
+	require.Equal(t, jitaccess.AuditApproved, (*events)[1].Type)
+	require.Equal(t, "approver", (*events)[1].Actor)
+}
+
+func TestJITAccessWorkflow_ApprovalTimeout(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+	recordAuditEvents(env)
+	recordNotifications(env, jitaccess.NotifierSettings{})
+
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username: "testuser",
+		Reason:   "testing",
+		NewRole:  "approvalRole",
+		Duration: time.Minute,
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "no approval received")
+	env.AssertActivityNotCalled(t, "SetUserRoleActivity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestJITAccessWorkflow_ExtendRevokeAndDrift(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+	events := recordAuditEvents(env)
+	recordNotifications(env, jitaccess.NotifierSettings{})
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	// Someone changed the role by hand while the grant was active.
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("dbOwner", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", mock.AnythingOfType("string")).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(jitaccess.ExtendSignal, jitaccess.ExtendRequest{Actor: "testuser", Duration: 5 * time.Hour, Reason: "still debugging"})
+	}, 5*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(jitaccess.StatusQuery)
+		require.NoError(t, err)
+		var status jitaccess.GrantStatus
+		require.NoError(t, value.Get(&status))
+		require.Equal(t, 1, status.Extensions)
+		// Extensions are capped at the role's one hour maximum from now.
+		require.WithinDuration(t, env.Now().Add(50*time.Minute), status.ExpiresAt, 0)
+
+		env.SignalWorkflow(jitaccess.RevokeSignal, jitaccess.RevokeRequest{Actor: "sre-lead", Reason: "incident closed"})
+	}, 15*time.Minute)
+
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username: "testuser",
+		Reason:   "testing",
+		NewRole:  "elevatedRole",
+		Duration: 10 * time.Minute,
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, []string{
+		jitaccess.AuditRequested, jitaccess.AuditGranted, jitaccess.AuditExtended,
+		jitaccess.AuditRevoked, jitaccess.AuditDriftDetected, jitaccess.AuditReverted,
+	}, auditTypes(*events))
+	env.AssertActivityCalled(t, "SetUserRoleActivity", mock.Anything, "testuser", "originalRole")
+
+	ids := make(map[string]bool)
+	for _, evt := range *events {
+		require.False(t, ids[evt.ID], "duplicate audit event ID %s", evt.ID)
+		ids[evt.ID] = true
+	}
+}
+
+func TestJITAccessWorkflow_ExtensionsFollowPolicy(t *testing.T) {
+	extend := func(t *testing.T, role string, extensions ...jitaccess.ExtendRequest) jitaccess.GrantStatus {
+		var ts testsuite.WorkflowTestSuite
+		env := ts.NewTestWorkflowEnvironment()
+		env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+		recordAuditEvents(env)
+		recordNotifications(env, jitaccess.NotifierSettings{})
+		env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+		env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return(role, nil).Once()
+		env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", mock.AnythingOfType("string")).Return(nil)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(jitaccess.ApprovalSignal, jitaccess.ApprovalDecision{Approved: true, Approver: "approver"})
+		}, time.Minute)
+
+		var status jitaccess.GrantStatus
+		for _, extension := range extensions {
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(jitaccess.ExtendSignal, extension)
+			}, 5*time.Minute)
+		}
+		env.RegisterDelayedCallback(func() {
+			value, err := env.QueryWorkflow(jitaccess.StatusQuery)
+			require.NoError(t, err)
+			require.NoError(t, value.Get(&status))
+		}, 6*time.Minute)
+
+		env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+			Username: "testuser",
+			Reason:   "testing",
+			NewRole:  role,
+			Duration: 10 * time.Minute,
+		})
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		return status
+	}
+
+	// Only the user and the principals allowed to act for them may extend a grant.
+	status := extend(t, "elevatedRole",
+		jitaccess.ExtendRequest{Actor: "mallory", Duration: time.Hour},
+		jitaccess.ExtendRequest{Duration: time.Hour},
+		jitaccess.ExtendRequest{Actor: "delegate", Duration: 5 * time.Minute},
+	)
+	require.Equal(t, 1, status.Extensions)
+
+	// Like a merged request, an extension cannot skip the approval of a role that requires one.
+	status = extend(t, "approvalRole", jitaccess.ExtendRequest{Actor: "testuser", Duration: time.Hour})
+	require.Equal(t, jitaccess.StateActive, status.State)
+	require.Zero(t, status.Extensions)
+}
+
+func TestJITAccessWorkflow_MergeOverlappingRequests(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+	events := recordAuditEvents(env)
+	recordNotifications(env, jitaccess.NotifierSettings{})
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("elevatedRole", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", "elevatedRole").Return(nil).Once()
+	// The original role must be restored exactly once, after the merged grant ends.
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", "originalRole").Return(nil).Once()
+
+	var merged jitaccess.GrantStatus
+	var conflict error
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(jitaccess.MergeRequestUpdate, "same-role", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { require.Fail(t, "same-role request rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				require.NoError(t, err)
+				merged = result.(jitaccess.GrantStatus)
+			},
+		}, jitaccess.JITAccessRequest{Username: "testuser", Reason: "testing", NewRole: "elevatedRole", Duration: 30 * time.Minute})
+	}, 2*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(jitaccess.MergeRequestUpdate, "other-role", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { require.Fail(t, "different-role request accepted") },
+			OnReject:   func(err error) { conflict = err },
+			OnComplete: func(interface{}, error) {},
+		}, jitaccess.JITAccessRequest{Username: "testuser", Reason: "testing", NewRole: "approvalRole", Duration: time.Minute})
+	}, 3*time.Minute)
+
+	start := env.Now()
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username: "testuser",
+		Reason:   "testing",
+		NewRole:  "elevatedRole",
+		Duration: 10 * time.Minute,
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	require.Equal(t, 1, merged.Merged)
+	require.WithinDuration(t, start.Add(32*time.Minute), merged.ExpiresAt, time.Second)
+	require.ErrorContains(t, conflict, "already has a elevatedRole grant in progress")
+	require.Equal(t, []string{
+		jitaccess.AuditRequested, jitaccess.AuditGranted, jitaccess.AuditExtended, jitaccess.AuditReverted,
+	}, auditTypes(*events))
+}
+
+func TestJITAccessWorkflow_Notifications(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+	recordAuditEvents(env)
+	notifications := recordNotifications(env, jitaccess.NotifierSettings{
+		ExpiryWarning: 5 * time.Minute,
+		Sinks:         []jitaccess.SinkSettings{{Name: "chat", Retry: jitaccess.SinkRetry{MaxAttempts: 3}}},
+	})
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("elevatedRole", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", mock.AnythingOfType("string")).Return(nil)
+
+	// The extension moves expiry, so the warning is sent again for the new expiry time.
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(jitaccess.ExtendSignal, jitaccess.ExtendRequest{Actor: "testuser", Duration: 10 * time.Minute})
+	}, 7*time.Minute)
+
+	start := env.Now()
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username: "testuser",
+		Reason:   "testing",
+		NewRole:  "elevatedRole",
+		Duration: 10 * time.Minute,
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	var events []string
+	for _, n := range *notifications {
+		events = append(events, n.Event)
+	}
+	require.Equal(t, []string{
+		jitaccess.NotifyGranted, jitaccess.NotifyExpiring, jitaccess.NotifyExpiring, jitaccess.NotifyReverted,
+	}, events)
+	require.WithinDuration(t, start.Add(5*time.Minute), (*notifications)[1].Time, time.Second)
+	require.WithinDuration(t, start.Add(15*time.Minute), (*notifications)[2].Time, time.Second)
+	require.Equal(t, "originalRole", (*notifications)[3].OriginalRole)
+}
+
+func TestJITAccessWorkflow_NotificationFailureDoesNotBlockGrant(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+
+	env.OnActivity(jitaccess.LoadPolicyActivity, mock.Anything).Return(testPolicy(), nil)
+	recordAuditEvents(env)
+	env.OnActivity(jitaccess.LoadNotifierSettingsActivity, mock.Anything).Return(jitaccess.NotifierSettings{
+		Sinks: []jitaccess.SinkSettings{{
+			Name:  "broken",
+			Retry: jitaccess.SinkRetry{MaxAttempts: 2, InitialInterval: jitaccess.Duration(time.Second), BackoffCoefficient: 2},
+		}},
+	}, nil)
+	env.OnActivity(jitaccess.NotifyActivity, mock.Anything, "broken", mock.Anything).Return(errors.New("sink down"))
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("originalRole", nil).Once()
+	env.OnActivity(jitaccess.GetUserRoleActivity, mock.Anything, "testuser").Return("elevatedRole", nil).Once()
+	env.OnActivity(jitaccess.SetUserRoleActivity, mock.Anything, "testuser", mock.AnythingOfType("string")).Return(nil)
+
+	env.ExecuteWorkflow(jitaccess.JITAccessWorkflow, jitaccess.JITAccessRequest{
+		Username: "testuser",
+		Reason:   "testing",
+		NewRole:  "elevatedRole",
+		Duration: time.Minute,
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertActivityCalled(t, "SetUserRoleActivity", mock.Anything, "testuser", "originalRole")
+	// Two attempts each for the granted and reverted notifications.
+	env.AssertNumberOfCalls(t, "NotifyActivity", 4)
+}
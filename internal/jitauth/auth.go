@@ -0,0 +1,160 @@
+// Package jitauth authenticates callers of the JIT access API.
+//
+// Callers present either an OIDC/JWT bearer token, validated against a local JWKS file,
+// or a static API token for automation. Either way the result is an Identity whose
+// Principal is used as the requester of JIT access requests.
+package jitauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Authentication methods reported in Identity.Method.
+const (
+	MethodJWT      = "jwt"
+	MethodAPIToken = "api_token"
+)
+
+// ErrUnauthenticated is returned when a request carries no usable credentials.
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Principal string
+	Method    string
+}
+
+// Config configures an Authenticator. At least one of JWT.JWKSFile and TokensFile is required.
+type Config struct {
+	JWT JWTConfig
+	// TokensFile is a JSON file of static API tokens, stored as SHA-256 hashes.
+	TokensFile string
+}
+
+// APIToken maps the SHA-256 hash of a static token to the principal it authenticates as.
+type APIToken struct {
+	Name      string `json:"name"`
+	Principal string `json:"principal"`
+	SHA256    string `json:"sha256"`
+}
+
+// Authenticator authenticates HTTP requests.
+type Authenticator struct {
+	jwt    *JWTValidator
+	tokens []APIToken
+}
+
+// NewAuthenticator loads the configured JWKS and token files.
+func NewAuthenticator(cfg Config) (*Authenticator, error) {
+	if cfg.JWT.JWKSFile == "" && cfg.TokensFile == "" {
+		return nil, fmt.Errorf("no authentication configured: set a JWKS file and/or an API tokens file")
+	}
+	a := &Authenticator{}
+	if cfg.JWT.JWKSFile != "" {
+		v, err := NewJWTValidator(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = v
+	}
+	if cfg.TokensFile != "" {
+		tokens, err := loadAPITokens(cfg.TokensFile)
+		if err != nil {
+			return nil, err
+		}
+		a.tokens = tokens
+	}
+	return a, nil
+}
+
+func loadAPITokens(path string) ([]APIToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API tokens file: %w", err)
+	}
+	var file struct {
+		Tokens []APIToken `json:"tokens"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse API tokens file %s: %w", path, err)
+	}
+	for i, t := range file.Tokens {
+		if t.Principal == "" {
+			return nil, fmt.Errorf("API token %q has no principal", t.Name)
+		}
+		hash, err := hex.DecodeString(t.SHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("API token %q: sha256 must be a hex-encoded SHA-256 hash", t.Name)
+		}
+		file.Tokens[i].SHA256 = strings.ToLower(t.SHA256)
+	}
+	return file.Tokens, nil
+}
+
+// HashToken returns the value to store in the tokens file for a static token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate derives the caller's identity from the Authorization header.
+// Tokens that look like JWTs are validated as JWTs; anything else is looked up as an API token.
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return Identity{}, ErrUnauthenticated
+	}
+	token = strings.TrimSpace(token)
+
+	if strings.Count(token, ".") == 2 && a.jwt != nil {
+		principal, err := a.jwt.Validate(token)
+		if err != nil {
+			return Identity{}, fmt.Errorf("invalid bearer token: %w", err)
+		}
+		return Identity{Principal: principal, Method: MethodJWT}, nil
+	}
+
+	hash := HashToken(token)
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(t.SHA256)) == 1 {
+			return Identity{Principal: t.Principal, Method: MethodAPIToken}, nil
+		}
+	}
+	return Identity{}, errors.New("invalid bearer token")
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the identity.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity set by Middleware.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// Middleware rejects unauthenticated requests with 401 and passes the caller's identity
+// to the next handler through the request context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="jit"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
+}
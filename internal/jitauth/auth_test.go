@@ -0,0 +1,218 @@
+package jitauth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"app/internal/jitauth"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testIssuer signs tokens with a key published in a JWKS file.
+type testIssuer struct {
+	key      *rsa.PrivateKey
+	kid      string
+	jwksFile string
+}
+
+func newTestIssuer(t *testing.T, kid string) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	iss := &testIssuer{key: key, kid: kid, jwksFile: filepath.Join(t.TempDir(), "jwks.json")}
+	iss.writeJWKS(t, iss)
+	return iss
+}
+
+// writeJWKS publishes the public keys of the given issuers.
+func (iss *testIssuer) writeJWKS(t *testing.T, issuers ...*testIssuer) {
+	var keys []map[string]string
+	for _, i := range issuers {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": i.kid,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		})
+	}
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(iss.jwksFile, data, 0o600))
+}
+
+func (iss *testIssuer) sign(t *testing.T, header, claims map[string]any) string {
+	h, err := json.Marshal(header)
+	require.NoError(t, err)
+	c, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, iss.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (iss *testIssuer) token(t *testing.T, claims map[string]any) string {
+	return iss.sign(t, map[string]any{"alg": "RS256", "typ": "JWT", "kid": iss.kid}, claims)
+}
+
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":                "https://idp.example.com",
+		"aud":                []string{"jit-api"},
+		"sub":                "00u1abcd",
+		"preferred_username": "demo-user",
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+func TestJWTValidator(t *testing.T) {
+	iss := newTestIssuer(t, "key-1")
+	v, err := jitauth.NewJWTValidator(jitauth.JWTConfig{
+		JWKSFile: iss.jwksFile,
+		Issuer:   "https://idp.example.com",
+		Audience: "jit-api",
+	})
+	require.NoError(t, err)
+
+	principal, err := v.Validate(iss.token(t, validClaims()))
+	require.NoError(t, err)
+	require.Equal(t, "demo-user", principal)
+
+	with := func(key string, value any) map[string]any {
+		c := validClaims()
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+	other := newTestIssuer(t, "key-1")
+	tests := map[string]struct {
+		token   string
+		wantErr string
+	}{
+		"expired":          {iss.token(t, with("exp", time.Now().Add(-time.Hour).Unix())), "expired"},
+		"no expiry":        {iss.token(t, with("exp", nil)), "no expiry"},
+		"not yet valid":    {iss.token(t, with("nbf", time.Now().Add(time.Hour).Unix())), "not valid yet"},
+		"wrong issuer":     {iss.token(t, with("iss", "https://evil.example.com")), "issuer"},
+		"wrong audience":   {iss.token(t, with("aud", "other-api")), "audience"},
+		"no username":      {iss.token(t, with("preferred_username", nil)), ""},
+		"alg none":         {iss.sign(t, map[string]any{"alg": "none", "kid": "key-1"}, validClaims()), "unsupported signing algorithm"},
+		"HMAC":             {iss.sign(t, map[string]any{"alg": "HS256", "kid": "key-1"}, validClaims()), "unsupported signing algorithm"},
+		"unknown key":      {iss.sign(t, map[string]any{"alg": "RS256", "kid": "key-9"}, validClaims()), "unknown key ID"},
+		"forged signature": {other.token(t, validClaims()), "invalid token signature"},
+		"malformed":        {"not-a-jwt", "malformed"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			principal, err := v.Validate(tt.token)
+			if tt.wantErr == "" {
+				// Without preferred_username the subject is used.
+				require.NoError(t, err)
+				require.Equal(t, "00u1abcd", principal)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestJWTValidator_KeyRotation(t *testing.T) {
+	iss := newTestIssuer(t, "key-1")
+	v, err := jitauth.NewJWTValidator(jitauth.JWTConfig{JWKSFile: iss.jwksFile})
+	require.NoError(t, err)
+
+	// A new key published to the same file is picked up without a restart.
+	rotated := newTestIssuer(t, "key-2")
+	rotated.jwksFile = iss.jwksFile
+	iss.writeJWKS(t, iss, rotated)
+
+	principal, err := v.Validate(rotated.token(t, validClaims()))
+	require.NoError(t, err)
+	require.Equal(t, "demo-user", principal)
+
+	// The file was just re-read for key-2, so a key published right after it is not seen
+	// until the reload interval has passed.
+	again := newTestIssuer(t, "key-3")
+	again.jwksFile = iss.jwksFile
+	again.writeJWKS(t, rotated, again)
+	_, err = v.Validate(again.token(t, validClaims()))
+	require.ErrorContains(t, err, "unknown key ID")
+}
+
+func TestAuthenticator_Middleware(t *testing.T) {
+	iss := newTestIssuer(t, "key-1")
+	tokensFile := filepath.Join(t.TempDir(), "tokens.json")
+	tokens, err := json.Marshal(map[string]any{"tokens": []jitauth.APIToken{
+		{Name: "nightly repair job", Principal: "ci-bot", SHA256: jitauth.HashToken("s3cret-ci-token")},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(tokensFile, tokens, 0o600))
+
+	auth, err := jitauth.NewAuthenticator(jitauth.Config{
+		JWT:        jitauth.JWTConfig{JWKSFile: iss.jwksFile},
+		TokensFile: tokensFile,
+	})
+	require.NoError(t, err)
+
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := jitauth.FromContext(r.Context())
+		require.True(t, ok)
+		w.Write([]byte(id.Method + ":" + id.Principal))
+	}))
+	call := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/jit-request", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("Bearer " + iss.token(t, validClaims()))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "jwt:demo-user", rec.Body.String())
+
+	rec = call("Bearer s3cret-ci-token")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "api_token:ci-bot", rec.Body.String())
+
+	for _, authorization := range []string{"", "Bearer wrong-token", "Basic ZGVtbzpkZW1v", "Bearer "} {
+		rec = call(authorization)
+		require.Equal(t, http.StatusUnauthorized, rec.Code, authorization)
+		require.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestNewAuthenticator_Invalid(t *testing.T) {
+	_, err := jitauth.NewAuthenticator(jitauth.Config{})
+	require.ErrorContains(t, err, "no authentication configured")
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, os.WriteFile(tokensFile, []byte(`{"tokens": [{"name": "plain", "principal": "ci-bot", "sha256": "s3cret"}]}`), 0o600))
+	_, err = jitauth.NewAuthenticator(jitauth.Config{TokensFile: tokensFile})
+	require.ErrorContains(t, err, "hex-encoded SHA-256")
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, []byte(`{"keys": []}`), 0o600))
+	_, err = jitauth.NewAuthenticator(jitauth.Config{JWT: jitauth.JWTConfig{JWKSFile: jwksFile}})
+	require.ErrorContains(t, err, "no RS256 signing keys")
+}
@@ -0,0 +1,265 @@
+package jitauth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew is the leeway allowed when checking exp, nbf and iat.
+const clockSkew = time.Minute
+
+// jwksReloadInterval is the least time between two reloads of the JWKS file for unknown key
+// IDs, so tokens with made-up key IDs cannot make the server re-read it on every request.
+const jwksReloadInterval = 10 * time.Second
+
+// JWTConfig configures bearer token validation.
+type JWTConfig struct {
+	// JWKSFile is a local JSON Web Key Set with the issuer's signing keys.
+	JWKSFile string
+	// Issuer and Audience are checked against the iss and aud claims when set.
+	Issuer   string
+	Audience string
+	// UsernameClaim names the claim holding the principal; defaults to preferred_username, then sub.
+	UsernameClaim string
+}
+
+// jwk is a single RSA key of a JSON Web Key Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWTValidator validates RS256-signed JWTs against the keys in a JWKS file.
+// The file is re-read when a token references an unknown key ID, at most once every
+// jwksReloadInterval, so keys can be rotated by replacing the file without restarting the server.
+type JWTValidator struct {
+	config JWTConfig
+	now    func() time.Time
+
+	mu   sync.RWMutex
+	keys map[string]*rsa.PublicKey
+	// reloaded is when an unknown key ID last made the validator re-read the file.
+	reloaded time.Time
+}
+
+// NewJWTValidator loads the JWKS file and returns a validator.
+func NewJWTValidator(cfg JWTConfig) (*JWTValidator, error) {
+	if cfg.JWKSFile == "" {
+		return nil, fmt.Errorf("JWKS file is required")
+	}
+	v := &JWTValidator{config: cfg, now: time.Now}
+	if err := v.reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *JWTValidator) reload() error {
+	data, err := os.ReadFile(v.config.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse JWKS file %s: %w", v.config.JWKSFile, err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("invalid key %q in JWKS file: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS file %s has no RS256 signing keys", v.config.JWKSFile)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+	return nil
+}
+
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("bad modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("bad exponent: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("unsupported exponent")
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	if key.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("RSA key must be at least 2048 bits")
+	}
+	return key, nil
+}
+
+func (v *JWTValidator) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	v.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	v.mu.Lock()
+	now := v.now()
+	throttled := !v.reloaded.IsZero() && now.Sub(v.reloaded) < jwksReloadInterval
+	if !throttled {
+		v.reloaded = now
+	}
+	v.mu.Unlock()
+	if throttled {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if err := v.reload(); err != nil {
+		return nil, err
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// claims holds the registered claims that are checked on every token.
+type claims struct {
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+	IssuedAt  *int64   `json:"iat"`
+}
+
+// audience accepts the aud claim as a single string or a list of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("aud must be a string or a list of strings")
+	}
+	*a = list
+	return nil
+}
+
+// Validate verifies the token's signature and claims and returns the principal it names.
+func (v *JWTValidator) Validate(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		Typ string `json:"typ"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", fmt.Errorf("malformed token header: %w", err)
+	}
+	// Only RS256 is accepted; in particular "none" and HMAC algorithms are rejected.
+	if header.Alg != "RS256" {
+		return "", fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return "", err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return "", errors.New("invalid token signature")
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return "", fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err := v.checkClaims(c); err != nil {
+		return "", err
+	}
+	var all map[string]any
+	if err := decodeSegment(parts[1], &all); err != nil {
+		return "", fmt.Errorf("malformed token claims: %w", err)
+	}
+	return v.principal(all)
+}
+
+func (v *JWTValidator) checkClaims(c claims) error {
+	now := v.now()
+	if c.ExpiresAt == nil {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(*c.ExpiresAt, 0).Add(clockSkew)) {
+		return errors.New("token has expired")
+	}
+	if c.NotBefore != nil && now.Add(clockSkew).Before(time.Unix(*c.NotBefore, 0)) {
+		return errors.New("token is not valid yet")
+	}
+	if c.IssuedAt != nil && now.Add(clockSkew).Before(time.Unix(*c.IssuedAt, 0)) {
+		return errors.New("token was issued in the future")
+	}
+	if v.config.Issuer != "" && c.Issuer != v.config.Issuer {
+		return fmt.Errorf("unexpected token issuer %q", c.Issuer)
+	}
+	if v.config.Audience != "" && !slices.Contains(c.Audience, v.config.Audience) {
+		return errors.New("token is not intended for this audience")
+	}
+	return nil
+}
+
+func (v *JWTValidator) principal(all map[string]any) (string, error) {
+	claimNames := []string{"preferred_username", "sub"}
+	if v.config.UsernameClaim != "" {
+		claimNames = []string{v.config.UsernameClaim}
+	}
+	for _, name := range claimNames {
+		if value, ok := all[name].(string); ok && value != "" {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("token has no %s claim", strings.Join(claimNames, " or "))
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
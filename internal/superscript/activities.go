@@ -2,17 +2,48 @@ package superscript
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"time"
 
-	"github.com/bitfield/script"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Application error types returned for requests that can never succeed.
+const (
+	UnknownScriptError       = "UnknownScript"
+	InvalidScriptParamsError = "InvalidScriptParams"
+	SandboxSetupError        = "SandboxSetupFailed"
 )
 
 // Activities holds the configuration for script execution activities
 type Activities struct {
 	ScriptBasePath string
 	Logger         slog.Logger
+	// Catalogue lists the scripts that may run; nil uses DefaultCatalogue.
+	Catalogue *Catalogue
+	// PaymentScript is the catalogue script RunPaymentCollectionScript runs; empty uses PaymentScriptName.
+	PaymentScript string
+	// Sources lists the order sources batches may name; nil has none.
+	Sources *SourceCatalogue
+	// Batches stores the orders and results of orchestrator batches; nil uses DefaultBatchDir.
+	Batches *BatchStore
+	// DeadLetters keeps the orders that failed; nil uses DefaultDeadLetterDir.
+	DeadLetters *DeadLetterStore
+	// Reports keeps the reports of completed batches; nil uses DefaultReportDir.
+	Reports ReportStore
+	// Faults injects faults into the scripts of requests without faults of their own.
+	Faults *FaultSpec
+	// AllowRequestFaults accepts the Faults of requests; otherwise such requests fail.
+	AllowRequestFaults bool
+	// AllowKillWorker lets faults inject FaultKillWorker; otherwise requests with it fail and
+	// the worker's Faults never kill it.
+	AllowKillWorker bool
+	// KillWorker is called for FaultKillWorker; nil kills the worker process.
+	KillWorker func()
 }
 
 // NewActivities creates a new instance of Activities
@@ -20,61 +51,262 @@ func NewActivities(scriptBasePath string, logger slog.Logger) *Activities {
 	return &Activities{
 		ScriptBasePath: scriptBasePath,
 		Logger:         logger,
+		Catalogue:      DefaultCatalogue(),
+		PaymentScript:  PaymentScriptName,
+		Batches:        NewBatchStore(""),
+		DeadLetters:    NewDeadLetterStore(""),
+		Reports:        NewDirReportStore(""),
 	}
 }
 
-// RunPaymentCollectionScript runs the single payment collection script for an OrderID
-// and returns the result in a standardized format
-func (a *Activities) RunPaymentCollectionScript(ctx context.Context, orderID string) (*PaymentResult, error) {
-	//logger := activity.GetLogger(ctx)
+// ScriptRequest names a catalogue script and the values of its parameters.
+type ScriptRequest struct {
+	Script string            `json:"script"`
+	Params map[string]string `json:"params,omitempty"`
+	// Faults injects faults for chaos testing, instead of the worker's faults.
+	Faults *FaultSpec `json:"faults,omitempty"`
+}
+
+// ScriptResult is the outcome of one script run.
+type ScriptResult struct {
+	Script        string        `json:"script"`
+	Success       bool          `json:"success"`
+	Output        string        `json:"output"`
+	ErrorMessage  string        `json:"error_message,omitempty"`
+	ExitCode      int           `json:"exit_code"`
+	ExecutionTime time.Duration `json:"execution_time"`
+	Timestamp     time.Time     `json:"timestamp"`
+	// OutputTruncated is set when Output was cut at the script's max_output.
+	OutputTruncated bool `json:"output_truncated,omitempty"`
+	// Results are the key/value results the script reported on its result descriptor.
+	Results map[string]string `json:"results,omitempty"`
+	// Failure is the last error the script reported, if it failed.
+	Failure *ScriptFailure `json:"failure,omitempty"`
+	// Outcome is how the run was classified, see OutcomeSuccess and friends.
+	Outcome string `json:"outcome,omitempty"`
+	// Attempt is the activity attempt that failed.
+	Attempt int32 `json:"attempt,omitempty"`
+	// Fault is the fault injected into the run, if any.
+	Fault string `json:"fault,omitempty"`
+}
+
+// RunScript runs a script from the catalogue with validated parameters.
+// Output is streamed as the script runs, and every line is recorded as a heartbeat with
+// ScriptProgress, so a script that stops making progress fails at ScriptHeartbeatTimeout.
+// Cancelling the activity, or reaching the script's timeout, kills its process group.
+// Records the script writes to its result descriptor (see ScriptRecord) become the Results and
+// Failure of the result. A non-zero exit is classified into an Outcome by scriptFailed.
+// The request's Faults, or the worker's, may inject a fault into the attempt, see FaultSpec.
+// Requests with faults the worker does not allow fail with FaultsDisabledError.
+func (a *Activities) RunScript(ctx context.Context, req ScriptRequest) (*ScriptResult, error) {
 	logger := slog.Default()
-	logger.Info("Starting payment collection activity", "orderID", orderID)
+	catalogue := a.Catalogue
+	if catalogue == nil {
+		catalogue = DefaultCatalogue()
+	}
+	spec, ok := catalogue.Script(req.Script)
+	if !ok {
+		return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("script %q is not in catalogue %s", req.Script, catalogue.Version), UnknownScriptError, nil)
+	}
+	args, err := spec.Args(req.Params)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), InvalidScriptParamsError, err)
+	}
+	if err := CheckFaults(req.Faults, a.AllowRequestFaults, a.AllowKillWorker); err != nil {
+		errType := InvalidScriptParamsError
+		if errors.Is(err, ErrRequestFaultsDisabled) || errors.Is(err, ErrKillWorkerDisabled) {
+			errType = FaultsDisabledError
+		}
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), errType, err)
+	}
+	command := scriptCommand{
+		Path: resolve(a.ScriptBasePath, spec.Path),
+		Args: args,
+		Env:  spec.Environ(os.LookupEnv),
+		Dir:  resolve(a.ScriptBasePath, spec.WorkDir),
+		// Output beyond the cap is dropped, so a chatty script cannot exhaust the worker's memory.
+		MaxOutput: spec.MaxOutputOrDefault(),
+		Sandbox:   spec.Sandbox,
+	}
+	timeout := spec.TimeoutOrDefault()
+	logger.Info("Executing script", "script", spec.Name, "path", command.Path, "args", args, "timeout", timeout, "sandboxed", spec.Sandbox != nil)
+	fault, faulty := a.pickFault(ctx, req, spec.Name, args)
+	if faulty {
+		logger.Warn("Injecting fault", "script", spec.Name, "args", args, "fault", fault.Kind, "exitCode", fault.ExitCode, "delay", fault.Delay)
+		command.Env = append(command.Env, fault.environ()...)
+		if fault.Kind == FaultKillWorker {
+			kill := a.KillWorker
+			if kill == nil {
+				kill = killWorker
+			}
+			timer := time.AfterFunc(fault.Delay, kill)
+			defer timer.Stop()
+		}
+	}
 
 	startTime := time.Now()
+	scriptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// Heartbeats are only possible when running as a Temporal activity.
+	heartbeat := activity.IsActivity(ctx)
+	out, err := runScript(scriptCtx, command, func(progress ScriptProgress) {
+		logger.Debug("Script output", "script", spec.Name, "line", progress.LastLine, "progress", progress.Message)
+		if heartbeat {
+			activity.RecordHeartbeat(ctx, progress)
+		}
+	})
+	if len(out.InvalidRecords) > 0 || out.InvalidDropped > 0 {
+		logger.Warn("Script wrote invalid result records", "script", spec.Name, "records", out.InvalidRecords, "dropped", out.InvalidDropped)
+	}
 
-	// Construct the command using the bitfield/script library
-	scriptPath := a.ScriptBasePath + "./scripts/single_payment_collection.sh"
-	if orderID == "4242" {
-		logger.Warn("Unit test for Activity .. 4242 ..")
-		scriptPath = a.ScriptBasePath + "./scripts/happy_payment_collection.sh"
-	}
-	cmdStr := fmt.Sprintf("%s %s", scriptPath, orderID)
-	logger.Info("Executing command", "command", cmdStr)
-
-	// Execute the script and capture output and exit code
-	execPipe := script.Exec(cmdStr)
-	output, err := execPipe.String()
-	//spew.Dump(output)
-	//spew.Dump(err)
-	// Assign exit code ..
-	exitCode := execPipe.ExitStatus()
+	result := &ScriptResult{
+		Script:        spec.Name,
+		Success:       err == nil && out.ExitCode == 0,
+		Output:        out.Output,
+		ExitCode:      out.ExitCode,
+		ExecutionTime: time.Since(startTime),
+		Timestamp:     time.Now(),
+
+		OutputTruncated: out.Truncated,
+		Fault:           fault.Kind,
+	}
+	result.Results, result.Failure = applyRecords(out.Records)
+	if out.Truncated {
+		logger.Warn("Script output truncated", "script", spec.Name, "maxOutput", command.MaxOutput)
+	}
+	if errors.Is(err, errSandboxSetup) {
+		// A sandbox that cannot be set up is a configuration error of the worker.
+		result.ErrorMessage = err.Error()
+		result.Outcome = OutcomeNonRetryable
+		logger.Error("Script sandbox setup failed", "script", spec.Name, "error", err)
+		return result, temporal.NewApplicationErrorWithOptions(err.Error(), SandboxSetupError, temporal.ApplicationErrorOptions{
+			NonRetryable: true,
+			Cause:        err,
+			Details:      []interface{}{*result},
+		})
+	}
 	if err != nil {
-		logger.Info("Script execution error", "error", err.Error())
-		//return nil, err
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			err = fmt.Errorf("script %s timed out after %s", spec.Name, timeout)
+		}
+		// The script could not start or was stopped; keep the output it produced so far.
+		result.ErrorMessage = err.Error()
+		result.Outcome = OutcomeRetryable
+		logger.Error("Script execution error", "script", spec.Name, "error", err, "output", out.Output)
+		return result, fmt.Errorf("script execution failed: %w", err)
+	}
+	if out.ExitCode != 0 {
+		return a.scriptFailed(ctx, spec, result)
 	}
+	result.Outcome = OutcomeSuccess
+	logger.Info("Script execution succeeded", "script", spec.Name, "executionTime", result.ExecutionTime)
+	return result, nil
+}
 
-	// Calculate execution time
-	executionTime := time.Since(startTime)
+// pickFault chooses the fault of this attempt from the request's faults, or else the worker's.
+// FaultKillWorker is only picked when the worker allows it.
+func (a *Activities) pickFault(ctx context.Context, req ScriptRequest, script string, args []string) (injectedFault, bool) {
+	faults := req.Faults
+	if faults == nil {
+		faults = a.Faults
+	}
+	attempt := int32(1)
+	if activity.IsActivity(ctx) {
+		attempt = activity.GetInfo(ctx).Attempt
+	}
+	fault, ok := faults.pick(script, args, attempt)
+	if ok && fault.Kind == FaultKillWorker && !a.AllowKillWorker {
+		return injectedFault{}, false
+	}
+	return fault, ok
+}
 
-	// Prepare result
-	result := &PaymentResult{
-		OrderID:       orderID,
-		Success:       exitCode == 0,
-		Output:        output,
-		ErrorMessage:  "",
-		ExitCode:      exitCode,
-		ExecutionTime: executionTime,
-		Timestamp:     time.Now(),
+// scriptFailed classifies a non-zero exit. The catalogue's rule for the exit code decides the
+// outcome; without one, the script's own error record does, and the failure is retryable otherwise.
+// Business failures complete the activity with Success false. Other failures return an application
+// error carrying the result as details, with the error code reported by the script as its type.
+func (a *Activities) scriptFailed(ctx context.Context, spec *ScriptSpec, result *ScriptResult) (*ScriptResult, error) {
+	logger := slog.Default()
+	result.ErrorMessage = fmt.Sprintf("Script failed with exit code: %d", result.ExitCode)
+	errType := ScriptErrorType
+	rule, hasRule := spec.ExitCodeRule(result.ExitCode)
+	if hasRule && rule.Reason != "" {
+		result.ErrorMessage = rule.Reason
+	}
+	if failure := result.Failure; failure != nil {
+		result.ErrorMessage = failure.Message
+		if failure.Code != "" {
+			errType = failure.Code
+		}
+	}
+	switch {
+	case hasRule:
+		result.Outcome = rule.Outcome
+	case result.Failure != nil && !result.Failure.Retryable:
+		result.Outcome = OutcomeNonRetryable
+	default:
+		result.Outcome = OutcomeRetryable
+	}
+	result.Attempt = 1
+	if activity.IsActivity(ctx) {
+		result.Attempt = activity.GetInfo(ctx).Attempt
 	}
 
-	if exitCode != 0 {
-		result.ErrorMessage = fmt.Sprintf("Script failed with exit code: %d", exitCode)
-		// We log the error but do not return it as an error to Temporal
-		// This way the workflow can properly handle the script failure
-		logger.Error("Script execution failed", "orderID", orderID, "exitCode", exitCode, "output", output)
-		return result, fmt.Errorf("Script execution failed with exit code: %d", exitCode)
+	if result.Outcome == OutcomeBusinessFailure {
+		logger.Warn("Script reported a business failure", "script", spec.Name, "exitCode", result.ExitCode, "reason", result.ErrorMessage)
+		return result, nil
+	}
+	message := fmt.Sprintf("Script execution failed with exit code %d: %s", result.ExitCode, result.ErrorMessage)
+	var nextRetryDelay time.Duration
+	if result.Outcome == OutcomeRetryable && hasRule && rule.Backoff != nil {
+		if rule.Backoff.MaxAttempts > 0 && result.Attempt >= rule.Backoff.MaxAttempts {
+			result.Outcome = OutcomeNonRetryable
+			message += fmt.Sprintf(" (gave up after %d attempts)", result.Attempt)
+		} else {
+			nextRetryDelay = rule.Backoff.Delay(result.Attempt)
+		}
 	}
-	logger.Info("Script execution succeeded", "orderID", orderID, "executionTime", executionTime)
+	// The result is also returned to the caller; the workflow decides how to handle the failure.
+	logger.Error("Script execution failed", "script", spec.Name, "exitCode", result.ExitCode, "outcome", result.Outcome, "failure", result.Failure, "output", result.Output)
+	return result, temporal.NewApplicationErrorWithOptions(message, errType, temporal.ApplicationErrorOptions{
+		NonRetryable:   result.Outcome == OutcomeNonRetryable,
+		Details:        []interface{}{*result},
+		NextRetryDelay: nextRetryDelay,
+	})
+}
 
-	return result, nil
+// RunPaymentCollectionScript runs the payment collection script for an OrderID
+// and returns the result in a standardized format. Faults, when set, are injected instead of
+// the worker's.
+func (a *Activities) RunPaymentCollectionScript(ctx context.Context, orderID string, faults *FaultSpec) (*PaymentResult, error) {
+	//logger := activity.GetLogger(ctx)
+	logger := slog.Default()
+	logger.Info("Starting payment collection activity", "orderID", orderID)
+
+	paymentScript := a.PaymentScript
+	if paymentScript == "" {
+		paymentScript = PaymentScriptName
+	}
+	result, err := a.RunScript(ctx, ScriptRequest{
+		Script: paymentScript,
+		Params: map[string]string{"order_id": orderID},
+		Faults: faults,
+	})
+	if result == nil {
+		return nil, err
+	}
+	return &PaymentResult{
+		OrderID:       orderID,
+		Success:       result.Success,
+		Output:        result.Output,
+		ErrorMessage:  result.ErrorMessage,
+		ExitCode:      result.ExitCode,
+		ExecutionTime: result.ExecutionTime,
+		Timestamp:     result.Timestamp,
+		Results:       result.Results,
+		Failure:       result.Failure,
+		Outcome:       result.Outcome,
+		Attempt:       result.Attempt,
+		Fault:         result.Fault,
+	}, err
 }
@@ -2,10 +2,15 @@ package superscript
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	gocmp "github.com/google/go-cmp/cmp"
+	"go.temporal.io/sdk/temporal"
 )
 
 func TestActivities_RunPaymentCollectionScript(t *testing.T) {
@@ -14,6 +19,7 @@ func TestActivities_RunPaymentCollectionScript(t *testing.T) {
 	type fields struct {
 		ScriptBasePath string
 		Logger         slog.Logger
+		PaymentScript  string
 	}
 	type args struct {
 		ctx     context.Context
@@ -36,12 +42,15 @@ func TestActivities_RunPaymentCollectionScript(t *testing.T) {
 			OrderID:      "ORD-1234",
 			Success:      false,
 			Output:       "ERROR: OrderID must be a number\nCleaning up resources...\nERROR: Script terminated with exit code: 2\n",
-			ErrorMessage: "Script failed with exit code: 2",
+			ErrorMessage: "OrderID must be a number",
 			ExitCode:     2,
+			Failure:      &ScriptFailure{Code: "INVALID_ORDER_ID", Message: "OrderID must be a number"},
+			Outcome:      OutcomeNonRetryable,
 		}, true},
 		{"case #1", fields{
 			ScriptBasePath: "./",
 			Logger:         *slog.Default(),
+			PaymentScript:  "happy_payment_collection",
 		}, args{
 			ctx:     context.Background(),
 			orderID: "4242",
@@ -51,6 +60,8 @@ func TestActivities_RunPaymentCollectionScript(t *testing.T) {
 			Output:       "Starting payment processing for OrderID: 4242\nStarting processing step 1...\nStep 1 completed successfully: Step1 4242\nPayment processing completed successfully for OrderID: 4242\nCleaning up resources...\n",
 			ErrorMessage: "",
 			ExitCode:     0,
+			Results:      map[string]string{"step1": "Step1 4242"},
+			Outcome:      OutcomeSuccess,
 		}, false},
 	}
 	for _, tt := range tests {
@@ -58,8 +69,9 @@ func TestActivities_RunPaymentCollectionScript(t *testing.T) {
 			a := &Activities{
 				ScriptBasePath: tt.fields.ScriptBasePath,
 				Logger:         tt.fields.Logger,
+				PaymentScript:  tt.fields.PaymentScript,
 			}
-			got, err := a.RunPaymentCollectionScript(tt.args.ctx, tt.args.orderID)
+			got, err := a.RunPaymentCollectionScript(tt.args.ctx, tt.args.orderID, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("RunPaymentCollectionScript() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -72,6 +84,9 @@ func TestActivities_RunPaymentCollectionScript(t *testing.T) {
 				Output:       got.Output,
 				ErrorMessage: got.ErrorMessage,
 				ExitCode:     got.ExitCode,
+				Results:      got.Results,
+				Failure:      got.Failure,
+				Outcome:      got.Outcome,
 			}
 			// Check subset
 			if !gocmp.Equal(gotSample, tt.want) {
@@ -84,3 +99,77 @@ func TestActivities_RunPaymentCollectionScript(t *testing.T) {
 		})
 	}
 }
+
+func TestActivities_RunPaymentCollectionScript_NonRetryable(t *testing.T) {
+	a := &Activities{ScriptBasePath: "./"}
+	_, err := a.RunPaymentCollectionScript(context.Background(), "ORD-1234", nil)
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || !appErr.NonRetryable() || appErr.Type() != "INVALID_ORDER_ID" {
+		t.Fatalf("RunPaymentCollectionScript() error = %v, want a non-retryable INVALID_ORDER_ID", err)
+	}
+	var details ScriptResult
+	if err := appErr.Details(&details); err != nil || details.ExitCode != 2 || details.Output == "" {
+		t.Errorf("error details = %+v, %v, want the script result", details, err)
+	}
+}
+
+func TestActivities_RunScript_ExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "exit.sh"), []byte("#!/bin/bash\necho \"exiting $1\"\nexit \"$1\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	catalogue := &Catalogue{Version: "test", Scripts: []ScriptSpec{{
+		Name:   "exit",
+		Path:   "exit.sh",
+		Params: []ParamSpec{{Name: "code", Pattern: "[0-9]+", Required: true}},
+		ExitCodes: []ExitCodeRule{
+			{Codes: []int{3}, Outcome: OutcomeBusinessFailure, Reason: "payment declined"},
+			{Codes: []int{4}, Outcome: OutcomeNonRetryable, Reason: "invalid order"},
+			{Codes: []int{5}, Outcome: OutcomeRetryable, Backoff: &BackoffSpec{Initial: Duration(10 * time.Second)}},
+			{Codes: []int{6}, Outcome: OutcomeRetryable, Backoff: &BackoffSpec{Initial: Duration(time.Second), MaxAttempts: 1}},
+		},
+	}}}
+	if err := catalogue.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := &Activities{ScriptBasePath: dir, Catalogue: catalogue}
+	run := func(code string) (*ScriptResult, *temporal.ApplicationError) {
+		result, err := a.RunScript(context.Background(), ScriptRequest{Script: "exit", Params: map[string]string{"code": code}})
+		var appErr *temporal.ApplicationError
+		if err != nil && !errors.As(err, &appErr) {
+			t.Fatalf("RunScript(%s) error = %v, want an application error", code, err)
+		}
+		return result, appErr
+	}
+
+	result, appErr := run("3")
+	if appErr != nil || result.Success || result.Outcome != OutcomeBusinessFailure || result.ErrorMessage != "payment declined" {
+		t.Errorf("business failure: %+v, %v", result, appErr)
+	}
+	result, appErr = run("4")
+	if appErr == nil || !appErr.NonRetryable() || result.Outcome != OutcomeNonRetryable {
+		t.Errorf("non-retryable: %+v, %v", result, appErr)
+	}
+	result, appErr = run("5")
+	if appErr == nil || appErr.NonRetryable() || appErr.NextRetryDelay() != 10*time.Second || result.Outcome != OutcomeRetryable {
+		t.Errorf("retryable with backoff: %+v, %v", result, appErr)
+	}
+	result, appErr = run("6")
+	if appErr == nil || !appErr.NonRetryable() || result.Outcome != OutcomeNonRetryable {
+		t.Errorf("retries exhausted: %+v, %v", result, appErr)
+	}
+	// Without a rule, the failure is retryable.
+	result, appErr = run("7")
+	if appErr == nil || appErr.NonRetryable() || appErr.Type() != ScriptErrorType || result.Outcome != OutcomeRetryable {
+		t.Errorf("unmapped: %+v, %v", result, appErr)
+	}
+}
+
+func TestBackoffSpec_Delay(t *testing.T) {
+	b := BackoffSpec{Initial: Duration(time.Second), Coefficient: 3, Max: Duration(20 * time.Second)}
+	for attempt, want := range map[int32]time.Duration{1: time.Second, 2: 3 * time.Second, 3: 9 * time.Second, 4: 20 * time.Second, 50: 20 * time.Second} {
+		if got := b.Delay(attempt); got != want {
+			t.Errorf("Delay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package superscript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Application error types of the orchestrator's activities.
+const (
+	// BatchNotFoundError is returned for batches that were never stored.
+	BatchNotFoundError = "BatchNotFound"
+	// InvalidBatchError is returned by the orchestrator for parameters it cannot run with.
+	InvalidBatchError = "InvalidBatch"
+	// PaymentRunningError is returned, and retried, while the payment workflow an order already
+	// had is still running.
+	PaymentRunningError = "PaymentRunning"
+	// PaymentNotFoundError is returned when the payment workflow of an order no longer exists.
+	PaymentNotFoundError = "PaymentWorkflowNotFound"
+)
+
+// StageOrdersRequest stores the orders of a batch.
+type StageOrdersRequest struct {
+	BatchID  string   `json:"batch_id"`
+	OrderIDs []string `json:"order_ids"`
+}
+
+// OrderPageRequest asks for up to Limit orders of a batch from Offset on.
+type OrderPageRequest struct {
+	BatchID string `json:"batch_id"`
+	Offset  int    `json:"offset"`
+	Limit   int    `json:"limit"`
+}
+
+// OrderPage is one page of the orders of a batch.
+type OrderPage struct {
+	OrderIDs []string `json:"order_ids"`
+	// Total is the number of orders in the batch.
+	Total int `json:"total"`
+}
+
+// RecordResultsRequest stores the results of the page of a batch starting at Offset.
+type RecordResultsRequest struct {
+	BatchID string          `json:"batch_id"`
+	Offset  int             `json:"offset"`
+	Results []PaymentResult `json:"results"`
+}
+
+func (a *Activities) batchStore() *BatchStore {
+	if a.Batches == nil {
+		return NewBatchStore("")
+	}
+	return a.Batches
+}
+
+func (a *Activities) deadLetterStore() *DeadLetterStore {
+	if a.DeadLetters == nil {
+		return NewDeadLetterStore("")
+	}
+	return a.DeadLetters
+}
+
+// StageBatchOrders stores the orders of a batch, so the orchestrator only keeps a cursor into
+// them in its history. It returns the number of orders.
+func (a *Activities) StageBatchOrders(ctx context.Context, req StageOrdersRequest) (int, error) {
+	if err := a.batchStore().SaveOrders(req.BatchID, req.OrderIDs); err != nil {
+		return 0, err
+	}
+	return len(req.OrderIDs), nil
+}
+
+// LoadOrderPage returns a page of the orders of a batch.
+func (a *Activities) LoadOrderPage(ctx context.Context, req OrderPageRequest) (*OrderPage, error) {
+	orderIDs, total, err := a.batchStore().LoadOrders(req.BatchID, req.Offset, req.Limit)
+	if errors.Is(err, ErrBatchNotFound) {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), BatchNotFoundError, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &OrderPage{OrderIDs: orderIDs, Total: total}, nil
+}
+
+// RecordBatchResults stores the results of one page of a batch, and adds its failed orders to
+// the dead-letter store. Retries overwrite the page and the dead letters.
+func (a *Activities) RecordBatchResults(ctx context.Context, req RecordResultsRequest) error {
+	if err := a.batchStore().SaveResults(req.BatchID, req.Offset, req.Results); err != nil {
+		return err
+	}
+	deadLetters := a.deadLetterStore()
+	for _, result := range req.Results {
+		if result.Success || result.OrderID == "" {
+			continue
+		}
+		if result.Status == PaymentDeduplicated {
+			// The failure is that of an earlier payment workflow; it was dead-lettered then, and
+			// may have been re-driven since.
+			if _, err := deadLetters.Get(result.OrderID); err == nil {
+				continue
+			}
+		}
+		failedAt := result.Timestamp
+		if failedAt.IsZero() {
+			failedAt = time.Now()
+		}
+		if err := deadLetters.AddFailure(req.BatchID, result, failedAt); err != nil {
+			return fmt.Errorf("failed to dead-letter order %s: %w", result.OrderID, err)
+		}
+	}
+	return nil
+}
+
+// AwaitPaymentResult returns the result of the payment workflow an order already has, or of
+// the re-drive that resolved it. While that workflow runs it fails with a retryable
+// PaymentRunningError, so the orchestrator's retry policy paces the wait without a
+// long-running activity. A workflow that failed gives a failed result with its error and what
+// its script reported.
+func (a *Activities) AwaitPaymentResult(ctx context.Context, orderID string) (*PaymentResult, error) {
+	c := activity.GetClient(ctx)
+	workflowID, err := a.paymentWorkflowOf(orderID)
+	if err != nil {
+		return nil, err
+	}
+	description, err := c.DescribeWorkflowExecution(ctx, workflowID, "")
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), PaymentNotFoundError, err)
+		}
+		return nil, err
+	}
+	if status := description.GetWorkflowExecutionInfo().GetStatus(); status == enums.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		return nil, temporal.NewApplicationError(fmt.Sprintf("payment workflow %s is still running", workflowID), PaymentRunningError)
+	}
+	var result PaymentResult
+	if err := c.GetWorkflow(ctx, workflowID, "").Get(ctx, &result); err != nil {
+		result = failedChildResult(orderID, err, time.Now())
+	}
+	result.WorkflowID = workflowID
+	return &result, nil
+}
+
+// paymentWorkflowOf returns the workflow whose result stands for the payment of an order: the
+// re-drive that resolved it, as its original workflow failed, or else the original workflow.
+func (a *Activities) paymentWorkflowOf(orderID string) (string, error) {
+	letter, err := a.deadLetterStore().Get(orderID)
+	if errors.Is(err, ErrDeadLetterNotFound) {
+		return PaymentWorkflowID(orderID), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if letter.Status == DeadLetterResolved && letter.LastWorkflowID != "" {
+		return letter.LastWorkflowID, nil
+	}
+	return PaymentWorkflowID(orderID), nil
+}
@@ -0,0 +1,169 @@
+package superscript
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultBatchDir is where batches are stored when SUPERSCRIPT_BATCH_DIR is not set.
+const DefaultBatchDir = "./superscript-batches"
+
+// ErrBatchNotFound is returned for batches that were never stored.
+var ErrBatchNotFound = errors.New("batch not found")
+
+var batchIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:@-]{1,200}$`)
+
+// BatchStore keeps the orders and per-order results of orchestrator batches outside of
+// workflow history, as files under Dir/<batch id>/:
+//   - orders.txt: one OrderID per line, in processing order,
+//   - results/<offset>.jsonl: the PaymentResults of the page starting at offset.
+//
+// Every file is written to a temporary file and renamed, so retried writes are idempotent.
+type BatchStore struct {
+	Dir string
+}
+
+// NewBatchStore returns a store under dir, or DefaultBatchDir when dir is empty.
+func NewBatchStore(dir string) *BatchStore {
+	if dir == "" {
+		dir = DefaultBatchDir
+	}
+	return &BatchStore{Dir: dir}
+}
+
+// ValidateBatchID checks that a batch ID can name a batch in the store: up to 200 letters,
+// digits and "_.:@-", and not only dots.
+func ValidateBatchID(batchID string) error {
+	if !batchIDPattern.MatchString(batchID) || strings.Trim(batchID, ".") == "" {
+		return fmt.Errorf("invalid batch ID %q", batchID)
+	}
+	return nil
+}
+
+func (s *BatchStore) batchDir(batchID string) (string, error) {
+	if err := ValidateBatchID(batchID); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, batchID), nil
+}
+
+// SaveOrders stores the orders of a batch, replacing any stored before.
+func (s *BatchStore) SaveOrders(batchID string, orderIDs []string) error {
+	dir, err := s.batchDir(batchID)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, id := range orderIDs {
+		if id == "" || strings.ContainsAny(id, "\r\n") {
+			return fmt.Errorf("invalid OrderID %q", id)
+		}
+		b.WriteString(id)
+		b.WriteByte('\n')
+	}
+	return writeFileAtomic(filepath.Join(dir, "orders.txt"), []byte(b.String()))
+}
+
+// LoadOrders returns up to limit orders of a batch from offset on, and how many orders
+// the batch has in total.
+func (s *BatchStore) LoadOrders(batchID string, offset, limit int) ([]string, int, error) {
+	dir, err := s.batchDir(batchID)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(filepath.Join(dir, "orders.txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	var page []string
+	total := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if total >= offset && len(page) < limit {
+			page = append(page, scanner.Text())
+		}
+		total++
+	}
+	return page, total, scanner.Err()
+}
+
+// SaveResults stores the results of the page of a batch that starts at offset.
+func (s *BatchStore) SaveResults(batchID string, offset int, results []PaymentResult) error {
+	dir, err := s.batchDir(batchID)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	for _, r := range results {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(filepath.Join(dir, "results", fmt.Sprintf("%09d.jsonl", offset)), []byte(b.String()))
+}
+
+// LoadResults returns every stored result of a batch, in order.
+func (s *BatchStore) LoadResults(batchID string) ([]PaymentResult, error) {
+	dir, err := s.batchDir(batchID)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := filepath.Glob(filepath.Join(dir, "results", "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
+		}
+	}
+	// Offsets are zero-padded, so the names sort in page order.
+	sort.Strings(pages)
+	var results []PaymentResult
+	for _, page := range pages {
+		data, err := os.ReadFile(page)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		for dec.More() {
+			var r PaymentResult
+			if err := dec.Decode(&r); err != nil {
+				return nil, fmt.Errorf("invalid results page %s: %w", page, err)
+			}
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
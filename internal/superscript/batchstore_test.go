@@ -0,0 +1,40 @@
+package superscript
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBatchStore(t *testing.T) {
+	store := NewBatchStore(t.TempDir())
+	if err := store.SaveOrders("b1", []string{"1", "2", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	page, total, err := store.LoadOrders("b1", 1, 5)
+	if err != nil || total != 3 || !reflect.DeepEqual(page, []string{"2", "3"}) {
+		t.Errorf("LoadOrders() = %v, %d, %v", page, total, err)
+	}
+	// Pages are keyed by offset, so a retried write replaces the page.
+	for _, results := range [][]PaymentResult{{{OrderID: "x"}}, {{OrderID: "2"}, {OrderID: "3"}}} {
+		if err := store.SaveResults("b1", 1, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.SaveResults("b1", 0, []PaymentResult{{OrderID: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	results, err := store.LoadResults("b1")
+	if err != nil || len(results) != 3 || results[0].OrderID != "1" || results[2].OrderID != "3" {
+		t.Errorf("LoadResults() = %+v, %v", results, err)
+	}
+
+	if _, _, err := store.LoadOrders("missing", 0, 1); !errors.Is(err, ErrBatchNotFound) {
+		t.Errorf("LoadOrders(missing) error = %v, want ErrBatchNotFound", err)
+	}
+	for _, id := range []string{"", "..", "a/b"} {
+		if err := store.SaveOrders(id, []string{"1"}); err == nil {
+			t.Errorf("SaveOrders(%q) error = nil, want an error", id)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package superscript
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// PaymentScriptName is the catalogue script RunPaymentCollectionScript runs by default.
+	PaymentScriptName = "single_payment_collection"
+
+	// DefaultScriptTimeout applies to scripts without a timeout in the catalogue.
+	DefaultScriptTimeout = 2 * time.Minute
+	// MaxScriptTimeout is the longest timeout a catalogue script may declare.
+	MaxScriptTimeout = time.Hour
+
+	// DefaultMaxOutput is how much output is kept of scripts without max_output.
+	DefaultMaxOutput = 1 << 20
+	// MaxOutputLimit is the largest max_output a catalogue script may declare.
+	MaxOutputLimit = 64 << 20
+)
+
+// Outcomes of a script run, decided by its exit code.
+const (
+	// OutcomeSuccess is a zero exit.
+	OutcomeSuccess = "success"
+	// OutcomeRetryable fails the activity so it is retried.
+	OutcomeRetryable = "retryable"
+	// OutcomeNonRetryable fails the activity without retries.
+	OutcomeNonRetryable = "non_retryable"
+	// OutcomeBusinessFailure completes the activity with Success false: the script did its job
+	// and the answer was no, such as a declined payment, so retrying cannot change it.
+	OutcomeBusinessFailure = "business_failure"
+)
+
+// defaultBackoffCoefficient applies to backoffs without a coefficient.
+const defaultBackoffCoefficient = 2.0
+
+// defaultParamPattern accepts values that are safe as a single argument of any script.
+const defaultParamPattern = `[A-Za-z0-9_.:@-]{1,256}`
+
+// defaultScriptPath is the PATH of scripts that do not pass PATH through from the worker.
+const defaultScriptPath = "/usr/local/bin:/usr/bin:/bin"
+
+var paramNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Duration is a time.Duration that reads and writes as a Go duration string ("1h30m") in JSON.
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// String formats the duration like time.Duration.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// UnmarshalJSON decodes a duration string such as "15m".
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Catalogue lists the scripts the runner may execute. Scripts that are not in the
+// catalogue cannot be run, and their parameters must match what the catalogue declares.
+type Catalogue struct {
+	Version string       `json:"version"`
+	Scripts []ScriptSpec `json:"scripts"`
+}
+
+// ScriptSpec declares one runnable script.
+type ScriptSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Path is the script to execute, relative to the runner's base path unless absolute.
+	// It is executed directly, never through a shell.
+	Path string `json:"path"`
+	// Params are passed as positional arguments in the order declared.
+	Params []ParamSpec `json:"params,omitempty"`
+	// Env lists worker environment variables passed through to the script.
+	// Every other variable of the worker is dropped.
+	Env []string `json:"env,omitempty"`
+	// SetEnv sets fixed environment variables for the script.
+	SetEnv map[string]string `json:"set_env,omitempty"`
+	// WorkDir is the working directory, relative to the base path unless absolute.
+	// Without it the script runs in the worker's working directory.
+	WorkDir string   `json:"work_dir,omitempty"`
+	Timeout Duration `json:"timeout,omitempty"`
+	// ExitCodes maps non-zero exit codes to outcomes. Codes without a rule are classified by
+	// the script's own error record, and are retryable otherwise.
+	ExitCodes []ExitCodeRule `json:"exit_codes,omitempty"`
+	// MaxOutput is how many bytes of output are kept (default DefaultMaxOutput). Output beyond
+	// it is read and dropped, and a truncation marker is added.
+	MaxOutput int `json:"max_output,omitempty"`
+	// Sandbox restricts the script's privileges and resources, see SandboxSpec.
+	Sandbox *SandboxSpec `json:"sandbox,omitempty"`
+}
+
+// ExitCodeRule decides the outcome of some exit codes.
+type ExitCodeRule struct {
+	Codes   []int  `json:"codes"`
+	Outcome string `json:"outcome"`
+	// Reason describes the outcome in the result, e.g. "invalid OrderID".
+	Reason string `json:"reason,omitempty"`
+	// Backoff overrides the workflow's retry interval for retryable outcomes.
+	Backoff *BackoffSpec `json:"backoff,omitempty"`
+}
+
+// BackoffSpec is a retry schedule enforced by the activity. The workflow's retry policy
+// still bounds the number of attempts.
+type BackoffSpec struct {
+	Initial     Duration `json:"initial"`
+	Coefficient float64  `json:"coefficient,omitempty"`
+	Max         Duration `json:"max,omitempty"`
+	// MaxAttempts makes the failure non-retryable from this attempt on.
+	MaxAttempts int32 `json:"max_attempts,omitempty"`
+}
+
+// Delay returns how long to wait before the attempt after the given one (starting at 1).
+func (b *BackoffSpec) Delay(attempt int32) time.Duration {
+	coefficient := b.Coefficient
+	if coefficient == 0 {
+		coefficient = defaultBackoffCoefficient
+	}
+	delay := float64(b.Initial)
+	for i := int32(1); i < attempt; i++ {
+		delay *= coefficient
+		if b.Max > 0 && delay >= float64(b.Max) {
+			return time.Duration(b.Max)
+		}
+	}
+	if b.Max > 0 && delay > float64(b.Max) {
+		return time.Duration(b.Max)
+	}
+	return time.Duration(delay)
+}
+
+// ExitCodeRule returns the rule for a non-zero exit code, if there is one.
+func (s *ScriptSpec) ExitCodeRule(exitCode int) (*ExitCodeRule, bool) {
+	for i := range s.ExitCodes {
+		for _, code := range s.ExitCodes[i].Codes {
+			if code == exitCode {
+				return &s.ExitCodes[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ParamSpec declares a script parameter.
+type ParamSpec struct {
+	Name string `json:"name"`
+	// Pattern must match the whole value; it defaults to letters, digits and "_.:@-".
+	Pattern  string `json:"pattern,omitempty"`
+	Required bool   `json:"required,omitempty"`
+	Default  string `json:"default,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// paymentExitCodes classifies the exit codes of the payment collection scripts. Exit code 1
+// is left unclassified: bash exits 1 for any failed command under "set -e", which is not
+// evidence of a bad OrderID.
+var paymentExitCodes = []ExitCodeRule{
+	{Codes: []int{2}, Outcome: OutcomeNonRetryable, Reason: "missing or invalid OrderID"},
+	{Codes: []int{75}, Outcome: OutcomeRetryable, Reason: "payment step failed", Backoff: &BackoffSpec{
+		Initial: Duration(time.Second), Coefficient: 2, Max: Duration(30 * time.Second),
+	}},
+}
+
+// DefaultCatalogue is used when no catalogue file is configured. It holds the payment
+// collection scripts shipped with this package.
+func DefaultCatalogue() *Catalogue {
+	orderID := []ParamSpec{{Name: "order_id", Pattern: `[A-Za-z0-9_-]{1,64}`, Required: true}}
+	c := &Catalogue{
+		Version: "builtin-1",
+		Scripts: []ScriptSpec{
+			{
+				Name:        PaymentScriptName,
+				Description: "Collect the payment of one order",
+				Path:        "scripts/single_payment_collection.sh",
+				Params:      orderID,
+				Timeout:     Duration(DefaultScriptTimeout),
+				ExitCodes:   paymentExitCodes,
+			},
+			{
+				Name:        "happy_payment_collection",
+				Description: "Payment collection that always succeeds, for tests",
+				Path:        "scripts/happy_payment_collection.sh",
+				Params:      orderID,
+				Timeout:     Duration(DefaultScriptTimeout),
+				ExitCodes:   paymentExitCodes,
+			},
+		},
+	}
+	if err := c.Validate(); err != nil {
+		panic(fmt.Sprintf("invalid built-in catalogue: %v", err))
+	}
+	return c
+}
+
+// LoadCatalogue reads a catalogue from a JSON file. An empty path returns DefaultCatalogue.
+func LoadCatalogue(path string) (*Catalogue, error) {
+	if path == "" {
+		return DefaultCatalogue(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script catalogue: %w", err)
+	}
+	var c Catalogue
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse script catalogue %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid script catalogue %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Validate checks the catalogue and compiles parameter patterns.
+func (c *Catalogue) Validate() error {
+	if c.Version == "" {
+		return fmt.Errorf("version is required")
+	}
+	seen := make(map[string]bool)
+	for i := range c.Scripts {
+		s := &c.Scripts[i]
+		if s.Name == "" || s.Path == "" {
+			return fmt.Errorf("script %d: name and path are required", i)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("script %s: duplicate name", s.Name)
+		}
+		seen[s.Name] = true
+		if s.Timeout < 0 || time.Duration(s.Timeout) > MaxScriptTimeout {
+			return fmt.Errorf("script %s: timeout must be between 0 and %s", s.Name, MaxScriptTimeout)
+		}
+		for name := range s.SetEnv {
+			if name == "" || strings.ContainsAny(name, "=\x00") {
+				return fmt.Errorf("script %s: invalid set_env name %q", s.Name, name)
+			}
+		}
+		if err := s.validateExitCodes(); err != nil {
+			return fmt.Errorf("script %s: %w", s.Name, err)
+		}
+		if s.MaxOutput < 0 || s.MaxOutput > MaxOutputLimit {
+			return fmt.Errorf("script %s: max_output must be between 0 and %d", s.Name, MaxOutputLimit)
+		}
+		if s.Sandbox != nil {
+			if err := s.Sandbox.validate(s); err != nil {
+				return fmt.Errorf("script %s: %w", s.Name, err)
+			}
+		}
+		params := make(map[string]bool)
+		for j := range s.Params {
+			p := &s.Params[j]
+			if !paramNamePattern.MatchString(p.Name) {
+				return fmt.Errorf("script %s: invalid parameter name %q", s.Name, p.Name)
+			}
+			if params[p.Name] {
+				return fmt.Errorf("script %s: duplicate parameter %s", s.Name, p.Name)
+			}
+			params[p.Name] = true
+			pattern := p.Pattern
+			if pattern == "" {
+				pattern = defaultParamPattern
+			}
+			re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+			if err != nil {
+				return fmt.Errorf("script %s: parameter %s: invalid pattern: %w", s.Name, p.Name, err)
+			}
+			p.re = re
+			if p.Default != "" {
+				if err := p.check(p.Default); err != nil {
+					return fmt.Errorf("script %s: default of %w", s.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ScriptSpec) validateExitCodes() error {
+	seen := make(map[int]bool)
+	for _, rule := range s.ExitCodes {
+		if len(rule.Codes) == 0 {
+			return fmt.Errorf("exit code rule without codes")
+		}
+		for _, code := range rule.Codes {
+			if code <= 0 || code > 255 {
+				return fmt.Errorf("exit code %d must be between 1 and 255", code)
+			}
+			if seen[code] {
+				return fmt.Errorf("exit code %d has more than one rule", code)
+			}
+			seen[code] = true
+		}
+		switch rule.Outcome {
+		case OutcomeRetryable, OutcomeNonRetryable, OutcomeBusinessFailure:
+		default:
+			return fmt.Errorf("exit codes %v: unknown outcome %q", rule.Codes, rule.Outcome)
+		}
+		if b := rule.Backoff; b != nil {
+			if rule.Outcome != OutcomeRetryable {
+				return fmt.Errorf("exit codes %v: backoff only applies to retryable outcomes", rule.Codes)
+			}
+			if b.Initial <= 0 || b.Max < 0 || (b.Max > 0 && b.Max < b.Initial) || b.MaxAttempts < 0 || (b.Coefficient != 0 && b.Coefficient < 1) {
+				return fmt.Errorf("exit codes %v: invalid backoff", rule.Codes)
+			}
+		}
+	}
+	return nil
+}
+
+// Script returns the catalogue entry with the given name.
+func (c *Catalogue) Script(name string) (*ScriptSpec, bool) {
+	for i := range c.Scripts {
+		if c.Scripts[i].Name == name {
+			return &c.Scripts[i], true
+		}
+	}
+	return nil, false
+}
+
+// Args validates params against the declared parameters and returns them as positional
+// arguments. Unknown parameters, missing required ones and values that do not match their
+// pattern are rejected.
+func (s *ScriptSpec) Args(params map[string]string) ([]string, error) {
+	declared := make(map[string]bool, len(s.Params))
+	for _, p := range s.Params {
+		declared[p.Name] = true
+	}
+	var unknown []string
+	for name := range params {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("script %s has no parameter %s", s.Name, strings.Join(unknown, ", "))
+	}
+
+	var args []string
+	for _, p := range s.Params {
+		value, ok := params[p.Name]
+		if !ok || value == "" {
+			if p.Required {
+				return nil, fmt.Errorf("script %s: parameter %s is required", s.Name, p.Name)
+			}
+			value = p.Default
+		}
+		if value == "" {
+			// Optional parameters keep their position as an empty argument.
+			args = append(args, "")
+			continue
+		}
+		if err := p.check(value); err != nil {
+			return nil, fmt.Errorf("script %s: %w", s.Name, err)
+		}
+		args = append(args, value)
+	}
+	return args, nil
+}
+
+func (p *ParamSpec) check(value string) error {
+	// Values starting with "-" could be taken as options, whatever the pattern says.
+	if strings.HasPrefix(value, "-") || strings.ContainsFunc(value, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return fmt.Errorf("parameter %s: value %q is not allowed", p.Name, value)
+	}
+	if !p.re.MatchString(value) {
+		return fmt.Errorf("parameter %s: value %q does not match %s", p.Name, value, p.re)
+	}
+	return nil
+}
+
+// Environ returns the environment of the script: the allow-listed variables of the worker,
+// looked up with lookup, then SetEnv. PATH falls back to a minimal default.
+func (s *ScriptSpec) Environ(lookup func(string) (string, bool)) []string {
+	env := map[string]string{"PATH": defaultScriptPath}
+	for _, name := range s.Env {
+		if value, ok := lookup(name); ok {
+			env[name] = value
+		}
+	}
+	for name, value := range s.SetEnv {
+		env[name] = value
+	}
+	environ := make([]string, 0, len(env))
+	for name, value := range env {
+		environ = append(environ, name+"="+value)
+	}
+	sort.Strings(environ)
+	return environ
+}
+
+// TimeoutOrDefault returns the script's timeout, or DefaultScriptTimeout.
+func (s *ScriptSpec) TimeoutOrDefault() time.Duration {
+	if s.Timeout > 0 {
+		return time.Duration(s.Timeout)
+	}
+	return DefaultScriptTimeout
+}
+
+// MaxOutputOrDefault returns the script's output cap, or DefaultMaxOutput.
+func (s *ScriptSpec) MaxOutputOrDefault() int {
+	if s.MaxOutput > 0 {
+		return s.MaxOutput
+	}
+	return DefaultMaxOutput
+}
+
+// resolve returns path relative to basePath unless it is absolute.
+func resolve(basePath, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(basePath, path)
+}
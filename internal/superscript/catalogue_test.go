@@ -0,0 +1,165 @@
+package superscript
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestLoadCatalogue_Example(t *testing.T) {
+	c, err := LoadCatalogue("../../demo/superscript/catalogue.example.json")
+	if err != nil {
+		t.Fatalf("LoadCatalogue() error = %v", err)
+	}
+	spec, ok := c.Script("rotate_logs")
+	if !ok {
+		t.Fatal("rotate_logs not found")
+	}
+	if spec.TimeoutOrDefault() != 15*time.Minute {
+		t.Errorf("timeout = %s, want 15m", spec.TimeoutOrDefault())
+	}
+	args, err := spec.Args(map[string]string{"service": "payments"})
+	if err != nil {
+		t.Fatalf("Args() error = %v", err)
+	}
+	if want := []string{"payments", "7"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("Args() = %v, want %v", args, want)
+	}
+}
+
+func TestScriptSpec_Args(t *testing.T) {
+	spec := DefaultCatalogue().Scripts[0]
+	tests := map[string]struct {
+		params  map[string]string
+		wantErr string
+	}{
+		"valid":             {map[string]string{"order_id": "4242"}, ""},
+		"missing required":  {map[string]string{}, "is required"},
+		"unknown parameter": {map[string]string{"order_id": "4242", "amount": "10"}, "has no parameter amount"},
+		"shell injection":   {map[string]string{"order_id": "4242; rm -rf /"}, "does not match"},
+		"command subst":     {map[string]string{"order_id": "$(id)"}, "does not match"},
+		"option injection":  {map[string]string{"order_id": "--help"}, "is not allowed"},
+		"newline":           {map[string]string{"order_id": "42\n42"}, "is not allowed"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := spec.Args(tt.params)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Args() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Args() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScriptSpec_ExitCodeRule_Payment(t *testing.T) {
+	spec := DefaultCatalogue().Scripts[0]
+	if rule, ok := spec.ExitCodeRule(2); !ok || rule.Outcome != OutcomeNonRetryable {
+		t.Errorf("exit code 2 rule = %+v, want non_retryable", rule)
+	}
+	// Bash exits 1 for any failed command under "set -e", so it must stay retryable.
+	if rule, ok := spec.ExitCodeRule(1); ok {
+		t.Errorf("exit code 1 rule = %+v, want none", rule)
+	}
+}
+
+func TestLoadCatalogue_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"missing version":  `{"scripts": []}`,
+		"missing path":     `{"version": "1", "scripts": [{"name": "a"}]}`,
+		"duplicate name":   `{"version": "1", "scripts": [{"name": "a", "path": "a.sh"}, {"name": "a", "path": "b.sh"}]}`,
+		"timeout over max": `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "timeout": "2h"}]}`,
+		"bad pattern":      `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "params": [{"name": "p", "pattern": "("}]}]}`,
+		"bad param name":   `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "params": [{"name": "Order ID"}]}]}`,
+		"default mismatch": `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "params": [{"name": "p", "pattern": "[0-9]+", "default": "x"}]}]}`,
+		"bad set_env name": `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "set_env": {"A=B": "c"}}]}`,
+		"duplicate param":  `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "params": [{"name": "p"}, {"name": "p"}]}]}`,
+		"negative timeout": `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "timeout": "-1m"}]}`,
+		"max_output over":  `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "max_output": 1073741824}]}`,
+		"sandbox with env": `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "env": ["HOME"], "sandbox": {}}]}`,
+		"sandbox dirs":     `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "work_dir": "w", "sandbox": {"temp_dir": true}}]}`,
+		"sandbox memory":   `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "sandbox": {"memory_mb": 1}}]}`,
+		"sandbox cpu":      `{"version": "1", "scripts": [{"name": "a", "path": "a.sh", "sandbox": {"cpu_time": "10ms"}}]}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "catalogue.json")
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadCatalogue(path); err == nil {
+				t.Error("LoadCatalogue() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestActivities_RunScript(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "env.sh")
+	body := "#!/bin/bash\necho \"arg=$1 pwd=$(pwd) allowed=${ALLOWED_VAR:-} secret=${SECRET_VAR:-} fixed=${FIXED_VAR:-}\"\nsleep \"${2:-0}\"\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	workDir := filepath.Join(dir, "work")
+	if err := os.Mkdir(workDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ALLOWED_VAR", "yes")
+	t.Setenv("SECRET_VAR", "leaked")
+
+	catalogue := &Catalogue{Version: "test", Scripts: []ScriptSpec{{
+		Name:    "env",
+		Path:    "env.sh",
+		Params:  []ParamSpec{{Name: "value", Required: true}, {Name: "sleep", Pattern: "[0-9]"}},
+		Env:     []string{"ALLOWED_VAR"},
+		SetEnv:  map[string]string{"FIXED_VAR": "set"},
+		WorkDir: "work",
+		Timeout: Duration(time.Second),
+	}}}
+	if err := catalogue.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a := &Activities{ScriptBasePath: dir, Catalogue: catalogue}
+
+	result, err := a.RunScript(context.Background(), ScriptRequest{Script: "env", Params: map[string]string{"value": "a b"}})
+	if err == nil {
+		t.Fatal("RunScript() accepted a value with a space")
+	}
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != InvalidScriptParamsError || !appErr.NonRetryable() {
+		t.Errorf("RunScript() error = %v, want a non-retryable %s", err, InvalidScriptParamsError)
+	}
+
+	result, err = a.RunScript(context.Background(), ScriptRequest{Script: "env", Params: map[string]string{"value": "x"}})
+	if err != nil {
+		t.Fatalf("RunScript() error = %v", err)
+	}
+	want := "arg=x pwd=" + workDir + " allowed=yes secret= fixed=set\n"
+	if result.Output != want || !result.Success {
+		t.Errorf("RunScript() = %+v, want output %q", result, want)
+	}
+
+	// The per-script timeout kills the script.
+	result, err = a.RunScript(context.Background(), ScriptRequest{Script: "env", Params: map[string]string{"value": "x", "sleep": "5"}})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 1s") || result.Output == "" {
+		t.Errorf("RunScript() = %+v, %v, want a timeout with partial output", result, err)
+	}
+
+	_, err = a.RunScript(context.Background(), ScriptRequest{Script: "rm"})
+	if !errors.As(err, &appErr) || appErr.Type() != UnknownScriptError {
+		t.Errorf("RunScript() error = %v, want %s", err, UnknownScriptError)
+	}
+}
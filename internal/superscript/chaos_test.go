@@ -0,0 +1,131 @@
+package superscript
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+)
+
+// TestChaos_Orchestrator runs batches with injected faults through a Temporal server, such as
+// temporal server start-dev, at SUPERSCRIPT_CHAOS_TEMPORAL_HOST. It is skipped without one.
+func TestChaos_Orchestrator(t *testing.T) {
+	host := os.Getenv("SUPERSCRIPT_CHAOS_TEMPORAL_HOST")
+	if host == "" {
+		t.Skip("SUPERSCRIPT_CHAOS_TEMPORAL_HOST is not set")
+	}
+	c, err := client.Dial(client.Options{HostPort: host})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	dir := t.TempDir()
+	executionLog := filepath.Join(dir, "executions.log")
+	catalogue := DefaultCatalogue()
+	spec, _ := catalogue.Script(PaymentScriptName)
+	// The script's own failures are off, so every failure is an injected one.
+	spec.SetEnv = map[string]string{
+		"SUPERSCRIPT_STEP1_FAIL_PCT":      "0",
+		"SUPERSCRIPT_STEP2_TIMEOUT_PCT":   "0",
+		"SUPERSCRIPT_STEP2_GIBBERISH_PCT": "0",
+		"SUPERSCRIPT_SLEEP_PCT":           "0",
+		"SUPERSCRIPT_EXECUTION_LOG":       executionLog,
+	}
+	a := &Activities{
+		ScriptBasePath: "./",
+		Catalogue:      catalogue,
+		PaymentScript:  PaymentScriptName,
+		Batches:        NewBatchStore(filepath.Join(dir, "batches")),
+		DeadLetters:    NewDeadLetterStore(filepath.Join(dir, "dlq")),
+		Reports:        NewDirReportStore(filepath.Join(dir, "reports")),
+		// The batches bring their own faults.
+		AllowRequestFaults: true,
+	}
+	w := worker.New(c, SuperscriptTaskQueue, worker.Options{})
+	w.RegisterWorkflow(OrchestratorWorkflow)
+	w.RegisterWorkflow(SinglePaymentCollectionWorkflow)
+	w.RegisterActivity(a)
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	prefix := fmt.Sprint(time.Now().Unix())
+	orders := make([]string, 20)
+	for i := range orders {
+		orders[i] = fmt.Sprintf("%s%03d", prefix, i)
+	}
+	faults := &FaultSpec{Seed: time.Now().UnixNano(), ExitPct: 20, SlowPct: 20, SlowDelay: Duration(100 * time.Millisecond), PartialPct: 20, Attempts: 2}
+	run := func(batchID string) BatchResult {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		workflowRun, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{ID: batchID, TaskQueue: SuperscriptTaskQueue}, OrchestratorWorkflowType, OrchestratorWorkflowParams{
+			BatchID:       batchID,
+			OrderIDs:      orders,
+			MaxConcurrent: 5,
+			Faults:        faults,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result BatchResult
+		if err := workflowRun.Get(ctx, &result); err != nil {
+			t.Fatalf("%s: %v", batchID, err)
+		}
+		return result
+	}
+
+	// Faults only hit the first two attempts, so the retries collect every order.
+	result := run("chaos-" + prefix)
+	if result.SuccessCount != len(orders) || result.DeduplicatedCount != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	collections := countCollections(t, executionLog)
+	for _, orderID := range orders {
+		// The script is not idempotent: a partial failure collected the payment before the
+		// attempt failed, and its retry collected it again.
+		want := 1
+		for attempt := int32(1); attempt <= faults.Attempts; attempt++ {
+			if fault, ok := faults.pick(PaymentScriptName, []string{orderID}, attempt); ok && fault.Kind == FaultPartial {
+				want++
+			}
+		}
+		if collections[orderID] != want {
+			t.Errorf("order %s was collected %d times, want %d", orderID, collections[orderID], want)
+		}
+	}
+
+	// Running the orders again finds their payment workflows, and runs no script.
+	result = run("chaos-again-" + prefix)
+	if result.SuccessCount != len(orders) || result.DeduplicatedCount != len(orders) {
+		t.Errorf("result of the second batch = %+v", result)
+	}
+	for orderID, n := range countCollections(t, executionLog) {
+		if n != collections[orderID] {
+			t.Errorf("order %s was collected again by the second batch", orderID)
+		}
+	}
+}
+
+// countCollections counts the collected payments per order in an execution log of
+// single_payment_collection.sh.
+func countCollections(t *testing.T, path string) map[string]int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collections := make(map[string]int)
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) == 4 && fields[3] == "collected" {
+			collections[fields[2]]++
+		}
+	}
+	return collections
+}
@@ -0,0 +1,272 @@
+package superscript
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// Signals, queries and updates of OrchestratorWorkflow.
+const (
+	// PauseSignal stops the orchestrator from starting new children; running ones finish.
+	PauseSignal = "pause"
+	// ResumeSignal lets a paused orchestrator start children again.
+	ResumeSignal = "resume"
+	// ProgressQuery returns the OrchestratorProgress of a batch.
+	ProgressQuery = "progress"
+	// EventsQuery returns the recent ProgressEvents of a batch with a Seq above its argument.
+	EventsQuery = "events"
+	// SetConcurrencyUpdate changes MaxConcurrent of a running batch and returns the previous value.
+	SetConcurrencyUpdate = "set-concurrency"
+
+	// MaxConcurrencyLimit is the highest MaxConcurrent a running batch can be set to.
+	MaxConcurrencyLimit = 100
+
+	// maxProgressEvents is how many of the latest events a run keeps for EventsQuery.
+	maxProgressEvents = 500
+	// eventOutputBytes is how much of the end of a child's output its event carries.
+	eventOutputBytes = 512
+)
+
+// Progress event types.
+const (
+	ChildStartedEvent   = "started"
+	ChildCompletedEvent = "completed"
+	ChildFailedEvent    = "failed"
+)
+
+// ProgressEvent is a child of a batch starting or ending.
+type ProgressEvent struct {
+	// Seq numbers the events of a batch from 1, across continue-as-new. A gap means events
+	// were dropped, e.g. by continue-as-new or because a client fell too far behind.
+	Seq     int64     `json:"seq"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	OrderID string    `json:"order_id"`
+	// Status, ExitCode, Outcome, Results, Error and Output are those of ended children; Output
+	// is the end of what the script printed, if the child returned it.
+	Status        string            `json:"status,omitempty"`
+	ExitCode      int               `json:"exit_code,omitempty"`
+	Outcome       string            `json:"outcome,omitempty"`
+	ExecutionTime Duration          `json:"execution_time,omitempty"`
+	Results       map[string]string `json:"results,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Output        string            `json:"output,omitempty"`
+}
+
+// OrchestratorProgress is how far a batch is. Counts cover every run of the batch.
+type OrchestratorProgress struct {
+	BatchID      string `json:"batch_id"`
+	TotalCount   int    `json:"total_count"`
+	Scheduled    int    `json:"scheduled"`
+	Completed    int    `json:"completed"`
+	SuccessCount int    `json:"success_count"`
+	FailCount    int    `json:"fail_count"`
+	// InFlight are the OrderIDs whose children are running.
+	InFlight      []string `json:"in_flight"`
+	MaxConcurrent int      `json:"max_concurrent"`
+	Paused        bool     `json:"paused"`
+	// Cancelling is set once the batch was cancelled and waits for its in-flight children.
+	Cancelling bool `json:"cancelling"`
+	// Runs is the number of runs completed before the current one.
+	Runs int `json:"runs"`
+	// RateLimit is the most children started per second, if limited.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	// RecentFailureRate is the failure rate backpressure watches, and Backoff the least time
+	// between children it currently imposes.
+	RecentFailureRate float64  `json:"recent_failure_rate,omitempty"`
+	Backoff           Duration `json:"backoff,omitempty"`
+}
+
+// orchestratorControl is the state of a batch that operators can see and change while it runs.
+type orchestratorControl struct {
+	cursor        *BatchCursor
+	maxConcurrent int
+	// page, scheduled and completed are the progress of the current page, on top of the cursor.
+	page                 *BatchResult
+	scheduled, completed int
+	// inFlight counts the running children per OrderID, running all of them.
+	inFlight   map[string]int
+	running    int
+	cancelling bool
+	throttle   *throttle
+	// faults are injected into the payment scripts of the children, see FaultSpec.
+	faults *FaultSpec
+	// events are the latest events of this run, oldest first.
+	events []ProgressEvent
+}
+
+// childStarted and childDone track the running children.
+func (c *orchestratorControl) childStarted(ctx workflow.Context, orderID string) {
+	c.scheduled++
+	c.running++
+	c.inFlight[orderID]++
+	c.addEvent(ProgressEvent{Time: workflow.Now(ctx), Type: ChildStartedEvent, OrderID: orderID})
+}
+
+func (c *orchestratorControl) childDone(ctx workflow.Context, result PaymentResult) {
+	c.completed++
+	c.running--
+	if c.inFlight[result.OrderID]--; c.inFlight[result.OrderID] == 0 {
+		delete(c.inFlight, result.OrderID)
+	}
+	event := ProgressEvent{
+		Time:          workflow.Now(ctx),
+		Type:          ChildCompletedEvent,
+		OrderID:       result.OrderID,
+		Status:        result.Status,
+		ExitCode:      result.ExitCode,
+		Outcome:       result.Outcome,
+		ExecutionTime: Duration(result.ExecutionTime),
+		Results:       result.Results,
+		Output:        result.Output,
+	}
+	if !result.Success {
+		event.Type = ChildFailedEvent
+		event.Error = failureReason(result)
+	}
+	if len(event.Output) > eventOutputBytes {
+		event.Output = "[...]" + event.Output[len(event.Output)-eventOutputBytes:]
+	}
+	c.addEvent(event)
+	if change := c.throttle.completed(result.Success); change != "" {
+		rate, _ := c.throttle.failureRate()
+		workflow.GetLogger(ctx).Warn("Backpressure "+change, "failureRate", rate, "delay", c.throttle.backoff())
+	}
+}
+
+// addEvent numbers an event and keeps it, dropping the oldest beyond maxProgressEvents.
+func (c *orchestratorControl) addEvent(event ProgressEvent) {
+	c.cursor.EventSeq++
+	event.Seq = c.cursor.EventSeq
+	c.events = append(c.events, event)
+	if len(c.events) > maxProgressEvents {
+		c.events = append(c.events[:0:0], c.events[len(c.events)-maxProgressEvents:]...)
+	}
+}
+
+// eventsAfter returns the kept events with a Seq above seq.
+func (c *orchestratorControl) eventsAfter(seq int64) []ProgressEvent {
+	i := sort.Search(len(c.events), func(i int) bool { return c.events[i].Seq > seq })
+	return append([]ProgressEvent{}, c.events[i:]...)
+}
+
+func (c *orchestratorControl) startPage(page *BatchResult) {
+	c.page, c.scheduled, c.completed = page, 0, 0
+}
+
+// endPage moves the counts of the current page, whose first done orders ran, into the cursor.
+func (c *orchestratorControl) endPage(done int) {
+	c.cursor.Offset += done
+	c.cursor.SuccessCount += c.page.SuccessCount
+	c.cursor.FailCount += c.page.FailCount
+	c.cursor.DeduplicatedCount += c.page.DeduplicatedCount
+	c.page, c.scheduled, c.completed = nil, 0, 0
+}
+
+// setUpHandlers registers the progress and events queries, the pause and resume signals and
+// the set-concurrency update.
+func (c *orchestratorControl) setUpHandlers(ctx workflow.Context, batchID string) error {
+	c.inFlight = make(map[string]int)
+	if err := workflow.SetQueryHandler(ctx, ProgressQuery, func() (OrchestratorProgress, error) {
+		return c.progress(batchID), nil
+	}); err != nil {
+		return err
+	}
+	if err := workflow.SetQueryHandler(ctx, EventsQuery, func(seq int64) ([]ProgressEvent, error) {
+		return c.eventsAfter(seq), nil
+	}); err != nil {
+		return err
+	}
+	if err := workflow.SetUpdateHandlerWithOptions(ctx, SetConcurrencyUpdate,
+		func(ctx workflow.Context, maxConcurrent int) (int, error) {
+			previous := c.maxConcurrent
+			c.maxConcurrent = maxConcurrent
+			workflow.GetLogger(ctx).Info("Concurrency changed", "batchID", batchID, "from", previous, "to", maxConcurrent)
+			return previous, nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, maxConcurrent int) error {
+				if maxConcurrent < 1 || maxConcurrent > MaxConcurrencyLimit {
+					return fmt.Errorf("max concurrent must be between 1 and %d", MaxConcurrencyLimit)
+				}
+				return nil
+			},
+		},
+	); err != nil {
+		return err
+	}
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for ctx.Err() == nil {
+			selector := workflow.NewSelector(ctx)
+			c.addSignals(ctx, selector)
+			selector.Select(ctx)
+		}
+	})
+	return nil
+}
+
+func (c *orchestratorControl) addSignals(ctx workflow.Context, selector workflow.Selector) {
+	logger := workflow.GetLogger(ctx)
+	selector.AddReceive(workflow.GetSignalChannel(ctx, PauseSignal), func(ch workflow.ReceiveChannel, more bool) {
+		ch.Receive(ctx, nil)
+		c.cursor.Paused = true
+		logger.Info("Batch paused", "offset", c.cursor.Offset+c.scheduled)
+	})
+	selector.AddReceive(workflow.GetSignalChannel(ctx, ResumeSignal), func(ch workflow.ReceiveChannel, more bool) {
+		ch.Receive(ctx, nil)
+		c.cursor.Paused = false
+		logger.Info("Batch resumed", "offset", c.cursor.Offset+c.scheduled)
+	})
+}
+
+// drainSignals applies the signals that arrived but were not handled yet, so none are lost
+// when the workflow continues as new.
+func (c *orchestratorControl) drainSignals(ctx workflow.Context) {
+	for {
+		selector := workflow.NewSelector(ctx)
+		c.addSignals(ctx, selector)
+		if !selector.HasPending() {
+			return
+		}
+		selector.Select(ctx)
+	}
+}
+
+// canSchedule reports whether another child may start now.
+func (c *orchestratorControl) canSchedule(ctx workflow.Context) bool {
+	return ctx.Err() == nil && !c.cursor.Paused && c.running < c.maxConcurrent
+}
+
+func (c *orchestratorControl) progress(batchID string) OrchestratorProgress {
+	inFlight := make([]string, 0, c.running)
+	for orderID, n := range c.inFlight {
+		for i := 0; i < n; i++ {
+			inFlight = append(inFlight, orderID)
+		}
+	}
+	sort.Strings(inFlight)
+	progress := OrchestratorProgress{
+		BatchID:       batchID,
+		TotalCount:    c.cursor.TotalCount,
+		Scheduled:     c.cursor.Offset + c.scheduled,
+		Completed:     c.cursor.Offset + c.completed,
+		SuccessCount:  c.cursor.SuccessCount,
+		FailCount:     c.cursor.FailCount,
+		InFlight:      inFlight,
+		MaxConcurrent: c.maxConcurrent,
+		Paused:        c.cursor.Paused,
+		Cancelling:    c.cancelling,
+		Runs:          c.cursor.Runs,
+		RateLimit:     c.throttle.rate,
+		Backoff:       Duration(c.throttle.backoff()),
+	}
+	progress.RecentFailureRate, _ = c.throttle.failureRate()
+	if c.page != nil {
+		progress.SuccessCount += c.page.SuccessCount
+		progress.FailCount += c.page.FailCount
+	}
+	return progress
+}
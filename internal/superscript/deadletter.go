@@ -0,0 +1,181 @@
+package superscript
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultDeadLetterDir is where failed orders are kept when SUPERSCRIPT_DLQ_DIR is not set.
+const DefaultDeadLetterDir = "./superscript-dlq"
+
+// Dead-letter statuses.
+const (
+	// DeadLetterFailed orders are waiting to be inspected or re-driven.
+	DeadLetterFailed = "failed"
+	// DeadLetterResolved orders succeeded when they were re-driven.
+	DeadLetterResolved = "resolved"
+)
+
+// failedOutputBytes is how much of the end of a failed script's output is kept with its result.
+const failedOutputBytes = 4 << 10
+
+// ErrDeadLetterNotFound is returned for orders that are not in the dead-letter store.
+var ErrDeadLetterNotFound = errors.New("order not in dead-letter store")
+
+// DeadLetter is an order whose payment collection failed, with what its last attempt left.
+type DeadLetter struct {
+	OrderID string `json:"order_id"`
+	// BatchID is the batch the order first failed in.
+	BatchID string `json:"batch_id"`
+	Status  string `json:"status"`
+	// Error, Output, ExitCode, Outcome and Failure are those of the last failed attempt.
+	Error    string         `json:"error"`
+	Output   string         `json:"output,omitempty"`
+	ExitCode int            `json:"exit_code"`
+	Outcome  string         `json:"outcome,omitempty"`
+	Failure  *ScriptFailure `json:"failure,omitempty"`
+	FailedAt time.Time      `json:"failed_at"`
+	// Redrives is how many times the order was re-driven.
+	Redrives       int        `json:"redrives"`
+	LastRedriveAt  *time.Time `json:"last_redrive_at,omitempty"`
+	LastWorkflowID string     `json:"last_workflow_id,omitempty"`
+}
+
+// DeadLetterStore keeps one file per failed order under Dir, named after the order ID.
+// Files are written like batch store files, so retried writes are idempotent.
+type DeadLetterStore struct {
+	Dir string
+}
+
+// NewDeadLetterStore returns a store under dir, or DefaultDeadLetterDir when dir is empty.
+func NewDeadLetterStore(dir string) *DeadLetterStore {
+	if dir == "" {
+		dir = DefaultDeadLetterDir
+	}
+	return &DeadLetterStore{Dir: dir}
+}
+
+func (s *DeadLetterStore) path(orderID string) string {
+	// Order IDs may hold any character but a newline; the encoding makes them safe file names.
+	return filepath.Join(s.Dir, base64.RawURLEncoding.EncodeToString([]byte(orderID))+".json")
+}
+
+// Get returns the dead letter of an order.
+func (s *DeadLetterStore) Get(orderID string) (*DeadLetter, error) {
+	data, err := os.ReadFile(s.path(orderID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrDeadLetterNotFound, orderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var letter DeadLetter
+	if err := json.Unmarshal(data, &letter); err != nil {
+		return nil, fmt.Errorf("invalid dead letter of order %s: %w", orderID, err)
+	}
+	return &letter, nil
+}
+
+func (s *DeadLetterStore) put(letter *DeadLetter) error {
+	if letter.OrderID == "" || strings.ContainsAny(letter.OrderID, "\r\n") {
+		return fmt.Errorf("invalid OrderID %q", letter.OrderID)
+	}
+	data, err := json.MarshalIndent(letter, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(letter.OrderID), append(data, '\n'))
+}
+
+// setFailure records the failed result of the latest attempt of the order, made at.
+func (l *DeadLetter) setFailure(result PaymentResult, at time.Time) {
+	l.Status = DeadLetterFailed
+	l.Error = result.Error
+	if l.Error == "" {
+		l.Error = result.ErrorMessage
+	}
+	l.Output = result.Output
+	l.ExitCode = result.ExitCode
+	l.Outcome = result.Outcome
+	l.Failure = result.Failure
+	l.FailedAt = at
+}
+
+// AddFailure records the failed result of an order in a batch. An order already in the store
+// keeps its first batch and its re-drive count, and gets the failure of its latest attempt.
+func (s *DeadLetterStore) AddFailure(batchID string, result PaymentResult, at time.Time) error {
+	letter, err := s.Get(result.OrderID)
+	if errors.Is(err, ErrDeadLetterNotFound) {
+		letter, err = &DeadLetter{OrderID: result.OrderID, BatchID: batchID}, nil
+	}
+	if err != nil {
+		return err
+	}
+	letter.setFailure(result, at)
+	return s.put(letter)
+}
+
+// RecordRedrive records the outcome of the redrive-th re-drive of an order, run as workflowID
+// at the given time. Recording the same re-drive again only replaces its outcome.
+func (s *DeadLetterStore) RecordRedrive(orderID string, redrive int, workflowID string, result PaymentResult, at time.Time) (*DeadLetter, error) {
+	letter, err := s.Get(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if redrive > letter.Redrives {
+		letter.Redrives = redrive
+	}
+	letter.LastRedriveAt = &at
+	letter.LastWorkflowID = workflowID
+	if result.Success {
+		letter.Status = DeadLetterResolved
+	} else {
+		letter.setFailure(result, at)
+	}
+	return letter, s.put(letter)
+}
+
+// List returns the dead letters with the given status, or all of them when status is empty,
+// oldest failure first.
+func (s *DeadLetterStore) List(status string) ([]DeadLetter, error) {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	letters := []DeadLetter{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(data, &letter); err != nil {
+			return nil, fmt.Errorf("invalid dead letter %s: %w", file, err)
+		}
+		if status == "" || letter.Status == status {
+			letters = append(letters, letter)
+		}
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		if !letters[i].FailedAt.Equal(letters[j].FailedAt) {
+			return letters[i].FailedAt.Before(letters[j].FailedAt)
+		}
+		return letters[i].OrderID < letters[j].OrderID
+	})
+	return letters, nil
+}
+
+// outputTail returns the end of a failed script's output, where its errors usually are.
+func outputTail(output string) string {
+	if len(output) <= failedOutputBytes {
+		return output
+	}
+	return "[...]" + output[len(output)-failedOutputBytes:]
+}
@@ -0,0 +1,56 @@
+package superscript
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterStore(t *testing.T) {
+	store := NewDeadLetterStore(t.TempDir())
+	first := time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+	// Order IDs are not file names.
+	for i, orderID := range []string{"../../etc/passwd", "A/1", "ORD 7"} {
+		result := PaymentResult{OrderID: orderID, ErrorMessage: "declined", ExitCode: 3}
+		if err := store.AddFailure("daily", result, first.Add(time.Duration(-i)*time.Minute)); err != nil {
+			t.Fatalf("AddFailure(%q) error = %v", orderID, err)
+		}
+	}
+	if _, err := store.Get("missing"); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("Get(missing) error = %v", err)
+	}
+
+	// A later failure in another batch keeps the first batch and the re-drive count.
+	if _, err := store.RecordRedrive("A/1", 1, "w1", PaymentResult{OrderID: "A/1", Error: "timeout"}, first.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddFailure("next", PaymentResult{OrderID: "A/1", Error: "still failing"}, first.Add(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	letter, err := store.Get("A/1")
+	if err != nil || letter.BatchID != "daily" || letter.Redrives != 1 || letter.Error != "still failing" {
+		t.Errorf("Get(A/1) = %+v, %v", letter, err)
+	}
+
+	if _, err := store.RecordRedrive("ORD 7", 1, "w2", PaymentResult{OrderID: "ORD 7", Success: true}, first.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := store.List(DeadLetterFailed)
+	if err != nil || len(failed) != 2 || failed[0].OrderID != "../../etc/passwd" || failed[0].Error != "declined" {
+		t.Errorf("List(failed) = %+v, %v", failed, err)
+	}
+	if all, _ := store.List(""); len(all) != 3 {
+		t.Errorf("List() = %d dead letters, want 3", len(all))
+	}
+}
+
+func TestOutputTail(t *testing.T) {
+	long := strings.Repeat("x", failedOutputBytes) + "error: gateway unreachable"
+	if got := outputTail(long); !strings.HasPrefix(got, "[...]") || !strings.HasSuffix(got, "gateway unreachable") || len(got) != failedOutputBytes+5 {
+		t.Errorf("outputTail() kept %d bytes", len(got))
+	}
+	if got := outputTail("ok"); got != "ok" {
+		t.Errorf("outputTail(ok) = %q", got)
+	}
+}
@@ -0,0 +1,244 @@
+package superscript
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scriptWaitDelay bounds how long output is still read after a cancelled script was killed,
+// in case a process that escaped the process group keeps the output open.
+const scriptWaitDelay = 5 * time.Second
+
+// Bounds on what is kept of a script's output, so a runaway script cannot exhaust the worker's
+// memory. Longer lines are cut, and only the first maxScriptRecords records are kept. Invalid
+// records are kept up to the same count and at most maxLineBytes in all; the rest are counted.
+const (
+	maxLineBytes     = 64 << 10
+	maxLastLineBytes = 1 << 10
+	maxScriptRecords = 1000
+)
+
+// truncatedMarker ends output that was cut at the script's output cap.
+const truncatedMarker = "\n[output truncated after %d bytes]\n"
+
+// ScriptProgress is the heartbeat detail recorded while a script runs.
+type ScriptProgress struct {
+	Lines    int    `json:"lines"`
+	Bytes    int64  `json:"bytes"`
+	LastLine string `json:"last_line"`
+	// Message and Percent come from the script's last progress record.
+	Message string `json:"message,omitempty"`
+	Percent int    `json:"percent,omitempty"`
+}
+
+// scriptCommand is a script invocation. It is executed directly, without a shell.
+type scriptCommand struct {
+	Path string
+	Args []string
+	// Env is the complete environment of the script; nil inherits the worker's environment.
+	Env []string
+	// Dir is the working directory; empty uses the worker's.
+	Dir string
+	// MaxOutput caps the output kept, DefaultMaxOutput when zero.
+	MaxOutput int
+	// Sandbox, when set, restricts the script; see applySandbox.
+	Sandbox *SandboxSpec
+}
+
+// scriptOutput is what a finished script produced.
+type scriptOutput struct {
+	Output   string
+	ExitCode int
+	// Truncated is set when output beyond MaxOutput was dropped.
+	Truncated bool
+	// Records are the valid structured records, in the order written.
+	Records []ScriptRecord
+	// InvalidRecords are lines of the result descriptor that were not valid records.
+	InvalidRecords []string
+	// InvalidDropped counts the invalid records beyond the bounds of InvalidRecords.
+	InvalidDropped int
+}
+
+// runScript runs the command and streams its output line by line, and the structured records
+// it writes to its result descriptor, to onProgress.
+// Stdout and stderr share one pipe, so the output keeps the order the script wrote it in.
+// Output beyond command.MaxOutput is drained and dropped, and marked with truncatedMarker.
+// A non-zero exit is reported through the exit code, not as an error. When ctx is done the
+// script's whole process group is killed and ctx.Err() is returned with what was read so far.
+// Failures to set up the sandbox wrap errSandboxSetup.
+func runScript(ctx context.Context, command scriptCommand, onProgress func(ScriptProgress)) (scriptOutput, error) {
+	out := scriptOutput{ExitCode: -1}
+	maxOutput := command.MaxOutput
+	if maxOutput <= 0 {
+		maxOutput = DefaultMaxOutput
+	}
+	cmd := exec.CommandContext(ctx, command.Path, command.Args...)
+	env := command.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env[:len(env):len(env)], fmt.Sprintf("%s=%d", ResultFDEnv, resultFD))
+	cmd.Dir = command.Dir
+	setProcessGroup(cmd)
+	if command.Sandbox != nil {
+		cleanup, err := applySandbox(cmd, command.Sandbox)
+		if err != nil {
+			return out, fmt.Errorf("%w: %w", errSandboxSetup, err)
+		}
+		defer cleanup()
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return out, err
+	}
+	cmd.Stderr = cmd.Stdout
+	results, resultsWriter, err := os.Pipe()
+	if err != nil {
+		return out, err
+	}
+	defer results.Close()
+	cmd.ExtraFiles = []*os.File{resultsWriter}
+	err = cmd.Start()
+	// The script holds its own copy of the write end.
+	resultsWriter.Close()
+	if err != nil {
+		return out, err
+	}
+
+	var (
+		mu           sync.Mutex
+		output       strings.Builder
+		progress     ScriptProgress
+		wg           sync.WaitGroup
+		invalidBytes int
+	)
+	report := func() {
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		readLines(stdout, func(line string, n int) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !out.Truncated {
+				if room := maxOutput - output.Len(); len(line) <= room {
+					output.WriteString(line)
+				} else {
+					output.WriteString(line[:room])
+					fmt.Fprintf(&output, truncatedMarker, maxOutput)
+					out.Truncated = true
+				}
+			}
+			progress.Lines++
+			progress.Bytes += int64(n)
+			progress.LastLine = lastLine(line)
+			report()
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		readLines(results, func(line string, _ int) {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			rec, err := parseRecord([]byte(line))
+			if err != nil {
+				if len(out.InvalidRecords) < maxScriptRecords && invalidBytes+len(line) <= maxLineBytes {
+					out.InvalidRecords = append(out.InvalidRecords, line)
+					invalidBytes += len(line)
+				} else {
+					out.InvalidDropped++
+				}
+				return
+			}
+			if len(out.Records) < maxScriptRecords {
+				out.Records = append(out.Records, rec)
+			}
+			if rec.Type == RecordProgress {
+				progress.Message = rec.Message
+				progress.Percent = rec.Percent
+				report()
+			}
+		})
+	}()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		select {
+		case <-done:
+		case <-time.After(scriptWaitDelay):
+			stdout.Close()
+			results.Close()
+			<-done
+		}
+	}
+	err = cmd.Wait()
+	out.Output = output.String()
+	if ctx.Err() != nil {
+		return out, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return out, err
+	}
+	out.ExitCode = cmd.ProcessState.ExitCode()
+	return out, nil
+}
+
+// readLines calls fn with every line read from r, including a final line without newline,
+// and the number of bytes it had. Lines longer than maxLineBytes are cut there.
+func readLines(r io.Reader, fn func(line string, n int)) {
+	reader := bufio.NewReaderSize(r, maxLineBytes)
+	var line []byte
+	n := 0
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		n += len(chunk)
+		if room := maxLineBytes - len(line); room > 0 {
+			line = append(line, chunk[:min(room, len(chunk))]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if n > 0 {
+			if n > len(line) && err == nil {
+				// Keep the newline of a cut line, so the output keeps its lines.
+				line = append(line, '\n')
+			}
+			fn(string(line), n)
+		}
+		if err != nil {
+			return
+		}
+		line, n = line[:0], 0
+	}
+}
+
+// lastLine returns line without its newline, cut to maxLastLineBytes for heartbeats.
+func lastLine(line string) string {
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) > maxLastLineBytes {
+		line = strings.ToValidUTF8(line[:maxLastLineBytes], "")
+	}
+	return line
+}
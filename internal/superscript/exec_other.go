@@ -0,0 +1,8 @@
+//go:build !unix
+
+package superscript
+
+import "os/exec"
+
+// setProcessGroup is a no-op without process groups; cancellation kills only the script itself.
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,175 @@
+package superscript
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/bash\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunScript_StreamsProgress(t *testing.T) {
+	path := writeTestScript(t, "echo one\necho two >&2\necho three\nexit 3\n")
+	var seen []ScriptProgress
+	out, err := runScript(context.Background(), scriptCommand{Path: path}, func(p ScriptProgress) {
+		seen = append(seen, p)
+	})
+	if err != nil {
+		t.Fatalf("runScript() error = %v", err)
+	}
+	output, exitCode := out.Output, out.ExitCode
+	if exitCode != 3 {
+		t.Errorf("exitCode = %d, want 3", exitCode)
+	}
+	if output != "one\ntwo\nthree\n" {
+		t.Errorf("output = %q", output)
+	}
+	want := ScriptProgress{Lines: 3, Bytes: int64(len(output)), LastLine: "three"}
+	if len(seen) != 3 || seen[2] != want {
+		t.Errorf("progress = %+v, want last %+v", seen, want)
+	}
+}
+
+func TestRunScript_CancelKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	// The background child keeps the output open; cancelling must kill it too.
+	path := writeTestScript(t, "sleep 60 &\necho $! > "+pidFile+"\necho started\nwait\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	out, err := runScript(ctx, scriptCommand{Path: path}, func(p ScriptProgress) {
+		if p.LastLine == "started" {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("runScript() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > scriptWaitDelay {
+		t.Errorf("runScript() took %s after cancellation", elapsed)
+	}
+	if out.Output != "started\n" {
+		t.Errorf("output = %q, want the partial output", out.Output)
+	}
+	pid, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The killed child is gone, or at most a zombie reparented to init. It may still be
+	// exiting when its output closes, so give it a moment.
+	stat := "/proc/" + strings.TrimSpace(string(pid)) + "/stat"
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		status, err := os.ReadFile(stat)
+		if err != nil || strings.Contains(string(status), ") Z ") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("child process survived cancellation: %s", status)
+		}
+	}
+}
+
+func TestRunScript_ResultRecords(t *testing.T) {
+	path := writeTestScript(t, `source "$(dirname "$0")/superscript_protocol.sh"
+echo working
+ss_progress "halfway" 50
+ss_result "transaction_id" "tx \"42\""
+echo "not json" >&"$SUPERSCRIPT_RESULT_FD"
+ss_error "GATEWAY_DOWN" "gateway unavailable" false
+exit 4
+`)
+	protocol, err := os.ReadFile("scripts/superscript_protocol.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "superscript_protocol.sh"), protocol, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var last ScriptProgress
+	out, err := runScript(context.Background(), scriptCommand{Path: path}, func(p ScriptProgress) { last = p })
+	if err != nil {
+		t.Fatalf("runScript() error = %v", err)
+	}
+	if out.ExitCode != 4 || out.Output != "working\n" {
+		t.Errorf("runScript() = %+v", out)
+	}
+	if last.Message != "halfway" || last.Percent != 50 {
+		t.Errorf("last progress = %+v, want the progress record", last)
+	}
+	if len(out.InvalidRecords) != 1 || out.InvalidRecords[0] != "not json" {
+		t.Errorf("InvalidRecords = %q", out.InvalidRecords)
+	}
+	results, failure := applyRecords(out.Records)
+	if results["transaction_id"] != `tx "42"` {
+		t.Errorf("results = %v", results)
+	}
+	want := ScriptFailure{Code: "GATEWAY_DOWN", Message: "gateway unavailable", Retryable: false}
+	if failure == nil || *failure != want {
+		t.Errorf("failure = %+v, want %+v", failure, want)
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	tests := map[string]bool{
+		`{"type":"progress","message":"step 1","percent":10}`: true,
+		`{"type":"result","key":"k","value":"v"}`:             true,
+		`{"type":"error","code":"E"}`:                         true,
+		`{"type":"progress","percent":120}`:                   false,
+		`{"type":"result","value":"v"}`:                       false,
+		`{"type":"error"}`:                                    false,
+		`{"type":"debug"}`:                                    false,
+		`plain text`:                                          false,
+	}
+	for line, valid := range tests {
+		if _, err := parseRecord([]byte(line)); (err == nil) != valid {
+			t.Errorf("parseRecord(%s) error = %v, want valid %t", line, err, valid)
+		}
+	}
+}
+
+func TestRunScript_OutputCap(t *testing.T) {
+	// 100 lines of 10 bytes, and a line longer than maxLineBytes.
+	path := writeTestScript(t, "for i in $(seq 100); do echo 123456789; done\nhead -c 100000 /dev/zero | tr '\\0' x\necho\necho last\n")
+	out, err := runScript(context.Background(), scriptCommand{Path: path, MaxOutput: 95}, nil)
+	if err != nil {
+		t.Fatalf("runScript() error = %v", err)
+	}
+	want := strings.Repeat("123456789\n", 9) + "12345" + "\n[output truncated after 95 bytes]\n"
+	if !out.Truncated || out.Output != want {
+		t.Errorf("output = %q, truncated = %v, want %q", out.Output, out.Truncated, want)
+	}
+
+	var lines []string
+	readLines(strings.NewReader(strings.Repeat("x", maxLineBytes+10)+"\nshort"), func(line string, n int) {
+		lines = append(lines, line)
+	})
+	if len(lines) != 2 || len(lines[0]) != maxLineBytes+1 || lines[1] != "short" {
+		t.Errorf("readLines() cut long lines into %d lines", len(lines))
+	}
+}
+
+func TestRunScript_InvalidRecordCap(t *testing.T) {
+	// 2000 invalid records of 99 bytes: the byte bound is hit before the count bound.
+	path := writeTestScript(t, "for i in $(seq 2000); do printf '%099d\\n' $i >&3; done\n")
+	out, err := runScript(context.Background(), scriptCommand{Path: path}, nil)
+	if err != nil {
+		t.Fatalf("runScript() error = %v", err)
+	}
+	kept := len(out.InvalidRecords)
+	if kept == 0 || kept != maxLineBytes/99 || kept+out.InvalidDropped != 2000 {
+		t.Errorf("kept %d invalid records and dropped %d", kept, out.InvalidDropped)
+	}
+}
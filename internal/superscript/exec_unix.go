@@ -0,0 +1,17 @@
+//go:build unix
+
+package superscript
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the script in its own process group and makes cancellation
+// kill the whole group, so processes started by the script do not outlive it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
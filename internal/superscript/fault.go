@@ -0,0 +1,200 @@
+package superscript
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Faults a FaultSpec can inject into a script attempt.
+const (
+	// FaultExit makes the script exit with one of the spec's exit codes before it does anything.
+	FaultExit = "exit"
+	// FaultHang makes the script stop making progress, until its heartbeat or script timeout.
+	FaultHang = "hang"
+	// FaultSlowOutput makes the script wait SlowDelay before each step's output.
+	FaultSlowOutput = "slow_output"
+	// FaultPartial makes the script exit with one of the exit codes after its side effect, such
+	// as a collected payment, so the attempt fails although the work was done.
+	FaultPartial = "partial"
+	// FaultKillWorker kills the worker process KillWorkerAfter into the attempt. The script is
+	// in a process group of its own and keeps running, as it would when a worker crashes.
+	FaultKillWorker = "kill_worker"
+)
+
+// Environment variables that tell a script which fault to inject, see ss_fault in
+// superscript_protocol.sh. FaultKillWorker is injected by the worker, not the script.
+const (
+	FaultEnv         = "SUPERSCRIPT_FAULT"
+	FaultExitCodeEnv = "SUPERSCRIPT_FAULT_EXIT_CODE"
+	FaultDelayEnv    = "SUPERSCRIPT_FAULT_DELAY"
+)
+
+// FaultsDisabledError is the application error type of faults a worker does not allow.
+const FaultsDisabledError = "FaultsDisabled"
+
+// Errors of faults a worker does not allow, see CheckFaults.
+var (
+	ErrRequestFaultsDisabled = errors.New("faults on requests are disabled, see SUPERSCRIPT_FAULTS_ENABLED")
+	ErrKillWorkerDisabled    = errors.New("kill_worker faults are disabled, see SUPERSCRIPT_FAULTS_KILL_WORKER")
+)
+
+// Defaults of a FaultSpec.
+const (
+	defaultFaultExitCode   = 75
+	defaultSlowDelay       = time.Second
+	defaultKillWorkerAfter = time.Second
+)
+
+// FaultSpec injects faults into script attempts for chaos testing. Each attempt gets at most
+// one fault, chosen by a hash of Seed, the script, its arguments and the attempt, so a run with
+// the same spec injects the same faults. Percentages add up to at most 100.
+type FaultSpec struct {
+	Seed int64 `json:"seed,omitempty"`
+	// ExitPct of attempts exit with one of ExitCodes (default 75) before doing anything.
+	ExitPct   int   `json:"exit_pct,omitempty"`
+	ExitCodes []int `json:"exit_codes,omitempty"`
+	// HangPct of attempts hang.
+	HangPct int `json:"hang_pct,omitempty"`
+	// SlowPct of attempts wait SlowDelay (default 1s) before each step's output.
+	SlowPct   int      `json:"slow_pct,omitempty"`
+	SlowDelay Duration `json:"slow_delay,omitempty"`
+	// PartialPct of attempts exit with one of ExitCodes after their side effect.
+	PartialPct int `json:"partial_pct,omitempty"`
+	// KillWorkerPct of attempts kill the worker KillWorkerAfter (default 1s) after they start.
+	KillWorkerPct   int      `json:"kill_worker_pct,omitempty"`
+	KillWorkerAfter Duration `json:"kill_worker_after,omitempty"`
+	// Attempts limits the faults to the first attempts of each script run, so retries can
+	// succeed; zero injects faults into every attempt.
+	Attempts int32 `json:"attempts,omitempty"`
+}
+
+// injectedFault is the fault chosen for one attempt.
+type injectedFault struct {
+	Kind     string
+	ExitCode int
+	Delay    time.Duration
+}
+
+// ParseFaultSpec reads a FaultSpec from JSON, such as SUPERSCRIPT_FAULTS. An empty string
+// disables fault injection and returns nil.
+func ParseFaultSpec(s string) (*FaultSpec, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var spec FaultSpec
+	if err := json.Unmarshal([]byte(s), &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse fault spec: %w", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate checks the percentages, exit codes and delays of the spec.
+func (f *FaultSpec) Validate() error {
+	total := 0
+	for _, pct := range []int{f.ExitPct, f.HangPct, f.SlowPct, f.PartialPct, f.KillWorkerPct} {
+		if pct < 0 || pct > 100 {
+			return fmt.Errorf("fault percentages must be between 0 and 100")
+		}
+		total += pct
+	}
+	if total > 100 {
+		return fmt.Errorf("fault percentages add up to %d, more than 100", total)
+	}
+	for _, code := range f.ExitCodes {
+		if code <= 0 || code > 255 {
+			return fmt.Errorf("fault exit code %d must be between 1 and 255", code)
+		}
+	}
+	if f.SlowDelay < 0 || time.Duration(f.SlowDelay) > MaxScriptTimeout || f.KillWorkerAfter < 0 || time.Duration(f.KillWorkerAfter) > MaxScriptTimeout {
+		return fmt.Errorf("fault delays must be between 0 and %s", MaxScriptTimeout)
+	}
+	if f.Attempts < 0 {
+		return fmt.Errorf("fault attempts must not be negative")
+	}
+	return nil
+}
+
+// CheckFaults checks the faults of a request against what the worker allows: faults on
+// requests at all (requestFaults), and FaultKillWorker (killWorker). Nil faults always pass.
+func CheckFaults(f *FaultSpec, requestFaults, killWorker bool) error {
+	if f == nil {
+		return nil
+	}
+	if !requestFaults {
+		return ErrRequestFaultsDisabled
+	}
+	if f.KillWorkerPct > 0 && !killWorker {
+		return ErrKillWorkerDisabled
+	}
+	return f.Validate()
+}
+
+// pick chooses the fault of an attempt of a script with args, if it gets one.
+func (f *FaultSpec) pick(script string, args []string, attempt int32) (injectedFault, bool) {
+	if f == nil || (f.Attempts > 0 && attempt > f.Attempts) {
+		return injectedFault{}, false
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%d", f.Seed, script, strings.Join(args, "\x00"), attempt)
+	sum := h.Sum64()
+	roll := int(sum % 100)
+	exitCode := defaultFaultExitCode
+	if len(f.ExitCodes) > 0 {
+		exitCode = f.ExitCodes[(sum/100)%uint64(len(f.ExitCodes))]
+	}
+	slowDelay := time.Duration(f.SlowDelay)
+	if slowDelay == 0 {
+		slowDelay = defaultSlowDelay
+	}
+	killAfter := time.Duration(f.KillWorkerAfter)
+	if killAfter == 0 {
+		killAfter = defaultKillWorkerAfter
+	}
+	for _, fault := range []struct {
+		pct   int
+		fault injectedFault
+	}{
+		{f.ExitPct, injectedFault{Kind: FaultExit, ExitCode: exitCode}},
+		{f.HangPct, injectedFault{Kind: FaultHang}},
+		{f.SlowPct, injectedFault{Kind: FaultSlowOutput, Delay: slowDelay}},
+		{f.PartialPct, injectedFault{Kind: FaultPartial, ExitCode: exitCode}},
+		{f.KillWorkerPct, injectedFault{Kind: FaultKillWorker, Delay: killAfter}},
+	} {
+		if roll < fault.pct {
+			return fault.fault, true
+		}
+		roll -= fault.pct
+	}
+	return injectedFault{}, false
+}
+
+// environ returns the variables that tell the script about the fault.
+func (f injectedFault) environ() []string {
+	if f.Kind == FaultKillWorker {
+		return nil
+	}
+	env := []string{FaultEnv + "=" + f.Kind}
+	if f.ExitCode != 0 {
+		env = append(env, FaultExitCodeEnv+"="+strconv.Itoa(f.ExitCode))
+	}
+	if f.Delay > 0 {
+		env = append(env, FaultDelayEnv+"="+strconv.FormatFloat(f.Delay.Seconds(), 'f', 3, 64))
+	}
+	return env
+}
+
+// killWorker kills the worker process without letting it clean up, as a crash would.
+func killWorker() {
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Kill()
+	}
+}
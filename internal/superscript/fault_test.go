@@ -0,0 +1,225 @@
+package superscript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestFaultSpec_Pick(t *testing.T) {
+	spec := &FaultSpec{Seed: 7, ExitPct: 20, HangPct: 10, SlowPct: 10, PartialPct: 10, KillWorkerPct: 10, ExitCodes: []int{3, 9}}
+	if err := spec.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		args := []string{fmt.Sprint(i)}
+		fault, ok := spec.pick(PaymentScriptName, args, 1)
+		if again, _ := spec.pick(PaymentScriptName, args, 1); again != fault {
+			t.Fatalf("pick(%d) = %+v, then %+v", i, fault, again)
+		}
+		if !ok {
+			counts["none"]++
+			continue
+		}
+		counts[fault.Kind]++
+		if (fault.Kind == FaultExit || fault.Kind == FaultPartial) && fault.ExitCode != 3 && fault.ExitCode != 9 {
+			t.Errorf("pick(%d) exit code = %d", i, fault.ExitCode)
+		}
+	}
+	// Roughly the percentages of the spec.
+	for kind, want := range map[string]int{FaultExit: 200, FaultHang: 100, FaultSlowOutput: 100, FaultPartial: 100, FaultKillWorker: 100, "none": 400} {
+		if got := counts[kind]; got < want*3/4 || got > want*5/4 {
+			t.Errorf("%s picked %d times out of 1000, want about %d", kind, got, want)
+		}
+	}
+
+	always := &FaultSpec{ExitPct: 100, Attempts: 2}
+	for attempt, want := range map[int32]bool{1: true, 2: true, 3: false} {
+		if _, ok := always.pick(PaymentScriptName, []string{"1"}, attempt); ok != want {
+			t.Errorf("attempt %d got a fault: %v, want %v", attempt, ok, want)
+		}
+	}
+	if _, ok := (*FaultSpec)(nil).pick(PaymentScriptName, []string{"1"}, 1); ok {
+		t.Error("nil spec picked a fault")
+	}
+}
+
+func TestParseFaultSpec(t *testing.T) {
+	spec, err := ParseFaultSpec(`{"exit_pct": 30, "slow_pct": 20, "slow_delay": "2s", "attempts": 1}`)
+	if err != nil || spec.ExitPct != 30 || spec.SlowDelay != Duration(2*time.Second) || spec.Attempts != 1 {
+		t.Errorf("ParseFaultSpec() = %+v, %v", spec, err)
+	}
+	if spec, err := ParseFaultSpec(""); spec != nil || err != nil {
+		t.Errorf("ParseFaultSpec(\"\") = %+v, %v", spec, err)
+	}
+	for _, invalid := range []string{`{"exit_pct": 60, "hang_pct": 50}`, `{"exit_pct": -1}`, `{"exit_codes": [0]}`, `{"slow_delay": "-1s"}`, `not json`} {
+		if _, err := ParseFaultSpec(invalid); err == nil {
+			t.Errorf("ParseFaultSpec(%s) succeeded", invalid)
+		}
+	}
+}
+
+func TestActivities_RunScript_Faults(t *testing.T) {
+	protocol, err := filepath.Abs("scripts/superscript_protocol.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	script := fmt.Sprintf(`#!/bin/bash
+source %q
+ss_fault start
+ss_fault output
+echo "working on $1"
+sleep 0.2
+ss_result "paid" "$1"
+ss_fault side_effect
+ss_fault output
+echo "done with $1"
+`, protocol)
+	if err := os.WriteFile(filepath.Join(dir, "pay.sh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	catalogue := &Catalogue{Version: "test", Scripts: []ScriptSpec{{
+		Name:    "pay",
+		Path:    "pay.sh",
+		Params:  []ParamSpec{{Name: "order_id", Required: true}},
+		Timeout: Duration(2 * time.Second),
+	}}}
+	if err := catalogue.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	killed := make(chan struct{}, 1)
+	a := &Activities{ScriptBasePath: dir, Catalogue: catalogue, AllowRequestFaults: true, AllowKillWorker: true, KillWorker: func() { killed <- struct{}{} }}
+	run := func(faults *FaultSpec) (*ScriptResult, error) {
+		return a.RunScript(context.Background(), ScriptRequest{Script: "pay", Params: map[string]string{"order_id": "1"}, Faults: faults})
+	}
+
+	result, err := run(&FaultSpec{ExitPct: 100, ExitCodes: []int{9}})
+	if err == nil || result.ExitCode != 9 || result.Fault != FaultExit || result.Results != nil || result.Failure == nil || result.Failure.Code != "INJECTED_FAULT" {
+		t.Errorf("exit: %+v, %v", result, err)
+	}
+	// A partial failure fails the attempt after the work was done.
+	result, err = run(&FaultSpec{PartialPct: 100})
+	if err == nil || result.ExitCode != 75 || result.Fault != FaultPartial || result.Results["paid"] != "1" {
+		t.Errorf("partial: %+v, %v", result, err)
+	}
+	result, err = run(&FaultSpec{SlowPct: 100, SlowDelay: Duration(100 * time.Millisecond)})
+	if err != nil || !result.Success || result.Fault != FaultSlowOutput || result.ExecutionTime < 400*time.Millisecond {
+		t.Errorf("slow output: %+v, %v", result, err)
+	}
+	result, err = run(&FaultSpec{HangPct: 100})
+	if err == nil || !strings.Contains(err.Error(), "timed out") || result.Fault != FaultHang {
+		t.Errorf("hang: %+v, %v", result, err)
+	}
+	result, err = run(&FaultSpec{KillWorkerPct: 100, KillWorkerAfter: Duration(10 * time.Millisecond)})
+	if err != nil || result.Fault != FaultKillWorker {
+		t.Errorf("kill worker: %+v, %v", result, err)
+	}
+	select {
+	case <-killed:
+	default:
+		t.Error("the worker was not killed")
+	}
+
+	// The worker's faults apply to requests without faults of their own.
+	a.Faults = &FaultSpec{ExitPct: 100}
+	if result, err := run(nil); err == nil || result.Fault != FaultExit {
+		t.Errorf("worker faults: %+v, %v", result, err)
+	}
+	if result, err := run(&FaultSpec{}); err != nil || result.Fault != "" {
+		t.Errorf("request without faults: %+v, %v", result, err)
+	}
+	var appErr *temporal.ApplicationError
+	if _, err := run(&FaultSpec{ExitPct: 101}); !errors.As(err, &appErr) || !appErr.NonRetryable() {
+		t.Errorf("invalid faults: %v", err)
+	}
+
+	// Without the worker's consent, requests with faults fail and the worker is never killed.
+	a.AllowKillWorker = false
+	if _, err := run(&FaultSpec{KillWorkerPct: 100}); !errors.As(err, &appErr) || appErr.Type() != FaultsDisabledError || !appErr.NonRetryable() {
+		t.Errorf("kill worker disabled: %v", err)
+	}
+	a.Faults = &FaultSpec{KillWorkerPct: 100, KillWorkerAfter: Duration(10 * time.Millisecond)}
+	if result, err := run(nil); err != nil || result.Fault != "" {
+		t.Errorf("worker's kill worker faults while disabled: %+v, %v", result, err)
+	}
+	select {
+	case <-killed:
+		t.Error("the worker was killed although kill_worker is disabled")
+	default:
+	}
+	a.AllowRequestFaults = false
+	if _, err := run(&FaultSpec{ExitPct: 100}); !errors.As(err, &appErr) || appErr.Type() != FaultsDisabledError {
+		t.Errorf("request faults disabled: %v", err)
+	}
+}
+
+func TestCheckFaults(t *testing.T) {
+	kill := &FaultSpec{KillWorkerPct: 10}
+	for _, tc := range []struct {
+		faults                    *FaultSpec
+		requestFaults, killWorker bool
+		want                      error
+	}{
+		{nil, false, false, nil},
+		{&FaultSpec{ExitPct: 10}, false, false, ErrRequestFaultsDisabled},
+		{&FaultSpec{ExitPct: 10}, true, false, nil},
+		{kill, true, false, ErrKillWorkerDisabled},
+		{kill, true, true, nil},
+	} {
+		if err := CheckFaults(tc.faults, tc.requestFaults, tc.killWorker); !errors.Is(err, tc.want) {
+			t.Errorf("CheckFaults(%+v, %t, %t) = %v, want %v", tc.faults, tc.requestFaults, tc.killWorker, err, tc.want)
+		}
+	}
+	if err := CheckFaults(&FaultSpec{ExitPct: 101}, true, true); err == nil {
+		t.Error("CheckFaults() accepted an invalid spec")
+	}
+}
+
+func TestOrchestratorWorkflow_Faults(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := newOrchestratorEnv(&ts, NewBatchStore(t.TempDir()))
+	attempts := make(map[string]int)
+	env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		if info.ActivityType.Name == "RunPaymentCollectionScript" {
+			attempts[info.WorkflowExecution.ID]++
+		}
+	})
+
+	// Every order fails its first two attempts, and the retries collect it exactly once.
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{
+		BatchID:  "chaos",
+		OrderIDs: []string{"1", "2", "3"},
+		Faults:   &FaultSpec{ExitPct: 100, Attempts: 2},
+	})
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	if result.SuccessCount != 3 || result.FailCount != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	for _, orderID := range []string{"1", "2", "3"} {
+		if got := attempts[PaymentWorkflowID(orderID)]; got != 3 {
+			t.Errorf("order %s ran %d attempts, want 3", orderID, got)
+		}
+	}
+
+	env = newOrchestratorEnv(&ts, NewBatchStore(t.TempDir()))
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{OrderIDs: []string{"1"}, Faults: &FaultSpec{ExitPct: 80, HangPct: 80}})
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != InvalidBatchError {
+		t.Errorf("OrchestratorWorkflow() with invalid faults error = %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package superscript
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ResultFDEnv tells a script which file descriptor to write structured records to.
+// See scripts/superscript_protocol.sh for the bash helpers.
+const ResultFDEnv = "SUPERSCRIPT_RESULT_FD"
+
+// resultFD is the descriptor the runner passes: the first of exec.Cmd.ExtraFiles.
+const resultFD = 3
+
+// ScriptErrorType is the application error type of script failures that carry no code.
+const ScriptErrorType = "ScriptError"
+
+// Record types of the structured result protocol.
+const (
+	RecordProgress = "progress"
+	RecordResult   = "result"
+	RecordError    = "error"
+)
+
+// ScriptRecord is one JSON line a script writes to its result descriptor:
+//
+//	{"type":"progress","message":"step 1 started","percent":0}
+//	{"type":"result","key":"transaction_id","value":"tx-42"}
+//	{"type":"error","code":"INVALID_ORDER_ID","message":"OrderID must be a number","retryable":false}
+type ScriptRecord struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	Percent int    `json:"percent,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Code    string `json:"code,omitempty"`
+	// Retryable classifies an error record; errors are retryable unless it is false.
+	Retryable *bool `json:"retryable,omitempty"`
+}
+
+// ScriptFailure is the error a script classified itself.
+type ScriptFailure struct {
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+// parseRecord decodes and checks one line of the result descriptor.
+func parseRecord(line []byte) (ScriptRecord, error) {
+	var rec ScriptRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return rec, fmt.Errorf("invalid record: %w", err)
+	}
+	switch rec.Type {
+	case RecordProgress:
+		if rec.Percent < 0 || rec.Percent > 100 {
+			return rec, fmt.Errorf("progress percent %d is out of range", rec.Percent)
+		}
+	case RecordResult:
+		if rec.Key == "" {
+			return rec, fmt.Errorf("result record without key")
+		}
+	case RecordError:
+		if rec.Message == "" && rec.Code == "" {
+			return rec, fmt.Errorf("error record without code or message")
+		}
+	default:
+		return rec, fmt.Errorf("unknown record type %q", rec.Type)
+	}
+	return rec, nil
+}
+
+// applyRecords folds records into the results and the last reported failure.
+func applyRecords(records []ScriptRecord) (map[string]string, *ScriptFailure) {
+	var results map[string]string
+	var failure *ScriptFailure
+	for _, rec := range records {
+		switch rec.Type {
+		case RecordResult:
+			if results == nil {
+				results = make(map[string]string)
+			}
+			results[rec.Key] = rec.Value
+		case RecordError:
+			failure = &ScriptFailure{
+				Code:      rec.Code,
+				Message:   rec.Message,
+				Retryable: rec.Retryable == nil || *rec.Retryable,
+			}
+			if failure.Message == "" {
+				failure.Message = rec.Code
+			}
+		}
+	}
+	return results, failure
+}
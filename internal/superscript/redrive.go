@@ -0,0 +1,265 @@
+package superscript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// RedriveWorkflowType is the workflow that re-drives dead-lettered orders.
+const RedriveWorkflowType = "RedriveWorkflow"
+
+// DefaultRedrivePageSize is how many orders one RedriveWorkflow run re-drives before it
+// continues as new.
+const DefaultRedrivePageSize = 500
+
+// RedriveParams are the parameters of RedriveWorkflow.
+type RedriveParams struct {
+	// OrderIDs are the dead-lettered orders to re-drive; empty re-drives every failed one.
+	OrderIDs []string
+	// MaxConcurrent is the maximum number of orders re-driven at a time (default 3).
+	MaxConcurrent int
+	// PageSize is how many orders one run re-drives (default DefaultRedrivePageSize).
+	PageSize int
+	// Cursor carries progress across continue-as-new. Callers leave it nil.
+	Cursor *RedriveCursor
+}
+
+// RedriveCursor is the position and running totals of a re-drive, carried across
+// continue-as-new.
+type RedriveCursor struct {
+	// After is the last order ID the previous runs selected; orders are selected in ID order.
+	After     string
+	Redriven  int
+	Succeeded int
+	Failed    int
+	Skipped   []string
+	// Runs is the number of runs completed before this one.
+	Runs int
+}
+
+// RedriveResult is the outcome of RedriveWorkflow.
+type RedriveResult struct {
+	Redriven  int `json:"redriven"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Results are those of the last run; the outcome of every re-drive is in the dead-letter
+	// store.
+	Results []PaymentResult `json:"results"`
+	// Skipped are OrderIDs that were not re-driven: not dead-lettered, already resolved, or
+	// being re-driven by another RedriveWorkflow.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// SelectRedrivesRequest asks for a page of the dead letters to re-drive: the first Limit
+// orders whose ID sorts after After.
+type SelectRedrivesRequest struct {
+	OrderIDs []string `json:"order_ids"`
+	After    string   `json:"after,omitempty"`
+	Limit    int      `json:"limit,omitempty"`
+}
+
+// RedriveTarget is a dead-lettered order to re-drive.
+type RedriveTarget struct {
+	OrderID string `json:"order_id"`
+	// Redrives is how many times the order was re-driven before.
+	Redrives int `json:"redrives"`
+}
+
+// RedriveSelection is what SelectRedrives found.
+type RedriveSelection struct {
+	Targets []RedriveTarget `json:"targets"`
+	Skipped []string        `json:"skipped,omitempty"`
+	// Next is set when orders remain after this page: the After of the next one.
+	Next string `json:"next,omitempty"`
+}
+
+// RecordRedriveRequest records the outcome of a re-drive in the dead-letter store.
+type RecordRedriveRequest struct {
+	OrderID    string        `json:"order_id"`
+	Redrive    int           `json:"redrive"`
+	WorkflowID string        `json:"workflow_id"`
+	Result     PaymentResult `json:"result"`
+}
+
+// RedriveWorkflowID is the ID of the redrive-th re-drive of an order. Every re-drive gets a
+// fresh ID, as the order's original SinglePaymentCollectionWorkflow ID rejects duplicates, and
+// starting the same re-drive twice is rejected in turn.
+func RedriveWorkflowID(orderID string, redrive int) string {
+	return fmt.Sprintf("%s-redrive-%d", PaymentWorkflowID(orderID), redrive)
+}
+
+// SelectRedrives returns a page of the failed dead letters among the requested orders, or of
+// all of them. Orders that fail again sort before the next page, so each is re-driven once.
+func (a *Activities) SelectRedrives(ctx context.Context, req SelectRedrivesRequest) (*RedriveSelection, error) {
+	store := a.deadLetterStore()
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultRedrivePageSize
+	}
+	selection := &RedriveSelection{}
+	if len(req.OrderIDs) == 0 {
+		letters, err := store.List(DeadLetterFailed)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(letters, func(i, j int) bool { return letters[i].OrderID < letters[j].OrderID })
+		for i, letter := range letters {
+			if letter.OrderID <= req.After {
+				continue
+			}
+			if len(selection.Targets) == limit {
+				selection.Next = letters[i-1].OrderID
+				break
+			}
+			selection.Targets = append(selection.Targets, RedriveTarget{OrderID: letter.OrderID, Redrives: letter.Redrives})
+		}
+		return selection, nil
+	}
+	orderIDs := append([]string(nil), req.OrderIDs...)
+	sort.Strings(orderIDs)
+	last := req.After
+	for _, orderID := range orderIDs {
+		if orderID <= last {
+			continue
+		}
+		if len(selection.Targets)+len(selection.Skipped) == limit {
+			selection.Next = last
+			break
+		}
+		last = orderID
+		letter, err := store.Get(orderID)
+		if errors.Is(err, ErrDeadLetterNotFound) || (err == nil && letter.Status != DeadLetterFailed) {
+			selection.Skipped = append(selection.Skipped, orderID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		selection.Targets = append(selection.Targets, RedriveTarget{OrderID: orderID, Redrives: letter.Redrives})
+	}
+	return selection, nil
+}
+
+// RecordRedrive records the outcome of a re-drive: the order is resolved when it succeeded,
+// and keeps its latest failure otherwise.
+func (a *Activities) RecordRedrive(ctx context.Context, req RecordRedriveRequest) error {
+	_, err := a.deadLetterStore().RecordRedrive(req.OrderID, req.Redrive, req.WorkflowID, req.Result, time.Now())
+	return err
+}
+
+// RedriveWorkflow retries dead-lettered orders, each in a new SinglePaymentCollectionWorkflow
+// with a RedriveWorkflowID, and records in the dead-letter store how each re-drive went. Each
+// run re-drives a page of the orders and continues as new while orders remain.
+func RedriveWorkflow(ctx workflow.Context, params RedriveParams) (*RedriveResult, error) {
+	logger := workflow.GetLogger(ctx)
+	concurrency := params.MaxConcurrent
+	if concurrency <= 0 {
+		concurrency = 3
+	}
+	if concurrency > MaxConcurrencyLimit {
+		concurrency = MaxConcurrencyLimit
+	}
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    30 * time.Second,
+		},
+	})
+
+	cursor := params.Cursor
+	if cursor == nil {
+		cursor = &RedriveCursor{}
+	}
+	var selection RedriveSelection
+	err := workflow.ExecuteActivity(ctx, "SelectRedrives", SelectRedrivesRequest{
+		OrderIDs: params.OrderIDs,
+		After:    cursor.After,
+		Limit:    params.PageSize,
+	}).Get(ctx, &selection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select dead letters: %w", err)
+	}
+	logger.Info("Re-driving dead-lettered orders", "count", len(selection.Targets), "skipped", len(selection.Skipped), "maxConcurrent", concurrency, "runs", cursor.Runs)
+
+	result := &RedriveResult{
+		Redriven:  cursor.Redriven,
+		Succeeded: cursor.Succeeded,
+		Failed:    cursor.Failed,
+		Results:   []PaymentResult{},
+		Skipped:   append(cursor.Skipped, selection.Skipped...),
+	}
+	running := 0
+	wg := workflow.NewWaitGroup(ctx)
+	for _, target := range selection.Targets {
+		if err := workflow.Await(ctx, func() bool { return running < concurrency }); err != nil {
+			break
+		}
+		redrive := target.Redrives + 1
+		workflowID := RedriveWorkflowID(target.OrderID, redrive)
+		childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+			WorkflowID:            workflowID,
+			WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+			TaskQueue:             SuperscriptTaskQueue,
+		})
+		future := workflow.ExecuteChildWorkflow(childCtx, SinglePaymentWorkflowType, SinglePaymentWorkflowParams{OrderID: target.OrderID})
+		running++
+		wg.Add(1)
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			defer wg.Done()
+			defer func() { running-- }()
+			var payment PaymentResult
+			if err := future.Get(ctx, &payment); err != nil {
+				if temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+					logger.Info("Re-drive already started elsewhere, skipping", "orderID", target.OrderID, "workflowID", workflowID)
+					result.Skipped = append(result.Skipped, target.OrderID)
+					return
+				}
+				payment = failedChildResult(target.OrderID, err, workflow.Now(ctx))
+			}
+			err := workflow.ExecuteActivity(ctx, "RecordRedrive", RecordRedriveRequest{
+				OrderID:    target.OrderID,
+				Redrive:    redrive,
+				WorkflowID: workflowID,
+				Result:     payment,
+			}).Get(ctx, nil)
+			if err != nil {
+				logger.Error("Failed to record re-drive", "orderID", target.OrderID, "workflowID", workflowID, "error", err)
+			}
+			result.Redriven++
+			result.Results = append(result.Results, payment)
+			if payment.Success {
+				result.Succeeded++
+			} else {
+				result.Failed++
+			}
+		})
+	}
+	wg.Wait(ctx)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if selection.Next != "" {
+		logger.Info("Continuing as new", "after", selection.Next, "redriven", result.Redriven)
+		params.Cursor = &RedriveCursor{
+			After:     selection.Next,
+			Redriven:  result.Redriven,
+			Succeeded: result.Succeeded,
+			Failed:    result.Failed,
+			Skipped:   result.Skipped,
+			Runs:      cursor.Runs + 1,
+		}
+		return nil, workflow.NewContinueAsNewError(ctx, RedriveWorkflowType, params)
+	}
+
+	logger.Info("Re-drive completed", "redriven", result.Redriven, "succeeded", result.Succeeded, "failed", result.Failed, "skipped", len(result.Skipped))
+	return result, nil
+}
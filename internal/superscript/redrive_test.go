@@ -0,0 +1,147 @@
+package superscript
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestRedriveWorkflow(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	store := NewBatchStore(t.TempDir())
+	deadLetters := deadLetterStore(store)
+	failedAt := time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+	for _, orderID := range []string{"1", "fail", "broken"} {
+		if err := deadLetters.AddFailure("daily", PaymentResult{OrderID: orderID, Error: "gateway timeout"}, failedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every dead letter, as if it were re-driven for the first time.
+	env := newOrchestratorEnv(&ts, store)
+	var childIDs []string
+	env.SetOnChildWorkflowStartedListener(func(info *workflow.Info, ctx workflow.Context, args converter.EncodedValues) {
+		childIDs = append(childIDs, info.WorkflowExecution.ID)
+	})
+	env.ExecuteWorkflow(RedriveWorkflow, RedriveParams{})
+	var result RedriveResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("RedriveWorkflow() error = %v", err)
+	}
+	if result.Redriven != 3 || result.Succeeded != 1 || result.Failed != 2 || len(result.Skipped) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if !containsString(childIDs, "SinglePaymentCollectionWorkflow-1-redrive-1") {
+		t.Errorf("children = %v, want fresh re-drive IDs", childIDs)
+	}
+	resolved, err := deadLetters.Get("1")
+	if err != nil || resolved.Status != DeadLetterResolved || resolved.Redrives != 1 || resolved.Error != "gateway timeout" {
+		t.Errorf("dead letter of 1 = %+v, %v", resolved, err)
+	}
+	broken, err := deadLetters.Get("broken")
+	if err != nil || broken.Status != DeadLetterFailed || broken.Redrives != 1 || broken.Output == "" || broken.LastWorkflowID != "SinglePaymentCollectionWorkflow-broken-redrive-1" {
+		t.Errorf("dead letter of broken = %+v, %v", broken, err)
+	}
+
+	// Selected orders: resolved and unknown ones are skipped.
+	env = newOrchestratorEnv(&ts, store)
+	env.ExecuteWorkflow(RedriveWorkflow, RedriveParams{OrderIDs: []string{"broken", "1", "missing"}})
+	result = RedriveResult{}
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("RedriveWorkflow() error = %v", err)
+	}
+	if result.Redriven != 1 || result.Failed != 1 || len(result.Skipped) != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	if broken, _ := deadLetters.Get("broken"); broken.Redrives != 2 {
+		t.Errorf("broken was re-driven %d times, want 2", broken.Redrives)
+	}
+}
+
+func TestRedriveWorkflow_Pages(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	store := NewBatchStore(t.TempDir())
+	deadLetters := deadLetterStore(store)
+	failedAt := time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+	for _, orderID := range []string{"1", "2", "fail", "3"} {
+		if err := deadLetters.AddFailure("daily", PaymentResult{OrderID: orderID, Error: "gateway timeout"}, failedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "fail" fails again and stays failed; later pages must not pick it up a second time.
+	params := RedriveParams{PageSize: 2}
+	var result RedriveResult
+	for run := 0; ; run++ {
+		if run == 5 {
+			t.Fatal("RedriveWorkflow did not complete in 5 runs")
+		}
+		env := newOrchestratorEnv(&ts, store)
+		env.ExecuteWorkflow(RedriveWorkflow, params)
+		err := env.GetWorkflowError()
+		var canErr *workflow.ContinueAsNewError
+		if !errors.As(err, &canErr) {
+			if err != nil {
+				t.Fatalf("run %d: RedriveWorkflow() error = %v", run, err)
+			}
+			if err := env.GetWorkflowResult(&result); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		params = RedriveParams{}
+		if err := converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if params.Cursor == nil || params.Cursor.Runs != 1 {
+		t.Errorf("cursor = %+v, want one run before the last", params.Cursor)
+	}
+	if result.Redriven != 4 || result.Succeeded != 3 || result.Failed != 1 || len(result.Results) != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	if letter, _ := deadLetters.Get("fail"); letter.Redrives != 1 {
+		t.Errorf("fail was re-driven %d times, want 1", letter.Redrives)
+	}
+}
+
+func TestActivities_PaymentWorkflowOf(t *testing.T) {
+	deadLetters := NewDeadLetterStore(t.TempDir())
+	a := &Activities{DeadLetters: deadLetters}
+	at := time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+	for _, orderID := range []string{"1", "2"} {
+		if err := deadLetters.AddFailure("daily", PaymentResult{OrderID: orderID, Error: "gateway timeout"}, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := deadLetters.RecordRedrive("1", 1, RedriveWorkflowID("1", 1), PaymentResult{OrderID: "1", Success: true}, at); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := deadLetters.RecordRedrive("2", 1, RedriveWorkflowID("2", 1), PaymentResult{OrderID: "2", Error: "declined"}, at); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"1":     "SinglePaymentCollectionWorkflow-1-redrive-1",
+		"2":     "SinglePaymentCollectionWorkflow-2",
+		"other": "SinglePaymentCollectionWorkflow-other",
+	}
+	for orderID, want := range tests {
+		if got, err := a.paymentWorkflowOf(orderID); err != nil || got != want {
+			t.Errorf("paymentWorkflowOf(%s) = %q, %v, want %q", orderID, got, err, want)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
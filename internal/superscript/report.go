@@ -0,0 +1,339 @@
+package superscript
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// slowestOrders is how many of the slowest orders a report lists.
+	slowestOrders = 10
+	// failureSampleOrders is how many OrderIDs a report lists for each failure reason.
+	failureSampleOrders = 10
+	// maxFailureReasonLen bounds a failure reason, which may be a whole error chain.
+	maxFailureReasonLen = 300
+)
+
+// Report formats, which are also the extensions of the stored reports.
+const (
+	ReportCSV  = "csv"
+	ReportJSON = "json"
+	ReportHTML = "html"
+)
+
+// ReportName is the name a batch's report in format is stored under.
+func ReportName(batchID, format string) string {
+	return batchID + "/report." + format
+}
+
+// BatchReport summarizes a completed batch.
+type BatchReport struct {
+	BatchID           string    `json:"batch_id"`
+	GeneratedAt       time.Time `json:"generated_at"`
+	StartTime         time.Time `json:"start_time"`
+	EndTime           time.Time `json:"end_time"`
+	Duration          Duration  `json:"duration"`
+	Runs              int       `json:"runs"`
+	TotalCount        int       `json:"total_count"`
+	SuccessCount      int       `json:"success_count"`
+	FailCount         int       `json:"fail_count"`
+	DeduplicatedCount int       `json:"deduplicated_count"`
+	// SuccessRate is BatchResult.GetSuccessRate, in percent.
+	SuccessRate int `json:"success_rate"`
+	// Slowest are the orders that took longest, slowest first.
+	Slowest []ReportOrder `json:"slowest"`
+	// Failures are the failed orders grouped by exit code, most frequent first.
+	Failures []FailureGroup `json:"failures"`
+	// Results are the results of every order; only the CSV and JSON reports list them.
+	Results []PaymentResult `json:"results"`
+}
+
+// ReportOrder is an order in the slowest list of a report.
+type ReportOrder struct {
+	OrderID       string   `json:"order_id"`
+	ExecutionTime Duration `json:"execution_time"`
+	Success       bool     `json:"success"`
+	Status        string   `json:"status,omitempty"`
+}
+
+// FailureGroup counts the failed orders that exited with ExitCode, by reason.
+type FailureGroup struct {
+	ExitCode int             `json:"exit_code"`
+	Count    int             `json:"count"`
+	Reasons  []FailureReason `json:"reasons"`
+}
+
+// FailureReason counts the failed orders with the same error, with a sample of their OrderIDs.
+type FailureReason struct {
+	Reason   string   `json:"reason"`
+	Count    int      `json:"count"`
+	OrderIDs []string `json:"order_ids"`
+}
+
+// failureReason is what a report says a failed order failed with: what the script reported,
+// or else the error of its workflow.
+func failureReason(result PaymentResult) string {
+	reason := result.Error
+	switch {
+	case result.Failure != nil && result.Failure.Message != "":
+		reason = result.Failure.Message
+		if result.Failure.Code != "" {
+			reason = result.Failure.Code + ": " + reason
+		}
+	case result.ErrorMessage != "":
+		reason = result.ErrorMessage
+	case reason == "":
+		reason = "unknown"
+	}
+	if len(reason) > maxFailureReasonLen {
+		reason = reason[:maxFailureReasonLen] + "..."
+	}
+	return reason
+}
+
+// NewBatchReport builds the report of a completed batch from its result and the results of
+// all of its orders.
+func NewBatchReport(batch BatchResult, results []PaymentResult, generatedAt time.Time) *BatchReport {
+	report := &BatchReport{
+		BatchID:           batch.BatchID,
+		GeneratedAt:       generatedAt,
+		StartTime:         batch.StartTime,
+		EndTime:           batch.EndTime,
+		Duration:          Duration(batch.EndTime.Sub(batch.StartTime)),
+		Runs:              batch.Runs,
+		TotalCount:        batch.TotalCount,
+		SuccessCount:      batch.SuccessCount,
+		FailCount:         batch.FailCount,
+		DeduplicatedCount: batch.DeduplicatedCount,
+		SuccessRate:       batch.GetSuccessRate(),
+		Slowest:           []ReportOrder{},
+		Failures:          []FailureGroup{},
+		Results:           results,
+	}
+	if report.Results == nil {
+		report.Results = []PaymentResult{}
+	}
+
+	byTime := make([]PaymentResult, 0, len(results))
+	for _, result := range results {
+		if result.ExecutionTime > 0 {
+			byTime = append(byTime, result)
+		}
+	}
+	sort.SliceStable(byTime, func(i, j int) bool { return byTime[i].ExecutionTime > byTime[j].ExecutionTime })
+	for _, result := range byTime[:min(len(byTime), slowestOrders)] {
+		report.Slowest = append(report.Slowest, ReportOrder{
+			OrderID:       result.OrderID,
+			ExecutionTime: Duration(result.ExecutionTime),
+			Success:       result.Success,
+			Status:        result.Status,
+		})
+	}
+
+	groups := make(map[int]*FailureGroup)
+	reasons := make(map[int]map[string]*FailureReason)
+	for _, result := range results {
+		if result.Success {
+			continue
+		}
+		group := groups[result.ExitCode]
+		if group == nil {
+			group = &FailureGroup{ExitCode: result.ExitCode}
+			groups[result.ExitCode] = group
+			reasons[result.ExitCode] = make(map[string]*FailureReason)
+		}
+		group.Count++
+		text := failureReason(result)
+		reason := reasons[result.ExitCode][text]
+		if reason == nil {
+			reason = &FailureReason{Reason: text}
+			reasons[result.ExitCode][text] = reason
+		}
+		reason.Count++
+		if len(reason.OrderIDs) < failureSampleOrders {
+			reason.OrderIDs = append(reason.OrderIDs, result.OrderID)
+		}
+	}
+	for exitCode, group := range groups {
+		for _, reason := range reasons[exitCode] {
+			group.Reasons = append(group.Reasons, *reason)
+		}
+		sort.Slice(group.Reasons, func(i, j int) bool {
+			if group.Reasons[i].Count != group.Reasons[j].Count {
+				return group.Reasons[i].Count > group.Reasons[j].Count
+			}
+			return group.Reasons[i].Reason < group.Reasons[j].Reason
+		})
+		report.Failures = append(report.Failures, *group)
+	}
+	sort.Slice(report.Failures, func(i, j int) bool {
+		if report.Failures[i].Count != report.Failures[j].Count {
+			return report.Failures[i].Count > report.Failures[j].Count
+		}
+		return report.Failures[i].ExitCode < report.Failures[j].ExitCode
+	})
+	return report
+}
+
+// CSV renders one row per order.
+func (r *BatchReport) CSV() ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"order_id", "success", "status", "exit_code", "outcome", "execution_time_ms", "timestamp", "workflow_id", "error"})
+	for _, result := range r.Results {
+		var timestamp string
+		if !result.Timestamp.IsZero() {
+			timestamp = result.Timestamp.UTC().Format(time.RFC3339)
+		}
+		var reason string
+		if !result.Success {
+			reason = failureReason(result)
+		}
+		w.Write([]string{
+			result.OrderID,
+			strconv.FormatBool(result.Success),
+			result.Status,
+			strconv.Itoa(result.ExitCode),
+			result.Outcome,
+			strconv.FormatInt(result.ExecutionTime.Milliseconds(), 10),
+			timestamp,
+			result.WorkflowID,
+			reason,
+		})
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// JSON renders the report with every order's result.
+func (r *BatchReport) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// reportTemplate renders a self-contained page: no scripts, and no styles or images to load.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Batch {{.BatchID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; vertical-align: top; }
+th { background: #f3f3f3; }
+.rate { font-size: 2em; font-weight: bold; }
+.ok { color: #1a7f37; }
+.failed { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>Batch {{.BatchID}}</h1>
+<p class="rate {{if eq .FailCount 0}}ok{{else}}failed{{end}}">{{.SuccessRate}}% succeeded</p>
+<table>
+<tr><th>Orders</th><td>{{.TotalCount}}</td></tr>
+<tr><th>Succeeded</th><td>{{.SuccessCount}}</td></tr>
+<tr><th>Failed</th><td>{{.FailCount}}</td></tr>
+<tr><th>Deduplicated</th><td>{{.DeduplicatedCount}}</td></tr>
+<tr><th>Started</th><td>{{.StartTime.UTC.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th>Ended</th><td>{{.EndTime.UTC.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th>Duration</th><td>{{.Duration}}</td></tr>
+<tr><th>Runs</th><td>{{.Runs}}</td></tr>
+</table>
+<h2>Failures by exit code</h2>
+{{if .Failures}}<table>
+<tr><th>Exit code</th><th>Orders</th><th>Reason</th><th>Orders with this reason</th></tr>
+{{range .Failures}}{{$group := .}}{{range $i, $reason := .Reasons}}<tr>
+{{if eq $i 0}}<td rowspan="{{len $group.Reasons}}">{{$group.ExitCode}}</td><td rowspan="{{len $group.Reasons}}">{{$group.Count}}</td>{{end}}
+<td>{{$reason.Reason}}</td><td>{{$reason.Count}}: {{range $j, $id := $reason.OrderIDs}}{{if $j}}, {{end}}{{$id}}{{end}}{{if gt $reason.Count (len $reason.OrderIDs)}}, ...{{end}}</td>
+</tr>
+{{end}}{{end}}</table>
+{{else}}<p>No failures.</p>
+{{end}}<h2>Slowest orders</h2>
+{{if .Slowest}}<table>
+<tr><th>Order</th><th>Time</th><th>Result</th></tr>
+{{range .Slowest}}<tr><td>{{.OrderID}}</td><td>{{.ExecutionTime}}</td><td class="{{if .Success}}ok">succeeded{{else}}failed">failed{{end}}{{if eq .Status "deduplicated"}} (deduplicated){{end}}</td></tr>
+{{end}}</table>
+{{else}}<p>No timed orders.</p>
+{{end}}<p>Generated {{.GeneratedAt.UTC.Format "2006-01-02 15:04:05 MST"}}.</p>
+</body>
+</html>
+`))
+
+// HTML renders a summary page: the success rate, failure reasons by exit code and the slowest
+// orders.
+func (r *BatchReport) HTML() ([]byte, error) {
+	var b bytes.Buffer
+	if err := reportTemplate.Execute(&b, r); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// GenerateReportRequest asks for the reports of a completed batch.
+type GenerateReportRequest struct {
+	Result BatchResult `json:"result"`
+}
+
+// GeneratedReports names the stored reports of a batch, by format.
+type GeneratedReports struct {
+	Reports map[string]string `json:"reports"`
+}
+
+func (a *Activities) reportStore() ReportStore {
+	if a.Reports == nil {
+		return NewDirReportStore("")
+	}
+	return a.Reports
+}
+
+// GenerateBatchReport renders the CSV, JSON and HTML reports of a completed batch from the
+// results in the batch store, and stores them in the report store under ReportName. Retries
+// render and store the reports again.
+func (a *Activities) GenerateBatchReport(ctx context.Context, req GenerateReportRequest) (*GeneratedReports, error) {
+	batch := req.Result
+	results, err := a.batchStore().LoadResults(batch.BatchID)
+	if errors.Is(err, ErrBatchNotFound) {
+		// A batch without orders stores nothing.
+		results, err = batch.Results, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load results of batch %s: %w", batch.BatchID, err)
+	}
+	report := NewBatchReport(batch, results, time.Now())
+
+	renderers := []struct {
+		format      string
+		contentType string
+		render      func() ([]byte, error)
+	}{
+		{ReportCSV, "text/csv; charset=utf-8", report.CSV},
+		{ReportJSON, "application/json", report.JSON},
+		{ReportHTML, "text/html; charset=utf-8", report.HTML},
+	}
+	generated := &GeneratedReports{Reports: make(map[string]string)}
+	store := a.reportStore()
+	for _, r := range renderers {
+		data, err := r.render()
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s report of batch %s: %w", r.format, batch.BatchID, err)
+		}
+		name := ReportName(batch.BatchID, r.format)
+		if err := store.Put(ctx, name, r.contentType, data); err != nil {
+			return nil, fmt.Errorf("failed to store report %s: %w", name, err)
+		}
+		generated.Reports[r.format] = name
+	}
+	return generated, nil
+}
@@ -0,0 +1,266 @@
+package superscript
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultReportDir is where batch reports are kept when neither SUPERSCRIPT_REPORT_DIR nor
+// SUPERSCRIPT_REPORT_S3_URL is set.
+const DefaultReportDir = "./superscript-reports"
+
+// defaultS3Region is the signing region of S3-compatible stores that do not care, e.g. MinIO.
+const defaultS3Region = "us-east-1"
+
+// ErrReportNotFound is returned for reports that were never stored.
+var ErrReportNotFound = errors.New("report not found")
+
+// ReportStore keeps rendered batch reports under names like "<batch id>/report.html".
+type ReportStore interface {
+	// Put stores a report, replacing any stored before under the same name.
+	Put(ctx context.Context, name, contentType string, data []byte) error
+	// Get returns a report and its content type.
+	Get(ctx context.Context, name string) ([]byte, string, error)
+}
+
+// ReportStoreConfig selects where reports are stored: an S3-compatible bucket when S3URL is
+// set, and a local directory otherwise.
+type ReportStoreConfig struct {
+	Dir string
+	// S3URL is the path-style URL of the bucket and an optional key prefix, e.g.
+	// "http://localhost:9000/reports/superscript".
+	S3URL           string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// NewReportStore returns the report store cfg describes.
+func NewReportStore(cfg ReportStoreConfig) (ReportStore, error) {
+	if cfg.S3URL == "" {
+		return NewDirReportStore(cfg.Dir), nil
+	}
+	u, err := url.Parse(cfg.S3URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid report S3 URL %q: want http(s)://host/bucket[/prefix]", cfg.S3URL)
+	}
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid report S3 URL %q: no bucket", cfg.S3URL)
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("report S3 URL %q needs an access key ID and secret", cfg.S3URL)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	return &S3ReportStore{
+		Endpoint:        u.Scheme + "://" + u.Host,
+		Bucket:          bucket,
+		Prefix:          prefix,
+		Region:          region,
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+	}, nil
+}
+
+// validReportName reports whether name is a batch ID and a file name, like the names
+// GenerateBatchReport stores.
+func validReportName(name string) bool {
+	batchID, file, ok := strings.Cut(name, "/")
+	return ok && batchIDPattern.MatchString(batchID) && strings.Trim(batchID, ".") != "" &&
+		batchIDPattern.MatchString(file) && !strings.HasPrefix(file, ".")
+}
+
+// DirReportStore keeps reports as files under Dir.
+type DirReportStore struct {
+	Dir string
+}
+
+// NewDirReportStore returns a store under dir, or DefaultReportDir when dir is empty.
+func NewDirReportStore(dir string) *DirReportStore {
+	if dir == "" {
+		dir = DefaultReportDir
+	}
+	return &DirReportStore{Dir: dir}
+}
+
+// Put writes the report like batch store files, so retried writes are idempotent.
+func (s *DirReportStore) Put(ctx context.Context, name, contentType string, data []byte) error {
+	if !validReportName(name) {
+		return fmt.Errorf("invalid report name %q", name)
+	}
+	return writeFileAtomic(filepath.Join(s.Dir, filepath.FromSlash(name)), data)
+}
+
+// Get returns a report, with the content type of its extension.
+func (s *DirReportStore) Get(ctx context.Context, name string) ([]byte, string, error) {
+	if !validReportName(name) {
+		return nil, "", fmt.Errorf("%w: %s", ErrReportNotFound, name)
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, filepath.FromSlash(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", fmt.Errorf("%w: %s", ErrReportNotFound, name)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, mime.TypeByExtension(path.Ext(name)), nil
+}
+
+// S3ReportStore keeps reports as objects in an S3-compatible bucket, such as AWS S3 or MinIO.
+// Requests are path-style and signed with AWS Signature Version 4.
+type S3ReportStore struct {
+	// Endpoint is the scheme and host of the service, e.g. "https://s3.eu-west-1.amazonaws.com".
+	Endpoint        string
+	Bucket          string
+	Prefix          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Client sends the requests; nil uses http.DefaultClient.
+	Client *http.Client
+}
+
+// Put uploads the report.
+func (s *S3ReportStore) Put(ctx context.Context, name, contentType string, data []byte) error {
+	if !validReportName(name) {
+		return fmt.Errorf("invalid report name %q", name)
+	}
+	resp, err := s.do(ctx, http.MethodPut, name, contentType, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s3Error(resp, name)
+	}
+	return nil
+}
+
+// Get downloads the report.
+func (s *S3ReportStore) Get(ctx context.Context, name string) ([]byte, string, error) {
+	if !validReportName(name) {
+		return nil, "", fmt.Errorf("%w: %s", ErrReportNotFound, name)
+	}
+	resp, err := s.do(ctx, http.MethodGet, name, "", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", fmt.Errorf("%w: %s", ErrReportNotFound, name)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, "", s3Error(resp, name)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+func s3Error(resp *http.Response, name string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("report %s: S3 answered %s: %s", name, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// objectPath is the escaped, path-style path of the object holding a report.
+func (s *S3ReportStore) objectPath(name string) string {
+	key := name
+	if prefix := strings.Trim(s.Prefix, "/"); prefix != "" {
+		key = prefix + "/" + name
+	}
+	return "/" + s3Escape(s.Bucket) + "/" + s3Escape(key)
+}
+
+// s3Escape escapes a key the way Signature Version 4 expects: everything but unreserved
+// characters and '/'.
+func s3Escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (s *S3ReportStore) do(ctx context.Context, method, name, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(s.Endpoint, "/")+s.objectPath(name), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body, time.Now().UTC())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// sign adds the Signature Version 4 Authorization header to req, signing its host, content
+// hash and date headers.
+func (s *S3ReportStore) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{date, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
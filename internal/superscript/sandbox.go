@@ -0,0 +1,89 @@
+package superscript
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Bounds of sandbox limits, so a typo cannot starve a script before it starts.
+const (
+	minSandboxMemoryMB  = 16
+	minSandboxOpenFiles = 16
+)
+
+// errSandboxSetup marks failures to prepare the sandbox; retrying does not fix them.
+var errSandboxSetup = errors.New("sandbox setup failed")
+
+// SandboxSpec restricts what a script can do. Sandboxes are only supported on Linux.
+// Sandboxed scripts get a scrubbed environment: PATH, HOME and TMPDIR, and set_env.
+type SandboxSpec struct {
+	// User runs the script as this user (name or uid) and its primary group.
+	// The worker must run as root to switch users.
+	User string `json:"user,omitempty"`
+	// TempDir runs the script in a fresh temporary directory, also its HOME and TMPDIR,
+	// which is removed when the script ends.
+	TempDir bool `json:"temp_dir,omitempty"`
+	// CPUTime, MemoryMB (address space) and OpenFiles are resource limits (rlimits).
+	// They are applied with prlimit(1), which must be installed on the worker.
+	CPUTime   Duration `json:"cpu_time,omitempty"`
+	MemoryMB  int      `json:"memory_mb,omitempty"`
+	OpenFiles int      `json:"open_files,omitempty"`
+	// NoNetwork runs the script in a new network namespace with no interfaces but loopback.
+	NoNetwork bool `json:"no_network,omitempty"`
+}
+
+func (sb *SandboxSpec) validate(s *ScriptSpec) error {
+	if len(s.Env) > 0 {
+		return fmt.Errorf("sandboxed scripts cannot pass worker environment variables through, use set_env")
+	}
+	if sb.TempDir && s.WorkDir != "" {
+		return fmt.Errorf("work_dir and sandbox temp_dir are exclusive")
+	}
+	if sb.CPUTime < 0 || (sb.CPUTime > 0 && time.Duration(sb.CPUTime) < time.Second) {
+		return fmt.Errorf("sandbox cpu_time must be at least 1s")
+	}
+	if sb.MemoryMB < 0 || (sb.MemoryMB > 0 && sb.MemoryMB < minSandboxMemoryMB) {
+		return fmt.Errorf("sandbox memory_mb must be at least %d", minSandboxMemoryMB)
+	}
+	if sb.OpenFiles < 0 || (sb.OpenFiles > 0 && sb.OpenFiles < minSandboxOpenFiles) {
+		return fmt.Errorf("sandbox open_files must be at least %d", minSandboxOpenFiles)
+	}
+	return nil
+}
+
+// limitArgs returns the prlimit(1) options for the sandbox's rlimits.
+func (sb *SandboxSpec) limitArgs() []string {
+	var args []string
+	if sb.CPUTime > 0 {
+		// RLIMIT_CPU is in whole seconds; round up.
+		seconds := (time.Duration(sb.CPUTime) + time.Second - 1) / time.Second
+		args = append(args, "--cpu="+strconv.FormatInt(int64(seconds), 10))
+	}
+	if sb.MemoryMB > 0 {
+		args = append(args, "--as="+strconv.FormatInt(int64(sb.MemoryMB)<<20, 10))
+	}
+	if sb.OpenFiles > 0 {
+		args = append(args, "--nofile="+strconv.Itoa(sb.OpenFiles))
+	}
+	return args
+}
+
+// wrapWithLimits makes cmd run its program through prlimit(1), which applies the limits to
+// itself and then execs the program, so the script never runs without them.
+func wrapWithLimits(cmd *exec.Cmd, limits []string) error {
+	if len(limits) == 0 {
+		return nil
+	}
+	prlimit, err := exec.LookPath("prlimit")
+	if err != nil {
+		return fmt.Errorf("resource limits need prlimit: %w", err)
+	}
+	args := append([]string{"prlimit"}, limits...)
+	args = append(args, "--", cmd.Path)
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = prlimit
+	return nil
+}
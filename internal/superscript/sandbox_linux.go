@@ -0,0 +1,79 @@
+//go:build linux
+
+package superscript
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// applySandbox prepares cmd to run inside sb. It returns a function that removes what it
+// created, to be called once the script has ended.
+func applySandbox(cmd *exec.Cmd, sb *SandboxSpec) (func(), error) {
+	cleanup := func() {}
+	attr := cmd.SysProcAttr
+	if attr == nil {
+		attr = &syscall.SysProcAttr{}
+		cmd.SysProcAttr = attr
+	}
+
+	uid, gid := os.Getuid(), os.Getgid()
+	if sb.User != "" {
+		u, err := lookupUser(sb.User)
+		if err != nil {
+			return cleanup, err
+		}
+		if os.Geteuid() != 0 {
+			return cleanup, fmt.Errorf("running scripts as %s needs a worker running as root", sb.User)
+		}
+		uid, _ = strconv.Atoi(u.Uid)
+		gid, _ = strconv.Atoi(u.Gid)
+		attr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}
+	}
+
+	if sb.NoNetwork {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+		if os.Geteuid() != 0 {
+			// Unprivileged workers need a user namespace to create a network namespace;
+			// it maps the worker's own ids, so the script keeps running as the worker's user.
+			attr.Cloneflags |= syscall.CLONE_NEWUSER
+			attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+			attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		}
+	}
+
+	env := append([]string(nil), cmd.Env...)
+	if sb.TempDir {
+		dir, err := os.MkdirTemp("", "superscript-")
+		if err != nil {
+			return cleanup, err
+		}
+		cleanup = func() { os.RemoveAll(dir) }
+		if sb.User != "" {
+			if err := os.Chown(dir, uid, gid); err != nil {
+				cleanup()
+				return func() {}, err
+			}
+		}
+		cmd.Dir = dir
+		env = append(env, "HOME="+dir, "TMPDIR="+dir)
+	}
+	cmd.Env = env
+
+	if err := wrapWithLimits(cmd, sb.limitArgs()); err != nil {
+		cleanup()
+		return func() {}, err
+	}
+	return cleanup, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return user.LookupId(name)
+	}
+	return user.Lookup(name)
+}
@@ -0,0 +1,79 @@
+package superscript
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunScript_SandboxTempDirAndEnv(t *testing.T) {
+	path := writeTestScript(t, "pwd\necho \"home=$HOME tmp=$TMPDIR\"\necho \"secret=$SECRET\"\ntouch scratch\n")
+	t.Setenv("SECRET", "worker-only")
+	spec := ScriptSpec{Name: "t", Path: path, SetEnv: map[string]string{"MODE": "test"}}
+	out, err := runScript(context.Background(), scriptCommand{
+		Path:    path,
+		Env:     spec.Environ(os.LookupEnv),
+		Sandbox: &SandboxSpec{TempDir: true},
+	}, nil)
+	if err != nil {
+		t.Fatalf("runScript() error = %v", err)
+	}
+	lines := strings.Split(out.Output, "\n")
+	dir := lines[0]
+	if !strings.HasPrefix(dir, os.TempDir()) || lines[1] != "home="+dir+" tmp="+dir || lines[2] != "secret=" {
+		t.Errorf("output = %q", out.Output)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("temp dir %s was not removed: %v", dir, err)
+	}
+}
+
+func TestRunScript_SandboxLimits(t *testing.T) {
+	if _, err := exec.LookPath("prlimit"); err != nil {
+		t.Skip("prlimit is not installed")
+	}
+	path := writeTestScript(t, "ulimit -n\nulimit -t\nulimit -v\n")
+	out, err := runScript(context.Background(), scriptCommand{
+		Path:    path,
+		Sandbox: &SandboxSpec{OpenFiles: 32, CPUTime: Duration(1500 * time.Millisecond), MemoryMB: 256},
+	}, nil)
+	if err != nil {
+		t.Fatalf("runScript() error = %v", err)
+	}
+	if want := "32\n2\n262144\n"; out.Output != want {
+		t.Errorf("output = %q, want %q", out.Output, want)
+	}
+}
+
+func TestRunScript_SandboxUserAndNetwork(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users needs root")
+	}
+	path := writeTestScript(t, "id -u\ncat /proc/net/dev | tail -n +3 | cut -d: -f1 | tr -d ' '\n")
+	// The test script lives in directories only root can enter.
+	for dir := filepath.Dir(path); dir != os.TempDir() && dir != "/"; dir = filepath.Dir(dir) {
+		if err := os.Chmod(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := runScript(context.Background(), scriptCommand{
+		Path:    path,
+		Sandbox: &SandboxSpec{User: "65534", NoNetwork: true, TempDir: true},
+	}, nil)
+	if err != nil {
+		t.Fatalf("runScript() error = %v", err)
+	}
+	if want := "65534\nlo\n"; out.Output != want {
+		t.Errorf("output = %q, want %q", out.Output, want)
+	}
+
+	_, err = runScript(context.Background(), scriptCommand{Path: path, Sandbox: &SandboxSpec{User: "no-such-user-xyz"}}, nil)
+	if !errors.Is(err, errSandboxSetup) {
+		t.Errorf("runScript() error = %v, want errSandboxSetup", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package superscript
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// applySandbox fails: sandboxes need Linux namespaces and credentials.
+func applySandbox(cmd *exec.Cmd, sb *SandboxSpec) (func(), error) {
+	return func() {}, errors.New("sandboxed scripts are only supported on Linux")
+}
@@ -0,0 +1,222 @@
+package superscript
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	// Embed the timezone database so run dates resolve the same way on every worker.
+	_ "time/tzdata"
+)
+
+const (
+	// ScheduleIDPrefix prefixes the IDs of all payment-collection schedules.
+	ScheduleIDPrefix = "superscript-daily-"
+	// ScheduledOrchestratorWorkflowType is the workflow the schedules start.
+	ScheduledOrchestratorWorkflowType = "ScheduledOrchestratorWorkflow"
+
+	// DefaultCatchupWindow is how late a missed run may still start, e.g. after an outage.
+	DefaultCatchupWindow = 6 * time.Hour
+	// minCatchupWindow is the shortest catch-up window Temporal accepts.
+	minCatchupWindow = 10 * time.Second
+)
+
+// ScheduledStartTimeKey is the search attribute Temporal sets on the workflows started by a
+// schedule, including backfilled ones, to the time they were scheduled for.
+var ScheduledStartTimeKey = temporal.NewSearchAttributeKeyTime("TemporalScheduledStartTime")
+
+var scheduleIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,100}$`)
+
+// overlapPolicies are the overlap policies a schedule can name.
+var overlapPolicies = map[string]enumspb.ScheduleOverlapPolicy{
+	"skip":            enumspb.SCHEDULE_OVERLAP_POLICY_SKIP,
+	"buffer_one":      enumspb.SCHEDULE_OVERLAP_POLICY_BUFFER_ONE,
+	"buffer_all":      enumspb.SCHEDULE_OVERLAP_POLICY_BUFFER_ALL,
+	"cancel_other":    enumspb.SCHEDULE_OVERLAP_POLICY_CANCEL_OTHER,
+	"terminate_other": enumspb.SCHEDULE_OVERLAP_POLICY_TERMINATE_OTHER,
+	"allow_all":       enumspb.SCHEDULE_OVERLAP_POLICY_ALLOW_ALL,
+}
+
+// OverlapPolicy returns the Temporal overlap policy named name, e.g. "skip" or "buffer_one".
+// An empty name is "skip".
+func OverlapPolicy(name string) (enumspb.ScheduleOverlapPolicy, error) {
+	if name == "" {
+		return enumspb.SCHEDULE_OVERLAP_POLICY_SKIP, nil
+	}
+	policy, ok := overlapPolicies[strings.ToLower(name)]
+	if !ok {
+		return enumspb.SCHEDULE_OVERLAP_POLICY_UNSPECIFIED, fmt.Errorf("unknown overlap policy %q", name)
+	}
+	return policy, nil
+}
+
+// PaymentSchedule describes a recurring payment-collection run. Every run discovers the
+// orders due on its run date from Source.
+type PaymentSchedule struct {
+	// ID is the schedule ID without ScheduleIDPrefix.
+	ID     string
+	Source OrderSource
+	// Calendars say when runs start, e.g. every day at 02:00; at least one is required.
+	Calendars []client.ScheduleCalendarSpec
+	// TimeZone is the IANA time zone the calendars and run dates are in; empty means UTC.
+	TimeZone string
+	// Overlap is what happens when a run is due while the previous one still runs (default
+	// "skip"). CatchupWindow is how late a missed run may still start (default
+	// DefaultCatchupWindow).
+	Overlap       string
+	CatchupWindow time.Duration
+	// MaxConcurrent, PageSize and ChildrenPerRun are passed to every run.
+	MaxConcurrent  int
+	PageSize       int
+	ChildrenPerRun int
+	Note           string
+	Paused         bool
+}
+
+// ScheduledRun is the input of ScheduledOrchestratorWorkflow.
+type ScheduledRun struct {
+	// ScheduleID names the batches of the schedule, one per run date.
+	ScheduleID string
+	TimeZone   string
+	// Params are the OrchestratorWorkflow params; RunDate and BatchID are set per run.
+	Params OrchestratorWorkflowParams
+}
+
+// ScheduleID returns the full Temporal schedule ID for a payment schedule ID.
+func ScheduleID(id string) string {
+	if strings.HasPrefix(id, ScheduleIDPrefix) {
+		return id
+	}
+	return ScheduleIDPrefix + id
+}
+
+// Validate checks that the schedule can be created.
+func (s PaymentSchedule) Validate() error {
+	var problems []string
+	if !scheduleIDPattern.MatchString(strings.TrimPrefix(s.ID, ScheduleIDPrefix)) {
+		problems = append(problems, "id must be 1 to 100 letters, digits, '.', '_' or '-'")
+	}
+	if err := s.Source.validateRef(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(s.Calendars) == 0 {
+		problems = append(problems, "at least one calendar is required")
+	}
+	if s.TimeZone != "" {
+		if _, err := time.LoadLocation(s.TimeZone); err != nil {
+			problems = append(problems, fmt.Sprintf("unknown time zone %q", s.TimeZone))
+		}
+	}
+	if _, err := OverlapPolicy(s.Overlap); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if s.CatchupWindow != 0 && s.CatchupWindow < minCatchupWindow {
+		problems = append(problems, fmt.Sprintf("catch-up window must be at least %s", minCatchupWindow))
+	}
+	if s.MaxConcurrent < 0 || s.MaxConcurrent > MaxConcurrencyLimit {
+		problems = append(problems, fmt.Sprintf("max concurrent must be between 1 and %d", MaxConcurrencyLimit))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid schedule: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ScheduleOptions returns the Temporal schedule that runs ScheduledOrchestratorWorkflow for s.
+// s must be valid.
+func (s PaymentSchedule) ScheduleOptions(taskQueue string) client.ScheduleOptions {
+	id := ScheduleID(s.ID)
+	overlap, _ := OverlapPolicy(s.Overlap)
+	catchupWindow := s.CatchupWindow
+	if catchupWindow == 0 {
+		catchupWindow = DefaultCatchupWindow
+	}
+	source := s.Source
+	return client.ScheduleOptions{
+		ID: id,
+		Spec: client.ScheduleSpec{
+			Calendars:    s.Calendars,
+			TimeZoneName: s.TimeZone,
+		},
+		Action: &client.ScheduleWorkflowAction{
+			ID:       id,
+			Workflow: ScheduledOrchestratorWorkflowType,
+			Args: []interface{}{ScheduledRun{
+				ScheduleID: id,
+				TimeZone:   s.TimeZone,
+				Params: OrchestratorWorkflowParams{
+					Source:         &source,
+					MaxConcurrent:  s.MaxConcurrent,
+					PageSize:       s.PageSize,
+					ChildrenPerRun: s.ChildrenPerRun,
+				},
+			}},
+			TaskQueue: taskQueue,
+		},
+		Overlap:       overlap,
+		CatchupWindow: catchupWindow,
+		Note:          s.Note,
+		Paused:        s.Paused,
+	}
+}
+
+// ScheduledBatch returns the orchestrator workflow ID and batch ID of a schedule's run on runDate.
+func ScheduledBatch(scheduleID, runDate string) (workflowID, batchID string) {
+	id := strings.TrimPrefix(ScheduleID(scheduleID), ScheduleIDPrefix)
+	return OrchestratorWorkflowType + "-" + ScheduleIDPrefix + id + "-" + runDate, ScheduleIDPrefix + id + "_" + runDate
+}
+
+// ScheduledOrchestratorWorkflow runs the batch of one scheduled day. The run date is the time
+// the run was scheduled for, in the schedule's time zone, so backfilled and late runs collect
+// the orders of the day they stand for rather than of the day they happen to run on.
+//
+// The batch runs as a child OrchestratorWorkflow with an ID per schedule and run date. A day
+// whose batch completed is never run again; a failed day can be re-run, e.g. by a backfill.
+// The re-run collects the orders checkpointed the first time, from the first one on: orders
+// whose payment workflow already ran are not paid again but reported as PaymentDeduplicated
+// with the result of that workflow.
+func ScheduledOrchestratorWorkflow(ctx workflow.Context, run ScheduledRun) (*BatchResult, error) {
+	logger := workflow.GetLogger(ctx)
+	location, err := time.LoadLocation(run.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule time zone: %w", err)
+	}
+	scheduledTime, ok := workflow.GetTypedSearchAttributes(ctx).GetTime(ScheduledStartTimeKey)
+	if !ok {
+		// Not started by the schedule, e.g. by hand: the run is for today.
+		scheduledTime = workflow.Now(ctx)
+	}
+	params := run.Params
+	params.RunDate = scheduledTime.In(location)
+	runDate := params.RunDate.Format(RunDateLayout)
+	var workflowID string
+	workflowID, params.BatchID = ScheduledBatch(run.ScheduleID, runDate)
+	logger.Info("Starting scheduled payment collection", "scheduleID", run.ScheduleID, "runDate", runDate, "scheduledTime", scheduledTime, "workflowID", workflowID)
+
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:            workflowID,
+		WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY,
+		// Cancelling a run, e.g. with the cancel_other overlap policy, lets its in-flight
+		// payments finish.
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_REQUEST_CANCEL,
+	})
+	child := workflow.ExecuteChildWorkflow(childCtx, OrchestratorWorkflowType, params)
+	if err := child.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+		if temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			logger.Info("Skipping scheduled run, the batch of this day is running or done", "runDate", runDate, "workflowID", workflowID)
+			return &BatchResult{BatchID: params.BatchID}, nil
+		}
+		return nil, err
+	}
+	var result BatchResult
+	if err := child.Get(ctx, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
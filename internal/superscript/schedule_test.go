@@ -0,0 +1,91 @@
+package superscript
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func dailySchedule() PaymentSchedule {
+	return PaymentSchedule{
+		ID:            "collections",
+		Source:        OrderSource{Name: "billing-api"},
+		Calendars:     []client.ScheduleCalendarSpec{{Hour: []client.ScheduleRange{{Start: 2}}}},
+		TimeZone:      "Asia/Kuala_Lumpur",
+		Overlap:       "buffer_one",
+		MaxConcurrent: 10,
+	}
+}
+
+func TestPaymentSchedule_ScheduleOptions(t *testing.T) {
+	s := dailySchedule()
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	opts := s.ScheduleOptions(SuperscriptTaskQueue)
+	if opts.ID != "superscript-daily-collections" || opts.Spec.TimeZoneName != "Asia/Kuala_Lumpur" ||
+		opts.Overlap != enumspb.SCHEDULE_OVERLAP_POLICY_BUFFER_ONE || opts.CatchupWindow != DefaultCatchupWindow {
+		t.Errorf("ScheduleOptions() = %+v", opts)
+	}
+	run := opts.Action.(*client.ScheduleWorkflowAction).Args[0].(ScheduledRun)
+	if run.ScheduleID != opts.ID || run.Params.Source.Name != s.Source.Name || run.Params.MaxConcurrent != 10 || !run.Params.RunDate.IsZero() {
+		t.Errorf("scheduled run = %+v", run)
+	}
+
+	invalid := dailySchedule()
+	invalid.ID = "no spaces"
+	invalid.Calendars = nil
+	invalid.TimeZone = "Mars/Olympus_Mons"
+	invalid.Overlap = "sometimes"
+	invalid.CatchupWindow = time.Second
+	invalid.Source = OrderSource{Type: SourceFile, Path: "/etc/passwd"}
+	err := invalid.Validate()
+	for _, want := range []string{"id must be", "name one instead", "at least one calendar", "unknown time zone", "unknown overlap policy", "catch-up window"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %v, want %q", err, want)
+		}
+	}
+}
+
+func TestScheduledOrchestratorWorkflow_RunDateFromScheduledTime(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(OrchestratorWorkflow)
+	env.RegisterWorkflowWithOptions(ScheduledOrchestratorWorkflow, workflow.RegisterOptions{Name: ScheduledOrchestratorWorkflowType})
+
+	var childID string
+	var got OrchestratorWorkflowParams
+	env.OnWorkflow(OrchestratorWorkflowType, mock.Anything, mock.Anything).Return(
+		func(ctx workflow.Context, params OrchestratorWorkflowParams) (*BatchResult, error) {
+			childID, got = workflow.GetInfo(ctx).WorkflowExecution.ID, params
+			return &BatchResult{BatchID: params.BatchID, SuccessCount: 3}, nil
+		})
+	// A backfilled run for 02:00 on 1 June in Kuala Lumpur, which is still 31 May in UTC.
+	scheduled := time.Date(2025, 5, 31, 18, 0, 0, 0, time.UTC)
+	if err := env.SetTypedSearchAttributesOnStart(temporal.NewSearchAttributes(ScheduledStartTimeKey.ValueSet(scheduled))); err != nil {
+		t.Fatal(err)
+	}
+
+	run := dailySchedule().ScheduleOptions(SuperscriptTaskQueue).Action.(*client.ScheduleWorkflowAction).Args[0].(ScheduledRun)
+	env.ExecuteWorkflow(ScheduledOrchestratorWorkflow, run)
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("ScheduledOrchestratorWorkflow() error = %v", err)
+	}
+	if childID != "OrchestratorWorkflow-superscript-daily-collections-2025-06-01" || got.BatchID != "superscript-daily-collections_2025-06-01" {
+		t.Errorf("child %s ran batch %s", childID, got.BatchID)
+	}
+	if got.RunDate.Format(RunDateLayout) != "2025-06-01" || !got.RunDate.Equal(scheduled) || got.Source == nil {
+		t.Errorf("child params = %+v", got)
+	}
+	if result.SuccessCount != 3 {
+		t.Errorf("result = %+v", result)
+	}
+}
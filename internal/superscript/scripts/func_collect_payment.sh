@@ -1,10 +1,22 @@
 #!/bin/bash
 # Library of functions for payment processing
 # Source this file in other scripts with: source "./func_collect_payment.sh"
+#
+# Failure injection, e.g. for benchmarks (percentages; defaults in parentheses):
+#   SUPERSCRIPT_STEP1_FAIL_PCT       step 1 fails (50)
+#   SUPERSCRIPT_STEP2_TIMEOUT_PCT    step 2 times out (20)
+#   SUPERSCRIPT_STEP2_GIBBERISH_PCT  step 2 prints gibberish and fails (20)
+#   SUPERSCRIPT_SLEEP_PCT            scales every sleep, 0 to not sleep at all (100)
+
+# Sleep for a number of seconds scaled by SUPERSCRIPT_SLEEP_PCT
+scaled_sleep() {
+    local ms=$(( $1 * 1000 * ${SUPERSCRIPT_SLEEP_PCT:-100} / 100 ))
+    sleep "$(( ms / 1000 )).$(printf '%03d' $(( ms % 1000 )))"
+}
 
 # Function to get IP address
 myip() {
-	curl -s http://icanhazip.com | \
+	curl -s --max-time 5 http://icanhazip.com | \
 	cut -d"/" -f 1 | \
 	grep -v 127\.0 | \
 	grep -v \:\:1 | \
@@ -18,13 +30,13 @@ process_step1() {
     local order_id="$1"
     
     # 50% chance of failure
-    if (( RANDOM % 2 )); then
+    if (( RANDOM % 100 < ${SUPERSCRIPT_STEP1_FAIL_PCT:-50} )); then
         echo "FAILED: Processing Step 1 for OrderID $order_id"
         return 1
     else
         # Random sleep between 1-3 seconds
         local sleep_time=$(( ( RANDOM % 3 ) + 1 ))
-        sleep "$sleep_time"
+        scaled_sleep "$sleep_time"
         echo "Step1 $order_id"
         return 0
     fi
@@ -39,16 +51,19 @@ process_step2() {
     # Generate a random number 0-99
     local rand=$((RANDOM % 100))
     
+    local timeout_pct=${SUPERSCRIPT_STEP2_TIMEOUT_PCT:-20}
+    local gibberish_pct=${SUPERSCRIPT_STEP2_GIBBERISH_PCT:-20}
+
     # 20% chance of timeout failure
-    if (( rand < 20 )); then
+    if (( rand < timeout_pct )); then
         # Timeout after 3-5 seconds
         local timeout=$(( ( RANDOM % 3 ) + 3 ))
-        sleep "$timeout"
+        scaled_sleep "$timeout"
         echo "ERROR: Timeout occurred after ${timeout}s for OrderID $order_id"
         return 2
     
     # 20% chance of gibberish failure  
-    elif (( rand < 40 )); then
+    elif (( rand < timeout_pct + gibberish_pct )); then
         # Output random gibberish
         echo "$(cat /dev/urandom | tr -dc 'a-zA-Z0-9' | fold -w 32 | head -n 1) $order_id ERROR!"
         return 3
@@ -57,7 +72,7 @@ process_step2() {
     else
         # Random sleep between 0-2 seconds
         local sleep_time=$(( RANDOM % 3 ))
-        sleep "$sleep_time"
+        scaled_sleep "$sleep_time"
         echo "Step2 $order_id"
         return 0
     fi
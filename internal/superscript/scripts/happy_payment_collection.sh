@@ -41,6 +41,8 @@ trap cleanup EXIT
 #    echo "ERROR: Cannot source functions library" >&2
 #    exit 1
 #fi
+# Structured results for the superscript runner (ss_progress, ss_result, ss_error)
+source "$(dirname "${BASH_SOURCE[0]}")/superscript_protocol.sh"
 
 # Function 1: Success 100% of the time
 # Input: OrderID
@@ -58,19 +60,22 @@ process_step1() {
 if [[ $# -lt 1 ]]; then
     echo "ERROR: Missing OrderID parameter" >&2
     echo "Usage: $0 <OrderID>" >&2
-    exit 1
+    exit 2
 fi
 
 # Verify OrderID is a number
 ORDER_ID="$1"
 if ! [[ "$ORDER_ID" =~ ^[0-9]+$ ]]; then
     echo "ERROR: OrderID must be a number" >&2
+    ss_error "INVALID_ORDER_ID" "OrderID must be a number" false
     exit 2
 fi
 
 echo "Starting payment processing for OrderID: $ORDER_ID"
+ss_fault start
 
 # Process Step 1
+ss_fault output
 echo "Starting processing step 1..."
 # Turn off errexit temporarily to capture the output and return code
 set +e
@@ -81,13 +86,18 @@ set -e
 if [[ $step1_code -ne 0 ]]; then
     LAST_ERROR_MSG="Step 1 failed: $step1_result"
     echo "$LAST_ERROR_MSG" >&2
-    exit $step1_code
+    # 75 (EX_TEMPFAIL): a failed step is temporary and may succeed when retried
+    exit 75
 fi
 
 echo "Step 1 completed successfully: $step1_result"
+ss_result "step1" "$step1_result"
+ss_fault side_effect
 
 # All steps completed successfully
+ss_fault output
 echo "Payment processing completed successfully for OrderID: $ORDER_ID"
+ss_progress "payment collected" 100
 exit 0
 #
 ## Enable strict mode
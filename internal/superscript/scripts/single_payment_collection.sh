@@ -12,10 +12,19 @@ IFS=$'\n\t'
 # Global variable to store last error message
 LAST_ERROR_MSG=""
 
+# Record an event of this run in SUPERSCRIPT_EXECUTION_LOG, if set, as
+# "<epoch seconds> <pid> <OrderID> <event>"; benchmarks count runs and payments from it
+log_execution() {
+    if [[ -n "${SUPERSCRIPT_EXECUTION_LOG:-}" && -n "${ORDER_ID:-}" ]]; then
+        echo "$(date +%s) $$ $ORDER_ID $1" >> "$SUPERSCRIPT_EXECUTION_LOG"
+    fi
+}
+
 # Setup error handling - ensures we clean up properly even if script is terminated early
 cleanup() {
     local exit_code=$?
     echo "Cleaning up resources..."
+    log_execution "exit $exit_code"
     
     # Add any cleanup actions here (e.g., removing temp files, releasing locks)
     
@@ -41,25 +50,36 @@ if ! source "$SOURCE_DIR/func_collect_payment.sh"; then
     echo "ERROR: Cannot source functions library" >&2
     exit 1
 fi
+# Structured results for the superscript runner (ss_progress, ss_result, ss_error)
+if ! source "$SOURCE_DIR/superscript_protocol.sh"; then
+    echo "ERROR: Cannot source protocol library" >&2
+    exit 1
+fi
 
 # Check if OrderID is provided
 if [[ $# -lt 1 ]]; then
     echo "ERROR: Missing OrderID parameter" >&2
     echo "Usage: $0 <OrderID>" >&2
-    exit 1
+    ss_error "MISSING_ORDER_ID" "Missing OrderID parameter" false
+    exit 2
 fi
 
 # Verify OrderID is a number
 ORDER_ID="$1"
 if ! [[ "$ORDER_ID" =~ ^[0-9]+$ ]]; then
     echo "ERROR: OrderID must be a number" >&2
+    ss_error "INVALID_ORDER_ID" "OrderID must be a number" false
     exit 2
 fi
 
 echo "Starting payment processing for OrderID: $ORDER_ID"
+log_execution attempt
+ss_fault start
 
 # Process Step 1
+ss_fault output
 echo "Starting processing step 1..."
+ss_progress "step 1 started" 0
 # Turn off errexit temporarily to capture the output and return code
 set +e
 step1_result=$(process_step1 "$ORDER_ID")
@@ -69,13 +89,18 @@ set -e
 if [[ $step1_code -ne 0 ]]; then
     LAST_ERROR_MSG="Step 1 failed: $step1_result"
     echo "$LAST_ERROR_MSG" >&2
-    exit $step1_code
+    ss_error "STEP1_FAILED" "$LAST_ERROR_MSG (code $step1_code)" true
+    # 75 (EX_TEMPFAIL): a failed step is temporary and may succeed when retried
+    exit 75
 fi
 
 echo "Step 1 completed successfully: $step1_result"
+ss_result "step1" "$step1_result"
 
 # Process Step 2
+ss_fault output
 echo "Starting processing step 2..."
+ss_progress "step 2 started" 50
 # Turn off errexit temporarily to capture the output and return code
 set +e
 step2_result=$(process_step2 "$ORDER_ID")
@@ -85,11 +110,18 @@ set -e
 if [[ $step2_code -ne 0 ]]; then
     LAST_ERROR_MSG="Step 2 failed: $step2_result"
     echo "$LAST_ERROR_MSG" >&2
-    exit $step2_code
+    ss_error "STEP2_FAILED" "$LAST_ERROR_MSG (code $step2_code)" true
+    # 75 (EX_TEMPFAIL): a failed step is temporary and may succeed when retried
+    exit 75
 fi
 
 echo "Step 2 completed successfully: $step2_result"
+ss_result "step2" "$step2_result"
+log_execution collected
+ss_fault side_effect
 
 # All steps completed successfully
+ss_fault output
 echo "Payment processing completed successfully for OrderID: $ORDER_ID"
+ss_progress "payment collected" 100
 exit 0
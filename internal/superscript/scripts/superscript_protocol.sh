@@ -0,0 +1,77 @@
+#!/bin/bash
+# Structured result protocol for scripts run by the superscript runner.
+# Source this file in other scripts with: source "./superscript_protocol.sh"
+#
+# The runner passes a file descriptor in SUPERSCRIPT_RESULT_FD and reads one JSON record
+# per line from it. Outside the runner the functions do nothing, so scripts still run by hand.
+#
+#   ss_progress <message> [percent]          report progress
+#   ss_result <key> <value>                   report a key/value result
+#   ss_error <code> <message> [retryable]     classify a failure; retryable is "true" (default) or "false"
+#   ss_fault <point>                          inject the fault the runner chose, see below
+#
+# For chaos testing, the runner may choose a fault for a run in SUPERSCRIPT_FAULT. Scripts call
+# ss_fault at these points, and it does nothing for other faults or without one:
+#   start        "exit" exits with SUPERSCRIPT_FAULT_EXIT_CODE, "hang" stops making progress
+#   output       "slow_output" waits SUPERSCRIPT_FAULT_DELAY seconds before the next output
+#   side_effect  "partial" exits with SUPERSCRIPT_FAULT_EXIT_CODE after the side effect was made
+
+# Escape a string for use inside a JSON string.
+ss_json_escape() {
+    local s="$1"
+    s="${s//\\/\\\\}"
+    s="${s//\"/\\\"}"
+    s="${s//$'\n'/\\n}"
+    s="${s//$'\r'/\\r}"
+    s="${s//$'\t'/\\t}"
+    printf '%s' "$s"
+}
+
+# Write one record to the result descriptor, if the runner provided one.
+ss_emit() {
+    if [[ -n "${SUPERSCRIPT_RESULT_FD:-}" ]]; then
+        printf '%s\n' "$1" >&"${SUPERSCRIPT_RESULT_FD}"
+    fi
+}
+
+ss_progress() {
+    local message percent
+    message="$(ss_json_escape "$1")"
+    percent="${2:-}"
+    if [[ "$percent" =~ ^[0-9]+$ ]]; then
+        ss_emit "{\"type\":\"progress\",\"message\":\"${message}\",\"percent\":${percent}}"
+    else
+        ss_emit "{\"type\":\"progress\",\"message\":\"${message}\"}"
+    fi
+}
+
+ss_result() {
+    ss_emit "{\"type\":\"result\",\"key\":\"$(ss_json_escape "$1")\",\"value\":\"$(ss_json_escape "$2")\"}"
+}
+
+ss_error() {
+    local retryable="true"
+    if [[ "${3:-true}" == "false" ]]; then
+        retryable="false"
+    fi
+    ss_emit "{\"type\":\"error\",\"code\":\"$(ss_json_escape "$1")\",\"message\":\"$(ss_json_escape "$2")\",\"retryable\":${retryable}}"
+}
+
+ss_fault() {
+    local fault="${SUPERSCRIPT_FAULT:-}"
+    local code="${SUPERSCRIPT_FAULT_EXIT_CODE:-75}"
+    case "$1:$fault" in
+        start:exit|side_effect:partial)
+            echo "FAULT: injected $fault fault at $1, exiting with $code" >&2
+            ss_error "INJECTED_FAULT" "injected $fault fault at $1" true
+            exit "$code"
+            ;;
+        start:hang)
+            echo "FAULT: injected hang" >&2
+            while true; do sleep 3600; done
+            ;;
+        output:slow_output)
+            sleep "${SUPERSCRIPT_FAULT_DELAY:-1}"
+            ;;
+    esac
+}
@@ -2,6 +2,7 @@
 ##
 ## Traditional Payment Collection Script
 ## This script processes a batch of OrderIDs using the single_payment_collection.sh script
+## Usage: traditional_payment_collection.sh [OrderID...] (without OrderIDs, a fixed list is processed)
 
 # Always use strict mode for reliable error handling
 # URL: http://redsymbol.net/articles/unofficial-bash-strict-mode/
@@ -34,6 +35,9 @@ ORDER_IDS=(
     6606
     8448
 )
+if [[ $# -gt 0 ]]; then
+    ORDER_IDS=("$@")
+fi
 
 # Summary counters
 TOTAL_COUNT=0
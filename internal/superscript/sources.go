@@ -0,0 +1,443 @@
+package superscript
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+
+	// The "sqlite" driver for SQL order sources.
+	_ "modernc.org/sqlite"
+)
+
+// Order source types.
+const (
+	SourceFile = "file"
+	SourceSQL  = "sql"
+	SourceHTTP = "http"
+)
+
+// InvalidOrderSourceError is the application error type for order sources that can never work.
+const InvalidOrderSourceError = "InvalidOrderSource"
+
+// RunDateLayout is how run dates are written to order sources and compared with due dates.
+const RunDateLayout = "2006-01-02"
+
+// maxSourceResponse bounds the response of an HTTP order source.
+const maxSourceResponse = 64 << 20
+
+// errNoColumns means the query of an sql source returns no columns, so it can never find orders.
+var errNoColumns = errors.New("sql order source query returns no columns")
+
+// OrderSource says where a run discovers its orders. Batches and schedules only name a source;
+// where it reads from comes from the worker's SourceCatalogue.
+type OrderSource struct {
+	// Name is the source's name in the SourceCatalogue.
+	Name string `json:"name,omitempty"`
+	Type string `json:"type,omitempty"`
+	// Path is the CSV (with a header row) or JSONL file of a file source. Format is "csv" or
+	// "jsonl", and follows the file extension when empty.
+	Path   string `json:"path,omitempty"`
+	Format string `json:"format,omitempty"`
+	// Driver and DSN open the database of an sql source; "sqlite" is built in. Query gets the
+	// run date (RunDateLayout) as its only argument and returns OrderIDs in its first column.
+	Driver string `json:"driver,omitempty"`
+	DSN    string `json:"dsn,omitempty"`
+	Query  string `json:"query,omitempty"`
+	// URL is fetched by an http source with the run date in the run_date query parameter. The
+	// response is a JSON array, an object with an "orders" array, or JSON lines, of OrderIDs or
+	// of records.
+	URL string `json:"url,omitempty"`
+	// OrderField and DueField name the CSV columns or JSON fields of records (default "order_id"
+	// and "due_date"). Records with a due date are only due on that date; others are always due.
+	OrderField string `json:"order_field,omitempty"`
+	DueField   string `json:"due_field,omitempty"`
+}
+
+// SourceCatalogue lists the order sources batches may discover orders from. Requests can only
+// name one of them, so they never make the worker read other files, open other databases or
+// fetch other URLs.
+type SourceCatalogue struct {
+	Sources []OrderSource `json:"sources"`
+}
+
+// LoadSourceCatalogue reads a source catalogue from a JSON file, such as SUPERSCRIPT_SOURCES.
+// An empty path returns an empty catalogue: batches then only run the OrderIDs they are given.
+func LoadSourceCatalogue(path string) (*SourceCatalogue, error) {
+	if path == "" {
+		return &SourceCatalogue{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source catalogue: %w", err)
+	}
+	var c SourceCatalogue
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse source catalogue %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid source catalogue %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Validate checks that every source has a unique name and what its type needs.
+func (c *SourceCatalogue) Validate() error {
+	seen := make(map[string]bool)
+	for i := range c.Sources {
+		s := &c.Sources[i]
+		if s.Name == "" {
+			return fmt.Errorf("source %d: name is required", i)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("source %s: duplicate name", s.Name)
+		}
+		seen[s.Name] = true
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("source %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// Resolve returns the catalogue source a request names. A nil catalogue has no sources.
+func (c *SourceCatalogue) Resolve(ref OrderSource) (OrderSource, error) {
+	if err := ref.validateRef(); err != nil {
+		return OrderSource{}, err
+	}
+	if c != nil {
+		for _, s := range c.Sources {
+			if s.Name == ref.Name {
+				return s, nil
+			}
+		}
+	}
+	return OrderSource{}, fmt.Errorf("unknown order source %q", ref.Name)
+}
+
+// validateRef checks that a request's source only names a catalogue source.
+func (s OrderSource) validateRef() error {
+	if s.Name == "" || s != (OrderSource{Name: s.Name}) {
+		return fmt.Errorf("order sources are configured on the worker: name one instead of giving its type, path, database or url")
+	}
+	return nil
+}
+
+// Validate checks that the source has what its type needs.
+func (s *OrderSource) Validate() error {
+	switch s.Type {
+	case SourceFile:
+		if s.Path == "" {
+			return fmt.Errorf("file order source needs a path")
+		}
+		if _, err := s.fileFormat(); err != nil {
+			return err
+		}
+	case SourceSQL:
+		if s.Driver == "" || s.DSN == "" || s.Query == "" {
+			return fmt.Errorf("sql order source needs a driver, dsn and query")
+		}
+	case SourceHTTP:
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("http order source needs an http(s) url")
+		}
+	default:
+		return fmt.Errorf("unknown order source type %q", s.Type)
+	}
+	return nil
+}
+
+func (s *OrderSource) fileFormat() (string, error) {
+	format := s.Format
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(s.Path)) {
+		case ".csv":
+			format = "csv"
+		case ".jsonl", ".ndjson":
+			format = "jsonl"
+		}
+	}
+	if format != "csv" && format != "jsonl" {
+		return "", fmt.Errorf("file order source format must be csv or jsonl")
+	}
+	return format, nil
+}
+
+func (s *OrderSource) fields() (orderField, dueField string) {
+	orderField, dueField = s.OrderField, s.DueField
+	if orderField == "" {
+		orderField = "order_id"
+	}
+	if dueField == "" {
+		dueField = "due_date"
+	}
+	return orderField, dueField
+}
+
+// Discover returns the OrderIDs due on runDate, without duplicates, in the source's order.
+func (s *OrderSource) Discover(ctx context.Context, runDate time.Time) ([]string, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	d := &discovery{runDate: runDate.Format(RunDateLayout), seen: make(map[string]bool)}
+	d.orderField, d.dueField = s.fields()
+	var err error
+	switch s.Type {
+	case SourceFile:
+		err = s.discoverFile(d)
+	case SourceSQL:
+		err = s.discoverSQL(ctx, d)
+	case SourceHTTP:
+		err = s.discoverHTTP(ctx, d)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.orderIDs, nil
+}
+
+// discovery collects the due orders of a source.
+type discovery struct {
+	runDate              string
+	orderField, dueField string
+	orderIDs             []string
+	seen                 map[string]bool
+}
+
+// add records an order unless it has a due date other than the run date.
+func (d *discovery) add(orderID, due string) error {
+	orderID = strings.TrimSpace(orderID)
+	if orderID == "" || strings.ContainsAny(orderID, "\r\n") {
+		return fmt.Errorf("invalid OrderID %q", orderID)
+	}
+	if due = strings.TrimSpace(due); due != "" {
+		// Timestamps are due on their date.
+		if len(due) > len(RunDateLayout) {
+			due = due[:len(RunDateLayout)]
+		}
+		if due != d.runDate {
+			return nil
+		}
+	}
+	if !d.seen[orderID] {
+		d.seen[orderID] = true
+		d.orderIDs = append(d.orderIDs, orderID)
+	}
+	return nil
+}
+
+// addRecord adds a decoded JSON value: an OrderID, or a record with the order and due fields.
+func (d *discovery) addRecord(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return d.add(v, "")
+	case json.Number:
+		return d.add(v.String(), "")
+	case map[string]interface{}:
+		orderID, ok := v[d.orderField]
+		if !ok {
+			return fmt.Errorf("record without %s: %v", d.orderField, v)
+		}
+		due := ""
+		if value, ok := v[d.dueField]; ok && value != nil {
+			due = fmt.Sprint(value)
+		}
+		return d.add(fmt.Sprint(orderID), due)
+	default:
+		return fmt.Errorf("unexpected record %v", value)
+	}
+}
+
+// addJSON adds the records of a JSON array, of an object with an "orders" array, or of JSON lines.
+func (d *discovery) addJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for first := true; ; first = false {
+		var value interface{}
+		if err := dec.Decode(&value); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		if first {
+			if object, ok := value.(map[string]interface{}); ok {
+				if orders, ok := object["orders"].([]interface{}); ok {
+					value = orders
+				}
+			}
+		}
+		records, ok := value.([]interface{})
+		if !ok {
+			records = []interface{}{value}
+		}
+		for _, record := range records {
+			if err := d.addRecord(record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *OrderSource) discoverFile(d *discovery) error {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if format, _ := s.fileFormat(); format == "jsonl" {
+		return d.addJSON(f)
+	}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("invalid CSV header: %w", err)
+	}
+	orderCol, dueCol := -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(name) {
+		case d.orderField:
+			orderCol = i
+		case d.dueField:
+			dueCol = i
+		}
+	}
+	if orderCol < 0 {
+		return fmt.Errorf("CSV has no %s column", d.orderField)
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if orderCol >= len(record) {
+			return fmt.Errorf("CSV line without %s: %v", d.orderField, record)
+		}
+		due := ""
+		if dueCol >= 0 && dueCol < len(record) {
+			due = record[dueCol]
+		}
+		if err := d.add(record[orderCol], due); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *OrderSource) discoverSQL(ctx context.Context, d *discovery) error {
+	db, err := sql.Open(s.Driver, s.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(ctx, s.Query, d.runDate)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return errNoColumns
+	}
+	values := make([]interface{}, len(columns))
+	values[0] = new(string)
+	for i := 1; i < len(values); i++ {
+		values[i] = new(interface{})
+	}
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return err
+		}
+		// The query already selects the due orders.
+		if err := d.add(*values[0].(*string), ""); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *OrderSource) discoverHTTP(ctx context.Context, d *discovery) error {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	query.Set("run_date", d.runDate)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("order source %s answered %s", s.URL, resp.Status)
+	}
+	return d.addJSON(io.LimitReader(resp.Body, maxSourceResponse))
+}
+
+// DiscoverOrdersRequest discovers the orders of a batch from a source.
+type DiscoverOrdersRequest struct {
+	BatchID string      `json:"batch_id"`
+	Source  OrderSource `json:"source"`
+	RunDate time.Time   `json:"run_date"`
+}
+
+// DiscoveredOrders is the outcome of DiscoverOrders.
+type DiscoveredOrders struct {
+	Total int `json:"total"`
+	// Checkpointed is set when the orders had already been discovered for the batch.
+	Checkpointed bool `json:"checkpointed"`
+}
+
+// DiscoverOrders finds the orders due on the run date and stages them for the batch. The
+// staged orders are the checkpoint: once they exist, retries and later runs of the batch use
+// them instead of querying a source that may have changed in the meantime.
+func (a *Activities) DiscoverOrders(ctx context.Context, req DiscoverOrdersRequest) (*DiscoveredOrders, error) {
+	logger := slog.Default()
+	store := a.batchStore()
+	if _, total, err := store.LoadOrders(req.BatchID, 0, 0); err == nil {
+		logger.Info("Orders already discovered", "batchID", req.BatchID, "total", total)
+		return &DiscoveredOrders{Total: total, Checkpointed: true}, nil
+	} else if !errors.Is(err, ErrBatchNotFound) {
+		return nil, err
+	}
+	source, err := a.Sources.Resolve(req.Source)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), InvalidOrderSourceError, err)
+	}
+	orderIDs, err := source.Discover(ctx, req.RunDate)
+	if errors.Is(err, errNoColumns) {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), InvalidOrderSourceError, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover orders from source %s: %w", source.Name, err)
+	}
+	if err := store.SaveOrders(req.BatchID, orderIDs); err != nil {
+		return nil, err
+	}
+	logger.Info("Orders discovered", "batchID", req.BatchID, "source", source.Name, "runDate", req.RunDate.Format(RunDateLayout), "total", len(orderIDs))
+	return &DiscoveredOrders{Total: len(orderIDs)}, nil
+}
@@ -0,0 +1,153 @@
+package superscript
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+var testRunDate = time.Date(2025, 6, 1, 2, 0, 0, 0, time.FixedZone("MYT", 8*3600))
+
+func writeSourceFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOrderSource_Discover(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "orders.db")
+	conn, err := sql.Open("sqlite", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`CREATE TABLE orders (id INTEGER, due TEXT);
+		INSERT INTO orders VALUES (7307, '2025-06-01'), (5493, '2025-06-02'), (7387, '2025-06-01')`)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotRunDate string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRunDate = r.URL.Query().Get("run_date")
+		w.Write([]byte(`{"orders": [{"order_id": 7307, "due_date": "2025-06-01T09:00:00+08:00"}, {"order_id": "5493", "due_date": "2025-06-02"}, "2614"]}`))
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		source OrderSource
+		want   []string
+	}{
+		"csv": {OrderSource{Type: SourceFile, Path: writeSourceFile(t, "orders.csv",
+			"customer,order_id,due_date\nann,7307,2025-06-01\nbob,5493,2025-06-02\ncid,2614,\nann,7307,2025-06-01\n")},
+			[]string{"7307", "2614"}},
+		"jsonl": {OrderSource{Type: SourceFile, Path: writeSourceFile(t, "orders.jsonl",
+			"{\"id\": \"A-1\", \"due\": \"2025-06-01\"}\n{\"id\": \"A-2\", \"due\": \"2025-05-31\"}\n"), OrderField: "id", DueField: "due"},
+			[]string{"A-1"}},
+		"sql": {OrderSource{Type: SourceSQL, Driver: "sqlite", DSN: db, Query: "SELECT id FROM orders WHERE due = ? ORDER BY id"},
+			[]string{"7307", "7387"}},
+		"http": {OrderSource{Type: SourceHTTP, URL: server.URL + "/due"},
+			[]string{"7307", "2614"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tt.source.Discover(context.Background(), testRunDate)
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Discover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if gotRunDate != "2025-06-01" {
+		t.Errorf("run_date = %q, want the run date in its own time zone", gotRunDate)
+	}
+}
+
+func TestActivities_DiscoverOrders_Checkpoint(t *testing.T) {
+	path := writeSourceFile(t, "orders.csv", "order_id\n1\n2\n")
+	sources := &SourceCatalogue{Sources: []OrderSource{{Name: "orders", Type: SourceFile, Path: path}}}
+	a := &Activities{Batches: NewBatchStore(t.TempDir()), Sources: sources}
+	req := DiscoverOrdersRequest{BatchID: "daily", Source: OrderSource{Name: "orders"}, RunDate: testRunDate}
+	got, err := a.DiscoverOrders(context.Background(), req)
+	if err != nil || got.Total != 2 || got.Checkpointed {
+		t.Fatalf("DiscoverOrders() = %+v, %v", got, err)
+	}
+	// The source changes, but the batch keeps the orders it discovered first.
+	if err := os.WriteFile(path, []byte("order_id\n1\n2\n3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err = a.DiscoverOrders(context.Background(), req)
+	if err != nil || got.Total != 2 || !got.Checkpointed {
+		t.Errorf("DiscoverOrders() again = %+v, %v, want the checkpoint", got, err)
+	}
+
+	// Requests only name configured sources.
+	for name, source := range map[string]OrderSource{
+		"unknown":  {Name: "other"},
+		"inline":   {Type: SourceFile, Path: "/etc/passwd"},
+		"override": {Name: "orders", Path: "/etc/passwd"},
+	} {
+		req.BatchID = name
+		req.Source = source
+		var appErr *temporal.ApplicationError
+		if _, err := a.DiscoverOrders(context.Background(), req); !errors.As(err, &appErr) || !appErr.NonRetryable() || appErr.Type() != InvalidOrderSourceError {
+			t.Errorf("DiscoverOrders(%s) error = %v, want a non-retryable error", name, err)
+		}
+	}
+}
+
+func TestActivities_DiscoverOrders_NoColumns(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "orders.db")
+	sources := &SourceCatalogue{Sources: []OrderSource{{Name: "empty", Type: SourceSQL, Driver: "sqlite", DSN: db, Query: "DELETE FROM orders WHERE due = ?"}}}
+	conn, err := sql.Open("sqlite", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`CREATE TABLE orders (id INTEGER, due TEXT)`)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Activities{Batches: NewBatchStore(t.TempDir()), Sources: sources}
+	_, err = a.DiscoverOrders(context.Background(), DiscoverOrdersRequest{BatchID: "daily", Source: OrderSource{Name: "empty"}, RunDate: testRunDate})
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || !appErr.NonRetryable() || appErr.Type() != InvalidOrderSourceError {
+		t.Errorf("DiscoverOrders() error = %v, want a non-retryable error", err)
+	}
+}
+
+func TestLoadSourceCatalogue(t *testing.T) {
+	sources, err := LoadSourceCatalogue("../../demo/superscript/sources.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source, err := sources.Resolve(OrderSource{Name: "due-orders-csv"}); err != nil || source.Path != "/tmp/orders.csv" {
+		t.Errorf("Resolve() = %+v, %v", source, err)
+	}
+	if empty, err := LoadSourceCatalogue(""); err != nil || len(empty.Sources) != 0 {
+		t.Errorf("LoadSourceCatalogue(\"\") = %+v, %v", empty, err)
+	}
+	for _, invalid := range []string{
+		`{"sources": [{"type": "file", "path": "orders.csv"}]}`,
+		`{"sources": [{"name": "a", "type": "file", "path": "a.csv"}, {"name": "a", "type": "file", "path": "b.csv"}]}`,
+		`{"sources": [{"name": "a", "type": "ftp"}]}`,
+	} {
+		if _, err := LoadSourceCatalogue(writeSourceFile(t, "sources.json", invalid)); err == nil {
+			t.Errorf("LoadSourceCatalogue(%s) succeeded", invalid)
+		}
+	}
+}
@@ -12,6 +12,8 @@ const (
 	SinglePaymentWorkflowType = "SinglePaymentCollectionWorkflow"
 	// OrchestratorWorkflowType Workflow types
 	OrchestratorWorkflowType = "OrchestratorWorkflow"
+	// ScriptWorkflowType Workflow types
+	ScriptWorkflowType = "ScriptWorkflow"
 
 	// SampleOrderID Sample OrderID for demonstration
 	SampleOrderID = "ORD-DEMO-123"
@@ -20,8 +22,28 @@ const (
 	SinglePaymentScriptPath = "./internal/superscript/scripts/single_payment_collection.sh"
 	// TraditionalBatchScriptPath script paths
 	TraditionalBatchScriptPath = "./internal/superscript/scripts/traditional_payment_collection.sh"
+
+	// ScriptHeartbeatTimeout fails a script activity that printed nothing for this long.
+	// Scripts heartbeat with every line of output, so long silent steps should log progress.
+	ScriptHeartbeatTimeout = 30 * time.Second
+
+	// DefaultPageSize is how many orders the orchestrator loads and runs at a time.
+	DefaultPageSize = 500
+	// DefaultChildrenPerRun is how many children one orchestrator run starts before it
+	// continues as new, keeping its history well below Temporal's limits.
+	DefaultChildrenPerRun = 2000
+	// ReportMaxAttempts bounds the attempts to generate the reports of a completed batch.
+	// The payments are done by then, so a report that cannot be generated does not fail the batch.
+	ReportMaxAttempts = 3
+	// DiscoverOrdersTimeout bounds one attempt to discover the orders of a batch from its source.
+	DiscoverOrdersTimeout = 10 * time.Minute
 )
 
+// PaymentWorkflowID is the ID of the SinglePaymentCollectionWorkflow of an order.
+func PaymentWorkflowID(orderID string) string {
+	return SinglePaymentWorkflowType + "-" + orderID
+}
+
 // PaymentResult contains information about a payment collection attempt
 type PaymentResult struct {
 	OrderID       string        `json:"order_id"`
@@ -32,17 +54,51 @@ type PaymentResult struct {
 	ExitCode      int           `json:"exit_code"`
 	ExecutionTime time.Duration `json:"execution_time"`
 	Timestamp     time.Time     `json:"timestamp"`
+	// Results are the key/value results the script reported, such as a transaction ID.
+	Results map[string]string `json:"results,omitempty"`
+	// Failure is the error the script classified itself, if it failed.
+	Failure *ScriptFailure `json:"failure,omitempty"`
+	// Outcome is how the run was classified: success, retryable, non_retryable or business_failure.
+	Outcome string `json:"outcome,omitempty"`
+	// Attempt is the activity attempt that failed.
+	Attempt int32 `json:"attempt,omitempty"`
+	// Status is PaymentExecuted, or PaymentDeduplicated when the result is that of a payment
+	// workflow the order already had.
+	Status string `json:"status,omitempty"`
+	// WorkflowID is the payment workflow the result comes from.
+	WorkflowID string `json:"workflow_id,omitempty"`
+	// Fault is the fault injected into the last attempt, if any.
+	Fault string `json:"fault,omitempty"`
 }
 
-// BatchResult contains information about a batch of payment collections
+// Payment result statuses.
+const (
+	// PaymentExecuted results come from the payment workflow the batch started for the order.
+	PaymentExecuted = "executed"
+	// PaymentDeduplicated results come from a payment workflow the order already had, started
+	// by an earlier batch or by an earlier duplicate of the order in the same batch. The order
+	// was not collected again.
+	PaymentDeduplicated = "deduplicated"
+)
+
+// BatchResult contains information about a batch of payment collections.
+// OrderIDs and Results are only set for batches that fit in one page; the results of larger
+// batches are in the batch store under BatchID.
 type BatchResult struct {
+	BatchID      string          `json:"batch_id"`
 	OrderIDs     []string        `json:"order_ids"`
 	Results      []PaymentResult `json:"results"`
 	TotalCount   int             `json:"total_count"`
 	SuccessCount int             `json:"success_count"`
 	FailCount    int             `json:"fail_count"`
-	StartTime    time.Time       `json:"start_time"`
-	EndTime      time.Time       `json:"end_time"`
+	// DeduplicatedCount is how many of the successes and failures are PaymentDeduplicated.
+	DeduplicatedCount int       `json:"deduplicated_count,omitempty"`
+	StartTime         time.Time `json:"start_time"`
+	EndTime           time.Time `json:"end_time"`
+	// Runs is the number of workflow runs the batch took, continue-as-new included.
+	Runs int `json:"runs,omitempty"`
+	// Reports names the batch's reports in the report store, by format.
+	Reports map[string]string `json:"reports,omitempty"`
 }
 
 // GetSuccessRate calculates the success rate as a percentage
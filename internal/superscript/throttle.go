@@ -0,0 +1,224 @@
+package superscript
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Backpressure defaults.
+const (
+	DefaultBackpressureWindow    = 20
+	DefaultFailureThreshold      = 0.5
+	DefaultBackpressureDelay     = time.Second
+	DefaultMaxBackpressureDelay  = time.Minute
+	maxRateLimit                 = 1000.0
+	minBackpressureWindowSamples = 5
+)
+
+// BackpressurePolicy slows a batch down while its recent children fail, e.g. because the
+// payment gateway is overloaded, and lets it run at full speed again once they recover.
+type BackpressurePolicy struct {
+	// Window is how many of the most recently completed children the failure rate is taken
+	// over (default DefaultBackpressureWindow).
+	Window int `json:"window,omitempty"`
+	// FailureThreshold is the failure rate, from 0 to 1, at which the batch slows down
+	// (default DefaultFailureThreshold). RecoveryThreshold is the rate below which it speeds
+	// up again (default half of FailureThreshold).
+	FailureThreshold  float64 `json:"failure_threshold,omitempty"`
+	RecoveryThreshold float64 `json:"recovery_threshold,omitempty"`
+	// InitialDelay is the least time between two children started while slowed down. It
+	// doubles every Window children that the failure rate stays above the threshold, up to
+	// MaxDelay (defaults DefaultBackpressureDelay and DefaultMaxBackpressureDelay).
+	InitialDelay Duration `json:"initial_delay,omitempty"`
+	MaxDelay     Duration `json:"max_delay,omitempty"`
+}
+
+// Validate checks the policy's values.
+func (p *BackpressurePolicy) Validate() error {
+	switch {
+	case p.Window < 0 || (p.Window > 0 && p.Window < minBackpressureWindowSamples):
+		return fmt.Errorf("backpressure window must be at least %d", minBackpressureWindowSamples)
+	case p.FailureThreshold < 0 || p.FailureThreshold > 1:
+		return fmt.Errorf("failure threshold must be between 0 and 1")
+	case p.RecoveryThreshold < 0 || (p.RecoveryThreshold > 0 && p.RecoveryThreshold >= p.withDefaults().FailureThreshold):
+		return fmt.Errorf("recovery threshold must be below the failure threshold")
+	case p.InitialDelay < 0 || p.MaxDelay < 0 || (p.MaxDelay > 0 && p.MaxDelay < p.withDefaults().InitialDelay):
+		return fmt.Errorf("backpressure delays must be positive, with max_delay at least initial_delay")
+	}
+	return nil
+}
+
+func (p BackpressurePolicy) withDefaults() BackpressurePolicy {
+	if p.Window == 0 {
+		p.Window = DefaultBackpressureWindow
+	}
+	if p.FailureThreshold == 0 {
+		p.FailureThreshold = DefaultFailureThreshold
+	}
+	if p.RecoveryThreshold == 0 {
+		p.RecoveryThreshold = p.FailureThreshold / 2
+	}
+	if p.InitialDelay == 0 {
+		p.InitialDelay = Duration(DefaultBackpressureDelay)
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = max(Duration(DefaultMaxBackpressureDelay), p.InitialDelay)
+	}
+	return p
+}
+
+// BackpressureState is what backpressure remembers across continue-as-new.
+type BackpressureState struct {
+	// Recent are the outcomes of the most recently completed children, oldest first; true is
+	// a failure.
+	Recent []bool
+	// Level is 0 at full speed; the delay between children is InitialDelay * 2^(Level-1).
+	Level int
+	// SinceChange counts the children completed since Level last changed.
+	SinceChange int
+}
+
+// RateLimitState is what the rate limit's token bucket remembers across continue-as-new, so
+// a new run does not start with a full burst.
+type RateLimitState struct {
+	// Tokens is how many tokens the bucket had at Refilled.
+	Tokens   float64
+	Refilled time.Time
+	// LastStart is when the last child started.
+	LastStart time.Time
+}
+
+// validateThrottle checks the rate limit and backpressure parameters of a batch.
+func validateThrottle(params OrchestratorWorkflowParams) error {
+	if params.RateLimit < 0 || params.RateLimit > maxRateLimit || math.IsNaN(params.RateLimit) {
+		return fmt.Errorf("rate limit must be between 0 and %g orders per second", maxRateLimit)
+	}
+	if params.RateBurst < 0 {
+		return fmt.Errorf("rate burst must not be negative")
+	}
+	if params.Backpressure != nil {
+		return params.Backpressure.Validate()
+	}
+	return nil
+}
+
+// throttle paces the children of a batch: a token bucket for the rate limit, and a delay
+// between children that grows while their failure rate is too high. It only uses workflow
+// time, so its decisions replay deterministically.
+type throttle struct {
+	rate   float64
+	burst  float64
+	bucket *RateLimitState
+
+	policy *BackpressurePolicy
+	state  *BackpressureState
+}
+
+// newThrottle returns the throttle of a batch, carrying on from bucket and state. The token
+// bucket of a new batch starts full.
+func newThrottle(params OrchestratorWorkflowParams, bucket *RateLimitState, state *BackpressureState) *throttle {
+	t := &throttle{rate: params.RateLimit, bucket: bucket, state: state}
+	if t.rate > 0 {
+		t.burst = float64(max(params.RateBurst, 1))
+		if bucket.Refilled.IsZero() {
+			bucket.Tokens = t.burst
+		}
+	}
+	if params.Backpressure != nil {
+		policy := params.Backpressure.withDefaults()
+		t.policy = &policy
+	}
+	return t
+}
+
+func (t *throttle) refill(now time.Time) {
+	if t.rate <= 0 {
+		return
+	}
+	b := t.bucket
+	if !b.Refilled.IsZero() && now.After(b.Refilled) {
+		b.Tokens = math.Min(t.burst, b.Tokens+now.Sub(b.Refilled).Seconds()*t.rate)
+	}
+	b.Refilled = now
+}
+
+// delay returns how long to wait before the next child may start, or 0 when it may start now.
+func (t *throttle) delay(now time.Time) time.Duration {
+	var wait time.Duration
+	if t.rate > 0 {
+		t.refill(now)
+		if t.bucket.Tokens < 1 {
+			wait = time.Duration(math.Ceil((1 - t.bucket.Tokens) / t.rate * float64(time.Second)))
+		}
+	}
+	if backoff := t.backoff(); backoff > 0 && !t.bucket.LastStart.IsZero() {
+		wait = max(wait, t.bucket.LastStart.Add(backoff).Sub(now))
+	}
+	return max(wait, 0)
+}
+
+// started takes a token for a child that starts now.
+func (t *throttle) started(now time.Time) {
+	if t.rate > 0 {
+		t.refill(now)
+		t.bucket.Tokens--
+	}
+	t.bucket.LastStart = now
+}
+
+// backoff is the least time between two children at the current backpressure level.
+func (t *throttle) backoff() time.Duration {
+	if t.policy == nil || t.state.Level == 0 {
+		return 0
+	}
+	delay := time.Duration(t.policy.InitialDelay) << (t.state.Level - 1)
+	if maxDelay := time.Duration(t.policy.MaxDelay); delay <= 0 || delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// failureRate is the failure rate of the recent children, and whether there are enough of them.
+func (t *throttle) failureRate() (float64, bool) {
+	if t.policy == nil || len(t.state.Recent) == 0 {
+		return 0, false
+	}
+	failures := 0
+	for _, failed := range t.state.Recent {
+		if failed {
+			failures++
+		}
+	}
+	full := len(t.state.Recent) >= min(t.policy.Window, minBackpressureWindowSamples)
+	return float64(failures) / float64(len(t.state.Recent)), full
+}
+
+// completed records the outcome of a child and adjusts the backpressure level. It returns
+// the change, if any: "slowing down", "recovered" or "".
+func (t *throttle) completed(success bool) string {
+	if t.policy == nil {
+		return ""
+	}
+	s := t.state
+	s.Recent = append(s.Recent, !success)
+	if len(s.Recent) > t.policy.Window {
+		s.Recent = s.Recent[len(s.Recent)-t.policy.Window:]
+	}
+	s.SinceChange++
+	rate, enough := t.failureRate()
+	switch {
+	case !enough:
+	case rate >= t.policy.FailureThreshold && (s.Level == 0 || s.SinceChange >= t.policy.Window):
+		// Slow down further every full window that stays above the threshold.
+		if t.backoff() < time.Duration(t.policy.MaxDelay) {
+			s.Level++
+		}
+		s.SinceChange = 0
+		return "slowing down"
+	case rate < t.policy.RecoveryThreshold && s.Level > 0:
+		s.Level, s.SinceChange = 0, 0
+		return "recovered"
+	}
+	return ""
+}
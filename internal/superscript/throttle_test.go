@@ -0,0 +1,147 @@
+package superscript
+
+import (
+	"testing"
+	"time"
+
+	gocmp "github.com/google/go-cmp/cmp"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestThrottle_TokenBucket(t *testing.T) {
+	start := time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+	th := newThrottle(OrchestratorWorkflowParams{RateLimit: 2, RateBurst: 2}, &RateLimitState{}, &BackpressureState{})
+	var delays []time.Duration
+	for _, at := range []time.Duration{0, 0, 0, 500 * time.Millisecond, 600 * time.Millisecond, 5 * time.Second} {
+		now := start.Add(at)
+		delay := th.delay(now)
+		delays = append(delays, delay)
+		if delay == 0 {
+			th.started(now)
+		}
+	}
+	// A burst of two, then one every half second; idle time refills at most the burst.
+	want := []time.Duration{0, 0, 500 * time.Millisecond, 0, 400 * time.Millisecond, 0}
+	if diff := gocmp.Diff(want, delays); diff != "" {
+		t.Errorf("delays (-want +got):\n%s", diff)
+	}
+}
+
+func TestThrottle_TokenBucketAcrossRuns(t *testing.T) {
+	now := time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+	params := OrchestratorWorkflowParams{RateLimit: 1, RateBurst: 3}
+	bucket := &RateLimitState{}
+	th := newThrottle(params, bucket, &BackpressureState{})
+	for i := 0; i < 3; i++ {
+		th.started(now)
+	}
+
+	// The next run carries the cursor's bucket over, with the burst used up.
+	var carried RateLimitState
+	data, err := converter.GetDefaultDataConverter().ToPayload(bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := converter.GetDefaultDataConverter().FromPayload(data, &carried); err != nil {
+		t.Fatal(err)
+	}
+	th = newThrottle(params, &carried, &BackpressureState{})
+	if delay := th.delay(now); delay != time.Second {
+		t.Errorf("delay of the next run = %s, want 1s", delay)
+	}
+}
+
+func TestThrottle_Backpressure(t *testing.T) {
+	state := &BackpressureState{}
+	th := newThrottle(OrchestratorWorkflowParams{Backpressure: &BackpressurePolicy{Window: 5, InitialDelay: Duration(time.Second), MaxDelay: Duration(3 * time.Second)}}, &RateLimitState{}, state)
+	var changes []string
+	var backoffs []time.Duration
+	outcomes := "FFFFF" + "FFFFF" + "FFFFF" + "SSS" + "SS" + "S"
+	for _, outcome := range outcomes {
+		if change := th.completed(outcome == 'S'); change != "" {
+			changes = append(changes, change)
+			backoffs = append(backoffs, th.backoff())
+		}
+	}
+	want := []string{"slowing down", "slowing down", "slowing down", "recovered"}
+	if diff := gocmp.Diff(want, changes); diff != "" {
+		t.Errorf("changes (-want +got):\n%s", diff)
+	}
+	// The delay doubles every window, up to the maximum, and is gone after the recovery.
+	if diff := gocmp.Diff([]time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 0}, backoffs); diff != "" {
+		t.Errorf("backoffs (-want +got):\n%s", diff)
+	}
+	if len(state.Recent) != 5 {
+		t.Errorf("kept %d outcomes, want the window of 5", len(state.Recent))
+	}
+}
+
+// childStartOffsets records when each child starts, relative to the start of the workflow.
+func childStartOffsets(env *testsuite.TestWorkflowEnvironment) *[]time.Duration {
+	var offsets []time.Duration
+	var start time.Time
+	env.SetOnChildWorkflowStartedListener(func(info *workflow.Info, ctx workflow.Context, args converter.EncodedValues) {
+		if start.IsZero() {
+			start = env.Now()
+		}
+		offsets = append(offsets, env.Now().Sub(start).Round(time.Second))
+	})
+	return &offsets
+}
+
+func TestOrchestratorWorkflow_RateLimit(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := newOrchestratorEnv(&ts, NewBatchStore(t.TempDir()))
+	offsets := childStartOffsets(env)
+
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{
+		BatchID:       "limited",
+		OrderIDs:      []string{"1", "2", "3", "4", "5"},
+		MaxConcurrent: 10,
+		RateLimit:     0.5,
+		RateBurst:     2,
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	want := []time.Duration{0, 0, 2 * time.Second, 4 * time.Second, 6 * time.Second}
+	if diff := gocmp.Diff(want, *offsets); diff != "" {
+		t.Errorf("child starts (-want +got):\n%s", diff)
+	}
+}
+
+func TestOrchestratorWorkflow_Backpressure(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := newOrchestratorEnv(&ts, NewBatchStore(t.TempDir()))
+	offsets := childStartOffsets(env)
+
+	orderIDs := []string{"fail1", "fail2", "fail3", "fail4", "fail5", "fail6", "1", "2", "3", "4", "5"}
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{
+		BatchID:       "gateway-down",
+		OrderIDs:      orderIDs,
+		MaxConcurrent: 1,
+		Backpressure:  &BackpressurePolicy{Window: 5, InitialDelay: Duration(10 * time.Second)},
+	})
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	// Five failures slow the batch down to a child every 10s, until the failure rate of the
+	// last five children drops below 25%.
+	s := time.Second
+	want := []time.Duration{0, 0, 0, 0, 0, 10 * s, 20 * s, 30 * s, 40 * s, 50 * s, 50 * s}
+	if diff := gocmp.Diff(want, *offsets); diff != "" {
+		t.Errorf("child starts (-want +got):\n%s", diff)
+	}
+	if result.FailCount != 6 || result.SuccessCount != 5 {
+		t.Errorf("result = %+v", result)
+	}
+
+	env = newOrchestratorEnv(&ts, NewBatchStore(t.TempDir()))
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{OrderIDs: []string{"1"}, Backpressure: &BackpressurePolicy{FailureThreshold: 2}})
+	if err := env.GetWorkflowError(); err == nil {
+		t.Error("OrchestratorWorkflow() with a failure threshold of 2 succeeded")
+	}
+}
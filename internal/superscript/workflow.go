@@ -10,11 +10,17 @@ import (
 	"go.temporal.io/sdk/workflow"
 )
 
+// pagedOrchestratorChange is the version marker of batches that page their orders through the
+// batch store. Batches started before it replay the loop over OrderIDs they started with.
+const pagedOrchestratorChange = "paged-orchestrator"
+
 // --- Parameter Structs ---
 
 // SinglePaymentWorkflowParams contains the parameters for the SinglePaymentWorkflow
 type SinglePaymentWorkflowParams struct {
 	OrderID string
+	// Faults injects faults into the payment script for chaos testing; nil uses the worker's.
+	Faults *FaultSpec
 }
 
 // OrchestratorWorkflowParams contains the parameters for the OrchestratorWorkflow
@@ -24,10 +30,92 @@ type OrchestratorWorkflowParams struct {
 	// MaxConcurrent is the maximum number of child workflows to run concurrently.
 	// Defaults to 3 if zero or negative.
 	MaxConcurrent int
+	// Source discovers the orders due on RunDate instead of OrderIDs. The discovered orders
+	// are checkpointed in the batch store, so they are only discovered once per batch.
+	Source *OrderSource
+	// BatchID names the batch in the batch store. OrderIDs are staged under it; without
+	// OrderIDs or Source, the orders already stored under it are run. Defaults to the
+	// workflow ID and first run ID; either way it must pass ValidateBatchID.
+	BatchID string
+	// PageSize is how many orders are loaded and run at a time (default DefaultPageSize).
+	PageSize int
+	// ChildrenPerRun is how many children one run starts before it continues as new with a
+	// cursor (default DefaultChildrenPerRun).
+	ChildrenPerRun int
+	// RateLimit is the most children started per second, to spare the payment gateway the
+	// scripts call; zero is unlimited. RateBurst is how many may start at once (default 1).
+	RateLimit float64
+	RateBurst int
+	// Backpressure slows the batch down while its recent children fail; nil disables it.
+	Backpressure *BackpressurePolicy
+	// Faults injects faults into the payment scripts of the batch for chaos testing; nil uses
+	// the worker's.
+	Faults *FaultSpec
+	// Cursor carries progress across continue-as-new. Callers leave it nil.
+	Cursor *BatchCursor
+}
+
+// BatchCursor is the position and running totals of a batch, carried across continue-as-new.
+type BatchCursor struct {
+	Offset       int
+	TotalCount   int
+	SuccessCount int
+	FailCount    int
+	// DeduplicatedCount counts the results of payment workflows the orders already had.
+	DeduplicatedCount int
+	StartTime         time.Time
+	// Runs is the number of runs completed before this one.
+	Runs int
+	// Paused is set while operators hold the batch with PauseSignal.
+	Paused bool
+	// Backpressure is the recent failure rate and how far the batch slowed down because of it.
+	Backpressure BackpressureState
+	// RateLimit is the token bucket of the batch's rate limit.
+	RateLimit RateLimitState
+	// EventSeq is the Seq of the latest ProgressEvent of the batch.
+	EventSeq int64
+}
+
+// ScriptWorkflowParams contains the parameters for the ScriptWorkflow
+type ScriptWorkflowParams struct {
+	Request ScriptRequest
+	// MaxAttempts bounds how often a failing script is run. Scripts are not assumed
+	// to be idempotent, so it defaults to 1.
+	MaxAttempts int32
 }
 
 // --- Workflows ---
 
+// ScriptWorkflow runs one catalogue script as a durable activity.
+// The activity enforces the script's own timeout; MaxScriptTimeout is only a backstop.
+func ScriptWorkflow(ctx workflow.Context, params ScriptWorkflowParams) (*ScriptResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting ScriptWorkflow", "script", params.Request.Script)
+
+	maxAttempts := params.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: MaxScriptTimeout,
+		HeartbeatTimeout:    ScriptHeartbeatTimeout,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    30 * time.Second,
+			MaximumAttempts:    maxAttempts,
+		},
+	})
+
+	var result ScriptResult
+	if err := workflow.ExecuteActivity(ctx, "RunScript", params.Request).Get(ctx, &result); err != nil {
+		logger.Error("Script failed", "script", params.Request.Script, "error", err)
+		return nil, fmt.Errorf("script %s failed: %w", params.Request.Script, err)
+	}
+	logger.Info("ScriptWorkflow completed", "script", params.Request.Script, "exitCode", result.ExitCode)
+	return &result, nil
+}
+
 // SinglePaymentCollectionWorkflow executes the payment collection script for a single OrderID
 // This workflow wraps a potentially non-idempotent activity call.
 func SinglePaymentCollectionWorkflow(ctx workflow.Context, params SinglePaymentWorkflowParams) (*PaymentResult, error) {
@@ -38,6 +126,7 @@ func SinglePaymentCollectionWorkflow(ctx workflow.Context, params SinglePaymentW
 	// Define activity options
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: 2 * time.Minute,
+		HeartbeatTimeout:    ScriptHeartbeatTimeout,
 		RetryPolicy: &temporal.RetryPolicy{
 			InitialInterval:    time.Second,
 			BackoffCoefficient: 2.0,
@@ -47,8 +136,12 @@ func SinglePaymentCollectionWorkflow(ctx workflow.Context, params SinglePaymentW
 	}
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
+	args := []interface{}{params.OrderID}
+	if params.Faults != nil {
+		args = append(args, params.Faults)
+	}
 	var activityResult PaymentResult // Activity should return this structure or similar
-	err := workflow.ExecuteActivity(ctx, "RunPaymentCollectionScript", params.OrderID).Get(ctx, &activityResult)
+	err := workflow.ExecuteActivity(ctx, "RunPaymentCollectionScript", args...).Get(ctx, &activityResult)
 
 	// Prepare the workflow result
 	result := &PaymentResult{
@@ -60,23 +153,369 @@ func SinglePaymentCollectionWorkflow(ctx workflow.Context, params SinglePaymentW
 	if err != nil {
 		logger.Error("Activity execution failed", "orderID", params.OrderID, "error", err)
 		result.Error = err.Error()
+		// Script failures carry the activity's result, with what the script reported, as details.
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) && appErr.HasDetails() && appErr.Details(&activityResult) == nil {
+			copyScriptOutcome(result, &activityResult)
+		}
 		// It's generally better to return the result struct even on activity error,
 		// indicating failure within the struct, rather than returning a workflow error,
 		// unless the failure prevents the workflow from providing any meaningful result.
 		// return result, nil // Indicate handled failure
 		return result, fmt.Errorf("activity RunPaymentCollectionScript failed for order %s: %w", params.OrderID, err)
 	} else if !activityResult.Success {
-		logger.Warn("Activity completed but reported failure", "orderID", params.OrderID, "activityError", activityResult.Error)
-		result.Error = activityResult.Error // Propagate error message from activity
+		// A business failure: the script ran to completion and the answer was no.
+		logger.Warn("Activity completed but reported failure", "orderID", params.OrderID, "outcome", activityResult.Outcome, "activityError", activityResult.ErrorMessage)
+		result.Error = activityResult.ErrorMessage // Propagate error message from activity
+		result.Output = outputTail(activityResult.Output)
 	}
+	copyScriptOutcome(result, &activityResult)
 
 	logger.Info("SinglePaymentCollectionWorkflow completed", "orderID", params.OrderID, "success", result.Success)
 	return result, nil // Workflow completed successfully, result indicates activity success/failure
 }
 
-// OrchestratorWorkflow orchestrates multiple SinglePaymentCollectionWorkflows concurrently
+// copyScriptOutcome copies what the script reported from the activity result.
+func copyScriptOutcome(result, activityResult *PaymentResult) {
+	result.ExitCode = activityResult.ExitCode
+	result.ErrorMessage = activityResult.ErrorMessage
+	result.Results = activityResult.Results
+	result.Failure = activityResult.Failure
+	result.Outcome = activityResult.Outcome
+	result.Attempt = activityResult.Attempt
+	result.Fault = activityResult.Fault
+}
+
+// OrchestratorWorkflow orchestrates multiple SinglePaymentCollectionWorkflows concurrently.
+// Orders are staged in the batch store and run a page at a time, and each page's results are
+// written back to the store, so history only holds a cursor and running totals. After
+// ChildrenPerRun children, or when Temporal suggests it, the workflow continues as new.
+//
+// Operators can follow the batch with ProgressQuery and EventsQuery, hold it with PauseSignal
+// and ResumeSignal, and change MaxConcurrent with SetConcurrencyUpdate. Cancelling the workflow
+// stops it from starting children, waits for the running ones and records their results before
+// it ends.
+// A batch that completes gets CSV, JSON and HTML reports from GenerateBatchReport.
 func OrchestratorWorkflow(ctx workflow.Context, params OrchestratorWorkflowParams) (*BatchResult, error) {
 	logger := workflow.GetLogger(ctx)
+	if workflow.GetVersion(ctx, pagedOrchestratorChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return legacyOrchestrator(ctx, params)
+	}
+
+	// Default concurrency
+	concurrency := params.MaxConcurrent
+	if concurrency <= 0 {
+		concurrency = 3 // Default concurrency
+	}
+	pageSize := params.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	childrenPerRun := params.ChildrenPerRun
+	if childrenPerRun <= 0 {
+		childrenPerRun = DefaultChildrenPerRun
+	}
+	storedBatch := params.BatchID != ""
+	if !storedBatch {
+		info := workflow.GetInfo(ctx)
+		params.BatchID = info.WorkflowExecution.ID + "_" + info.FirstRunID
+	}
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    30 * time.Second,
+		},
+	})
+
+	cursor := params.Cursor
+	if cursor == nil {
+		cursor = &BatchCursor{StartTime: workflow.Now(ctx)}
+	}
+	// The default batch ID comes from the workflow ID, which may hold anything; an ID the
+	// batch store rejects would fail every activity attempt.
+	if err := ValidateBatchID(params.BatchID); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), InvalidBatchError, err)
+	}
+	if err := validateThrottle(params); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), InvalidBatchError, err)
+	}
+	if params.Faults != nil {
+		if err := params.Faults.Validate(); err != nil {
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), InvalidBatchError, err)
+		}
+	}
+	control := &orchestratorControl{cursor: cursor, maxConcurrent: concurrency, throttle: newThrottle(params, &cursor.RateLimit, &cursor.Backpressure), faults: params.Faults}
+	if err := control.setUpHandlers(ctx, params.BatchID); err != nil {
+		return nil, err
+	}
+
+	if params.Cursor == nil {
+		logger.Info("Starting OrchestratorWorkflow", "batchID", params.BatchID, "orderCount", len(params.OrderIDs), "runDate", params.RunDate, "maxConcurrent", concurrency)
+		if len(params.OrderIDs) == 0 && params.Source == nil && !storedBatch {
+			logger.Info("No OrderIDs to process, completing workflow.")
+			return &BatchResult{BatchID: params.BatchID, StartTime: cursor.StartTime, EndTime: workflow.Now(ctx), Runs: 1}, nil
+		}
+		if params.Source != nil {
+			if len(params.OrderIDs) > 0 {
+				return nil, temporal.NewNonRetryableApplicationError("OrderIDs and Source are exclusive", InvalidOrderSourceError, nil)
+			}
+			discoverCtx := workflow.WithStartToCloseTimeout(ctx, DiscoverOrdersTimeout)
+			var discovered DiscoveredOrders
+			err := workflow.ExecuteActivity(discoverCtx, "DiscoverOrders", DiscoverOrdersRequest{
+				BatchID: params.BatchID,
+				Source:  *params.Source,
+				RunDate: params.RunDate,
+			}).Get(ctx, &discovered)
+			if err != nil {
+				return nil, fmt.Errorf("failed to discover orders of batch %s: %w", params.BatchID, err)
+			}
+			cursor.TotalCount = discovered.Total
+			logger.Info("Orders discovered", "batchID", params.BatchID, "source", params.Source.Name, "total", discovered.Total, "checkpointed", discovered.Checkpointed)
+		} else if len(params.OrderIDs) > 0 {
+			err := workflow.ExecuteActivity(ctx, "StageBatchOrders", StageOrdersRequest{
+				BatchID:  params.BatchID,
+				OrderIDs: params.OrderIDs,
+			}).Get(ctx, &cursor.TotalCount)
+			if err != nil {
+				return nil, fmt.Errorf("failed to stage orders of batch %s: %w", params.BatchID, err)
+			}
+		}
+	} else {
+		logger.Info("Continuing OrchestratorWorkflow", "batchID", params.BatchID, "offset", cursor.Offset, "totalCount", cursor.TotalCount, "runs", cursor.Runs)
+	}
+
+	batchResult := &BatchResult{BatchID: params.BatchID, StartTime: cursor.StartTime}
+	started := 0
+	for ctx.Err() == nil {
+		limit := min(pageSize, childrenPerRun-started)
+		var page OrderPage
+		err := workflow.ExecuteActivity(ctx, "LoadOrderPage", OrderPageRequest{
+			BatchID: params.BatchID,
+			Offset:  cursor.Offset,
+			Limit:   limit,
+		}).Get(ctx, &page)
+		if temporal.IsCanceledError(err) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load orders of batch %s: %w", params.BatchID, err)
+		}
+		cursor.TotalCount = page.Total
+		if len(page.OrderIDs) == 0 {
+			break
+		}
+
+		pageResult := runPaymentPage(ctx, page.OrderIDs, control, cursor.StartTime)
+		// The results of a cancelled page are still recorded.
+		recordCtx, _ := workflow.NewDisconnectedContext(ctx)
+		err = workflow.ExecuteActivity(recordCtx, "RecordBatchResults", RecordResultsRequest{
+			BatchID: params.BatchID,
+			Offset:  cursor.Offset,
+			Results: pageResult.Results,
+		}).Get(recordCtx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record results of batch %s: %w", params.BatchID, err)
+		}
+		control.endPage(len(pageResult.Results))
+		started += len(pageResult.Results)
+		// Small batches return their results; larger ones leave them in the batch store.
+		if cursor.Offset == cursor.TotalCount && cursor.Offset <= pageSize {
+			batchResult.OrderIDs = page.OrderIDs
+			batchResult.Results = pageResult.Results
+		}
+
+		if cursor.Offset >= cursor.TotalCount || ctx.Err() != nil {
+			break
+		}
+		if started >= childrenPerRun || workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			// Carry what operators changed into the next run.
+			if err := workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) }); err != nil {
+				break
+			}
+			control.drainSignals(ctx)
+			cursor.Runs++
+			logger.Info("Continuing as new", "batchID", params.BatchID, "offset", cursor.Offset, "totalCount", cursor.TotalCount, "paused", cursor.Paused)
+			params.OrderIDs = nil
+			params.MaxConcurrent = control.maxConcurrent
+			params.Cursor = cursor
+			return nil, workflow.NewContinueAsNewError(ctx, OrchestratorWorkflowType, params)
+		}
+	}
+
+	if ctx.Err() != nil {
+		logger.Info("Orchestrator workflow cancelled",
+			"batchID", params.BatchID,
+			"completed", cursor.Offset,
+			"totalCount", cursor.TotalCount,
+			"successCount", cursor.SuccessCount,
+			"failCount", cursor.FailCount,
+		)
+		return nil, ctx.Err()
+	}
+
+	batchResult.TotalCount = cursor.TotalCount
+	batchResult.SuccessCount = cursor.SuccessCount
+	batchResult.FailCount = cursor.FailCount
+	batchResult.DeduplicatedCount = cursor.DeduplicatedCount
+	batchResult.Runs = cursor.Runs + 1
+	batchResult.EndTime = workflow.Now(ctx)
+	reportCtx := workflow.WithRetryPolicy(ctx, temporal.RetryPolicy{
+		InitialInterval:    time.Second,
+		BackoffCoefficient: 2.0,
+		MaximumInterval:    30 * time.Second,
+		MaximumAttempts:    ReportMaxAttempts,
+	})
+	var reports GeneratedReports
+	if err := workflow.ExecuteActivity(reportCtx, "GenerateBatchReport", GenerateReportRequest{Result: *batchResult}).Get(ctx, &reports); err != nil {
+		logger.Warn("Failed to generate batch reports", "batchID", batchResult.BatchID, "error", err)
+	} else {
+		batchResult.Reports = reports.Reports
+	}
+	logger.Info("Orchestrator workflow completed",
+		"batchID", batchResult.BatchID,
+		"totalCount", batchResult.TotalCount,
+		"successCount", batchResult.SuccessCount,
+		"failCount", batchResult.FailCount,
+		"deduplicatedCount", batchResult.DeduplicatedCount,
+		"successRate", fmt.Sprintf("%d%%", batchResult.GetSuccessRate()),
+		"runs", batchResult.Runs,
+		"duration", batchResult.EndTime.Sub(batchResult.StartTime),
+	)
+	return batchResult, nil
+}
+
+// runPaymentPage runs a SinglePaymentCollectionWorkflow for every order of a page, at most
+// MaxConcurrent at a time and none while the batch is paused, and returns their results and
+// counts. Once ctx is cancelled no more children start, and the running ones are waited for;
+// Results then only holds the orders that were scheduled.
+func runPaymentPage(ctx workflow.Context, orderIDs []string, control *orchestratorControl, batchStart time.Time) *BatchResult {
+	logger := workflow.GetLogger(ctx)
+	batchResult := &BatchResult{
+		Results:   make([]PaymentResult, len(orderIDs)), // Pre-allocate results slice
+		StartTime: batchStart,
+	}
+	control.startPage(batchResult)
+	// Children are not cancelled with the batch, so they can be waited for.
+	childBase, _ := workflow.NewDisconnectedContext(ctx)
+
+	logger.Info("Starting concurrent child workflow execution", "orderCount", len(orderIDs), "concurrency", control.maxConcurrent)
+
+	for control.completed < control.scheduled || (control.scheduled < len(orderIDs) && ctx.Err() == nil) {
+		if control.scheduled < len(orderIDs) && control.canSchedule(ctx) {
+			if wait := control.throttle.delay(workflow.Now(ctx)); wait > 0 {
+				// Rate limited or slowed down: wait, unless the batch is paused or cancelled first.
+				_, _ = workflow.AwaitWithTimeout(ctx, wait, func() bool { return !control.canSchedule(ctx) })
+				continue
+			}
+			control.throttle.started(workflow.Now(ctx))
+			idx := control.scheduled
+			orderID := orderIDs[idx]
+			control.childStarted(ctx, orderID)
+
+			logger.Info("Scheduling child workflow", "index", idx, "orderID", orderID)
+
+			workflowID := PaymentWorkflowID(orderID)
+			childCtx := workflow.WithChildOptions(childBase, workflow.ChildWorkflowOptions{
+				WorkflowID:            workflowID,
+				WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+				TaskQueue:             SuperscriptTaskQueue,
+			})
+
+			exFuture := workflow.ExecuteChildWorkflow(
+				childCtx, SinglePaymentWorkflowType,
+				SinglePaymentWorkflowParams{OrderID: orderID, Faults: control.faults},
+			)
+			workflow.Go(childBase, func(ctx workflow.Context) {
+				recordChildResult(ctx, exFuture, orderID, idx, batchResult)
+				control.childDone(ctx, batchResult.Results[idx])
+			})
+			continue
+		}
+		if ctx.Err() != nil && !control.cancelling {
+			control.cancelling = true
+			logger.Info("Batch cancelled, waiting for in-flight children", "inFlight", control.running)
+		}
+		// Wait for a child to complete, or for a change that allows scheduling more.
+		completed := control.completed
+		waitCtx := ctx
+		if ctx.Err() != nil {
+			waitCtx = childBase
+		}
+		_ = workflow.Await(waitCtx, func() bool {
+			return control.completed != completed || (control.scheduled < len(orderIDs) && control.canSchedule(ctx))
+		})
+	}
+	batchResult.Results = batchResult.Results[:control.scheduled]
+	return batchResult
+}
+
+// recordChildResult waits for the child of the order at index idx and records its result,
+// counting it as exactly one success or failure. When the order already had a payment
+// workflow, e.g. from an earlier batch or a duplicate earlier in this one, no new payment is
+// made: the result of the existing workflow is waited for and recorded as PaymentDeduplicated.
+func recordChildResult(ctx workflow.Context, future workflow.ChildWorkflowFuture, orderID string, idx int, batchResult *BatchResult) {
+	logger := workflow.GetLogger(ctx)
+	workflowID := PaymentWorkflowID(orderID)
+	var result PaymentResult
+	err := future.Get(ctx, &result)
+	switch {
+	case temporal.IsWorkflowExecutionAlreadyStartedError(err):
+		logger.Info("Order already has a payment workflow, waiting for its result", "index", idx, "orderID", orderID, "workflowID", workflowID)
+		if err := workflow.ExecuteActivity(ctx, "AwaitPaymentResult", orderID).Get(ctx, &result); err != nil {
+			logger.Error("Failed to get the result of the existing payment workflow", "orderID", orderID, "workflowID", workflowID, "error", err)
+			result = failedChildResult(orderID, err, workflow.Now(ctx))
+		}
+		if result.WorkflowID != "" {
+			// The order was re-driven since its payment workflow failed.
+			workflowID = result.WorkflowID
+		}
+		result.Status = PaymentDeduplicated
+		batchResult.DeduplicatedCount++
+	case err != nil:
+		// The child failed, e.g. its script failed for good.
+		logger.Warn("Child workflow failed", "index", idx, "orderID", orderID, "workflowID", workflowID, "error", err)
+		result = failedChildResult(orderID, err, workflow.Now(ctx))
+		result.Status = PaymentExecuted
+	default:
+		logger.Info("Child workflow completed", "index", idx, "orderID", orderID, "success", result.Success)
+		result.Status = PaymentExecuted
+	}
+	result.OrderID = orderID
+	result.WorkflowID = workflowID
+	batchResult.Results[idx] = result
+	if result.Success {
+		batchResult.SuccessCount++
+	} else {
+		batchResult.FailCount++
+	}
+}
+
+// failedChildResult is the result of a payment child that failed with err: the error, and what
+// the script reported, from the result its activity attached to the error.
+func failedChildResult(orderID string, err error, now time.Time) PaymentResult {
+	result := PaymentResult{OrderID: orderID, Error: err.Error(), Timestamp: now}
+	for cause := err; cause != nil; cause = errors.Unwrap(cause) {
+		var appErr *temporal.ApplicationError
+		if !errors.As(cause, &appErr) {
+			break
+		}
+		var activityResult PaymentResult
+		if appErr.HasDetails() && appErr.Details(&activityResult) == nil {
+			copyScriptOutcome(&result, &activityResult)
+			result.Output = outputTail(activityResult.Output)
+			break
+		}
+		cause = appErr
+	}
+	return result
+}
+
+// legacyOrchestrator is the orchestrator of batches started before pagedOrchestratorChange: it
+// holds every order and result in history and runs the children from one semaphore-bounded loop.
+func legacyOrchestrator(ctx workflow.Context, params OrchestratorWorkflowParams) (*BatchResult, error) {
+	logger := workflow.GetLogger(ctx)
 
 	// Default concurrency
 	concurrency := params.MaxConcurrent
@@ -119,7 +558,7 @@ func OrchestratorWorkflow(ctx workflow.Context, params OrchestratorWorkflowParam
 
 			logger.Info("Scheduling child workflow", "index", idx, "orderID", orderID)
 
-			workflowID := fmt.Sprintf("%s-%s", SinglePaymentWorkflowType, orderID)
+			workflowID := PaymentWorkflowID(orderID)
 			childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
 				WorkflowID:            workflowID,
 				WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
@@ -135,7 +574,7 @@ func OrchestratorWorkflow(ctx workflow.Context, params OrchestratorWorkflowParam
 			selector.AddFuture(exFuture, func(f workflow.Future) {
 				completedIdx := futuresMap[f]
 				completedOrderID := params.OrderIDs[completedIdx]
-				completedWorkflowID := fmt.Sprintf("%s-%s", SinglePaymentWorkflowType, completedOrderID)
+				completedWorkflowID := PaymentWorkflowID(completedOrderID)
 				var result PaymentResult
 
 				err := f.Get(ctx, &result)
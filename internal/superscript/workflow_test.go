@@ -0,0 +1,408 @@
+package superscript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gocmp "github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// newOrchestratorEnv returns a test environment whose payment script succeeds for every
+// order except those starting with "fail", which are declined, and "broken", whose script fails for good. Batches
+// are stored in store, and dead letters in deadLetterStore(store). Injected faults fail the
+// attempt they are picked for.
+func newOrchestratorEnv(ts *testsuite.WorkflowTestSuite, store *BatchStore) *testsuite.TestWorkflowEnvironment {
+	env := ts.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(OrchestratorWorkflow)
+	env.RegisterWorkflow(SinglePaymentCollectionWorkflow)
+	env.RegisterWorkflow(RedriveWorkflow)
+	env.RegisterActivityWithOptions(func(ctx context.Context, orderID string, faults *FaultSpec) (*PaymentResult, error) {
+		attempt := activity.GetInfo(ctx).Attempt
+		if fault, ok := faults.pick(PaymentScriptName, []string{orderID}, attempt); ok {
+			result := PaymentResult{OrderID: orderID, ExitCode: fault.ExitCode, Outcome: OutcomeRetryable, Attempt: attempt, Fault: fault.Kind}
+			return nil, temporal.NewApplicationErrorWithOptions("injected "+fault.Kind+" fault", ScriptErrorType, temporal.ApplicationErrorOptions{
+				Details: []interface{}{result},
+			})
+		}
+		switch {
+		case strings.HasPrefix(orderID, "fail"):
+			return &PaymentResult{OrderID: orderID, Output: "card declined", ErrorMessage: "declined", ExitCode: 3, Outcome: OutcomeBusinessFailure}, nil
+		case orderID == "broken":
+			result := PaymentResult{OrderID: orderID, Output: "connecting to gateway\ngateway unreachable", ExitCode: 2, Outcome: OutcomeNonRetryable}
+			return nil, temporal.NewApplicationErrorWithOptions("Script execution failed with exit code 2", ScriptErrorType, temporal.ApplicationErrorOptions{
+				NonRetryable: true,
+				Details:      []interface{}{result},
+			})
+		}
+		return &PaymentResult{OrderID: orderID, Success: true, Outcome: OutcomeSuccess}, nil
+	}, activity.RegisterOptions{Name: "RunPaymentCollectionScript"})
+	a := &Activities{Batches: store, DeadLetters: deadLetterStore(store), Reports: reportStore(store), Sources: sourceCatalogue(store)}
+	env.RegisterActivity(a.StageBatchOrders)
+	env.RegisterActivity(a.LoadOrderPage)
+	env.RegisterActivity(a.RecordBatchResults)
+	env.RegisterActivity(a.DiscoverOrders)
+	env.RegisterActivity(a.SelectRedrives)
+	env.RegisterActivity(a.RecordRedrive)
+	env.RegisterActivity(a.GenerateBatchReport)
+	return env
+}
+
+// sourceCatalogue has the source "orders", the CSV file orders.csv next to the batches.
+func sourceCatalogue(store *BatchStore) *SourceCatalogue {
+	return &SourceCatalogue{Sources: []OrderSource{{Name: "orders", Type: SourceFile, Path: filepath.Join(store.Dir, "orders.csv")}}}
+}
+
+func deadLetterStore(store *BatchStore) *DeadLetterStore {
+	return NewDeadLetterStore(filepath.Join(store.Dir, "dead-letters"))
+}
+
+func reportStore(store *BatchStore) *DirReportStore {
+	return NewDirReportStore(filepath.Join(store.Dir, "reports"))
+}
+
+func TestOrchestratorWorkflow_SmallBatch(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	store := NewBatchStore(t.TempDir())
+	env := newOrchestratorEnv(&ts, store)
+
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{BatchID: "small", OrderIDs: []string{"1", "fail", "3"}})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalCount != 3 || result.SuccessCount != 2 || result.FailCount != 1 || len(result.Results) != 3 || result.Runs != 1 {
+		t.Errorf("result = %+v", result)
+	}
+	stored, err := store.LoadResults("small")
+	if err != nil || len(stored) != 3 || stored[1].OrderID != "fail" {
+		t.Errorf("stored results = %+v, %v", stored, err)
+	}
+}
+
+func TestOrchestratorWorkflow_InvalidBatchID(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	tests := map[string]struct {
+		workflowID string
+		batchID    string
+	}{
+		"given":              {batchID: "../daily"},
+		"from a workflow ID": {workflowID: "daily/2025-06-01"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := NewBatchStore(t.TempDir())
+			env := newOrchestratorEnv(&ts, store)
+			if tt.workflowID != "" {
+				env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: tt.workflowID})
+			}
+			env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{BatchID: tt.batchID, OrderIDs: []string{"1"}})
+			var appErr *temporal.ApplicationError
+			if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != InvalidBatchError || !appErr.NonRetryable() {
+				t.Errorf("OrchestratorWorkflow() error = %v, want a non-retryable %s", err, InvalidBatchError)
+			}
+		})
+	}
+}
+
+func TestOrchestratorWorkflow_ReplaysBatchesStartedBeforePaging(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := newOrchestratorEnv(&ts, NewBatchStore(t.TempDir()))
+	env.OnGetVersion("paged-orchestrator", workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+
+	// The batch predates the batch store, so it runs its OrderIDs without staging them.
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{OrderIDs: []string{"1", "fail", "3"}})
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	if result.TotalCount != 3 || result.SuccessCount != 2 || result.FailCount != 1 || len(result.Results) != 3 {
+		t.Errorf("result = %+v", result)
+	}
+	env.AssertActivityNotCalled(t, "StageBatchOrders", mock.Anything, mock.Anything)
+}
+
+func TestOrchestratorWorkflow_DeadLettersFailedOrders(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	store := NewBatchStore(t.TempDir())
+	env := newOrchestratorEnv(&ts, store)
+
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{BatchID: "mixed", OrderIDs: []string{"1", "fail", "broken"}})
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	if result.SuccessCount != 1 || result.FailCount != 2 || result.Results[2].OrderID != "broken" {
+		t.Errorf("result = %+v", result)
+	}
+	letters, err := deadLetterStore(store).List(DeadLetterFailed)
+	if err != nil || len(letters) != 2 {
+		t.Fatalf("dead letters = %+v, %v", letters, err)
+	}
+	for _, letter := range letters {
+		if letter.BatchID != "mixed" || letter.Error == "" || letter.Output == "" || letter.ExitCode == 0 || letter.FailedAt.IsZero() {
+			t.Errorf("dead letter = %+v", letter)
+		}
+	}
+}
+
+func TestOrchestratorWorkflow_DuplicateOrders(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	store := NewBatchStore(t.TempDir())
+	env := newOrchestratorEnv(&ts, store)
+	slowChildren(env)
+	// The payment of the first "1" is still running the first time its duplicate looks.
+	lookups := 0
+	env.RegisterActivityWithOptions(func(ctx context.Context, orderID string) (*PaymentResult, error) {
+		if lookups++; lookups == 1 {
+			return nil, temporal.NewApplicationError("still running", PaymentRunningError)
+		}
+		return &PaymentResult{OrderID: orderID, Success: true, Output: "collected by the first run"}, nil
+	}, activity.RegisterOptions{Name: "AwaitPaymentResult"})
+
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{BatchID: "dups", OrderIDs: []string{"1", "2", "1"}, MaxConcurrent: 3})
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	if result.SuccessCount != 3 || result.FailCount != 0 || result.DeduplicatedCount != 1 || lookups != 2 {
+		t.Errorf("result = %+v after %d lookups", result, lookups)
+	}
+	want := []string{PaymentExecuted, PaymentExecuted, PaymentDeduplicated}
+	for i, r := range result.Results {
+		if r.Status != want[i] || r.WorkflowID != "SinglePaymentCollectionWorkflow-"+r.OrderID {
+			t.Errorf("result %d = %+v, want status %s", i, r, want[i])
+		}
+	}
+	if result.Results[2].Output != "collected by the first run" {
+		t.Errorf("deduplicated result = %+v, want the existing workflow's", result.Results[2])
+	}
+}
+
+func TestOrchestratorWorkflow_Source(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	store := NewBatchStore(t.TempDir())
+	env := newOrchestratorEnv(&ts, store)
+	if err := os.MkdirAll(store.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(store.Dir, "orders.csv"), []byte("order_id,due_date\n1,2025-06-01\n2,2025-06-02\nfail,2025-06-01\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{
+		BatchID: "daily",
+		RunDate: testRunDate,
+		Source:  &OrderSource{Name: "orders"},
+	})
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.OrderIDs, []string{"1", "fail"}) || result.SuccessCount != 1 || result.FailCount != 1 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestOrchestratorWorkflow_ContinuesAsNew(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	store := NewBatchStore(t.TempDir())
+	var orderIDs []string
+	for i := 0; i < 7; i++ {
+		orderIDs = append(orderIDs, fmt.Sprint(i))
+	}
+	orderIDs[5] = "fail"
+
+	params := OrchestratorWorkflowParams{BatchID: "big", OrderIDs: orderIDs, PageSize: 2, ChildrenPerRun: 3}
+	var result BatchResult
+	for run := 1; ; run++ {
+		if run > 5 {
+			t.Fatal("batch did not complete")
+		}
+		env := newOrchestratorEnv(&ts, store)
+		env.ExecuteWorkflow(OrchestratorWorkflow, params)
+		err := env.GetWorkflowError()
+		var canErr *workflow.ContinueAsNewError
+		if !errors.As(err, &canErr) {
+			if err != nil {
+				t.Fatalf("run %d: OrchestratorWorkflow() error = %v", run, err)
+			}
+			if err := env.GetWorkflowResult(&result); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		params = OrchestratorWorkflowParams{}
+		if err := converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &params); err != nil {
+			t.Fatal(err)
+		}
+		// Only the cursor is carried over, never the orders.
+		if params.OrderIDs != nil || params.Cursor == nil || params.Cursor.Offset != 3*run {
+			t.Fatalf("run %d continued with %+v", run, params)
+		}
+	}
+
+	if result.TotalCount != 7 || result.SuccessCount != 6 || result.FailCount != 1 || result.Runs != 3 || result.Results != nil {
+		t.Errorf("result = %+v", result)
+	}
+	stored, err := store.LoadResults("big")
+	if err != nil || len(stored) != 7 {
+		t.Fatalf("stored results = %+v, %v", stored, err)
+	}
+	for i, r := range stored {
+		if r.OrderID != orderIDs[i] {
+			t.Errorf("stored result %d is for order %s, want %s", i, r.OrderID, orderIDs[i])
+		}
+	}
+}
+
+// slowChildren makes every payment child take a minute.
+func slowChildren(env *testsuite.TestWorkflowEnvironment) {
+	env.OnWorkflow(SinglePaymentWorkflowType, mock.Anything, mock.Anything).Return(
+		func(ctx workflow.Context, params SinglePaymentWorkflowParams) (*PaymentResult, error) {
+			if err := workflow.Sleep(ctx, time.Minute); err != nil {
+				return nil, err
+			}
+			return &PaymentResult{OrderID: params.OrderID, Success: true}, nil
+		})
+}
+
+func queryProgress(t *testing.T, env *testsuite.TestWorkflowEnvironment) OrchestratorProgress {
+	t.Helper()
+	value, err := env.QueryWorkflow(ProgressQuery)
+	if err != nil {
+		t.Fatalf("QueryWorkflow() error = %v", err)
+	}
+	var progress OrchestratorProgress
+	if err := value.Get(&progress); err != nil {
+		t.Fatal(err)
+	}
+	return progress
+}
+
+func TestOrchestratorWorkflow_PauseResumeAndConcurrency(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := newOrchestratorEnv(&ts, NewBatchStore(t.TempDir()))
+	slowChildren(env)
+
+	var snapshots []OrchestratorProgress
+	env.RegisterDelayedCallback(func() {
+		snapshots = append(snapshots, queryProgress(t, env))
+		env.SignalWorkflow(PauseSignal, nil)
+	}, 30*time.Second)
+	env.RegisterDelayedCallback(func() {
+		snapshots = append(snapshots, queryProgress(t, env))
+		env.UpdateWorkflow(SetConcurrencyUpdate, "to-3", &testsuite.TestUpdateCallback{
+			OnReject: func(err error) { t.Errorf("update rejected: %v", err) },
+			OnAccept: func() {},
+			OnComplete: func(previous interface{}, err error) {
+				if err != nil || previous != 2 {
+					t.Errorf("set-concurrency returned %v, %v, want 2", previous, err)
+				}
+			},
+		}, 3)
+		env.UpdateWorkflow(SetConcurrencyUpdate, "invalid", &testsuite.TestUpdateCallback{
+			OnReject:   func(err error) {},
+			OnAccept:   func() { t.Error("set-concurrency 0 was accepted") },
+			OnComplete: func(interface{}, error) {},
+		}, 0)
+		env.SignalWorkflow(ResumeSignal, nil)
+	}, 90*time.Second)
+	env.RegisterDelayedCallback(func() {
+		snapshots = append(snapshots, queryProgress(t, env))
+	}, 100*time.Second)
+
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{
+		BatchID:       "controlled",
+		OrderIDs:      []string{"1", "2", "3", "4", "5", "6"},
+		MaxConcurrent: 2,
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	want := []OrchestratorProgress{
+		{BatchID: "controlled", TotalCount: 6, Scheduled: 2, InFlight: []string{"1", "2"}, MaxConcurrent: 2},
+		// Paused: the first children completed, and no more were started.
+		{BatchID: "controlled", TotalCount: 6, Scheduled: 2, Completed: 2, SuccessCount: 2, InFlight: []string{}, MaxConcurrent: 2, Paused: true},
+		{BatchID: "controlled", TotalCount: 6, Scheduled: 5, Completed: 2, SuccessCount: 2, InFlight: []string{"3", "4", "5"}, MaxConcurrent: 3},
+	}
+	if diff := gocmp.Diff(want, snapshots); diff != "" {
+		t.Errorf("progress (-want +got):\n%s", diff)
+	}
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil || result.SuccessCount != 6 {
+		t.Errorf("result = %+v, %v", result, err)
+	}
+}
+
+func TestOrchestratorWorkflow_CancelWaitsForInFlight(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	store := NewBatchStore(t.TempDir())
+	env := newOrchestratorEnv(&ts, store)
+	slowChildren(env)
+
+	env.RegisterDelayedCallback(env.CancelWorkflow, 30*time.Second)
+	env.RegisterDelayedCallback(func() {
+		if progress := queryProgress(t, env); !progress.Cancelling || len(progress.InFlight) != 2 {
+			t.Errorf("progress after cancel = %+v", progress)
+		}
+	}, 31*time.Second)
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{
+		BatchID:       "cancelled",
+		OrderIDs:      []string{"1", "2", "3", "4"},
+		MaxConcurrent: 2,
+	})
+	if err := env.GetWorkflowError(); !temporal.IsCanceledError(err) {
+		t.Fatalf("OrchestratorWorkflow() error = %v, want cancelled", err)
+	}
+	// The in-flight children finished and were recorded; no others were started.
+	stored, err := store.LoadResults("cancelled")
+	if err != nil || len(stored) != 2 || !stored[0].Success || !stored[1].Success {
+		t.Errorf("stored results = %+v, %v", stored, err)
+	}
+}
+
+func TestOrchestratorWorkflow_Events(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := newOrchestratorEnv(&ts, NewBatchStore(t.TempDir()))
+
+	env.ExecuteWorkflow(OrchestratorWorkflow, OrchestratorWorkflowParams{BatchID: "watched", OrderIDs: []string{"1", "broken"}, MaxConcurrent: 1})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("OrchestratorWorkflow() error = %v", err)
+	}
+	value, err := env.QueryWorkflow(EventsQuery, int64(1))
+	if err != nil {
+		t.Fatalf("QueryWorkflow() error = %v", err)
+	}
+	var events []ProgressEvent
+	if err := value.Get(&events); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, event := range events {
+		got = append(got, fmt.Sprintf("%d %s %s", event.Seq, event.Type, event.OrderID))
+	}
+	want := []string{"2 completed 1", "3 started broken", "4 failed broken"}
+	if diff := gocmp.Diff(want, got); diff != "" {
+		t.Fatalf("events (-want +got):\n%s", diff)
+	}
+	if failed := events[2]; failed.ExitCode != 2 || failed.Output != "connecting to gateway\ngateway unreachable" || failed.Error == "" {
+		t.Errorf("failed event = %+v", failed)
+	}
+}
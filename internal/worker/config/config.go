@@ -28,10 +28,42 @@ type WorkerConfig struct {
 	HTTPHost string
 
 	// Feature-specific settings
-	SuperscriptBasePath  string
-	JITTaskQueue         string
-	BatchProcessingQueue string
-	KilcronTaskQueue     string
+	SuperscriptBasePath      string
+	SuperscriptCatalogue     string
+	SuperscriptSources       string
+	SuperscriptBatchDir      string
+	SuperscriptDeadLetterDir string
+	JITTaskQueue             string
+	JITPolicyFile            string
+	JITAuditLog              string
+	JITNotifyConfig          string
+	JITAuthJWKSFile          string
+	JITAuthIssuer            string
+	JITAuthAudience          string
+	JITAuthUsernameClaim     string
+	JITAPITokensFile         string
+	JITAuthDisabled          bool
+	// Access reconciliation against Atlas; an interval of 0 disables the schedule
+	JITBaselineFile       string
+	JITReconcileInterval  time.Duration
+	JITReconcileRemediate bool
+	BatchProcessingQueue  string
+	KilcronTaskQueue      string
+
+	// Superscript batch reports go to the S3-compatible bucket at SuperscriptReportS3URL when
+	// it is set, and to SuperscriptReportDir otherwise
+	SuperscriptReportDir      string
+	SuperscriptReportS3URL    string
+	SuperscriptReportS3Region string
+	AWSAccessKeyID            string
+	AWSSecretAccessKey        string
+
+	// Faults injected into superscript scripts for chaos testing, as a superscript.FaultSpec in
+	// JSON; empty disables them. Faults on requests are only accepted with
+	// SuperscriptFaultsEnabled, and kill_worker faults only with SuperscriptFaultsKillWorker
+	SuperscriptFaults           string
+	SuperscriptFaultsEnabled    bool
+	SuperscriptFaultsKillWorker bool
 
 	// Atlas/MongoDB settings (for JIT feature)
 	AtlasPublicKey  string
@@ -63,10 +95,38 @@ func LoadConfig() *WorkerConfig {
 		HTTPHost: getEnv("HTTP_HOST", "localhost"),
 
 		// Feature-specific defaults
-		SuperscriptBasePath:  getEnv("SUPERSCRIPT_BASE_PATH", "./internal/features/superscript/scripts/"),
-		JITTaskQueue:         getEnv("JIT_TASK_QUEUE", "jit_access_task_queue"),
-		BatchProcessingQueue: getEnv("BATCH_PROCESSING_QUEUE", "batch_processing_task_queue"),
-		KilcronTaskQueue:     getEnv("KILCRON_TASK_QUEUE", "kilcron_task_queue"),
+		SuperscriptBasePath:      getEnv("SUPERSCRIPT_BASE_PATH", "./internal/superscript/"),
+		SuperscriptCatalogue:     getEnv("SUPERSCRIPT_CATALOGUE", ""),
+		SuperscriptSources:       getEnv("SUPERSCRIPT_SOURCES", ""),
+		SuperscriptBatchDir:      getEnv("SUPERSCRIPT_BATCH_DIR", "./superscript-batches"),
+		SuperscriptDeadLetterDir: getEnv("SUPERSCRIPT_DLQ_DIR", "./superscript-dlq"),
+		JITTaskQueue:             getEnv("JIT_TASK_QUEUE", "jit_access_task_queue"),
+		JITPolicyFile:            getEnv("JIT_POLICY_FILE", ""),
+		JITAuditLog:              getEnv("JIT_AUDIT_LOG", "./jit-audit.log"),
+		JITNotifyConfig:          getEnv("JIT_NOTIFY_CONFIG", ""),
+		JITAuthJWKSFile:          getEnv("JIT_AUTH_JWKS_FILE", ""),
+		JITAuthIssuer:            getEnv("JIT_AUTH_ISSUER", ""),
+		JITAuthAudience:          getEnv("JIT_AUTH_AUDIENCE", ""),
+		JITAuthUsernameClaim:     getEnv("JIT_AUTH_USERNAME_CLAIM", ""),
+		JITAPITokensFile:         getEnv("JIT_API_TOKENS_FILE", ""),
+		JITAuthDisabled:          getEnvBool("JIT_AUTH_DISABLED", false),
+		JITBaselineFile:          getEnv("JIT_BASELINE_FILE", ""),
+		JITReconcileInterval:     getEnvDuration("JIT_RECONCILE_INTERVAL", 0),
+		JITReconcileRemediate:    getEnvBool("JIT_RECONCILE_REMEDIATE", false),
+		BatchProcessingQueue:     getEnv("BATCH_PROCESSING_QUEUE", "batch_processing_task_queue"),
+		KilcronTaskQueue:         getEnv("KILCRON_TASK_QUEUE", "kilcron_task_queue"),
+
+		// Superscript batch reports
+		SuperscriptReportDir:      getEnv("SUPERSCRIPT_REPORT_DIR", "./superscript-reports"),
+		SuperscriptReportS3URL:    getEnv("SUPERSCRIPT_REPORT_S3_URL", ""),
+		SuperscriptReportS3Region: getEnv("SUPERSCRIPT_REPORT_S3_REGION", ""),
+		AWSAccessKeyID:            getEnv("AWS_ACCESS_KEY_ID", ""),
+		AWSSecretAccessKey:        getEnv("AWS_SECRET_ACCESS_KEY", ""),
+
+		// Superscript fault injection
+		SuperscriptFaults:           getEnv("SUPERSCRIPT_FAULTS", ""),
+		SuperscriptFaultsEnabled:    getEnvBool("SUPERSCRIPT_FAULTS_ENABLED", false),
+		SuperscriptFaultsKillWorker: getEnvBool("SUPERSCRIPT_FAULTS_KILL_WORKER", false),
 
 		// Atlas/MongoDB settings (for JIT feature)
 		AtlasPublicKey:  getEnv("ATLAS_PUBLIC_KEY", ""),
@@ -104,6 +164,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvSlice(key string, defaultValue []string) []string {
 	if value := os.Getenv(key); value != "" {
 		return strings.Split(value, ",")